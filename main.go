@@ -63,6 +63,12 @@ func Atoi(gs string) int {
 	}
 	var b uint8
 	var n int
+	if isHexLiteral(gs) {
+		for _, b = range []uint8(gs[2:len(gs)]) {
+			n = n*16 + hexDigit(b)
+		}
+		return n
+	}
 
 	var isMinus bool
 	for _, b = range []uint8(gs) {
@@ -96,18 +102,16 @@ func Itoa(ival int) string {
 	var right int
 	var ix = 0
 	var minus bool
-	minus = false
+	minus = ival < 0
+	// digits are taken without negating ival, which overflows for the minimum int
 	for ix = 0; ival != 0; ix = ix + 1 {
-		if ival < 0 {
-			ival = -1 * ival
-			minus = true
-			r[0] = '-'
-		} else {
-			next = ival / 10
-			right = ival - next*10
-			ival = next
-			buf[ix] = uint8('0' + right)
+		next = ival / 10
+		right = ival - next*10
+		if right < 0 {
+			right = -right
 		}
+		ival = next
+		buf[ix] = uint8('0' + right)
 	}
 
 	var j int
@@ -121,6 +125,10 @@ func Itoa(ival int) string {
 		}
 	}
 
+	if minus {
+		r[0] = '-'
+		return string(r[0 : ix+1])
+	}
 	return string(r[0:ix])
 }
 
@@ -142,7 +150,17 @@ func logf(format string, a ...string) {
 	}
 	var f = "# " + format
 	var s = fmtSprintf(f, a)
-	syscall.Write(1, []uint8(s))
+	// Literals can contain newlines. Keep every line a comment of the assembly.
+	var buf []uint8
+	var i int
+	for i = 0; i < len(s); i++ {
+		buf = append(buf, s[i])
+		if s[i] == '\n' && i < len(s)-1 {
+			buf = append(buf, '#')
+			buf = append(buf, ' ')
+		}
+	}
+	syscall.Write(1, buf)
 }
 
 // --- scanner ---
@@ -189,13 +207,32 @@ func isLetter(ch uint8) bool {
 	if ch == '_' {
 		return true
 	}
-	return ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z')
+	// Bytes of non-ASCII characters are regarded as letters
+	return ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ch >= 0x80
 }
 
 func isDecimal(ch uint8) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHex(ch uint8) bool {
+	return isDecimal(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
+func hexDigit(ch uint8) int {
+	if isDecimal(ch) {
+		return int(ch - '0')
+	}
+	if 'a' <= ch && ch <= 'f' {
+		return int(ch-'a') + 10
+	}
+	return int(ch-'A') + 10
+}
+
+func isHexLiteral(lit string) bool {
+	return len(lit) > 2 && lit[0] == '0' && (lit[1] == 'x' || lit[1] == 'X')
+}
+
 func (s *scanner) scanIdentifier() string {
 	var offset = s.offset
 	for isLetter(s.ch) || isDecimal(s.ch) {
@@ -204,12 +241,50 @@ func (s *scanner) scanIdentifier() string {
 	return string(s.src[offset:s.offset])
 }
 
-func (s *scanner) scanNumber() string {
+// Scan an integer or float literal. It returns the token and the literal.
+func (s *scanner) scanNumber() (string, string) {
 	var offset = s.offset
+	var tok = "INT"
+	if s.ch == '0' && (s.src[s.nextOffset] == 'x' || s.src[s.nextOffset] == 'X') {
+		s.next()
+		s.next()
+		for isHex(s.ch) || s.ch == '.' {
+			if s.ch == '.' {
+				tok = "FLOAT"
+			}
+			s.next()
+		}
+		if s.ch == 'p' || s.ch == 'P' {
+			tok = "FLOAT"
+			s.scanExponent()
+		}
+		return tok, string(s.src[offset:s.offset])
+	}
+	for isDecimal(s.ch) {
+		s.next()
+	}
+	if s.ch == '.' {
+		tok = "FLOAT"
+		s.next()
+		for isDecimal(s.ch) {
+			s.next()
+		}
+	}
+	if s.ch == 'e' || s.ch == 'E' {
+		tok = "FLOAT"
+		s.scanExponent()
+	}
+	return tok, string(s.src[offset:s.offset])
+}
+
+func (s *scanner) scanExponent() {
+	s.next() // consume 'e' or 'p'
+	if s.ch == '+' || s.ch == '-' {
+		s.next()
+	}
 	for isDecimal(s.ch) {
 		s.next()
 	}
-	return string(s.src[offset:s.offset])
 }
 
 func (s *scanner) scanString() string {
@@ -247,14 +322,58 @@ func (s *scanner) scanChar() string {
 	return string(s.src[offset:s.offset])
 }
 
+// Scan a raw string literal. The opening '`' is already consumed.
+func (s *scanner) scanRawString() string {
+	var offset = s.offset - 1
+	for s.ch != '`' {
+		if s.offset >= len(s.src) {
+			panic2(__func__, "raw string literal not terminated")
+		}
+		s.next()
+	}
+	s.next() // consume ending '`'
+	return string(s.src[offset:s.offset])
+}
+
+// Scan a line comment or a block comment. The opening '/' is already consumed.
 func (s *scanner) scanComment() string {
 	var offset = s.offset - 1
-	for s.ch != '\n' {
+	if s.ch == '/' {
+		for s.ch != '\n' && s.offset < len(s.src) {
+			s.next()
+		}
+		return string(s.src[offset:s.offset])
+	}
+	s.next() // consume '*'
+	for !(s.ch == '*' && s.nextOffset < len(s.src) && s.src[s.nextOffset] == '/') {
+		if s.offset >= len(s.src) {
+			panic2(__func__, "comment not terminated")
+		}
 		s.next()
 	}
+	s.next()
+	s.next() // consume "*/"
 	return string(s.src[offset:s.offset])
 }
 
+// Whether the comment starting at s.offset-1 acts like a newline.
+// A line comment does, and so does a block comment which contains a newline.
+func (s *scanner) isCommentLineEnd() bool {
+	if s.ch == '/' {
+		return true
+	}
+	var i int
+	for i = s.nextOffset; i < len(s.src)-1; i++ {
+		if s.src[i] == '\n' {
+			return true
+		}
+		if s.src[i] == '*' && s.src[i+1] == '/' {
+			return false
+		}
+	}
+	return true // not terminated
+}
+
 type TokenContainer struct {
 	pos int    // what's this ?
 	tok string // token.Token
@@ -287,10 +406,9 @@ func (s *scanner) Scan() *TokenContainer {
 			insertSemi = true
 			tok = "IDENT"
 		}
-	} else if isDecimal(ch) {
+	} else if isDecimal(ch) || (ch == '.' && isDecimal(s.src[s.nextOffset])) {
 		insertSemi = true
-		lit = s.scanNumber()
-		tok = "INT"
+		tok, lit = s.scanNumber()
 	} else {
 		s.next()
 		switch ch {
@@ -306,6 +424,10 @@ func (s *scanner) Scan() *TokenContainer {
 			insertSemi = true
 			lit = s.scanChar()
 			tok = "CHAR"
+		case '`': // back quote
+			insertSemi = true
+			lit = s.scanRawString()
+			tok = "STRING"
 		// https://golang.org/ref/spec#Operators_and_punctuation
 		//	+    &     +=    &=     &&    ==    !=    (    )
 		//	-    |     -=    |=     ||    <     <=    [    ]
@@ -381,10 +503,9 @@ func (s *scanner) Scan() *TokenContainer {
 				tok = "*"
 			}
 		case '/':
-			if s.ch == '/' {
+			if s.ch == '/' || s.ch == '*' {
 				// comment
-				// @TODO block comment
-				if s.insertSemi {
+				if s.insertSemi && s.isCommentLineEnd() {
 					s.ch = '/'
 					s.offset = s.offset - 1
 					s.nextOffset = s.offset + 1
@@ -393,9 +514,12 @@ func (s *scanner) Scan() *TokenContainer {
 					s.insertSemi = false
 					return tc
 				}
+				// A comment on a single line does not change insertSemi
+				insertSemi = s.insertSemi
 				lit = s.scanComment()
 				tok = "COMMENT"
 			} else if s.ch == '=' {
+				s.next()
 				tok = "/="
 			} else {
 				tok = "/"
@@ -541,28 +665,35 @@ type astObject struct {
 }
 
 type astExpr struct {
-	dtype        string
-	ident        *astIdent
-	arrayType    *astArrayType
-	basicLit     *astBasicLit
-	callExpr     *astCallExpr
-	binaryExpr   *astBinaryExpr
-	unaryExpr    *astUnaryExpr
-	selectorExpr *astSelectorExpr
-	indexExpr    *astIndexExpr
-	sliceExpr    *astSliceExpr
-	starExpr     *astStarExpr
-	parenExpr    *astParenExpr
-	structType   *astStructType
-	compositeLit *astCompositeLit
-	keyValueExpr *astKeyValueExpr
-	ellipsis     *astEllipsis
+	dtype          string
+	ident          *astIdent
+	arrayType      *astArrayType
+	basicLit       *astBasicLit
+	callExpr       *astCallExpr
+	binaryExpr     *astBinaryExpr
+	unaryExpr      *astUnaryExpr
+	selectorExpr   *astSelectorExpr
+	indexExpr      *astIndexExpr
+	sliceExpr      *astSliceExpr
+	starExpr       *astStarExpr
+	parenExpr      *astParenExpr
+	structType     *astStructType
+	compositeLit   *astCompositeLit
+	keyValueExpr   *astKeyValueExpr
+	ellipsis       *astEllipsis
+	mapType        *astMapType
+	chanType       *astChanType
+	interfaceType  *astInterfaceType
+	funcType       *astFuncType
+	typeAssertExpr *astTypeAssertExpr
+	funcLit        *astFuncLit
 }
 
 type astField struct {
-	Name   *astIdent
-	Type   *astExpr
-	Offset int
+	Name     *astIdent
+	Type     *astExpr
+	Offset   int
+	Embedded bool // an embedded field is named after its type
 }
 
 type astFieldList struct {
@@ -600,6 +731,7 @@ type astParenExpr struct {
 type astSelectorExpr struct {
 	X   *astExpr
 	Sel *astIdent
+	tmp *astExpr // a temporary variable holding X if it is a non-addressable struct
 }
 
 type astIndexExpr struct {
@@ -616,8 +748,10 @@ type astSliceExpr struct {
 }
 
 type astCallExpr struct {
-	Fun  *astExpr   // function expression
-	Args []*astExpr // function arguments; or nil
+	Fun       *astExpr       // function expression
+	Args      []*astExpr     // function arguments; or nil
+	Ellipsis  bool           // the last argument is followed by "..."
+	tupleArgs *astAssignStmt // assigns the results of g to the args of f(g())
 }
 
 type astStarExpr struct {
@@ -635,6 +769,11 @@ type astBinaryExpr struct {
 	Op string
 }
 
+type astTypeAssertExpr struct {
+	X    *astExpr
+	Type *astExpr
+}
+
 // Type nodes
 type astArrayType struct {
 	Len *astExpr
@@ -645,11 +784,36 @@ type astStructType struct {
 	Fields *astFieldList
 }
 
+type astMapType struct {
+	Key   *astExpr
+	Value *astExpr
+}
+
+// Dir is a bit set of astSEND and astRECV
+type astChanType struct {
+	Dir   int
+	Value *astExpr
+}
+
+const astSEND int = 1
+const astRECV int = 2
+
+// Methods have a name and *astFuncType. Embedded interfaces have no name.
+type astInterfaceType struct {
+	Methods *astFieldList
+}
+
 type astFuncType struct {
 	Params  *astFieldList
 	Results *astFieldList
 }
 
+type astFuncLit struct {
+	Type *astFuncType
+	Body *astBlockStmt
+	fnc  *Func
+}
+
 type astStmt struct {
 	dtype      string
 	DeclStmt   *astDeclStmt
@@ -657,6 +821,8 @@ type astStmt struct {
 	blockStmt  *astBlockStmt
 	assignStmt *astAssignStmt
 	returnStmt *astReturnStmt
+	deferStmt  *astDeferStmt
+	goStmt     *astGoStmt
 	ifStmt     *astIfStmt
 	forStmt    *astForStmt
 	incDecStmt *astIncDecStmt
@@ -664,7 +830,12 @@ type astStmt struct {
 	rangeStmt  *astRangeStmt
 	branchStmt *astBranchStmt
 	switchStmt *astSwitchStmt
+	typeSwitchStmt *astTypeSwitchStmt
 	caseClause *astCaseClause
+	sendStmt   *astSendStmt
+	selectStmt *astSelectStmt
+	commClause *astCommClause
+	labeledStmt *astLabeledStmt
 }
 
 type astDeclStmt struct {
@@ -690,10 +861,24 @@ type astReturnStmt struct {
 	Results []*astExpr
 }
 
+type astDeferStmt struct {
+	Call *astCallExpr
+}
+
+type astGoStmt struct {
+	Call *astCallExpr
+}
+
 type astBranchStmt struct {
-	Tok        string
-	Label      string
-	currentFor *astStmt
+	Tok    string
+	Label  string
+	target *astStmt // loop, switch or select for break and continue, labeled statement for goto, case clause for fallthrough
+}
+
+type astLabeledStmt struct {
+	Label *astIdent
+	Stmt  *astStmt
+	label string // in the assembly
 }
 
 type astBlockStmt struct {
@@ -710,12 +895,38 @@ type astIfStmt struct {
 type astCaseClause struct {
 	List []*astExpr
 	Body []*astStmt
+	implicit *astValueSpec // v of "switch v := x.(type)" in this clause
+	label    string        // of the body
 }
 
 type astSwitchStmt struct {
-	Tag  *astExpr
-	Body *astBlockStmt
-	// lableExit string
+	Init      *astStmt
+	Tag       *astExpr // nil means true
+	Body      *astBlockStmt
+	labelExit string
+}
+
+type astTypeSwitchStmt struct {
+	Init      *astStmt
+	Assign    *astStmt // x := y.(type) or y.(type)
+	Body      *astBlockStmt
+	subject   *Variable // holds y
+	labelExit string
+}
+
+type astSendStmt struct {
+	Chan  *astExpr
+	Value *astExpr
+}
+
+type astCommClause struct {
+	Comm *astStmt // send or receive statement; nil means default case
+	Body []*astStmt
+}
+
+type astSelectStmt struct {
+	Body      *astBlockStmt
+	labelExit string
 }
 
 type astForStmt struct {
@@ -723,7 +934,6 @@ type astForStmt struct {
 	Cond      *astExpr
 	Post      *astStmt
 	Body      *astBlockStmt
-	Outer     *astStmt // outer loop
 	labelPost string
 	labelExit string
 }
@@ -731,9 +941,9 @@ type astForStmt struct {
 type astRangeStmt struct {
 	Key       *astExpr
 	Value     *astExpr
+	Tok       string // "=" or ":="
 	X         *astExpr
 	Body      *astBlockStmt
-	Outer     *astStmt // outer loop
 	labelPost string
 	labelExit string
 	lenvar    *Variable
@@ -755,6 +965,9 @@ type astValueSpec struct {
 	Name  *astIdent
 	Type  *astExpr
 	Value *astExpr
+	iota  int            // index of the spec in a const declaration
+	refs  *declRefs      // of the initializer of a package-level variable
+	tuple *astAssignStmt // of "var a, b = f()", which assigns all the names at once
 }
 
 type astTypeSpec struct {
@@ -770,7 +983,7 @@ type astDecl struct {
 }
 
 type astGenDecl struct {
-	Spec *astSpec
+	Specs []*astSpec
 }
 
 type astFuncDecl struct {
@@ -778,6 +991,7 @@ type astFuncDecl struct {
 	Name *astIdent
 	Type *astFuncType
 	Body *astBlockStmt
+	refs *declRefs
 }
 
 type astFile struct {
@@ -808,6 +1022,14 @@ func scopeInsert(s *astScope, obj *astObject) {
 	})
 }
 
+// Insert obj under another name
+func scopeInsertAlias(s *astScope, name string, obj *astObject) {
+	s.Objects = append(s.Objects, &objectEntry{
+		name: name,
+		obj:  obj,
+	})
+}
+
 func scopeLookup(s *astScope, name string) *astObject {
 	var oe *objectEntry
 	for _, oe = range s.Objects {
@@ -927,10 +1149,25 @@ func (p *parser) parseIdent() *astIdent {
 	}
 }
 
-func (p *parser) parseImportDecl() *astImportSpec {
+func (p *parser) parseImportDecl() []*astImportSpec {
 	p.expect("import", __func__)
-	var path = p.tok.lit
+	var specs []*astImportSpec
+	if p.tok.tok != "(" {
+		specs = append(specs, p.parseImportSpec())
+		return specs
+	}
 	p.next()
+	for p.tok.tok != ")" && p.tok.tok != "EOF" {
+		specs = append(specs, p.parseImportSpec())
+	}
+	p.expect(")", __func__)
+	p.expectSemi(__func__)
+	return specs
+}
+
+func (p *parser) parseImportSpec() *astImportSpec {
+	var path = p.tok.lit
+	p.expect("STRING", __func__)
 	p.expectSemi(__func__)
 
 	return &astImportSpec{
@@ -997,7 +1234,14 @@ func (p *parser) parsePointerType() *astExpr {
 func (p *parser) parseArrayType() *astExpr {
 	p.expect("[", __func__)
 	var ln *astExpr
-	if p.tok.tok != "]" {
+	if p.tok.tok == "..." {
+		// [...]T of a composite literal. The length is settled by its elements.
+		p.next()
+		ln = &astExpr{
+			dtype: "*astEllipsis",
+			ellipsis: &astEllipsis{},
+		}
+	} else if p.tok.tok != "]" {
 		ln = p.parseRhs()
 	}
 	p.expect("]", __func__)
@@ -1013,19 +1257,121 @@ func (p *parser) parseArrayType() *astExpr {
 	return r
 }
 
-func (p *parser) parseFieldDecl(scope *astScope) *astField {
+func (p *parser) parseMapType() *astExpr {
+	p.expect("map", __func__)
+	p.expect("[", __func__)
+	var key = p.parseType()
+	p.expect("]", __func__)
+	var value = p.parseType()
+	return &astExpr{
+		dtype : "*astMapType",
+		mapType : &astMapType{
+			Key : key,
+			Value : value,
+		},
+	}
+}
 
-	var varType = p.parseVarType(false)
-	var typ = p.tryVarType(false)
+func (p *parser) parseInterfaceType() *astExpr {
+	p.expect("interface", __func__)
+	p.expect("{", __func__)
 
-	p.expectSemi(__func__)
+	var list []*astField
+	for p.tok.tok == "IDENT" {
+		var ident = p.parseIdent()
+		var field = &astField{}
+		if p.tok.tok == "(" {
+			// method
+			var _nil *astScope
+			var scope = astNewScope(_nil)
+			var sig = p.parseSignature(scope)
+			field.Name = ident
+			field.Type = &astExpr{
+				dtype : "*astFuncType",
+				funcType : &astFuncType{
+					Params : sig.params,
+					Results : sig.results,
+				},
+			}
+		} else {
+			// embedded interface
+			var typ = &astExpr{
+				dtype : "*astIdent",
+				ident : ident,
+			}
+			p.resolve(typ)
+			field.Type = typ
+		}
+		list = append(list, field)
+		p.expectSemi(__func__)
+	}
+	p.expect("}", __func__)
+
+	return &astExpr{
+		dtype : "*astInterfaceType",
+		interfaceType : &astInterfaceType{
+			Methods : &astFieldList{
+				List : list,
+			},
+		},
+	}
+}
+
+func (p *parser) parseFuncType() *astExpr {
+	p.expect("func", __func__)
+	var scope = astNewScope(p.topScope) // function scope
+	var sig = p.parseSignature(scope)
+	return &astExpr{
+		dtype : "*astFuncType",
+		funcType : &astFuncType{
+			Params : sig.params,
+			Results : sig.results,
+		},
+	}
+}
 
-	var field = &astField{
-		Type : typ,
-		Name : varType.ident,
+func (p *parser) parseFieldDecl(scope *astScope) *astField {
+	var field = &astField{}
+	if p.tok.tok == "*" {
+		// embedded *T
+		p.next()
+		var base = p.parseTypeName()
+		p.resolve(base)
+		field.Type = &astExpr{
+			dtype: "*astStarExpr",
+			starExpr: &astStarExpr{
+				X: base,
+			},
+		}
+		field.Name = &astIdent{
+			Name: base.ident.Name,
+		}
+		field.Embedded = true
+	} else {
+		var ident = p.parseIdent()
+		if p.tok.tok == ";" || p.tok.tok == "}" || p.tok.tok == "STRING" {
+			// embedded T
+			field.Type = &astExpr{
+				dtype: "*astIdent",
+				ident: ident,
+			}
+			field.Name = &astIdent{
+				Name: ident.Name,
+			}
+			field.Embedded = true
+		} else {
+			field.Name = ident
+			field.Type = p.parseVarType(false)
+		}
 	}
-	declareField(field, scope, astVar, varType.ident)
-	p.resolve(typ)
+	if p.tok.tok == "STRING" {
+		p.next() // ignore the tag
+	}
+
+	p.expectSemi(__func__)
+
+	declareField(field, scope, astVar, field.Name)
+	p.resolve(field.Type)
 	return field
 }
 
@@ -1056,6 +1402,11 @@ func (p *parser) parseStructType() *astExpr {
 func (p *parser) parseTypeName() *astExpr {
 	logf(" [%s] begin\n", __func__)
 	var ident = p.parseIdent()
+	if p.tok.tok == "." {
+		// a qualified type name like runtime.Error is an ident of the universe
+		p.next()
+		ident.Name = ident.Name + "." + p.parseIdent().Name
+	}
 	logf(" [%s] end\n", __func__)
 	return &astExpr{
 		ident: ident,
@@ -1063,6 +1414,29 @@ func (p *parser) parseTypeName() *astExpr {
 	}
 }
 
+func (p *parser) parseChanType() *astExpr {
+	var dir = astSEND + astRECV
+	if p.tok.tok == "chan" {
+		p.next()
+		if p.tok.tok == "<-" {
+			p.next()
+			dir = astSEND
+		}
+	} else {
+		p.expect("<-", __func__)
+		p.expect("chan", __func__)
+		dir = astRECV
+	}
+	var value = p.parseType()
+	return &astExpr{
+		dtype : "*astChanType",
+		chanType : &astChanType{
+			Dir : dir,
+			Value : value,
+		},
+	}
+}
+
 func (p *parser) tryIdentOrType() *astExpr {
 	logf(" [%s] begin\n", __func__)
 	switch p.tok.tok {
@@ -1072,6 +1446,14 @@ func (p *parser) tryIdentOrType() *astExpr {
 		return p.parseArrayType()
 	case "struct":
 		return p.parseStructType()
+	case "map":
+		return p.parseMapType()
+	case "chan", "<-":
+		return p.parseChanType()
+	case "interface":
+		return p.parseInterfaceType()
+	case "func":
+		return p.parseFuncType()
 	case "*":
 		return p.parsePointerType()
 	case "(":
@@ -1196,6 +1578,11 @@ func (p *parser) parseResult(scope *astScope) *astFieldList {
 		return _r
 	}
 	var typ = p.tryType()
+	if typ == nil {
+		// e.g. a method without results in an interface type
+		var _r *astFieldList
+		return _r
+	}
 	var list []*astField
 	list = append(list, &astField{
 		Type: typ,
@@ -1295,7 +1682,7 @@ func (p *parser) parseOperand() *astExpr {
 		p.tryResolve(eIdent, true)
 		logf("   end %s\n", __func__)
 		return eIdent
-	case "INT", "STRING", "CHAR":
+	case "INT", "FLOAT", "STRING", "CHAR":
 		var basicLit = &astBasicLit{
 			Kind : p.tok.tok,
 			Value : p.tok.lit,
@@ -1306,6 +1693,8 @@ func (p *parser) parseOperand() *astExpr {
 			dtype:    "*astBasicLit",
 			basicLit: basicLit,
 		}
+	case "func":
+		return p.parseFuncTypeOrLit()
 	case "(":
 		p.next() // consume "("
 		parserExprLev++
@@ -1329,6 +1718,34 @@ func (p *parser) parseOperand() *astExpr {
 	return typ
 }
 
+func (p *parser) parseFuncTypeOrLit() *astExpr {
+	p.expect("func", __func__)
+	var scope = astNewScope(p.topScope) // function scope
+	var sig = p.parseSignature(scope)
+	var funcType = &astFuncType{
+		Params : sig.params,
+		Results : sig.results,
+	}
+	if p.tok.tok != "{" {
+		return &astExpr{
+			dtype : "*astFuncType",
+			funcType : funcType,
+		}
+	}
+
+	var oldExprLev = parserExprLev
+	parserExprLev = 0
+	var body = p.parseBody(scope)
+	parserExprLev = oldExprLev
+	return &astExpr{
+		dtype : "*astFuncLit",
+		funcLit : &astFuncLit{
+			Type : funcType,
+			Body : body,
+		},
+	}
+}
+
 func (p *parser) parseRhsOrType() *astExpr {
 	var x = p.parseExpr()
 	return x
@@ -1337,22 +1754,30 @@ func (p *parser) parseRhsOrType() *astExpr {
 func (p *parser) parseCallExpr(fn *astExpr) *astExpr {
 	p.expect("(", __func__)
 	logf(" [parsePrimaryExpr] p.tok.tok=%s\n", p.tok.tok)
+	parserExprLev++
 	var list []*astExpr
+	var ellipsis bool
 	for p.tok.tok != ")" {
 		var arg = p.parseExpr()
 		list = append(list, arg)
+		if p.tok.tok == "..." {
+			ellipsis = true
+			p.next()
+		}
 		if p.tok.tok == "," {
 			p.next()
 		} else if p.tok.tok == ")" {
 			break
 		}
 	}
+	parserExprLev--
 	p.expect(")", __func__)
 	return &astExpr{
 		dtype:    "*astCallExpr",
 		callExpr: &astCallExpr{
-			Fun:  fn,
-			Args: list,
+			Fun:      fn,
+			Args:     list,
+			Ellipsis: ellipsis,
 		},
 	}
 }
@@ -1375,28 +1800,41 @@ func (p *parser) parsePrimaryExpr() *astExpr {
 		switch p.tok.tok {
 		case ".":
 			p.next() // consume "."
-			if p.tok.tok != "IDENT" {
-				panic2(__func__, "tok should be IDENT")
-			}
-			// Assume CallExpr
-			var secondIdent = p.parseIdent()
-			var sel = &astSelectorExpr{
-				X : x,
-				Sel : secondIdent,
-			}
 			if p.tok.tok == "(" {
-				var fn = &astExpr{
-					dtype : "*astSelectorExpr",
-					selectorExpr : sel,
-				}
-				// string = x.ident.Name + "." + secondIdent
-				x = p.parseCallExpr(fn)
-				logf(" [parsePrimaryExpr] 741 p.tok.tok=%s\n", p.tok.tok)
+				x = p.parseTypeAssertion(x)
 			} else {
-				logf("   end parsePrimaryExpr()\n")
-				x = &astExpr{
-					dtype : "*astSelectorExpr",
-					selectorExpr : sel,
+				if p.tok.tok != "IDENT" {
+					panic2(__func__, "tok should be IDENT")
+				}
+				// Assume CallExpr
+				var secondIdent = p.parseIdent()
+				var sel = &astSelectorExpr{
+					X : x,
+					Sel : secondIdent,
+				}
+				if x.dtype == "*astIdent" && x.ident.Obj == nil && x.ident.Name+"."+secondIdent.Name == gRuntimeError.Name {
+					// the type name runtime.Error as in "case runtime.Error:"
+					x = &astExpr{
+						dtype : "*astIdent",
+						ident : &astIdent{
+							Name : gRuntimeError.Name,
+						},
+					}
+					p.resolve(x)
+				} else if p.tok.tok == "(" {
+					var fn = &astExpr{
+						dtype : "*astSelectorExpr",
+						selectorExpr : sel,
+					}
+					// string = x.ident.Name + "." + secondIdent
+					x = p.parseCallExpr(fn)
+					logf(" [parsePrimaryExpr] 741 p.tok.tok=%s\n", p.tok.tok)
+				} else {
+					logf("   end parsePrimaryExpr()\n")
+					x = &astExpr{
+						dtype : "*astSelectorExpr",
+						selectorExpr : sel,
+					}
 				}
 			}
 		case "(":
@@ -1405,7 +1843,8 @@ func (p *parser) parsePrimaryExpr() *astExpr {
 			p.resolve(x)
 			x = p.parseIndexOrSlice(x)
 		case "{":
-			if isLiteralType(x) && parserExprLev >= 0 {
+			// T{} in a control clause is ambiguous only if T is a type name
+			if isLiteralType(x) && (parserExprLev >= 0 || !isTypeName(x)) {
 				x = p.parseLiteralValue(x)
 			} else {
 				return x
@@ -1420,13 +1859,42 @@ func (p *parser) parsePrimaryExpr() *astExpr {
 	return x
 }
 
+// x.(T)
+func (p *parser) parseTypeAssertion(x *astExpr) *astExpr {
+	p.expect("(", __func__)
+	var typ *astExpr
+	if p.tok.tok == "type" {
+		// x.(type) of a type switch
+		p.next()
+	} else {
+		typ = p.parseType()
+	}
+	p.expect(")", __func__)
+	return &astExpr{
+		dtype : "*astTypeAssertExpr",
+		typeAssertExpr : &astTypeAssertExpr{
+			X : x,
+			Type : typ,
+		},
+	}
+}
+
+// An element whose type is elided like {1, 2} of []Point{{1, 2}}
+func (p *parser) parseValue() *astExpr {
+	if p.tok.tok == "{" {
+		var _nil *astExpr
+		return p.parseLiteralValue(_nil)
+	}
+	return p.parseExpr()
+}
+
 func (p *parser) parseElement() *astExpr {
-	var x = p.parseExpr() // key or value
+	var x = p.parseValue() // key or value
 	var v *astExpr
 	var kvExpr *astKeyValueExpr
 	if p.tok.tok == ":" {
 		p.next() // skip ":"
-		v = p.parseExpr()
+		v = p.parseValue()
 		kvExpr = &astKeyValueExpr{
 			Key : x,
 			Value : v,
@@ -1472,6 +1940,17 @@ func (p *parser) parseLiteralValue(typ *astExpr) *astExpr {
 	}
 }
 
+func isTypeName(x *astExpr) bool {
+	switch x.dtype {
+	case "*astIdent":
+	case "*astSelectorExpr":
+		return x.selectorExpr.X.dtype == "*astIdent"
+	default:
+		return false
+	}
+	return true
+}
+
 func isLiteralType(x *astExpr) bool {
 	switch x.dtype {
 	case "*astIdent":
@@ -1489,6 +1968,7 @@ func isLiteralType(x *astExpr) bool {
 
 func (p *parser) parseIndexOrSlice(x *astExpr) *astExpr {
 	p.expect("[", __func__)
+	parserExprLev++
 	var index = make([]*astExpr, 3, 3)
 	if p.tok.tok != ":" {
 		index[0] = p.parseRhs()
@@ -1501,18 +1981,26 @@ func (p *parser) parseIndexOrSlice(x *astExpr) *astExpr {
 			index[ncolons] = p.parseRhs()
 		}
 	}
+	parserExprLev--
 	p.expect("]", __func__)
 
 	if ncolons > 0 {
 		// slice expression
-		if ncolons == 2 {
-			panic2(__func__, "TBI: ncolons=2")
+		var slice3 = ncolons == 2
+		if slice3 {
+			if index[1] == nil {
+				panic2(__func__, "middle index required in 3-index slice")
+			}
+			if index[2] == nil {
+				panic2(__func__, "final index required in 3-index slice")
+			}
 		}
 		var sliceExpr = &astSliceExpr{
-			Slice3 : false,
-			X : x,
-			Low : index[0],
-			High : index[1],
+			Slice3: slice3,
+			X:      x,
+			Low:    index[0],
+			High:   index[1],
+			Max:    index[2],
 		}
 
 		return &astExpr{
@@ -1534,7 +2022,7 @@ func (p *parser) parseUnaryExpr() *astExpr {
 	var r *astExpr
 	logf("   begin parseUnaryExpr()\n")
 	switch p.tok.tok {
-	case "+", "-", "!", "&":
+	case "+", "-", "!", "&", "^":
 		var tok = p.tok.tok
 		p.next()
 		var x = p.parseUnaryExpr()
@@ -1545,6 +2033,27 @@ func (p *parser) parseUnaryExpr() *astExpr {
 		r.unaryExpr.Op = tok
 		r.unaryExpr.X = x
 		return r
+	case "<-":
+		p.next() // consume "<-"
+		if p.tok.tok == "chan" {
+			// <-chan T
+			p.next()
+			var value = p.parseType()
+			return &astExpr{
+				dtype : "*astChanType",
+				chanType : &astChanType{
+					Dir : astRECV,
+					Value : value,
+				},
+			}
+		}
+		var x = p.parseUnaryExpr()
+		r = &astExpr{}
+		r.dtype = "*astUnaryExpr"
+		r.unaryExpr = &astUnaryExpr{}
+		r.unaryExpr.Op = "<-"
+		r.unaryExpr.X = x
+		return r
 	case "*":
 		p.next() // consume "*"
 		var x = p.parseUnaryExpr()
@@ -1569,9 +2078,9 @@ func precedence(op string) int {
 		return 2
 	case "==", "!=", "<", "<=", ">", ">=":
 		return 3
-	case "+", "-":
+	case "+", "-", "|", "^":
 		return 4
-	case "*", "/", "%":
+	case "*", "/", "%", "<<", ">>", "&", "&^":
 		return 5
 	default:
 		return 0
@@ -1645,7 +2154,11 @@ func (p *parser) parseForStmt() *astStmt {
 	var s3 *astStmt
 	var isRange bool
 	parserExprLev = -1
-	if p.tok.tok != "{" {
+	if p.tok.tok == "range" {
+		// for range x
+		s2 = p.parseRangeWithoutVars()
+		isRange = true
+	} else if p.tok.tok != "{" {
 		if p.tok.tok != ";" {
 			s2 = p.parseSimpleStmt(true)
 			isRange = s2.isRange
@@ -1691,6 +2204,7 @@ func (p *parser) parseForStmt() *astStmt {
 		var rangeStmt = &astRangeStmt{}
 		rangeStmt.Key = key
 		rangeStmt.Value = value
+		rangeStmt.Tok = as.Tok
 		rangeStmt.X = rangeX
 		rangeStmt.Body = body
 		var r = &astStmt{}
@@ -1713,11 +2227,40 @@ func (p *parser) parseForStmt() *astStmt {
 	return r
 }
 
+// Parse "range x" of "for range x" as an assignment with no lhs
+func (p *parser) parseRangeWithoutVars() *astStmt {
+	p.expect("range", __func__)
+	var rangeUnary = &astUnaryExpr{}
+	rangeUnary.Op = "range"
+	rangeUnary.X = p.parseRhs()
+	var y = &astExpr{}
+	y.dtype = "*astUnaryExpr"
+	y.unaryExpr = rangeUnary
+	var as = &astAssignStmt{}
+	as.Tok = "="
+	as.Rhs = []*astExpr{y}
+	var s = &astStmt{}
+	s.dtype = "*astAssignStmt"
+	s.assignStmt = as
+	s.isRange = true
+	return s
+}
+
 func (p *parser) parseIfStmt() *astStmt {
 	p.expect("if", __func__)
+	p.openScope()
 	parserExprLev = -1
-	var condStmt *astStmt = p.parseSimpleStmt(false)
-	if condStmt.dtype != "*astExprStmt" {
+	var init *astStmt
+	var condStmt *astStmt
+	if p.tok.tok != ";" {
+		condStmt = p.parseSimpleStmt(false)
+	}
+	if p.tok.tok == ";" {
+		p.next() // consume ";"
+		init = condStmt
+		condStmt = p.parseSimpleStmt(false)
+	}
+	if condStmt.dtype != "*astExprStmt" {
 		panic2(__func__, "unexpected dtype="+condStmt.dtype)
 	}
 	var cond = condStmt.exprStmt.X
@@ -1738,7 +2281,9 @@ func (p *parser) parseIfStmt() *astStmt {
 	} else {
 		p.expectSemi(__func__)
 	}
+	p.closeScope()
 	var ifStmt = &astIfStmt{}
+	ifStmt.Init = init
 	ifStmt.Cond = cond
 	ifStmt.Body = body
 	ifStmt.Else = else_
@@ -1749,7 +2294,8 @@ func (p *parser) parseIfStmt() *astStmt {
 	return r
 }
 
-func (p *parser) parseCaseClause() *astCaseClause {
+// typeSwitchVar is v of "switch v := x.(type)", or nil
+func (p *parser) parseCaseClause(typeSwitchVar *astIdent) *astCaseClause {
 	logf(" [%s] start\n", __func__)
 	var list []*astExpr
 	if p.tok.tok == "case" {
@@ -1761,8 +2307,26 @@ func (p *parser) parseCaseClause() *astCaseClause {
 
 	p.expect(":", __func__)
 	p.openScope()
-	var body = p.parseStmtList()
 	var r = &astCaseClause{}
+	if typeSwitchVar != nil {
+		// Each clause has its own v, typed by the case if it lists a single type.
+		// Otherwise (or for "case nil") the type is that of x, which is filled in by walk.
+		var spec = &astValueSpec{
+			Name: &astIdent{
+				Name: typeSwitchVar.Name,
+			},
+		}
+		if len(list) == 1 {
+			spec.Type = list[0]
+		}
+		var objDecl = &ObjDecl{
+			dtype:     "*astValueSpec",
+			valueSpec: spec,
+		}
+		declare(objDecl, p.topScope, astVar, spec.Name)
+		r.implicit = spec
+	}
+	var body = p.parseStmtList()
 	r.Body = body
 	r.List = list
 	p.closeScope()
@@ -1770,21 +2334,58 @@ func (p *parser) parseCaseClause() *astCaseClause {
 	return r
 }
 
+// x.(type) or v := x.(type)
+func isTypeSwitchGuard(s *astStmt) bool {
+	var e *astExpr
+	switch s.dtype {
+	case "*astExprStmt":
+		e = s.exprStmt.X
+	case "*astAssignStmt":
+		var as = s.assignStmt
+		if as.Tok != ":=" || len(as.Lhs) != 1 {
+			return false
+		}
+		e = as.Rhs[0]
+	default:
+		return false
+	}
+	return e.dtype == "*astTypeAssertExpr" && e.typeAssertExpr.Type == nil
+}
+
 func (p *parser) parseSwitchStmt() *astStmt {
 	p.expect("switch", __func__)
 	p.openScope()
 
+	var s1 *astStmt
 	var s2 *astStmt
 	parserExprLev = -1
-	s2 = p.parseSimpleStmt(false)
+	if p.tok.tok != "{" {
+		if p.tok.tok != ";" {
+			s2 = p.parseSimpleStmt(false)
+		}
+		if p.tok.tok == ";" {
+			p.next() // consume ";"
+			s1 = s2
+			s2 = nil
+			if p.tok.tok != "{" {
+				s2 = p.parseSimpleStmt(false)
+			}
+		}
+	}
 	parserExprLev = 0
 
+	var typeSwitchVar *astIdent
+	var isTypeSwitch = s2 != nil && isTypeSwitchGuard(s2)
+	if isTypeSwitch && s2.dtype == "*astAssignStmt" {
+		typeSwitchVar = s2.assignStmt.Lhs[0].ident
+	}
+
 	p.expect("{", __func__)
 	var list []*astStmt
 	var cc *astCaseClause
 	var ccs *astStmt
 	for p.tok.tok == "case" || p.tok.tok == "default" {
-		cc = p.parseCaseClause()
+		cc = p.parseCaseClause(typeSwitchVar)
 		ccs = &astStmt{}
 		ccs.dtype = "*astCaseClause"
 		ccs.caseClause = cc
@@ -1795,16 +2396,75 @@ func (p *parser) parseSwitchStmt() *astStmt {
 	var body = &astBlockStmt{}
 	body.List = list
 
+	var s *astStmt
+	if isTypeSwitch {
+		s = &astStmt{
+			dtype: "*astTypeSwitchStmt",
+			typeSwitchStmt: &astTypeSwitchStmt{
+				Init:   s1,
+				Assign: s2,
+				Body:   body,
+			},
+		}
+		p.closeScope()
+		return s
+	}
+
 	var switchStmt = &astSwitchStmt{}
+	switchStmt.Init = s1
 	switchStmt.Body = body
-	switchStmt.Tag = makeExpr(s2)
-	var s = &astStmt{}
+	if s2 != nil {
+		switchStmt.Tag = makeExpr(s2)
+	}
+	s = &astStmt{}
 	s.dtype = "*astSwitchStmt"
 	s.switchStmt = switchStmt
 	p.closeScope()
 	return s
 }
 
+func (p *parser) parseCommClause() *astCommClause {
+	p.openScope()
+	var comm *astStmt
+	if p.tok.tok == "case" {
+		p.next() // consume "case"
+		comm = p.parseSimpleStmt(false)
+	} else {
+		p.expect("default", __func__)
+	}
+	p.expect(":", __func__)
+	var r = &astCommClause{}
+	r.Comm = comm
+	r.Body = p.parseStmtList()
+	p.closeScope()
+	return r
+}
+
+func (p *parser) parseSelectStmt() *astStmt {
+	p.expect("select", __func__)
+	p.expect("{", __func__)
+	var list []*astStmt
+	var cc *astCommClause
+	var ccs *astStmt
+	for p.tok.tok == "case" || p.tok.tok == "default" {
+		cc = p.parseCommClause()
+		ccs = &astStmt{}
+		ccs.dtype = "*astCommClause"
+		ccs.commClause = cc
+		list = append(list, ccs)
+	}
+	p.expect("}", __func__)
+	p.expectSemi(__func__)
+	var body = &astBlockStmt{}
+	body.List = list
+	var s = &astStmt{}
+	s.dtype = "*astSelectStmt"
+	s.selectStmt = &astSelectStmt{
+		Body: body,
+	}
+	return s
+}
+
 func (p *parser) parseLhsList() []*astExpr {
 	logf(" [%s] start\n", __func__)
 	var list = p.parseExprList()
@@ -1824,6 +2484,7 @@ func (p *parser) parseSimpleStmt(isRangeOK bool) *astStmt {
 	switch stok {
 	case ":=", "=":
 		var assignToken = stok
+		var as = &astAssignStmt{}
 		p.next() // consume =
 		if isRangeOK && p.tok.tok == "range" {
 			p.next() // consume "range"
@@ -1835,24 +2496,18 @@ func (p *parser) parseSimpleStmt(isRangeOK bool) *astStmt {
 			y.dtype = "*astUnaryExpr"
 			y.unaryExpr = rangeUnary
 			isRange = true
+			as.Rhs = make([]*astExpr, 1, 1)
+			as.Rhs[0] = y
 		} else {
-			y = p.parseExpr() // rhs
+			as.Rhs = p.parseRhsList()
 		}
-		var as = &astAssignStmt{}
 		as.Tok = assignToken
 		as.Lhs = x
-		as.Rhs = make([]*astExpr, 1, 1)
-		as.Rhs[0] = y
 		s.dtype = "*astAssignStmt"
 		s.assignStmt = as
 		s.isRange = isRange
 		if assignToken == ":=" {
-			// Obj.Decl = astAssignStmt
-			var objDecl = &ObjDecl{
-				dtype: "*astAssignStmt",
-				assignment: as,
-			}
-			declare(objDecl, p.topScope, astVar, x[0].ident)
+			p.shortVarDecl(as)
 		}
 		logf(" parseSimpleStmt end =, := %s\n", __func__)
 		return s
@@ -1866,6 +2521,49 @@ func (p *parser) parseSimpleStmt(isRangeOK bool) *astStmt {
 	}
 
 	switch stok {
+	case ":":
+		// L: stmt
+		if len(x) == 1 && x[0].dtype == "*astIdent" {
+			p.next() // consume ":"
+			var labeled = &astLabeledStmt{}
+			labeled.Label = x[0].ident
+			if p.tok.tok == "}" {
+				// a label at the end of a block labels an empty statement
+				labeled.Stmt = blockStmt2Stmt(&astBlockStmt{})
+			} else {
+				labeled.Stmt = p.parseStmt()
+			}
+			s.dtype = "*astLabeledStmt"
+			s.labeledStmt = labeled
+			return s
+		}
+	case "<-":
+		p.next() // consume "<-"
+		var value = p.parseExpr()
+		var sSend = &astStmt{}
+		sSend.dtype = "*astSendStmt"
+		sSend.sendStmt = &astSendStmt{
+			Chan:  x[0],
+			Value: value,
+		}
+		return sSend
+	case "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "&^=":
+		// x op= y is parsed as x = x op y
+		p.next() // consume the assign op
+		var binaryExpr = &astBinaryExpr{}
+		binaryExpr.Op = stok[0 : len(stok)-1]
+		binaryExpr.X = x[0]
+		binaryExpr.Y = p.parseRhs()
+		var as = &astAssignStmt{}
+		as.Tok = "="
+		as.Lhs = x
+		as.Rhs = []*astExpr{&astExpr{
+			dtype:      "*astBinaryExpr",
+			binaryExpr: binaryExpr,
+		}}
+		s.dtype = "*astAssignStmt"
+		s.assignStmt = as
+		return s
 	case "++", "--":
 		var s = &astStmt{}
 		var sInc = &astIncDecStmt{}
@@ -1885,13 +2583,37 @@ func (p *parser) parseSimpleStmt(isRangeOK bool) *astStmt {
 	return r
 }
 
+// Declare new variables on the lhs of ":=".
+// Variables already declared in the same scope are just reused.
+func (p *parser) shortVarDecl(as *astAssignStmt) {
+	var lhs *astExpr
+	for _, lhs = range as.Lhs {
+		assert(lhs.dtype == "*astIdent", "non-name on left side of :=", __func__)
+		var ident = lhs.ident
+		if ident.Name == "_" {
+			continue
+		}
+		var obj = scopeLookup(p.topScope, ident.Name)
+		if obj != nil {
+			ident.Obj = obj
+			continue
+		}
+		// Obj.Decl = astAssignStmt
+		var objDecl = &ObjDecl{
+			dtype: "*astAssignStmt",
+			assignment: as,
+		}
+		declare(objDecl, p.topScope, astVar, ident)
+	}
+}
+
 func (p *parser) parseStmt() *astStmt {
 	logf("\n")
 	logf(" = begin %s\n", __func__)
 	var s *astStmt
 	switch p.tok.tok {
-	case "var":
-		var genDecl = p.parseDecl("var")
+	case "var", "const":
+		var genDecl = p.parseDecl(p.tok.tok)
 		s = &astStmt{}
 		s.dtype = "*astDeclStmt"
 		s.DeclStmt = &astDeclStmt{}
@@ -1900,17 +2622,25 @@ func (p *parser) parseStmt() *astStmt {
 		decl.genDecl = genDecl
 		s.DeclStmt.Decl = decl
 		logf(" = end parseStmt()\n")
-	case "IDENT", "*":
+	case "IDENT", "*", "func", "(", "<-":
 		s = p.parseSimpleStmt(false)
-		p.expectSemi(__func__)
+		if s.dtype != "*astLabeledStmt" {
+			p.expectSemi(__func__)
+		}
 	case "return":
 		s = p.parseReturnStmt()
-	case "break", "continue":
+	case "go":
+		s = p.parseGoStmt()
+	case "defer":
+		s = p.parseDeferStmt()
+	case "break", "continue", "goto", "fallthrough":
 		s = p.parseBranchStmt(p.tok.tok)
 	case "if":
 		s = p.parseIfStmt()
 	case "switch":
 		s = p.parseSwitchStmt()
+	case "select":
+		s = p.parseSelectStmt()
 	case "for":
 		s = p.parseForStmt()
 	default:
@@ -1942,11 +2672,15 @@ func (p *parser) parseRhsList() []*astExpr {
 
 func (p *parser) parseBranchStmt(tok string) *astStmt {
 	p.expect(tok, __func__)
-
+	var label string
+	if tok != "fallthrough" && p.tok.tok == "IDENT" {
+		label = p.parseIdent().Name
+	}
 	p.expectSemi(__func__)
 
 	var branchStmt = &astBranchStmt{}
 	branchStmt.Tok = tok
+	branchStmt.Label = label
 	var s = &astStmt{}
 	s.dtype = "*astBranchStmt"
 	s.branchStmt = branchStmt
@@ -1968,6 +2702,39 @@ func (p *parser) parseReturnStmt() *astStmt {
 	return r
 }
 
+// The call of a go or defer statement
+func (p *parser) parseCallOfStmt(callType string) *astCallExpr {
+	var x = p.parseExpr()
+	if x.dtype != "*astCallExpr" {
+		panic2(__func__, "expression in "+callType+" must be function call")
+	}
+	return x.callExpr
+}
+
+func (p *parser) parseGoStmt() *astStmt {
+	p.expect("go", __func__)
+	var call = p.parseCallOfStmt("go")
+	p.expectSemi(__func__)
+	var goStmt = &astGoStmt{}
+	goStmt.Call = call
+	var r = &astStmt{}
+	r.dtype = "*astGoStmt"
+	r.goStmt = goStmt
+	return r
+}
+
+func (p *parser) parseDeferStmt() *astStmt {
+	p.expect("defer", __func__)
+	var call = p.parseCallOfStmt("defer")
+	p.expectSemi(__func__)
+	var deferStmt = &astDeferStmt{}
+	deferStmt.Call = call
+	var r = &astStmt{}
+	r.dtype = "*astDeferStmt"
+	r.deferStmt = deferStmt
+	return r
+}
+
 func (p *parser) parseStmtList () []*astStmt {
 	var list []*astStmt
 	for p.tok.tok != "}" && p.tok.tok != "EOF" && p.tok.tok != "case" && p.tok.tok != "default" {
@@ -2004,42 +2771,43 @@ func (p *parser) parseBlockStmt() *astBlockStmt {
 	return r
 }
 
+// var, const or type declaration, which may be a group in parentheses
 func (p *parser) parseDecl(keyword string) *astGenDecl {
-	var r *astGenDecl
-	switch p.tok.tok {
-	case "var":
-		p.expect(keyword, __func__)
-		var ident = p.parseIdent()
-		var typ = p.parseType()
-		var value *astExpr
-		if p.tok.tok == "=" {
-			p.next()
-			value = p.parseExpr()
-		}
-		p.expectSemi(__func__)
-		var valSpec = &astValueSpec{}
-		valSpec.Name = ident
-		valSpec.Type = typ
-		valSpec.Value = value
-		var spec = &astSpec{}
-		spec.dtype = "*astValueSpec"
-		spec.valueSpec = valSpec
-		var objDecl = &ObjDecl{}
-		objDecl.dtype = "*astValueSpec"
-		objDecl.valueSpec = valSpec
-		declare(objDecl, p.topScope, astVar, ident)
-		r = &astGenDecl{}
-		r.Spec = spec
+	p.expect(keyword, __func__)
+	var r = &astGenDecl{}
+	if p.tok.tok != "(" {
+		var _nil []*astSpec
+		r.Specs = p.parseSpec(keyword, 0, _nil)
 		return r
-	default:
-		panic2(__func__, "TBI\n")
 	}
+	p.next()
+	var iota int
+	var specs []*astSpec
+	for p.tok.tok != ")" && p.tok.tok != "EOF" {
+		specs = p.parseSpec(keyword, iota, specs)
+		var spec *astSpec
+		for _, spec = range specs {
+			r.Specs = append(r.Specs, spec)
+		}
+		iota++
+	}
+	p.expect(")", __func__)
+	p.expectSemi(__func__)
 	return r
 }
 
-func (p *parser) parserTypeSpec() *astSpec {
+// prev is the previous spec in the group, whose type and values are repeated for an omitted const value.
+func (p *parser) parseSpec(keyword string, iota int, prev []*astSpec) []*astSpec {
+	if keyword == "type" {
+		var r []*astSpec
+		r = append(r, p.parseTypeSpec())
+		return r
+	}
+	return p.parseValueSpec(keyword, iota, prev)
+}
+
+func (p *parser) parseTypeSpec() *astSpec {
 	logf(" [%s] start\n", __func__)
-	p.expect("type", __func__)
 	var ident = p.parseIdent()
 	logf(" decl type %s\n", ident.Name)
 
@@ -2058,34 +2826,93 @@ func (p *parser) parserTypeSpec() *astSpec {
 	return r
 }
 
-func (p *parser) parseValueSpec(keyword string) *astSpec {
+// Each name of "a, b T = x, y" gets a spec of its own,
+// while "a, b = f()" is one spec sharing the value.
+func (p *parser) parseValueSpec(keyword string, iota int, prev []*astSpec) []*astSpec {
 	logf(" [parserValueSpec] start\n")
-	p.expect(keyword, __func__)
-	var ident = p.parseIdent()
-	logf(" var = %s\n", ident.Name)
+	var idents []*astIdent
+	idents = append(idents, p.parseIdent())
+	for p.tok.tok == "," {
+		p.next()
+		idents = append(idents, p.parseIdent())
+	}
 	var typ = p.parseType()
-	var value *astExpr
+	var values []*astExpr
 	if p.tok.tok == "=" {
 		p.next()
-		value = p.parseExpr()
+		values = p.parseRhsList()
 	}
 	p.expectSemi(__func__)
+	var sp *astSpec
+	if keyword == "const" && typ == nil && len(values) == 0 {
+		// implicit repetition of the previous type and values
+		if len(prev) != len(idents) {
+			panic2(__func__, "missing init expr for const declaration")
+		}
+		for _, sp = range prev {
+			typ = sp.valueSpec.Type
+			values = append(values, sp.valueSpec.Value)
+		}
+	}
+	var kind = astCon
+	if keyword == "var" {
+		kind = astVar
+	}
+	var r []*astSpec
+	if kind == astVar && len(values) == 1 && len(idents) > 1 {
+		return append(r, p.newTupleValueSpec(idents, typ, values[0]))
+	}
+	if len(values) > 0 && len(values) != len(idents) {
+		panic2(__func__, "assignment mismatch in declaration")
+	}
+	var i int
+	var ident *astIdent
+	for i, ident = range idents {
+		logf(" %s = %s\n", keyword, ident.Name)
+		var spec = &astValueSpec{}
+		spec.Name = ident
+		spec.Type = typ
+		spec.iota = iota
+		if len(values) > 0 {
+			spec.Value = values[i]
+		}
+		var objDecl = &ObjDecl{}
+		objDecl.dtype = "*astValueSpec"
+		objDecl.valueSpec = spec
+		declare(objDecl, p.topScope, kind, ident)
+		sp = &astSpec{}
+		sp.dtype = "*astValueSpec"
+		sp.valueSpec = spec
+		r = append(r, sp)
+	}
+	logf(" [parserValueSpec] end\n")
+	return r
+}
+
+// var a, b T = f()
+func (p *parser) newTupleValueSpec(idents []*astIdent, typ *astExpr, value *astExpr) *astSpec {
 	var spec = &astValueSpec{}
-	spec.Name = ident
+	spec.Name = idents[0]
 	spec.Type = typ
 	spec.Value = value
+	spec.tuple = &astAssignStmt{}
+	spec.tuple.Tok = "="
+	spec.tuple.Rhs = []*astExpr{value}
+	var ident *astIdent
+	for _, ident = range idents {
+		logf(" var %s\n", ident.Name)
+		var objDecl = &ObjDecl{}
+		objDecl.dtype = "*astValueSpec"
+		objDecl.valueSpec = spec
+		declare(objDecl, p.topScope, astVar, ident)
+		spec.tuple.Lhs = append(spec.tuple.Lhs, &astExpr{
+			dtype: "*astIdent",
+			ident: ident,
+		})
+	}
 	var r = &astSpec{}
 	r.dtype = "*astValueSpec"
 	r.valueSpec = spec
-	var objDecl = &ObjDecl{}
-	objDecl.dtype = "*astValueSpec"
-	objDecl.valueSpec = spec
-	var kind = astCon
-	if keyword == "var" {
-		kind = astVar
-	}
-	declare(objDecl, p.topScope, kind, ident)
-	logf(" [parserValueSpec] end\n")
 	return r
 }
 
@@ -2125,7 +2952,7 @@ func (p *parser) parseFuncDecl() *astDecl {
 	decl.funcDecl.Type.Params = params
 	decl.funcDecl.Type.Results = results
 	decl.funcDecl.Body = body
-	if receivers == nil {
+	if receivers == nil && ident.Name != "init" {
 		var objDecl = &ObjDecl{}
 		objDecl.dtype = "*astFuncDecl"
 		objDecl.funcDecl = funcDecl
@@ -2156,25 +2983,14 @@ func (p *parser) parseFile() *astFile {
 
 	for p.tok.tok != "EOF" {
 		switch p.tok.tok {
-		case "var", "const":
-			var spec = p.parseValueSpec(p.tok.tok)
-			var genDecl = &astGenDecl{}
-			genDecl.Spec = spec
+		case "var", "const", "type":
 			decl = &astDecl{}
 			decl.dtype = "*astGenDecl"
-			decl.genDecl = genDecl
+			decl.genDecl = p.parseDecl(p.tok.tok)
 		case "func":
 			logf("\n\n")
 			decl = p.parseFuncDecl()
 			logf(" func decl parsed:%s\n", decl.funcDecl.Name.Name)
-		case "type":
-			var spec = p.parserTypeSpec()
-			var genDecl = &astGenDecl{}
-			genDecl.Spec = spec
-			decl = &astDecl{}
-			decl.dtype = "*astGenDecl"
-			decl.genDecl = genDecl
-			logf(" type parsed:%s\n", "")
 		default:
 			panic2(__func__, "TBI:"+p.tok.tok)
 		}
@@ -2194,6 +3010,10 @@ func (p *parser) parseFile() *astFile {
 	var idnt *astIdent
 	logf(" [parserFile] resolving parser's unresolved (n=%s)\n", Itoa(len(p.unresolved)))
 	for _, idnt = range p.unresolved {
+		if idnt.Obj != nil {
+			// declared later by ":="
+			continue
+		}
 		logf(" [parserFile] resolving ident %s ...\n", idnt.Name)
 		var obj *astObject = scopeLookup(p.pkgScope, idnt.Name)
 		if obj != nil {
@@ -2225,6 +3045,7 @@ func parseFile(filename string) *astFile {
 
 // --- codegen ---
 var debugCodeGen bool
+var noBoundsCheck bool // index and slice bounds are not checked at runtime
 
 func emitComment(indent int, format string, a ...string) {
 	if !debugCodeGen {
@@ -2241,11 +3062,31 @@ func emitComment(indent int, format string, a ...string) {
 }
 
 func evalInt(expr *astExpr) int {
-	switch expr.dtype {
-	case "*astBasicLit":
-		return Atoi(expr.basicLit.Value)
+	var v = evalConst(expr, 0)
+	if v != nil && v.kind == "FLOAT" && v.typ == nil {
+		// an untyped float constant like 4.0
+		return floatConstToInt(v)
 	}
-	return 0
+	if v == nil || v.kind != "INT" {
+		panic2(__func__, "constant integer expected: "+expr.dtype)
+	}
+	return v.ival
+}
+
+// The value of a float constant used as an integer, which must be integral
+func floatConstToInt(v *constValue) int {
+	var lit = v.sval
+	if lit == "" {
+		lit = formatFloatConst(v)
+	}
+	if natCmp(v.den.mag, natFromInt(1)) != 0 {
+		panic2(__func__, "constant "+lit+" truncated to integer")
+	}
+	// a uint64 value beyond int keeps its bits
+	if !bigFitsBits(v.num, 64, true) && !bigFitsBits(v.num, 64, false) {
+		panic2(__func__, "constant "+lit+" overflows")
+	}
+	return bigToInt(v.num)
 }
 
 func emitPopBool(comment string) {
@@ -2269,12 +3110,12 @@ func emitPopSlice() {
 
 func emitPushStackTop(condType *Type, comment string) {
 	switch kind(condType) {
-	case T_STRING:
+	case T_STRING, T_INTERFACE:
 		fmtPrintf("  movq 8(%%rsp), %%rcx # copy str.len from stack top (%s)\n", comment)
 		fmtPrintf("  movq 0(%%rsp), %%rax # copy str.ptr from stack top (%s)\n", comment)
 		fmtPrintf("  pushq %%rcx # str.len\n")
 		fmtPrintf("  pushq %%rax # str.ptr\n")
-	case T_POINTER, T_UINTPTR, T_BOOL, T_INT, T_UINT8, T_UINT16:
+	case T_POINTER, T_UINTPTR, T_BOOL, T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_FLOAT32, T_FLOAT64, T_MAP, T_CHAN, T_FUNC, T_STRUCT, T_ARRAY:
 		fmtPrintf("  movq (%%rsp), %%rax # copy stack top value (%s) \n", comment)
 		fmtPrintf("  pushq %%rax\n")
 	default:
@@ -2306,18 +3147,30 @@ func emitLoad(t *Type) {
 		fmtPrintf("  pushq %%rdx # cap\n")
 		fmtPrintf("  pushq %%rcx # len\n")
 		fmtPrintf("  pushq %%rax # ptr\n")
-	case T_STRING:
+	case T_STRING, T_INTERFACE:
 		fmtPrintf("  movq %d(%%rax), %%rdx\n", Itoa(8))
 		fmtPrintf("  movq %d(%%rax), %%rax\n", Itoa(0))
 		fmtPrintf("  pushq %%rdx # len\n")
 		fmtPrintf("  pushq %%rax # ptr\n")
+	case T_INT8:
+		fmtPrintf("  movsbq %d(%%rax), %%rax # load int8\n", Itoa(0))
+		fmtPrintf("  pushq %%rax\n")
 	case T_UINT8:
 		fmtPrintf("  movzbq %d(%%rax), %%rax # load uint8\n", Itoa(0))
 		fmtPrintf("  pushq %%rax\n")
+	case T_INT16:
+		fmtPrintf("  movswq %d(%%rax), %%rax # load int16\n", Itoa(0))
+		fmtPrintf("  pushq %%rax\n")
 	case T_UINT16:
 		fmtPrintf("  movzwq %d(%%rax), %%rax # load uint16\n", Itoa(0))
 		fmtPrintf("  pushq %%rax\n")
-	case T_INT, T_BOOL, T_UINTPTR, T_POINTER:
+	case T_INT32:
+		fmtPrintf("  movslq %d(%%rax), %%rax # load int32\n", Itoa(0))
+		fmtPrintf("  pushq %%rax\n")
+	case T_UINT32, T_FLOAT32:
+		fmtPrintf("  movl %d(%%rax), %%eax # load 32 bits\n", Itoa(0))
+		fmtPrintf("  pushq %%rax\n")
+	case T_INT, T_INT64, T_UINT, T_UINT64, T_FLOAT64, T_BOOL, T_UINTPTR, T_POINTER, T_MAP, T_CHAN, T_FUNC:
 		fmtPrintf("  movq %d(%%rax), %%rax # load int\n", Itoa(0))
 		fmtPrintf("  pushq %%rax\n")
 	case T_ARRAY, T_STRUCT:
@@ -2333,6 +3186,13 @@ func emitVariableAddr(variable *Variable) {
 
 	if variable.isGlobal {
 		fmtPrintf("  leaq %s(%%rip), %%rax # global variable addr \"%s\"\n", variable.globalSymbol,  variable.name)
+	} else if variable.fnc != currentFunc {
+		var index = getFreeVarIndex(currentFunc, variable)
+		assert(index >= 0, "variable is not captured: "+variable.name, __func__)
+		fmtPrintf("  movq %d(%%rbp), %%rax # closure\n", Itoa(currentFunc.closureOffset))
+		fmtPrintf("  movq %d(%%rax), %%rax # captured variable addr \"%s\"\n", Itoa(ptrSize*(index+1)), variable.name)
+	} else if variable.isBoxed {
+		fmtPrintf("  movq %d(%%rbp), %%rax # boxed variable addr \"%s\"\n", Itoa(variable.boxOffset),  variable.name)
 	} else {
 		fmtPrintf("  leaq %d(%%rbp), %%rax # local variable addr \"%s\"\n", Itoa(variable.localOffset),  variable.name)
 	}
@@ -2340,18 +3200,56 @@ func emitVariableAddr(variable *Variable) {
 	fmtPrintf("  pushq %%rax\n")
 }
 
+// Copy a variable captured by closures into a new heap area
+func emitRenewBox(variable *Variable, t *Type) {
+	if !variable.isBoxed {
+		return
+	}
+	emitCallMalloc(getSizeOfType(t))
+	fmtPrintf("  popq %%rax # new box\n")
+	fmtPrintf("  movq %d(%%rbp), %%rcx # old box\n", Itoa(variable.boxOffset))
+	fmtPrintf("  movq %%rax, %d(%%rbp) # box of \"%s\"\n", Itoa(variable.boxOffset), variable.name)
+	fmtPrintf("  pushq $%d # size\n", Itoa(getSizeOfType(t)))
+	fmtPrintf("  pushq %%rax # dst\n")
+	fmtPrintf("  pushq %%rcx # src\n")
+	fmtPrintf("  callq runtime.memcopy\n")
+	emitRevertStackPointer(ptrSize*2 + intSize)
+}
+
+// Allocate a new heap area for a variable captured by closures.
+// This must be done where the variable is declared.
+func emitNewBox(variable *Variable, t *Type) {
+	if !variable.isBoxed {
+		return
+	}
+	emitCallMalloc(getSizeOfType(t))
+	fmtPrintf("  popq %%rax # box\n")
+	fmtPrintf("  movq %%rax, %d(%%rbp) # box of \"%s\"\n", Itoa(variable.boxOffset), variable.name)
+}
+
+// Push the length and then the head address of a list
 func emitListHeadAddr(list *astExpr) {
 	var t = getTypeOfExpr(list)
 	switch kind(t) {
 	case T_ARRAY:
+		var arrayType = getUnderlyingType(t).e.arrayType
+		fmtPrintf("  pushq $%d # array length\n", Itoa(evalInt(arrayType.Len)))
 		emitAddr(list) // array head
+	case T_POINTER:
+		// p[i] is (*p)[i]
+		var arrayType = getUnderlyingType(getPointedArrayType(t)).e.arrayType
+		fmtPrintf("  pushq $%d # array length\n", Itoa(evalInt(arrayType.Len)))
+		emitExpr(list, nil) // array head
+		emitNilCheck()
 	case T_SLICE:
 		emitExpr(list, nil)
 		emitPopSlice()
+		fmtPrintf("  pushq %%rcx # slice.len\n")
 		fmtPrintf("  pushq %%rax # slice.ptr\n")
 	case T_STRING:
 		emitExpr(list, nil)
 		emitPopString()
+		fmtPrintf("  pushq %%rcx # string.len\n")
 		fmtPrintf("  pushq %%rax # string.ptr\n")
 	default:
 		panic2(__func__, "kind="+kind(getTypeOfExpr(list)))
@@ -2377,12 +3275,18 @@ func emitAddr(expr *astExpr) {
 			panic2(__func__, "Unexpected Kind "+expr.ident.Obj.Kind)
 		}
 	case "*astIndexExpr":
+		if kind(getTypeOfExpr(expr.indexExpr.X)) == T_MAP {
+			emitMapElementAddr(expr.indexExpr)
+			return
+		}
 		emitExpr(expr.indexExpr.Index, nil) // index number
 		var list = expr.indexExpr.X
 		var elmType = getTypeOfExpr(expr)
 		emitListElementAddr(list, elmType)
 	case "*astStarExpr":
 		emitExpr(expr.starExpr.X, nil)
+	case "*astParenExpr":
+		emitAddr(expr.parenExpr.X)
 	case "*astSelectorExpr": // (X).Sel
 		if isOsArgs(expr.selectorExpr) {
 			fmtPrintf("  leaq %s(%%rip), %%rax # hack for os.Args\n", "__args__")
@@ -2395,7 +3299,12 @@ func emitAddr(expr *astExpr) {
 		case T_STRUCT:
 			// strct.field
 			structType = typeOfX
-			emitAddr(expr.selectorExpr.X)
+			if expr.selectorExpr.tmp != nil {
+				emitAssign(expr.selectorExpr.tmp, expr.selectorExpr.X)
+				emitAddr(expr.selectorExpr.tmp)
+			} else {
+				emitAddr(expr.selectorExpr.X)
+			}
 		case T_POINTER:
 			// ptr.field
 			assert(typeOfX.e.dtype == "*astStarExpr", "should be *astStarExpr", __func__)
@@ -2405,18 +3314,30 @@ func emitAddr(expr *astExpr) {
 		default:
 			panic2(__func__, "TBI:"+kind(typeOfX))
 		}
-		var field = lookupStructField(getStructTypeSpec(structType), expr.selectorExpr.Sel.Name)
+		if structType.e.dtype == "*astStructType" {
+			// field offsets of an anonymous struct are set on use
+			calcStructSizeAndSetFieldOffset(getStructType(structType))
+		}
+		var field = lookupStructField(getStructType(structType), expr.selectorExpr.Sel.Name)
 		var offset = getStructFieldOffset(field)
 		emitAddConst(offset, "struct head address + struct.field offset")
 	case "*astCompositeLit":
 		var knd = kind(getTypeOfExpr(expr))
 		switch knd {
-		case T_STRUCT:
-			// result of evaluation of a struct literal is its address
+		case T_STRUCT, T_ARRAY:
+			// result of evaluation of a struct or array literal is its address
 			emitExpr(expr, nil)
 		default:
 			panic2(__func__, "TBI "+ knd)
 		}
+	case "*astCallExpr":
+		switch kind(getTypeOfExpr(expr)) {
+		case T_STRUCT, T_ARRAY:
+			// a struct result is pushed as the address of its copy
+			emitExpr(expr, nil)
+		default:
+			panic2(__func__, "TBI "+expr.dtype)
+		}
 	default:
 		panic2(__func__, "TBI "+expr.dtype)
 	}
@@ -2441,6 +3362,8 @@ func isType(expr *astExpr) bool {
 		return isType(expr.parenExpr.X)
 	case "*astStarExpr":
 		return isType(expr.starExpr.X)
+	case "*astInterfaceType", "*astFuncType":
+		return true
 	default:
 		emitComment(0, "[isType][%s] is not considered a type\n", expr.dtype)
 	}
@@ -2451,27 +3374,47 @@ func isType(expr *astExpr) bool {
 
 func emitConversion(tp *Type, arg0 *astExpr) {
 	emitComment(0, "[emitConversion]\n")
+	if kind(tp) == T_INTERFACE {
+		emitExpr(arg0, tp)
+		return
+	}
 	var typeExpr = tp.e
 	switch typeExpr.dtype {
 	case "*astIdent":
 		switch typeExpr.ident.Obj {
 		case gString: // string(e)
-			switch kind(getTypeOfExpr(arg0)) {
-			case T_SLICE: // string(slice)
+			var fromType = getTypeOfExpr(arg0)
+			switch kind(fromType) {
+			case T_SLICE:
+				if kind(getElementTypeOfListType(fromType)) == T_INT32 {
+					// string(runes)
+					emitCall("runtime.slicerunetostring", []*Arg{&Arg{e: arg0}}, []*astField{&astField{Type: tString.e}})
+					return
+				}
+				// string(bytes)
 				emitExpr(arg0, nil) // slice
 				emitPopSlice()
 				fmtPrintf("  pushq %%rcx # str len\n")
 				fmtPrintf("  pushq %%rax # str ptr\n")
+			case T_STRING:
+				emitExpr(arg0, nil)
+			default: // string(r)
+				emitCall("runtime.intstring", []*Arg{&Arg{e: arg0, t: tInt}}, []*astField{&astField{Type: tString.e}})
 			}
-		case gInt, gUint8, gUint16, gUintptr: // int(e)
-			emitComment(0, "[emitConversion] to int \n")
-			emitExpr(arg0, nil)
+		case gInt, gInt8, gInt16, gInt32, gInt64, gUint, gUint8, gUint16, gUint32, gUint64, gUintptr, gFloat32, gFloat64: // int(e)
+			emitComment(0, "[emitConversion] to number \n")
+			emitNumericConversion(tp, arg0)
 		default:
 			if typeExpr.ident.Obj.Kind == astTyp {
 				if typeExpr.ident.Obj.Decl.dtype != "*astTypeSpec" {
 					panic2(__func__, "Something is wrong")
 				}
-				emitExpr(arg0, e2t(typeExpr.ident.Obj.Decl.typeSpec.Type))
+				var underlying = e2t(typeExpr.ident.Obj.Decl.typeSpec.Type)
+				if isNumericKind(kind(underlying)) {
+					emitNumericConversion(tp, arg0)
+				} else {
+					emitExpr(arg0, underlying)
+				}
 			} else{
 				panic2(__func__, "[*astIdent] TBI : "+typeExpr.ident.Obj.Name)
 			}
@@ -2484,6 +3427,11 @@ func emitConversion(tp *Type, arg0 *astExpr) {
 		if (kind(getTypeOfExpr(arg0))) != T_STRING {
 			panic2(__func__, "source type should be string")
 		}
+		if kind(e2t(arrayType.Elt)) == T_INT32 {
+			// []rune(s)
+			emitCall("runtime.stringtoslicerune", []*Arg{&Arg{e: arg0}}, getRuntimeSliceResults())
+			return
+		}
 		emitComment(2, "Conversion of string => slice \n")
 		emitExpr(arg0, nil)
 		emitPopString()
@@ -2506,12 +3454,12 @@ func emitZeroValue(t *Type) {
 		fmtPrintf("  pushq $0 # slice zero value\n")
 		fmtPrintf("  pushq $0 # slice zero value\n")
 		fmtPrintf("  pushq $0 # slice zero valuer\n")
-	case T_STRING:
-		fmtPrintf("  pushq $0 # string zero value\n")
-		fmtPrintf("  pushq $0 # string zero value\n")
-	case T_INT, T_UINTPTR, T_UINT8, T_POINTER, T_BOOL:
+	case T_STRING, T_INTERFACE:
 		fmtPrintf("  pushq $0 # %s zero value\n", kind(t))
-	case T_STRUCT:
+		fmtPrintf("  pushq $0 # %s zero value\n", kind(t))
+	case T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_FLOAT32, T_FLOAT64, T_UINTPTR, T_POINTER, T_BOOL, T_MAP, T_CHAN, T_FUNC:
+		fmtPrintf("  pushq $0 # %s zero value\n", kind(t))
+	case T_STRUCT, T_ARRAY:
 		var structSize = getSizeOfType(t)
 		fmtPrintf("# zero value of a struct. size=%s (allocating on heap)\n", Itoa(structSize))
 		emitCallMalloc(structSize)
@@ -2525,8 +3473,12 @@ func emitLen(arg *astExpr) {
 	switch kind(getTypeOfExpr(arg)) {
 	case T_ARRAY:
 		var typ = getTypeOfExpr(arg)
-		var arrayType = typ.e.arrayType
-		emitExpr(arrayType.Len, nil)
+		var arrayType = getUnderlyingType(typ).e.arrayType
+		fmtPrintf("  pushq $%d # array length\n", Itoa(evalInt(arrayType.Len)))
+	case T_POINTER:
+		// constant even for a nil pointer
+		var arrayType = getUnderlyingType(getPointedArrayType(getTypeOfExpr(arg))).e.arrayType
+		fmtPrintf("  pushq $%d # array length\n", Itoa(evalInt(arrayType.Len)))
 	case T_SLICE:
 		emitExpr(arg, nil)
 		emitPopSlice()
@@ -2535,6 +3487,18 @@ func emitLen(arg *astExpr) {
 		emitExpr(arg, nil)
 		emitPopString()
 		fmtPrintf("  pushq %%rcx # len\n")
+	case T_MAP:
+		labelid++
+		var labelNil = ".L.maplen.nil." + Itoa(labelid)
+		emitExpr(arg, nil)
+		fmtPrintf("  popq %%rax # map\n")
+		fmtPrintf("  cmpq $0, %%rax\n")
+		fmtPrintf("  je %s # len of nil map is 0\n", labelNil)
+		fmtPrintf("  movq 0(%%rax), %%rax # map.count\n")
+		fmtPrintf("  %s:\n", labelNil)
+		fmtPrintf("  pushq %%rax # len\n")
+	case T_CHAN:
+		emitChanLenOrCap(arg, 0)
 	default:
 		throw(kind(getTypeOfExpr(arg)))
 	}
@@ -2545,2087 +3509,7579 @@ func emitCap(arg *astExpr) {
 	switch kind(getTypeOfExpr(arg)) {
 	case T_ARRAY:
 		var typ = getTypeOfExpr(arg)
-		var arrayType = typ.e.arrayType
-		emitExpr(arrayType.Len, nil)
+		var arrayType = getUnderlyingType(typ).e.arrayType
+		fmtPrintf("  pushq $%d # array length\n", Itoa(evalInt(arrayType.Len)))
+	case T_POINTER:
+		var arrayType = getUnderlyingType(getPointedArrayType(getTypeOfExpr(arg))).e.arrayType
+		fmtPrintf("  pushq $%d # array length\n", Itoa(evalInt(arrayType.Len)))
 	case T_SLICE:
 		emitExpr(arg, nil)
 		emitPopSlice()
 		fmtPrintf("  pushq %%rdx # cap\n")
 	case T_STRING:
 		panic("cap() cannot accept string type")
+	case T_CHAN:
+		emitChanLenOrCap(arg, 8)
 	default:
 		throw(kind(getTypeOfExpr(arg)))
 	}
 }
 
-func emitCallMalloc(size int) {
-	fmtPrintf("  pushq $%s\n", Itoa(size))
-	// call malloc and return pointer
-	var resultList = []*astField{
-		&astField{
-			Type:    tUintptr.e,
-		},
-	}
-	fmtPrintf("  callq runtime.malloc\n") // no need to invert args orders
-	emitRevertStackPointer(intSize)
-	emitReturnedValue(resultList)
+// Push the field at offset of hchan, or 0 for a nil channel
+func emitChanLenOrCap(arg *astExpr, offset int) {
+	labelid++
+	var labelNil = ".L.chanlen.nil." + Itoa(labelid)
+	emitExpr(arg, nil)
+	fmtPrintf("  popq %%rax # chan\n")
+	fmtPrintf("  cmpq $0, %%rax\n")
+	fmtPrintf("  je %s # len and cap of nil chan are 0\n", labelNil)
+	fmtPrintf("  movq %d(%%rax), %%rax # hchan.qcount or hchan.dataqsiz\n", Itoa(offset))
+	fmtPrintf("  %s:\n", labelNil)
+	fmtPrintf("  pushq %%rax\n")
 }
 
-func emitStructLiteral(e *astCompositeLit) {
-	// allocate heap area with zero value
-	fmtPrintf("  # Struct literal\n")
-	var structType = e2t(e.Type)
-	emitZeroValue(structType) // push address of the new storage
-	var kvExpr *astKeyValueExpr
-	var i int
-	var elm *astExpr
-	for i, elm = range e.Elts {
-		assert(elm.dtype == "*astKeyValueExpr", "wrong dtype 1:" + elm.dtype, __func__)
-		kvExpr = elm.keyValueExpr
-		assert(kvExpr.Key.dtype == "*astIdent", "wrong dtype 2:" + elm.dtype, __func__)
-		var fieldName = kvExpr.Key.ident
-		fmtPrintf("  #  - [%s] : key=%s, value=%s\n", Itoa(i), fieldName.Name, kvExpr.Value.dtype)
-		var field = lookupStructField(getStructTypeSpec(structType), fieldName.Name)
-		var fieldType = e2t(field.Type)
-		var fieldOffset = getStructFieldOffset(field)
-		// push lhs address
-		emitPushStackTop(tUintptr, "address of struct heaad")
-		emitAddConst(fieldOffset, "address of struct field")
-		// push rhs value
-		emitExpr(kvExpr.Value, fieldType)
-		// assign
-		emitStore(fieldType)
-	}
+func getChanElemType(chanType *Type) *Type {
+	assert(kind(chanType) == T_CHAN, "should be a chan type", __func__)
+	return e2t(getUnderlyingType(chanType).e.chanType.Value)
 }
 
-func emitArrayLiteral(arrayType *astArrayType, arrayLen int, elts []*astExpr) {
-	var elmType = e2t(arrayType.Elt)
-	var elmSize = getSizeOfType(elmType)
-	var memSize = elmSize * arrayLen
-	emitCallMalloc(memSize) // push
-	var i int
-	var elm *astExpr
-	for i, elm = range elts {
-		// emit lhs
-		emitPushStackTop(tUintptr, "malloced address")
-		emitAddConst(elmSize*i, "malloced address + elmSize * index ("+Itoa(i)+")")
-		emitExpr(elm, elmType)
-		emitStore(elmType)
-	}
-}
-
-func emitInvertBoolValue() {
-	emitPopBool("")
-	fmtPrintf("  xor $1, %%rax\n")
-	fmtPrintf("  pushq %%rax\n")
-}
+// --- channels ---
+// The results of runtime.chanrecv
+const recvOK int = 1
+const recvClosed int = 2
 
-func emitTrue() {
-	fmtPrintf("  pushq $1 # true\n")
+// Set %rax to the address of the value pushed at offset(%rsp).
+// Structs and arrays are pushed as their addresses.
+func emitAddrOfPushedValue(t *Type, offset int) {
+	switch kind(t) {
+	case T_ARRAY, T_STRUCT:
+		fmtPrintf("  movq %d(%%rsp), %%rax # value addr\n", Itoa(offset))
+	default:
+		fmtPrintf("  leaq %d(%%rsp), %%rax # value addr\n", Itoa(offset))
+	}
 }
 
-func emitFalse() {
-	fmtPrintf("  pushq $0 # false\n")
+// <-ch
+// The received value is pushed. %rax is the result of runtime.chanrecv.
+func emitChanRecv(ch *astExpr) {
+	var elemType = getChanElemType(getTypeOfExpr(ch))
+	emitZeroValue(elemType) // where to receive into
+	emitExpr(ch, nil)
+	emitCallChanrecv(elemType)
 }
 
-type Arg struct {
-	e      *astExpr
-	t      *Type // expected type
-	offset int
+// Stack before: [value][chan]. The chan is popped and the value is overwritten by the received one.
+func emitCallChanrecv(elemType *Type) {
+	fmtPrintf("  popq %%rcx # chan\n")
+	emitAddrOfPushedValue(elemType, 0)
+	fmtPrintf("  pushq $1 # block\n")
+	fmtPrintf("  pushq %%rax # elem\n")
+	fmtPrintf("  pushq %%rcx # chan\n")
+	fmtPrintf("  callq runtime.chanrecv\n")
+	emitRevertStackPointer(ptrSize * 3)
 }
 
-func emitArgs(args []*Arg) int {
-	var totalPushedSize int
-	//var arg *astExpr
-	var arg *Arg
-	for _, arg = range args {
-		var t *Type
-		if arg.t != nil {
-			t = arg.t
-		} else {
-			t = getTypeOfExpr(arg.e)
-		}
-		arg.offset = totalPushedSize
-		totalPushedSize = totalPushedSize + getPushSizeOfType(t)
+// Store the value on the stack to lhs, or discard it for "_"
+func emitStoreStackTopTo(lhs *astExpr, t *Type) {
+	if isBlankIdent(lhs) {
+		emitRevertStackTop(t)
+		return
 	}
-	fmtPrintf("  subq $%d, %%rsp # for args\n", Itoa(totalPushedSize))
-	for _, arg = range args {
-		emitExpr(arg.e, arg.t)
+	emitAddr(lhs)
+	fmtPrintf("  popq %%rax # lhs addr\n")
+	emitInsertAddrUnderValue(getPushSizeOfType(t))
+	emitStore(t)
+}
+
+// v, ok = <-ch
+func emitChanRecvCommaOk(lhs0 *astExpr, lhs1 *astExpr, e *astUnaryExpr) {
+	var elemType = getChanElemType(getTypeOfExpr(e.X))
+	emitChanRecv(e.X)
+	fmtPrintf("  cmpq $%d, %%rax\n", Itoa(recvOK))
+	fmtPrintf("  sete %%al\n")
+	fmtPrintf("  movzbq %%al, %%rax\n")
+	fmtPrintf("  pushq %%rax # ok\n")
+	emitStoreStackTopTo(lhs1, tBool)
+	emitStoreStackTopTo(lhs0, elemType)
+}
+
+// ch <- v
+func emitChanSend(s *astSendStmt) {
+	var elemType = getChanElemType(getTypeOfExpr(s.Chan))
+	var size = getPushSizeOfType(elemType)
+	emitExpr(s.Chan, nil)
+	emitExpr(s.Value, elemType)
+	fmtPrintf("  movq %d(%%rsp), %%rcx # chan\n", Itoa(size))
+	emitAddrOfPushedValue(elemType, 0)
+	fmtPrintf("  pushq $1 # block\n")
+	fmtPrintf("  pushq %%rax # elem\n")
+	fmtPrintf("  pushq %%rcx # chan\n")
+	fmtPrintf("  callq runtime.chansend\n")
+	emitRevertStackPointer(ptrSize*3 + size + ptrSize)
+}
+
+// for v := range ch
+func emitRangeChan(stmt *astRangeStmt) {
+	labelid++
+	var labelCond = ".L.range.cond." + Itoa(labelid)
+	var labelBody = ".L.range.body." + Itoa(labelid)
+	var labelPost = ".L.range.post." + Itoa(labelid)
+	var labelExit = ".L.range.exit." + Itoa(labelid)
+
+	stmt.labelPost = labelPost
+	stmt.labelExit = labelExit
+	var elemType = getChanElemType(getTypeOfExpr(stmt.X))
+
+	// The channel is evaluated once
+	emitComment(2, "ForRange Initialization\n")
+	emitVariableAddr(stmt.indexvar)
+	emitExpr(stmt.X, nil)
+	emitStore(tUintptr)
+
+	// Condition: receive until the channel is closed
+	emitComment(2, "ForRange Condition\n")
+	fmtPrintf("  %s:\n", labelCond)
+	emitZeroValue(elemType)
+	emitVariableAddr(stmt.indexvar)
+	emitLoad(tUintptr)
+	emitCallChanrecv(elemType)
+	fmtPrintf("  cmpq $%d, %%rax\n", Itoa(recvClosed))
+	fmtPrintf("  jne %s # jmp if received\n", labelBody)
+	emitRevertStackTop(elemType)
+	fmtPrintf("  jmp %s\n", labelExit)
+
+	// assign the received value to the variable
+	fmtPrintf("  %s:\n", labelBody)
+	if stmt.Key != nil && !isBlankIdent(stmt.Key) {
+		if stmt.Tok == ":=" {
+			emitNewBox(stmt.Key.ident.Obj.Variable, elemType)
+		}
+		emitStoreStackTopTo(stmt.Key, elemType)
+	} else {
+		emitRevertStackTop(elemType)
+	}
+
+	// Body
+	emitComment(2, "ForRange Body\n")
+	emitStmt(blockStmt2Stmt(stmt.Body))
+
+	// Post statement
+	emitComment(2, "ForRange Post statement\n")
+	fmtPrintf("  %s:\n", labelPost) // used for "continue"
+	fmtPrintf("  jmp %s\n", labelCond)
+	fmtPrintf("  %s:\n", labelExit)
+}
+
+// The layout of runtime.scase
+const scaseSize int = 32
+const caseSend int = 1
+const caseRecv int = 2
+
+// The channel and the value of a select case, which are evaluated before selecting
+type selectCase struct {
+	clause   *astCommClause
+	dir      int
+	ch       *astExpr
+	elemType *Type
+	offset   int // offset of the pushed value from the top of the pushed cases
+	label    string
+}
+
+func newSelectCase(cc *astCommClause) *selectCase {
+	var sc = &selectCase{}
+	sc.clause = cc
+	var comm = cc.Comm
+	var recv *astExpr
+	switch comm.dtype {
+	case "*astSendStmt":
+		sc.dir = caseSend
+		sc.ch = comm.sendStmt.Chan
+	case "*astExprStmt":
+		recv = comm.exprStmt.X
+	case "*astAssignStmt":
+		recv = comm.assignStmt.Rhs[0]
+	default:
+		panic2(__func__, "unexpected comm clause "+comm.dtype)
+	}
+	if recv != nil {
+		assert(recv.dtype == "*astUnaryExpr" && recv.unaryExpr.Op == "<-", "select case must be receive or send", __func__)
+		sc.dir = caseRecv
+		sc.ch = recv.unaryExpr.X
+	}
+	sc.elemType = getChanElemType(getTypeOfExpr(sc.ch))
+	return sc
+}
+
+// select { cases }
+// The channels and values of all cases are pushed, followed by an array of runtime.scase.
+// Then runtime.selectgo returns the index of the chosen case, or -1 for default.
+func emitSelectStmt(s *astSelectStmt) {
+	labelid++
+	var labelEnd = ".L.select." + Itoa(labelid) + ".exit"
+	s.labelExit = labelEnd
+	var labelDefault = labelEnd
+	var cases []*selectCase
+	var defaultClause *astCommClause
+	var c *astStmt
+	var sc *selectCase
+	for _, c = range s.Body.List {
+		if c.commClause.Comm == nil {
+			defaultClause = c.commClause
+			labelid++
+			labelDefault = ".L.select.default." + Itoa(labelid)
+			continue
+		}
+		sc = newSelectCase(c.commClause)
+		labelid++
+		sc.label = ".L.select.case." + Itoa(labelid)
+		cases = append(cases, sc)
 	}
-	fmtPrintf("  addq $%d, %%rsp # for args\n", Itoa(totalPushedSize))
 
-	for _, arg = range args {
-		var t *Type
-		if arg.t != nil {
-			t = arg.t
+	// push [chan][value] of each case
+	var pushed int
+	for _, sc = range cases {
+		emitExpr(sc.ch, nil)
+		if sc.dir == caseSend {
+			emitExpr(sc.clause.Comm.sendStmt.Value, sc.elemType)
 		} else {
-			t = getTypeOfExpr(arg.e)
-		}
-		switch kind(t) {
-		case T_BOOL, T_INT, T_UINT8, T_POINTER, T_UINTPTR:
-			fmtPrintf("  movq %d-8(%%rsp) , %%rax # load\n", Itoa(-arg.offset))
-			fmtPrintf("  movq %%rax, %d(%%rsp) # store\n", Itoa(+arg.offset))
-		case T_STRING:
-			fmtPrintf("  movq %d-16(%%rsp), %%rax\n", Itoa(-arg.offset))
-			fmtPrintf("  movq %d-8(%%rsp), %%rcx\n", Itoa(-arg.offset))
-			fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(+arg.offset))
-			fmtPrintf("  movq %%rcx, %d+8(%%rsp)\n", Itoa(+arg.offset))
-		case T_SLICE:
-			fmtPrintf("  movq %d-24(%%rsp), %%rax\n", Itoa(-arg.offset)) // arg1: slc.ptr
-			fmtPrintf("  movq %d-16(%%rsp), %%rcx\n", Itoa(-arg.offset)) // arg1: slc.len
-			fmtPrintf("  movq %d-8(%%rsp), %%rdx\n", Itoa(-arg.offset))  // arg1: slc.cap
-			fmtPrintf("  movq %%rax, %d+0(%%rsp)\n", Itoa(+arg.offset))  // arg1: slc.ptr
-			fmtPrintf("  movq %%rcx, %d+8(%%rsp)\n", Itoa(+arg.offset))  // arg1: slc.len
-			fmtPrintf("  movq %%rdx, %d+16(%%rsp)\n", Itoa(+arg.offset)) // arg1: slc.cap
-		default:
-			throw(kind(t))
+			emitZeroValue(sc.elemType) // where to receive into
 		}
+		pushed = pushed + ptrSize + getPushSizeOfType(sc.elemType)
+		sc.offset = pushed - getPushSizeOfType(sc.elemType)
 	}
 
-	return totalPushedSize
-}
-
-func prepareArgs(funcType *astFuncType, receiver *astExpr, eArgs []*astExpr) []*Arg {
-	if funcType == nil {
-		panic("no funcType")
+	// build the scase array below them
+	var n = len(cases)
+	var arraySize = n * scaseSize
+	var frameSize = pushed + arraySize
+	var i int
+	fmtPrintf("  subq $%d, %%rsp # scases\n", Itoa(arraySize))
+	for i, sc = range cases {
+		var valueOffset = frameSize - sc.offset - getPushSizeOfType(sc.elemType)
+		var base = i * scaseSize
+		fmtPrintf("  movq %d(%%rsp), %%rax # chan\n", Itoa(valueOffset+getPushSizeOfType(sc.elemType)))
+		fmtPrintf("  movq %%rax, %d(%%rsp) # scase.c\n", Itoa(base))
+		emitAddrOfPushedValue(sc.elemType, valueOffset)
+		fmtPrintf("  movq %%rax, %d(%%rsp) # scase.elem\n", Itoa(base+8))
+		fmtPrintf("  movq $%d, %d(%%rsp) # scase.dir\n", Itoa(sc.dir), Itoa(base+16))
+		fmtPrintf("  movq $0, %d(%%rsp) # scase.received\n", Itoa(base+24))
+	}
+	fmtPrintf("  leaq 0(%%rsp), %%rax # scases\n")
+	if defaultClause != nil {
+		fmtPrintf("  pushq $0 # block\n")
+	} else {
+		fmtPrintf("  pushq $1 # block\n")
 	}
-	var params = funcType.Params.List
-	var variadicArgs []*astExpr
-	var variadicElp *astEllipsis
-	var args []*Arg
-	var eArg *astExpr
-	var param *astField
-	var argIndex int
-	var arg *Arg
-	var lenParams = len(params)
-	for argIndex, eArg = range eArgs {
-		emitComment(0, "[%s][*astIdent][default] loop idx %s, len params %s\n", __func__, Itoa(argIndex), Itoa(lenParams))
-		if argIndex < lenParams {
-			param = params[argIndex]
-			if param.Type.dtype == "*astEllipsis" {
-				variadicElp = param.Type.ellipsis
-				variadicArgs = make([]*astExpr, 0, 20)
+	fmtPrintf("  pushq $%d # number of cases\n", Itoa(n))
+	fmtPrintf("  pushq %%rax # scases\n")
+	fmtPrintf("  callq runtime.selectgo\n")
+	emitRevertStackPointer(ptrSize * 3)
+
+	// assign the received values while the frame is alive, then jump to the body
+	for i, sc = range cases {
+		labelid++
+		var labelNext = ".L.select.next." + Itoa(labelid)
+		fmtPrintf("  cmpq $%d, %%rax\n", Itoa(i))
+		fmtPrintf("  jne %s\n", labelNext)
+		if sc.clause.Comm.dtype == "*astAssignStmt" {
+			var as = sc.clause.Comm.assignStmt
+			var valueOffset = frameSize - sc.offset - getPushSizeOfType(sc.elemType)
+			var lhs *astExpr
+			for _, lhs = range as.Lhs {
+				if isNewShortVar(lhs, as) {
+					emitNewBox(lhs.ident.Obj.Variable, getTypeOfExpr(lhs))
+				}
+			}
+			if len(as.Lhs) == 2 && !isBlankIdent(as.Lhs[1]) {
+				emitAddr(as.Lhs[1])
+				fmtPrintf("  pushq %d(%%rsp) # scase.received\n", Itoa(i*scaseSize+24+ptrSize))
+				emitStore(tBool)
+			}
+			if !isBlankIdent(as.Lhs[0]) {
+				emitAddr(as.Lhs[0])
+				emitAddrOfPushedValue(sc.elemType, valueOffset+ptrSize)
+				fmtPrintf("  pushq %%rax # received value addr\n")
+				emitLoad(sc.elemType)
+				emitStore(sc.elemType)
 			}
 		}
-		if variadicElp != nil {
-			variadicArgs = append(variadicArgs, eArg)
-			continue
-		}
-
-		var paramType = e2t(param.Type)
-		arg = &Arg{}
-		arg.e = eArg
-		arg.t = paramType
-		args = append(args, arg)
+		emitRevertStackPointer(frameSize)
+		fmtPrintf("  jmp %s\n", sc.label)
+		fmtPrintf("  %s:\n", labelNext)
 	}
+	emitRevertStackPointer(frameSize)
+	fmtPrintf("  jmp %s\n", labelDefault)
 
-	if variadicElp != nil {
-		// collect args as a slice
-		var sliceType = &astArrayType{}
-		sliceType.Elt = variadicElp.Elt
-		var eSliceType = &astExpr{}
-		eSliceType.dtype = "*astArrayType"
-		eSliceType.arrayType = sliceType
-		var sliceLiteral = &astCompositeLit{}
-		sliceLiteral.Type = eSliceType
-		sliceLiteral.Elts = variadicArgs
-		var eSliceLiteral = &astExpr{}
-		eSliceLiteral.compositeLit = sliceLiteral
-		eSliceLiteral.dtype = "*astCompositeLit"
-		var _arg = &Arg{}
-		_arg.e = eSliceLiteral
-		_arg.t = e2t(eSliceType)
-		args = append(args, _arg)
-	} else if len(args) < len(params) {
-		// Add nil as a variadic arg
-		emitComment(0, "len(args)=%s, len(params)=%s\n", Itoa(len(args)), Itoa(len(params)))
-		var param = params[len(args)]
-		if param == nil {
-			panic2(__func__, "param should not be nil")
+	for _, sc = range cases {
+		fmtPrintf("  %s:\n", sc.label)
+		var _s *astStmt
+		for _, _s = range sc.clause.Body {
+			emitStmt(_s)
 		}
-		if param.Type == nil {
-			panic2(__func__, "param.Type should not be nil")
+		fmtPrintf("  jmp %s\n", labelEnd)
+	}
+	if defaultClause != nil {
+		fmtPrintf("  %s:\n", labelDefault)
+		var _s *astStmt
+		for _, _s = range defaultClause.Body {
+			emitStmt(_s)
 		}
-		assert(param.Type.dtype == "*astEllipsis", "internal error", __func__)
-
-		var _arg = &Arg{}
-		_arg.e = eNil
-		_arg.t = e2t(param.Type)
-		args = append(args, _arg)
 	}
+	fmtPrintf("  %s:\n", labelEnd)
+}
 
-	if receiver != nil {
-		var receiverAndArgs []*Arg = []*Arg{
-			&Arg{
-				e: receiver,
-				t: getTypeOfExpr(receiver),
-			},
+// Map key kinds known by the runtime
+const mapKeyMem int = 0       // compare and hash key bytes
+const mapKeyString int = 1    // compare and hash string contents
+const mapKeyComposite int = 2 // a struct or an array which contains strings, floats or interfaces
+const mapKeyFloat32 int = 3   // compare and hash by value
+const mapKeyFloat64 int = 4
+const mapKeyInterface int = 5 // compare by the dynamic type and value
+
+func getMapKeyKind(keyType *Type) int {
+	switch kind(keyType) {
+	case T_STRING:
+		return mapKeyString
+	case T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_UINTPTR, T_POINTER, T_BOOL, T_CHAN:
+		return mapKeyMem
+	case T_FLOAT32:
+		return mapKeyFloat32
+	case T_FLOAT64:
+		return mapKeyFloat64
+	case T_STRUCT, T_ARRAY:
+		if !isComparable(keyType) {
+			panic2(__func__, "invalid map key type "+typeString(keyType))
 		}
-		var a *Arg
-		for _, a = range args {
-			receiverAndArgs = append(receiverAndArgs, a)
+		if isMemComparable(keyType) {
+			return mapKeyMem
 		}
-		return receiverAndArgs
+		return mapKeyComposite
+	default:
+		panic2(__func__, "invalid map key type "+typeString(keyType))
 	}
-
-	return args
+	return 0
 }
 
-func emitCall(symbol string, args []*Arg, results []*astField) {
-	emitComment(0, "[%s] %s\n", __func__, symbol)
-	var totalPushedSize = emitArgs(args)
-	fmtPrintf("  callq %s\n", symbol)
-	emitRevertStackPointer(totalPushedSize)
-	emitReturnedValue(results)
+// The layout of a composite key is the number of its strings, floats and interfaces,
+// followed by the offset and the key kind of each of them.
+// It is also used to compare the values of the type boxed in interfaces.
+type keyLayout struct {
+	label   string
+	key     string
+	offsets []int
+	kinds   []int
 }
 
-func emitReturnedValue(resultList []*astField) {
-	switch len(resultList) {
-	case 0:
-		// do nothing
-	case 1:
-		var retval0 = resultList[0]
-		var knd = kind(e2t(retval0.Type))
-		switch knd {
-		case T_STRING:
-			fmtPrintf("  pushq %%rdi # str len\n")
-			fmtPrintf("  pushq %%rax # str ptr\n")
-		case T_BOOL, T_INT, T_UINTPTR, T_POINTER:
-			fmtPrintf("  pushq %%rax\n")
-		case T_SLICE:
-			fmtPrintf("  pushq %%rsi # slice cap\n")
-			fmtPrintf("  pushq %%rdi # slice len\n")
-			fmtPrintf("  pushq %%rax # slice ptr\n")
-		default:
-			panic2(__func__, "Unexpected kind="+knd)
+var keyLayouts []*keyLayout
+
+func getKeyLayout(keyType *Type) *keyLayout {
+	var key = typeString(keyType)
+	var kl *keyLayout
+	for _, kl = range keyLayouts {
+		if kl.key == key {
+			return kl
 		}
-	default:
-		panic2(__func__,"multipul returned values is not supported ")
 	}
+	kl = &keyLayout{
+		label: "keylayout." + Itoa(len(keyLayouts)),
+		key:   key,
+	}
+	setKeyParts(kl, keyType, 0)
+	keyLayouts = append(keyLayouts, kl)
+	return kl
 }
 
-func emitFuncall(fun *astExpr, eArgs []*astExpr) {
-	var symbol string
-	var receiver *astExpr
-	var funcType *astFuncType
-	switch fun.dtype {
-	case "*astIdent":
-		emitComment(0, "[%s][*astIdent]\n", __func__)
-		var fnIdent = fun.ident
-		switch fnIdent.Obj {
-		case gLen:
-			var arg = eArgs[0]
-			emitLen(arg)
-			return
-		case gCap:
-			var arg = eArgs[0]
-			emitCap(arg)
-			return
-		case gNew:
-			var typeArg = e2t(eArgs[0])
-			var size = getSizeOfType(typeArg)
-			emitCallMalloc(size)
-			return
-		case gMake:
-			var typeArg = e2t(eArgs[0])
-			switch kind(typeArg) {
-			case T_SLICE:
-				// make([]T, ...)
-				var arrayType = typeArg.e.arrayType
-				//assert(ok, "should be *ast.ArrayType")
-				var elmSize = getSizeOfType(e2t(arrayType.Elt))
-				var numlit = newNumberLiteral(elmSize)
-				var eNumLit = &astExpr{}
-				eNumLit.dtype = "*astBasicLit"
-				eNumLit.basicLit = numlit
+// Collect the parts of a key which are not compared by their bytes
+func setKeyParts(kl *keyLayout, t *Type, base int) {
+	switch kind(t) {
+	case T_STRING, T_FLOAT32, T_FLOAT64:
+		kl.offsets = append(kl.offsets, base)
+		kl.kinds = append(kl.kinds, getMapKeyKind(t))
+	case T_INTERFACE:
+		kl.offsets = append(kl.offsets, base)
+		kl.kinds = append(kl.kinds, mapKeyInterface)
+	case T_ARRAY:
+		var arrayType = getUnderlyingType(t).e.arrayType
+		var elemType = e2t(arrayType.Elt)
+		var elemSize = getSizeOfType(elemType)
+		var i int
+		for i = 0; i < evalInt(arrayType.Len); i++ {
+			setKeyParts(kl, elemType, base+i*elemSize)
+		}
+	case T_STRUCT:
+		var field *astField
+		for _, field = range getUnderlyingType(t).e.structType.Fields.List {
+			setKeyParts(kl, e2t(field.Type), base+getStructFieldOffset(field))
+		}
+	}
+}
 
-				var args []*Arg = []*Arg{
-					// elmSize
-					&Arg{
-						e: eNumLit,
-						t: tInt,
-					},
-					// len
-					&Arg{
-						e: eArgs[1],
-						t: tInt,
-					},
-					// cap
-					&Arg{
-						e: eArgs[2],
-						t: tInt,
-					},
-				}
+func getMapKeyType(mapType *Type) *Type {
+	assert(kind(mapType) == T_MAP, "should be a map type", __func__)
+	return e2t(getUnderlyingType(mapType).e.mapType.Key)
+}
 
+func getMapValueType(mapType *Type) *Type {
+	assert(kind(mapType) == T_MAP, "should be a map type", __func__)
+	return e2t(getUnderlyingType(mapType).e.mapType.Value)
+}
 
-				var resultList = []*astField{
-					&astField{
-						Type: generalSlice,
-					},
-				}
-				emitCall("runtime.makeSlice", args, resultList)
-				return
-			default:
-				panic2(__func__, "TBI")
-			}
+// push a new empty map
+func emitMakeMap(mapType *Type) {
+	var keyType = getMapKeyType(mapType)
+	var valueType = getMapValueType(mapType)
+	var keyKind = getMapKeyKind(keyType)
+	if keyKind == mapKeyComposite {
+		fmtPrintf("  leaq %s(%%rip), %%rax # key layout\n", getKeyLayout(keyType).label)
+		fmtPrintf("  pushq %%rax\n")
+	} else {
+		fmtPrintf("  pushq $0 # key layout\n")
+	}
+	fmtPrintf("  pushq $%d # key kind\n", Itoa(keyKind))
+	fmtPrintf("  pushq $%d # value size\n", Itoa(getSizeOfType(valueType)))
+	fmtPrintf("  pushq $%d # key size\n", Itoa(getSizeOfType(keyType)))
+	fmtPrintf("  callq runtime.makeMap\n")
+	emitRevertStackPointer(intSize*3 + ptrSize)
+	fmtPrintf("  pushq %%rax # map\n")
+}
 
-			return
-		case gAppend:
-			var sliceArg = eArgs[0]
-			var elemArg = eArgs[1]
-			var elmType = getElementTypeOfListType(getTypeOfExpr(sliceArg))
-			var elmSize = getSizeOfType(elmType)
-
-			var args []*Arg = []*Arg{
-				// slice
-				&Arg{
-					e: sliceArg,
-				},
-				// element
-				&Arg{
-					e: elemArg,
-					t: elmType,
-				},
-			}
+// Call a runtime map function like f(m *Map, key uintptr) uintptr.
+// Stack before: [map][key][`offset` bytes of something]
+// Map and key are left on the stack. The result is in %rax.
+func emitCallMapRuntime(symbol string, keyType *Type, offset int) {
+	switch kind(keyType) {
+	case T_ARRAY, T_STRUCT:
+		fmtPrintf("  movq %d(%%rsp), %%rax # key addr\n", Itoa(offset))
+	default:
+		fmtPrintf("  leaq %d(%%rsp), %%rax # key addr\n", Itoa(offset))
+	}
+	fmtPrintf("  pushq %%rax # key addr\n")
+	var mapOffset = offset + getPushSizeOfType(keyType) + ptrSize
+	fmtPrintf("  pushq %d(%%rsp) # map\n", Itoa(mapOffset))
+	fmtPrintf("  callq %s\n", symbol)
+	emitRevertStackPointer(ptrSize * 2)
+}
 
-			var symbol string
-			switch elmSize {
-			case 1:
-				symbol = "runtime.append1"
-			case 8:
-				symbol = "runtime.append8"
-			case 16:
-				symbol = "runtime.append16"
-			case 24:
-				symbol = "runtime.append24"
-			default:
-				panic2(__func__, "Unexpected elmSize")
-			}
-			var resultList = []*astField{
-				&astField{
-					Type: generalSlice,
-				},
-			}
-			emitCall(symbol, args, resultList)
-			return
-		case gPanic:
-			symbol = "runtime.panic"
-			_args := []*Arg{&Arg{
-				e: eArgs[0],
-			}}
-			emitCall(symbol, _args, nil)
+// Push the value at the address in %rax, or the zero value if the address is 0.
+func emitLoadOrZeroValue(t *Type) {
+	labelid++
+	var labelZero = ".L.zero." + Itoa(labelid)
+	var labelEnd = ".L.zero.end." + Itoa(labelid)
+	fmtPrintf("  cmpq $0, %%rax\n")
+	fmtPrintf("  je %s # not found\n", labelZero)
+	fmtPrintf("  pushq %%rax\n")
+	emitLoad(t)
+	fmtPrintf("  jmp %s\n", labelEnd)
+	fmtPrintf("  %s:\n", labelZero)
+	emitZeroValue(t)
+	fmtPrintf("  %s:\n", labelEnd)
+}
+
+// Insert the address in %rax under the value of `size` bytes on the stack top,
+// so that emitStore can be applied.
+func emitInsertAddrUnderValue(size int) {
+	fmtPrintf("  subq $8, %%rsp\n")
+	var i int
+	for i = 0; i < size; i = i + 8 {
+		fmtPrintf("  movq %d(%%rsp), %%rcx\n", Itoa(i+8))
+		fmtPrintf("  movq %%rcx, %d(%%rsp)\n", Itoa(i))
+	}
+	fmtPrintf("  movq %%rax, %d(%%rsp) # addr\n", Itoa(size))
+}
+
+// m[k]
+func emitMapGet(e *astIndexExpr) {
+	var mapType = getTypeOfExpr(e.X)
+	var keyType = getMapKeyType(mapType)
+	emitExpr(e.X, nil)
+	emitExpr(e.Index, keyType)
+	emitCallMapRuntime("runtime.mapaccess1", keyType, 0)
+	emitRevertStackPointer(ptrSize + getPushSizeOfType(keyType))
+	emitLoadOrZeroValue(getMapValueType(mapType))
+}
+
+// Address of m[k]. A new entry is created if k is not in m.
+func emitMapElementAddr(e *astIndexExpr) {
+	var mapType = getTypeOfExpr(e.X)
+	var keyType = getMapKeyType(mapType)
+	emitExpr(e.X, nil)
+	emitExpr(e.Index, keyType)
+	emitCallMapRuntime("runtime.mapassign", keyType, 0)
+	emitRevertStackPointer(ptrSize + getPushSizeOfType(keyType))
+	fmtPrintf("  pushq %%rax # addr of map element\n")
+}
+
+// m[k] = v
+// Unlike emitAssign, the value is evaluated before the entry is created.
+func emitMapSet(e *astIndexExpr, rhs *astExpr) {
+	var mapType = getTypeOfExpr(e.X)
+	var keyType = getMapKeyType(mapType)
+	var valueType = getMapValueType(mapType)
+	emitExpr(e.X, nil)
+	emitExpr(e.Index, keyType)
+	emitExpr(rhs, valueType)
+	var valueSize = getPushSizeOfType(valueType)
+	emitCallMapRuntime("runtime.mapassign", keyType, valueSize)
+	emitInsertAddrUnderValue(valueSize)
+	emitStore(valueType)
+	emitRevertStackPointer(ptrSize + getPushSizeOfType(keyType))
+}
+
+// v, ok = m[k]
+func emitMapCommaOk(lhs0 *astExpr, lhs1 *astExpr, e *astIndexExpr) {
+	var mapType = getTypeOfExpr(e.X)
+	var keyType = getMapKeyType(mapType)
+	var valueType = getMapValueType(mapType)
+	emitExpr(e.X, nil)
+	emitExpr(e.Index, keyType)
+	emitCallMapRuntime("runtime.mapaccess1", keyType, 0)
+	emitRevertStackPointer(ptrSize + getPushSizeOfType(keyType))
+	fmtPrintf("  pushq %%rax # addr of map element or 0\n")
+	if !isBlankIdent(lhs0) {
+		emitAddr(lhs0)
+		fmtPrintf("  movq 8(%%rsp), %%rax # addr of map element or 0\n")
+		emitLoadOrZeroValue(valueType)
+		emitStore(valueType)
+	}
+	if !isBlankIdent(lhs1) {
+		emitAddr(lhs1)
+		fmtPrintf("  movq 8(%%rsp), %%rax # addr of map element or 0\n")
+		fmtPrintf("  cmpq $0, %%rax\n")
+		fmtPrintf("  setne %%al\n")
+		fmtPrintf("  movzbq %%al, %%rax\n")
+		fmtPrintf("  pushq %%rax # ok\n")
+		emitStore(getTypeOfExpr(lhs1))
+	}
+	emitRevertStackPointer(ptrSize)
+}
+
+func emitMapLiteral(e *astCompositeLit) {
+	var mapType = e2t(e.Type)
+	var keyType = getMapKeyType(mapType)
+	var valueType = getMapValueType(mapType)
+	var valueSize = getPushSizeOfType(valueType)
+	emitMakeMap(mapType)
+	var elm *astExpr
+	for _, elm = range e.Elts {
+		assert(elm.dtype == "*astKeyValueExpr", "map literal element should be key: value", __func__)
+		emitPushStackTop(mapType, "map")
+		emitExpr(elm.keyValueExpr.Key, keyType)
+		emitExpr(elm.keyValueExpr.Value, valueType)
+		emitCallMapRuntime("runtime.mapassign", keyType, valueSize)
+		emitInsertAddrUnderValue(valueSize)
+		emitStore(valueType)
+		emitRevertStackPointer(ptrSize + getPushSizeOfType(keyType))
+	}
+}
+
+func isBlankIdent(e *astExpr) bool {
+	return e.dtype == "*astIdent" && e.ident.Name == "_"
+}
+
+func isNil(e *astExpr) bool {
+	return e.dtype == "*astIdent" && e.ident.Obj == gNil
+}
+
+// Types whose values are stored in the data word of interfaces as they are
+func isDirectIface(t *Type) bool {
+	switch kind(t) {
+	case T_POINTER, T_MAP, T_CHAN, T_FUNC:
+		return true
+	}
+	return false
+}
+
+// Box the value of e into an interface value of ifaceType
+func emitConvertToInterface(e *astExpr, fromType *Type, ifaceType *Type) {
+	emitComment(2, "Convert %s to %s\n", typeString(fromType), typeString(ifaceType))
+	if isDirectIface(fromType) {
+		emitExpr(e, nil) // data
+	} else {
+		emitCallMalloc(getSizeOfType(fromType))
+		emitPushStackTop(tUintptr, "boxed value")
+		emitExpr(e, nil)
+		emitStore(fromType) // data
+	}
+	var tab = getItab(ifaceType, fromType)
+	fmtPrintf("  leaq %s(%%rip), %%rax # itab\n", tab.label)
+	fmtPrintf("  pushq %%rax\n")
+}
+
+// Replace the itab of the interface value on the stack top
+// by calling a runtime function like f(inter *_type, itab uintptr) uintptr.
+func emitCallItabRuntime(symbol string, ifaceType *Type) {
+	fmtPrintf("  leaq %s(%%rip), %%rax # interface type\n", getTypeDescriptor(ifaceType).label)
+	fmtPrintf("  pushq %%rax\n")
+	fmtPrintf("  callq %s\n", symbol)
+	emitRevertStackPointer(ptrSize * 2)
+	fmtPrintf("  pushq %%rax # new itab\n")
+}
+
+// Convert the interface value on the stack top to another interface type
+func emitConvertInterface(fromType *Type, toType *Type) {
+	emitComment(2, "Convert %s to %s\n", typeString(fromType), typeString(toType))
+	emitCallItabRuntime("runtime.convI2I", toType)
+}
+
+// Push the value of the dynamic type t from the data word in %rcx
+func emitUnboxValue(t *Type) {
+	fmtPrintf("  pushq %%rcx # data\n")
+	if !isDirectIface(t) {
+		emitLoad(t)
+	}
+}
+
+// x.(T)
+func emitTypeAssertExpr(e *astTypeAssertExpr) {
+	var t = e2t(e.Type)
+	var ifaceType = getTypeOfExpr(e.X)
+	emitExpr(e.X, nil)
+	if kind(t) == T_INTERFACE {
+		emitCallItabRuntime("runtime.assertI2I", t)
+		return
+	}
+	labelid++
+	var labelFail = ".L.typeassert.fail." + Itoa(labelid)
+	var labelEnd = ".L.typeassert.end." + Itoa(labelid)
+	fmtPrintf("  popq %%rax # itab\n")
+	fmtPrintf("  popq %%rcx # data\n")
+	fmtPrintf("  leaq %s(%%rip), %%rdx # %s\n", getTypeDescriptor(t).label, typeString(t))
+	fmtPrintf("  cmpq $0, %%rax\n")
+	fmtPrintf("  je %s # nil\n", labelFail)
+	fmtPrintf("  movq 0(%%rax), %%rax # dynamic type\n")
+	fmtPrintf("  cmpq %%rdx, %%rax\n")
+	fmtPrintf("  jne %s\n", labelFail)
+	emitUnboxValue(t)
+	fmtPrintf("  jmp %s\n", labelEnd)
+	fmtPrintf("  %s:\n", labelFail)
+	fmtPrintf("  leaq %s(%%rip), %%rcx # %s\n", getTypeDescriptor(ifaceType).label, typeString(ifaceType))
+	fmtPrintf("  pushq %%rcx # interface type\n")
+	fmtPrintf("  pushq %%rdx # asserted type\n")
+	fmtPrintf("  pushq %%rax # dynamic type or nil\n")
+	fmtPrintf("  callq runtime.panicdottype\n")
+	fmtPrintf("  %s:\n", labelEnd)
+}
+
+// v, ok = x.(T)
+func emitTypeAssertCommaOk(lhs0 *astExpr, lhs1 *astExpr, e *astTypeAssertExpr) {
+	var t = e2t(e.Type)
+	emitExpr(e.X, nil)
+	// make [data][ok] on the stack, where ok is the new itab for interface types
+	if kind(t) == T_INTERFACE {
+		emitCallItabRuntime("runtime.assertI2I2", t)
+	} else {
+		labelid++
+		var labelNil = ".L.typeassert.nil." + Itoa(labelid)
+		var labelEnd = ".L.typeassert.end." + Itoa(labelid)
+		fmtPrintf("  popq %%rax # itab\n")
+		fmtPrintf("  cmpq $0, %%rax\n")
+		fmtPrintf("  je %s # nil\n", labelNil)
+		fmtPrintf("  movq 0(%%rax), %%rax # dynamic type\n")
+		fmtPrintf("  leaq %s(%%rip), %%rdx # %s\n", getTypeDescriptor(t).label, typeString(t))
+		fmtPrintf("  cmpq %%rdx, %%rax\n")
+		fmtPrintf("  sete %%al\n")
+		fmtPrintf("  movzbq %%al, %%rax\n")
+		fmtPrintf("  %s:\n", labelNil)
+		fmtPrintf("  pushq %%rax # ok\n")
+		fmtPrintf("  %s:\n", labelEnd)
+	}
+	if !isBlankIdent(lhs0) {
+		labelid++
+		var labelZero = ".L.typeassert.zero." + Itoa(labelid)
+		var labelStore = ".L.typeassert.store." + Itoa(labelid)
+		emitAddr(lhs0)
+		fmtPrintf("  movq 8(%%rsp), %%rax # ok\n")
+		fmtPrintf("  movq 16(%%rsp), %%rcx # data\n")
+		fmtPrintf("  cmpq $0, %%rax\n")
+		fmtPrintf("  je %s\n", labelZero)
+		if kind(t) == T_INTERFACE {
+			fmtPrintf("  pushq %%rcx # data\n")
+			fmtPrintf("  pushq %%rax # itab\n")
+		} else {
+			emitUnboxValue(t)
+		}
+		fmtPrintf("  jmp %s\n", labelStore)
+		fmtPrintf("  %s:\n", labelZero)
+		emitZeroValue(t)
+		fmtPrintf("  %s:\n", labelStore)
+		emitStore(t)
+	}
+	if !isBlankIdent(lhs1) {
+		emitAddr(lhs1)
+		fmtPrintf("  movq 8(%%rsp), %%rax # ok\n")
+		fmtPrintf("  cmpq $0, %%rax\n")
+		fmtPrintf("  setne %%al\n")
+		fmtPrintf("  movzbq %%al, %%rax\n")
+		fmtPrintf("  pushq %%rax # ok\n")
+		emitStore(getTypeOfExpr(lhs1))
+	}
+	emitRevertStackPointer(ptrSize * 2)
+}
+
+// Call a function value. The callee gets the closure in %rdx.
+func emitFuncValueCall(fun *astExpr, eArgs []*astExpr, hasEllipsis bool) {
+	var funcType = getUnderlyingType(getTypeOfExpr(fun)).e.funcType
+	emitExpr(fun, nil)
+	var args = prepareArgs(funcType, nil, eArgs, hasEllipsis)
+	var resultList = getFuncResults(funcType)
+	emitAllocResultArea(resultList)
+	var totalSize = emitArgs(args)
+	fmtPrintf("  movq %d(%%rsp), %%rdx # closure\n", Itoa(totalSize+getResultAreaSize(resultList)))
+	fmtPrintf("  callq *0(%%rdx)\n")
+	if hasResultArea(resultList) {
+		emitRevertStackPointer(totalSize)
+		emitDropUnderResultArea(resultList, ptrSize)
+		return
+	}
+	emitRevertStackPointer(totalSize + ptrSize)
+	emitReturnedValue(resultList)
+}
+
+// Make a closure, which is a function pointer followed by pointers to captured variables
+func emitFuncLit(lit *astFuncLit) {
+	var fnc = lit.fnc
+	var symbol = getFuncSymbol(pkg.name, fnc.name)
+	if len(fnc.freeVars) == 0 {
+		fmtPrintf("  leaq %s(%%rip), %%rax # func value\n", getFuncValueSymbol(symbol))
+		fmtPrintf("  pushq %%rax\n")
+		return
+	}
+	emitCallMalloc(ptrSize * (1 + len(fnc.freeVars)))
+	fmtPrintf("  movq 0(%%rsp), %%rcx # closure\n")
+	fmtPrintf("  leaq %s(%%rip), %%rax\n", symbol)
+	fmtPrintf("  movq %%rax, 0(%%rcx) # func\n")
+	var i int
+	var v *Variable
+	for i, v = range fnc.freeVars {
+		emitVariableAddr(v)
+		fmtPrintf("  popq %%rax\n")
+		fmtPrintf("  movq 0(%%rsp), %%rcx # closure\n")
+		fmtPrintf("  movq %%rax, %d(%%rcx) # captured variable \"%s\"\n", Itoa(ptrSize*(i+1)), v.name)
+	}
+}
+
+// A method value x.m is a closure, which holds the receiver and is called through a wrapper.
+// The closure of a method of an interface holds the method found in the itab and the data.
+type methodValueWrapper struct {
+	label     string
+	symbol    string // of the method, or "" to call the method in the closure
+	rcvOffset int    // of the receiver in the closure
+	rcvSize   int
+	funcType  *astFuncType
+}
+
+var methodValueWrappers []*methodValueWrapper
+
+func emitMethodValue(e *astSelectorExpr, method *Method) {
+	labelid++
+	var wrapper = &methodValueWrapper{}
+	wrapper.label = ".L.methodvalue." + Itoa(labelid)
+	wrapper.funcType = method.funcType
+	methodValueWrappers = append(methodValueWrappers, wrapper)
+	var rcvType = getTypeOfExpr(e.X)
+	if kind(rcvType) == T_INTERFACE {
+		var index = getInterfaceMethodIndex(rcvType, e.Sel.Name)
+		wrapper.rcvOffset = ptrSize * 2
+		wrapper.rcvSize = ptrSize
+		emitExpr(e.X, nil)
+		emitCallMalloc(ptrSize * 3)
+		fmtPrintf("  movq 0(%%rsp), %%rcx # closure\n")
+		fmtPrintf("  movq 8(%%rsp), %%rax # itab\n")
+		fmtPrintf("  movq %d(%%rax), %%rax # method %s\n", Itoa(ptrSize*(index+1)), e.Sel.Name)
+		fmtPrintf("  movq %%rax, 8(%%rcx)\n")
+		fmtPrintf("  movq 16(%%rsp), %%rax # data\n")
+		fmtPrintf("  movq %%rax, 16(%%rcx)\n")
+		fmtPrintf("  leaq %s(%%rip), %%rax\n", wrapper.label)
+		fmtPrintf("  movq %%rax, 0(%%rcx) # func\n")
+		fmtPrintf("  popq %%rcx\n")
+		emitRevertStackPointer(ptrSize * 2)
+		fmtPrintf("  pushq %%rcx\n")
+		return
+	}
+	var receiver = adjustReceiver(e.X, method)
+	rcvType = getTypeOfExpr(receiver)
+	wrapper.symbol = getFuncSymbol(pkg.name, getMethodSymbol(method))
+	wrapper.rcvOffset = ptrSize
+	wrapper.rcvSize = getArgSizeOfType(rcvType)
+	emitCallMalloc(ptrSize + wrapper.rcvSize)
+	fmtPrintf("  movq 0(%%rsp), %%rcx # closure\n")
+	fmtPrintf("  leaq %s(%%rip), %%rax\n", wrapper.label)
+	fmtPrintf("  movq %%rax, 0(%%rcx) # func\n")
+	fmtPrintf("  leaq %d(%%rcx), %%rax # receiver\n", Itoa(ptrSize))
+	fmtPrintf("  pushq %%rax\n")
+	emitExpr(receiver, rcvType)
+	emitStore(rcvType)
+}
+
+// The receiver type T or *T of a method expression
+func getRecvTypeOfMethodExpr(e *astSelectorExpr) *Type {
+	var x = e.X
+	for x.dtype == "*astParenExpr" {
+		x = x.parenExpr.X
+	}
+	return e2t(x)
+}
+
+// A method expression I.m of an interface, or T.m of a method promoted to the struct type T,
+// is a thunk which takes the receiver by value and calls the method with a pointer receiver
+type methodExprThunk struct {
+	label    string
+	symbol   string // of the method with a pointer receiver, or "" to call the method in the itab
+	index    int    // of the method in the itab
+	rcvSize  int
+	funcType *astFuncType
+}
+
+var methodExprThunks []*methodExprThunk
+
+// The method which a method expression selects, and the embedded fields to reach it
+func getMethodExprSelection(e *astSelectorExpr) *selection {
+	var rcvType = getRecvTypeOfMethodExpr(e)
+	var sels = findSelections(rcvType, e.Sel.Name)
+	if len(sels) != 1 || sels[0].method == nil {
+		panic2(__func__, typeString(rcvType)+"."+e.Sel.Name+" is not a method")
+	}
+	return sels[0]
+}
+
+func emitMethodExprThunk(thunk *methodExprThunk) {
+	methodExprThunks = append(methodExprThunks, thunk)
+	fmtPrintf("  leaq %s(%%rip), %%rax # func value\n", getFuncValueSymbol(thunk.label))
+	fmtPrintf("  pushq %%rax\n")
+}
+
+// A method expression T.m or (*T).m is the method itself, which takes the receiver as the first argument
+func emitMethodExpr(e *astSelectorExpr) {
+	var rcvType = getRecvTypeOfMethodExpr(e)
+	var sel = getMethodExprSelection(e)
+	var method = sel.method
+	var symbol string
+	labelid++
+	var thunk = &methodExprThunk{}
+	thunk.label = ".L.methodexpr." + Itoa(labelid)
+	thunk.funcType = method.funcType
+	if kind(rcvType) == T_INTERFACE {
+		thunk.index = getInterfaceMethodIndex(rcvType, e.Sel.Name)
+		thunk.rcvSize = ptrSize * 2
+		emitMethodExprThunk(thunk)
+		return
+	}
+	if len(sel.path) > 0 {
+		// the wrapper of the promoted method takes a pointer to the outer struct
+		var structType = rcvType
+		if kind(rcvType) == T_POINTER {
+			structType = e2t(rcvType.e.starExpr.X)
+		}
+		var promoted = getPromotedMethod(structType, sel)
+		symbol = getFuncSymbol(pkg.name, "$"+promoted.rcvNamedType.Name+"."+promoted.name)
+		if kind(rcvType) != T_POINTER {
+			thunk.symbol = symbol
+			thunk.rcvSize = getArgSizeOfType(rcvType)
+			emitMethodExprThunk(thunk)
 			return
 		}
+	} else if kind(rcvType) == T_POINTER && !method.isPtrMethod {
+		// the wrapper of the value method
+		symbol = getFuncSymbol(pkg.name, "$"+method.rcvNamedType.Name+"."+method.name)
+	} else {
+		symbol = getFuncSymbol(pkg.name, getMethodSymbol(method))
+	}
+	fmtPrintf("  leaq %s(%%rip), %%rax # func value\n", getFuncValueSymbol(symbol))
+	fmtPrintf("  pushq %%rax\n")
+}
 
-		var fn = fun.ident
-		if fn.Name == "print" {
-			emitExpr(eArgs[0], nil)
-			fmtPrintf("  callq runtime.printstring\n")
-			fmtPrintf("  addq $%s, %%rsp # revert \n", Itoa(16))
-			return
+// The type of T.m or (*T).m, which has the receiver as the first parameter
+func getMethodExprType(e *astSelectorExpr) *Type {
+	var rcvType = getRecvTypeOfMethodExpr(e)
+	var sel = getMethodExprSelection(e)
+	var method = sel.method
+	if method.isPtrMethod && kind(rcvType) != T_POINTER && !isPromotedToValue(sel) {
+		panic2(__func__, "invalid method expression "+typeString(rcvType)+"."+method.name+" (needs pointer receiver (*"+typeString(rcvType)+")."+method.name+")")
+	}
+	var params = &astFieldList{}
+	params.List = append(params.List, &astField{
+		Type: rcvType.e,
+	})
+	var field *astField
+	for _, field = range method.funcType.Params.List {
+		params.List = append(params.List, field)
+	}
+	return e2t(&astExpr{
+		dtype: "*astFuncType",
+		funcType: &astFuncType{
+			Params:  params,
+			Results: method.funcType.Results,
+		},
+	})
+}
+
+// The method which x.m selects, or nil if it is a field
+func lookupSelectedMethod(e *astSelectorExpr) *Method {
+	if e.X.dtype == "*astIdent" && e.X.ident.Obj.Kind == "Pkg" {
+		return nil
+	}
+	var t *Type
+	if isType(e.X) {
+		t = getRecvTypeOfMethodExpr(e)
+	} else {
+		t = getTypeOfExpr(e.X)
+	}
+	var sels = findSelections(t, e.Sel.Name)
+	if len(sels) != 1 {
+		return nil
+	}
+	return sels[0].method
+}
+
+// x.f is a struct field rather than a method
+func isFieldSelector(e *astSelectorExpr) bool {
+	resolvePromotedSelector(e)
+	var t = getTypeOfExpr(e.X)
+	if kind(t) == T_POINTER {
+		t = e2t(getUnderlyingType(t).e.starExpr.X)
+	}
+	if kind(t) != T_STRUCT {
+		return false
+	}
+	var field *astField
+	for _, field = range getStructFields(getStructType(t)) {
+		if field.Name.Name == e.Sel.Name {
+			return true
 		}
+	}
+	return false
+}
+
+// y of "x := y.(type)" or "y.(type)"
+func getTypeSwitchSubject(s *astTypeSwitchStmt) *astExpr {
+	var e *astExpr
+	if s.Assign.dtype == "*astAssignStmt" {
+		e = s.Assign.assignStmt.Rhs[0]
+	} else {
+		e = s.Assign.exprStmt.X
+	}
+	return e.typeAssertExpr.X
+}
+
+// Jump to labelCase if the subject of a type switch matches the case e
+func emitTypeSwitchCaseMatch(subject *Variable, subjectType *Type, e *astExpr, labelCase string) {
+	emitVariableAddr(subject)
+	emitLoad(subjectType)
+	if isNil(e) {
+		fmtPrintf("  popq %%rax # itab\n")
+		fmtPrintf("  popq %%rcx # data\n")
+		fmtPrintf("  cmpq $0, %%rax\n")
+		fmtPrintf("  je %s # case nil\n", labelCase)
+		return
+	}
+	var t = e2t(e)
+	if kind(t) == T_INTERFACE {
+		emitCallItabRuntime("runtime.assertI2I2", t)
+		fmtPrintf("  popq %%rax # itab\n")
+		fmtPrintf("  popq %%rcx # data\n")
+		fmtPrintf("  cmpq $0, %%rax\n")
+		fmtPrintf("  jne %s # case %s\n", labelCase, typeString(t))
+		return
+	}
+	labelid++
+	var labelNext = ".L.typeswitch.next." + Itoa(labelid)
+	fmtPrintf("  popq %%rax # itab\n")
+	fmtPrintf("  popq %%rcx # data\n")
+	fmtPrintf("  cmpq $0, %%rax\n")
+	fmtPrintf("  je %s # nil\n", labelNext)
+	fmtPrintf("  movq 0(%%rax), %%rax # dynamic type\n")
+	fmtPrintf("  leaq %s(%%rip), %%rcx # %s\n", getTypeDescriptor(t).label, typeString(t))
+	fmtPrintf("  cmpq %%rcx, %%rax\n")
+	fmtPrintf("  je %s # case %s\n", labelCase, typeString(t))
+	fmtPrintf("  %s:\n", labelNext)
+}
 
-		if fn.Name == "makeSlice1" || fn.Name == "makeSlice8" || fn.Name == "makeSlice16" || fn.Name == "makeSlice24" {
-			fn.Name = "makeSlice"
+// Set v of "switch v := x.(type)" at the beginning of a clause
+func emitTypeSwitchVar(subject *Variable, subjectType *Type, spec *astValueSpec) {
+	var t = e2t(spec.Type)
+	emitNewBox(spec.Name.Obj.Variable, t)
+	emitVariableAddr(spec.Name.Obj.Variable)
+	emitVariableAddr(subject)
+	emitLoad(subjectType)
+	if kind(t) == T_INTERFACE {
+		if typeString(t) != typeString(subjectType) {
+			emitConvertInterface(subjectType, t)
 		}
-		// general function call
-		symbol = getFuncSymbol(pkg.name, fn.Name)
-		emitComment(0, "[%s][*astIdent][default] start\n", __func__)
+	} else {
+		fmtPrintf("  popq %%rax # itab\n")
+		fmtPrintf("  popq %%rcx # data\n")
+		emitUnboxValue(t)
+	}
+	emitStore(t)
+}
+
+// Call a method through an interface.
+// The receiver is passed as an interface value, and then its itab is dropped
+// so that the callee gets the data word as the receiver.
+func emitInterfaceMethodCall(ifaceType *Type, receiver *astExpr, methodName *astIdent, eArgs []*astExpr, hasEllipsis bool) {
+	var method = lookupMethod(ifaceType, methodName)
+	var args = prepareArgs(method.funcType, receiver, eArgs, hasEllipsis)
+	var resultList = getFuncResults(method.funcType)
+	var index = getInterfaceMethodIndex(ifaceType, methodName.Name)
+	emitAllocResultArea(resultList)
+	var totalSize = emitArgs(args)
+	fmtPrintf("  movq 0(%%rsp), %%rax # itab\n")
+	fmtPrintf("  movq %d(%%rax), %%rax # method %s\n", Itoa(ptrSize*(index+1)), methodName.Name)
+	fmtPrintf("  addq $%d, %%rsp # drop itab\n", Itoa(ptrSize))
+	fmtPrintf("  callq *%%rax\n")
+	emitRevertStackPointer(totalSize - ptrSize)
+	emitReturnedValue(resultList)
+}
 
-		var obj = fn.Obj
-		var decl = obj.Decl
-		if decl == nil {
-			panic2(__func__, "[*astCallExpr] decl is nil")
+// Take the address of or dereference the receiver to fit the method
+func adjustReceiver(receiver *astExpr, method *Method) *astExpr {
+	var isPtr = kind(getTypeOfExpr(receiver)) == T_POINTER
+	if method.isPtrMethod && !isPtr {
+		if !isAddressable(receiver) {
+			panic2(__func__, "cannot call pointer method "+method.name+" on "+typeString(getTypeOfExpr(receiver)))
 		}
-		if decl.dtype != "*astFuncDecl" {
-			panic2(__func__, "[*astCallExpr] decl.dtype is invalid")
+		return &astExpr{
+			dtype: "*astUnaryExpr",
+			unaryExpr: &astUnaryExpr{
+				Op: "&",
+				X:  receiver,
+			},
 		}
-		var fndecl = decl.funcDecl
-		if fndecl == nil {
-			panic2(__func__, "[*astCallExpr] fndecl is nil")
+	}
+	if !method.isPtrMethod && isPtr {
+		return &astExpr{
+			dtype: "*astStarExpr",
+			starExpr: &astStarExpr{
+				X: receiver,
+			},
 		}
-		if fndecl.Type == nil {
-			panic2(__func__, "[*astCallExpr] fndecl.Type is nil")
+	}
+	return receiver
+}
+
+// Whether &x is allowed
+func isAddressable(e *astExpr) bool {
+	switch e.dtype {
+	case "*astIdent":
+		return e.ident.Obj.Kind == astVar
+	case "*astParenExpr":
+		return isAddressable(e.parenExpr.X)
+	case "*astStarExpr":
+		return true
+	case "*astIndexExpr":
+		switch kind(getTypeOfExpr(e.indexExpr.X)) {
+		case T_SLICE, T_POINTER:
+			return true
+		case T_ARRAY:
+			return isAddressable(e.indexExpr.X)
 		}
-		funcType = fndecl.Type
 	case "*astSelectorExpr":
-		var selectorExpr = fun.selectorExpr
-		if selectorExpr.X.dtype != "*astIdent" {
-			panic2(__func__, "TBI selectorExpr.X.dtype="+selectorExpr.X.dtype)
+		if e.selectorExpr.X.dtype == "*astIdent" && e.selectorExpr.X.ident.Obj.Kind == "Pkg" {
+			return true
 		}
-		symbol = selectorExpr.X.ident.Name + "." + selectorExpr.Sel.Name
-		switch symbol {
-		case "unsafe.Pointer":
-			emitExpr(eArgs[0], nil)
-			return
-		case "os.Exit":
-			funcType = funcTypeOsExit
-		case "syscall.Open":
-			funcType = funcTypeSyscallOpen
-		case "syscall.Read":
-			funcType = funcTypeSyscallRead
-		case "syscall.Write":
-			funcType = funcTypeSyscallWrite
-		case "syscall.Syscall":
-			funcType = funcTypeSyscallSyscall
-		default:
-			// Assume method call
-			receiver = selectorExpr.X
-			var receiverType = getTypeOfExpr(receiver)
-			var method = lookupMethod(receiverType, selectorExpr.Sel)
-			funcType = method.funcType
-			var subsymbol = getMethodSymbol(method)
-			symbol = getFuncSymbol(pkg.name, subsymbol)
+		if kind(getTypeOfExpr(e.selectorExpr.X)) == T_POINTER {
+			return true
 		}
-	case "*astParenExpr":
-		panic2(__func__, "[astParenExpr] TBI ")
-	default:
-		panic2(__func__, "TBI fun.dtype="+fun.dtype)
+		return isAddressable(e.selectorExpr.X)
 	}
+	return false
+}
 
-	var args = prepareArgs(funcType, receiver, eArgs)
-	var resultList []*astField
-	if funcType.Results != nil {
-		resultList = funcType.Results.List
+// If x == y is a comparison of interfaces, returns the interface type.
+func getInterfaceTypeOfComparison(e *astBinaryExpr) *Type {
+	var r *Type
+	if e.Op != "==" && e.Op != "!=" {
+		return r
+	}
+	if !isNil(e.X) && kind(getTypeOfExpr(e.X)) == T_INTERFACE {
+		return getTypeOfExpr(e.X)
 	}
-	emitCall(symbol, args, resultList)
+	if !isNil(e.Y) && kind(getTypeOfExpr(e.Y)) == T_INTERFACE {
+		return getTypeOfExpr(e.Y)
+	}
+	return r
 }
 
-func emitExpr(e *astExpr, forceType *Type) {
-	emitComment(2, "[emitExpr] dtype=%s\n", e.dtype)
-	switch e.dtype {
-	case "*astIdent":
-		var ident = e.ident
-		if ident.Obj == nil {
-			panic2(__func__, "ident unresolved:"+ident.Name)
-		}
-		switch e.ident.Obj {
-		case gTrue:
-			emitTrue()
-			return
-		case gFalse:
-			emitFalse()
-			return
-		case gNil:
-			if forceType == nil {
-				panic2(__func__, "Type is required to emit nil")
-			}
-			switch kind(forceType) {
-			case T_SLICE, T_POINTER:
-				emitZeroValue(forceType)
-			default:
-				panic2(__func__, "Unexpected kind="+kind(forceType))
+func emitCallMalloc(size int) {
+	fmtPrintf("  pushq $%s\n", Itoa(size))
+	// call malloc and return pointer
+	var resultList = []*astField{
+		&astField{
+			Type:    tUintptr.e,
+		},
+	}
+	fmtPrintf("  callq runtime.malloc\n") // no need to invert args orders
+	emitRevertStackPointer(intSize)
+	emitReturnedValue(resultList)
+}
+
+func emitStructLiteral(e *astCompositeLit) {
+	// allocate heap area with zero value
+	fmtPrintf("  # Struct literal\n")
+	var structType = e2t(e.Type)
+	emitZeroValue(structType) // push address of the new storage
+	var fields = getStructFields(getStructType(structType))
+	var i int
+	var elm *astExpr
+	for i, elm = range e.Elts {
+		var field *astField
+		var value *astExpr
+		if elm.dtype == "*astKeyValueExpr" {
+			var kvExpr = elm.keyValueExpr
+			assert(kvExpr.Key.dtype == "*astIdent", "wrong dtype 2:" + elm.dtype, __func__)
+			field = lookupStructField(getStructType(structType), kvExpr.Key.ident.Name)
+			value = kvExpr.Value
+		} else {
+			// T{v1, v2, ...} lists all the fields in order
+			if len(e.Elts) != len(fields) {
+				panic2(__func__, "too few or too many values in "+typeString(structType)+" literal")
 			}
-			return
+			field = fields[i]
+			value = elm
 		}
-		switch ident.Obj.Kind {
-		case astVar:
-			emitAddr(e)
-			var t = getTypeOfExpr(e)
-			emitLoad(t)
-		case astCon:
-			var valSpec = ident.Obj.Decl.valueSpec
-			assert(valSpec != nil, "valSpec should not be nil", __func__)
-			assert(valSpec.Value != nil, "valSpec should not be nil", __func__)
-			assert(valSpec.Value.dtype == "*astBasicLit", "const value should be a literal", __func__)
-			var t *Type
-			if valSpec.Type != nil {
-				t = e2t(valSpec.Type)
-			} else {
-				t = forceType
-			}
-			emitExpr(valSpec.Value, t)
-		case astTyp:
-			panic2(__func__, "[*astIdent] Kind Typ should not come here")
-		default:
-			panic2(__func__, "[*astIdent] unknown Kind="+ident.Obj.Kind+" Name="+ident.Obj.Name)
+		fmtPrintf("  #  - [%s] : key=%s, value=%s\n", Itoa(i), field.Name.Name, value.dtype)
+		var fieldType = e2t(field.Type)
+		var fieldOffset = getStructFieldOffset(field)
+		// push lhs address
+		emitPushStackTop(tUintptr, "address of struct heaad")
+		emitAddConst(fieldOffset, "address of struct field")
+		// push rhs value
+		emitExpr(value, fieldType)
+		// assign
+		emitStore(fieldType)
+	}
+}
+
+// The number of elements of an array or slice literal,
+// where an element with a key is placed at the index of the key
+func getListLiteralLength(elts []*astExpr) int {
+	var length int
+	var index int
+	var elm *astExpr
+	for _, elm = range elts {
+		if elm.dtype == "*astKeyValueExpr" {
+			index = evalInt(elm.keyValueExpr.Key)
 		}
-	case "*astIndexExpr":
-		emitAddr(e)
-		emitLoad(getTypeOfExpr(e))
-	case "*astStarExpr":
-		emitAddr(e)
-		emitLoad(getTypeOfExpr(e))
-	case "*astSelectorExpr":
-		emitAddr(e)
-		emitLoad(getTypeOfExpr(e))
-	case "*astBasicLit":
-		//		emitComment(0, "basicLit.Kind = %s \n", e.basicLit.Kind)
-		switch e.basicLit.Kind {
-		case "INT":
-			var ival = Atoi(e.basicLit.Value)
-			fmtPrintf("  pushq $%d # number literal\n", Itoa(ival))
-		case "STRING":
-			var sl = getStringLiteral(e.basicLit)
-			if sl.strlen == 0 {
-				// zero value
-				emitZeroValue(tString)
-			} else {
-				fmtPrintf("  pushq $%d # str len\n", Itoa(sl.strlen))
-				fmtPrintf("  leaq %s, %%rax # str ptr\n", sl.label)
-				fmtPrintf("  pushq %%rax # str ptr\n")
-			}
-		case "CHAR":
-			var val = e.basicLit.Value
-			var char = val[1]
-			if val[1] == '\\' {
-				switch val[2] {
-				case '\'':
-					char = '\''
-				case 'n':
-					char = '\n'
-				case '\\':
-					char = '\\'
-				case 't':
-					char = '\t'
-				case 'r':
-					char = '\r'
-				}
+		index++
+		if index > length {
+			length = index
+		}
+	}
+	return length
+}
+
+func emitArrayLiteral(arrayType *astArrayType, arrayLen int, elts []*astExpr) {
+	var elmType = e2t(arrayType.Elt)
+	var elmSize = getSizeOfType(elmType)
+	var memSize = elmSize * arrayLen
+	emitCallMalloc(memSize) // push
+	var index int
+	var elm *astExpr
+	for _, elm = range elts {
+		if elm.dtype == "*astKeyValueExpr" {
+			// [...]T{k: v}
+			index = evalInt(elm.keyValueExpr.Key)
+			elm = elm.keyValueExpr.Value
+		}
+		if index >= arrayLen {
+			panic2(__func__, "index "+Itoa(index)+" out of bounds [0:"+Itoa(arrayLen)+"]")
+		}
+		// emit lhs
+		emitPushStackTop(tUintptr, "malloced address")
+		emitAddConst(elmSize*index, "malloced address + elmSize * index ("+Itoa(index)+")")
+		emitExpr(elm, elmType)
+		emitStore(elmType)
+		index++
+	}
+}
+
+func emitInvertBoolValue() {
+	emitPopBool("")
+	fmtPrintf("  xor $1, %%rax\n")
+	fmtPrintf("  pushq %%rax\n")
+}
+
+func emitTrue() {
+	fmtPrintf("  pushq $1 # true\n")
+}
+
+func emitFalse() {
+	fmtPrintf("  pushq $0 # false\n")
+}
+
+type Arg struct {
+	e      *astExpr
+	t      *Type // expected type
+	offset int
+}
+
+func emitArgs(args []*Arg) int {
+	var totalArgSize int
+	var totalPushedSize int
+	//var arg *astExpr
+	var arg *Arg
+	for _, arg = range args {
+		var t *Type
+		if arg.t != nil {
+			t = arg.t
+		} else {
+			t = getTypeOfExpr(arg.e)
+		}
+		arg.offset = totalArgSize
+		totalArgSize = totalArgSize + getArgSizeOfType(t)
+		totalPushedSize = totalPushedSize + getPushSizeOfType(t)
+	}
+	fmtPrintf("  subq $%d, %%rsp # for args\n", Itoa(totalArgSize))
+	for _, arg = range args {
+		emitExpr(arg.e, arg.t)
+	}
+	fmtPrintf("  addq $%d, %%rsp # for args\n", Itoa(totalPushedSize))
+
+	// pushed values are below the stack top
+	var pushedOffset int
+	for _, arg = range args {
+		var t *Type
+		if arg.t != nil {
+			t = arg.t
+		} else {
+			t = getTypeOfExpr(arg.e)
+		}
+		var pushed = -pushedOffset
+		pushedOffset = pushedOffset + getPushSizeOfType(t)
+		switch kind(t) {
+		case T_BOOL, T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_FLOAT32, T_FLOAT64, T_POINTER, T_UINTPTR, T_MAP, T_CHAN, T_FUNC:
+			fmtPrintf("  movq %d-8(%%rsp) , %%rax # load\n", Itoa(pushed))
+			fmtPrintf("  movq %%rax, %d(%%rsp) # store\n", Itoa(+arg.offset))
+		case T_STRING, T_INTERFACE:
+			fmtPrintf("  movq %d-16(%%rsp), %%rax\n", Itoa(pushed))
+			fmtPrintf("  movq %d-8(%%rsp), %%rcx\n", Itoa(pushed))
+			fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(+arg.offset))
+			fmtPrintf("  movq %%rcx, %d+8(%%rsp)\n", Itoa(+arg.offset))
+		case T_SLICE:
+			fmtPrintf("  movq %d-24(%%rsp), %%rax\n", Itoa(pushed)) // arg1: slc.ptr
+			fmtPrintf("  movq %d-16(%%rsp), %%rcx\n", Itoa(pushed)) // arg1: slc.len
+			fmtPrintf("  movq %d-8(%%rsp), %%rdx\n", Itoa(pushed))  // arg1: slc.cap
+			fmtPrintf("  movq %%rax, %d+0(%%rsp)\n", Itoa(+arg.offset))  // arg1: slc.ptr
+			fmtPrintf("  movq %%rcx, %d+8(%%rsp)\n", Itoa(+arg.offset))  // arg1: slc.len
+			fmtPrintf("  movq %%rdx, %d+16(%%rsp)\n", Itoa(+arg.offset)) // arg1: slc.cap
+		case T_STRUCT, T_ARRAY:
+			// copy the whole value
+			fmtPrintf("  movq %d-8(%%rsp), %%rsi # addr of %s\n", Itoa(pushed), kind(t))
+			var i int
+			for i = 0; i < getArgSizeOfType(t); i = i + 8 {
+				fmtPrintf("  movq %d(%%rsi), %%rax\n", Itoa(i))
+				fmtPrintf("  movq %%rax, %d+%d(%%rsp)\n", Itoa(+arg.offset), Itoa(i))
 			}
-			fmtPrintf("  pushq $%d # convert char literal to int\n", Itoa(int(char)))
 		default:
-			panic2(__func__, "[*astBasicLit] TBI : "+e.basicLit.Kind)
+			throw(kind(t))
 		}
-	case "*astCallExpr":
-		var fun = e.callExpr.Fun
-		emitComment(0, "[%s][*astCallExpr]\n", __func__)
-		if isType(fun) {
-			emitConversion(e2t(fun), e.callExpr.Args[0])
-			return
+	}
+
+	return totalArgSize
+}
+
+func prepareArgs(funcType *astFuncType, receiver *astExpr, eArgs []*astExpr, hasEllipsis bool) []*Arg {
+	if funcType == nil {
+		panic("no funcType")
+	}
+	var params = funcType.Params.List
+	var variadicArgs []*astExpr
+	var variadicElp *astEllipsis
+	var args []*Arg
+	var eArg *astExpr
+	var param *astField
+	var argIndex int
+	var arg *Arg
+	var lenParams = len(params)
+	for argIndex, eArg = range eArgs {
+		emitComment(0, "[%s][*astIdent][default] loop idx %s, len params %s\n", __func__, Itoa(argIndex), Itoa(lenParams))
+		if argIndex < lenParams {
+			param = params[argIndex]
+			// f(s...) passes the slice as it is
+			if param.Type.dtype == "*astEllipsis" && !hasEllipsis {
+				variadicElp = param.Type.ellipsis
+				variadicArgs = make([]*astExpr, 0, 20)
+			}
 		}
-		emitFuncall(fun, e.callExpr.Args)
-	case "*astParenExpr":
-		emitExpr(e.parenExpr.X, nil)
-	case "*astSliceExpr":
-		var list = e.sliceExpr.X
-		var listType = getTypeOfExpr(list)
-		emitExpr(e.sliceExpr.High, nil)
-		emitExpr(e.sliceExpr.Low, nil)
-		fmtPrintf("  popq %%rcx # low\n")
-		fmtPrintf("  popq %%rax # high\n")
-		fmtPrintf("  subq %%rcx, %%rax # high - low\n")
-		switch kind(listType) {
-		case T_SLICE, T_ARRAY:
-			fmtPrintf("  pushq %%rax # cap\n")
-			fmtPrintf("  pushq %%rax # len\n")
-		case T_STRING:
-			fmtPrintf("  pushq %%rax # len\n")
-			// no cap
-		default:
-			panic2(__func__, "Unknown kind="+kind(listType))
+		if variadicElp != nil {
+			variadicArgs = append(variadicArgs, eArg)
+			continue
+		}
+
+		var paramType = e2t(param.Type)
+		arg = &Arg{}
+		arg.e = eArg
+		arg.t = paramType
+		args = append(args, arg)
+	}
+
+	if variadicElp != nil {
+		// collect args as a slice
+		var sliceType = &astArrayType{}
+		sliceType.Elt = variadicElp.Elt
+		var eSliceType = &astExpr{}
+		eSliceType.dtype = "*astArrayType"
+		eSliceType.arrayType = sliceType
+		var sliceLiteral = &astCompositeLit{}
+		sliceLiteral.Type = eSliceType
+		sliceLiteral.Elts = variadicArgs
+		var eSliceLiteral = &astExpr{}
+		eSliceLiteral.compositeLit = sliceLiteral
+		eSliceLiteral.dtype = "*astCompositeLit"
+		var _arg = &Arg{}
+		_arg.e = eSliceLiteral
+		_arg.t = e2t(eSliceType)
+		args = append(args, _arg)
+	} else if len(args) < len(params) {
+		// Add nil as a variadic arg
+		emitComment(0, "len(args)=%s, len(params)=%s\n", Itoa(len(args)), Itoa(len(params)))
+		var param = params[len(args)]
+		if param == nil {
+			panic2(__func__, "param should not be nil")
+		}
+		if param.Type == nil {
+			panic2(__func__, "param.Type should not be nil")
+		}
+		assert(param.Type.dtype == "*astEllipsis", "internal error", __func__)
+
+		var _arg = &Arg{}
+		_arg.e = eNil
+		_arg.t = e2t(param.Type)
+		args = append(args, _arg)
+	}
+
+	if receiver != nil {
+		var receiverAndArgs []*Arg = []*Arg{
+			&Arg{
+				e: receiver,
+				t: getTypeOfExpr(receiver),
+			},
+		}
+		var a *Arg
+		for _, a = range args {
+			receiverAndArgs = append(receiverAndArgs, a)
 		}
+		return receiverAndArgs
+	}
+
+	return args
+}
+
+func emitCall(symbol string, args []*Arg, results []*astField) {
+	emitComment(0, "[%s] %s\n", __func__, symbol)
+	emitAllocResultArea(results)
+	var totalPushedSize = emitArgs(args)
+	fmtPrintf("  callq %s\n", symbol)
+	emitRevertStackPointer(totalPushedSize)
+	emitReturnedValue(results)
+}
+
+// A single result is returned in registers.
+// Multiple results are stored by the callee into the result area,
+// which the caller reserves right above the arguments.
+func hasResultArea(results []*astField) bool {
+	return len(results) > 1
+}
+
+func getResultAreaSize(results []*astField) int {
+	if !hasResultArea(results) {
+		return 0
+	}
+	var size int
+	var field *astField
+	for _, field = range results {
+		size = size + getArgSizeOfType(e2t(field.Type))
+	}
+	return size
+}
+
+// Offset of the i-th result in the result area. The first result is at the lowest address.
+func getResultOffset(results []*astField, i int) int {
+	var offset int
+	var j int
+	for j = 0; j < i; j++ {
+		offset = offset + getArgSizeOfType(e2t(results[j].Type))
+	}
+	return offset
+}
+
+func emitAllocResultArea(results []*astField) {
+	if hasResultArea(results) {
+		fmtPrintf("  subq $%d, %%rsp # result area\n", Itoa(getResultAreaSize(results)))
+	}
+}
+
+// Drop `size` bytes under the result area on the stack top
+func emitDropUnderResultArea(results []*astField, size int) {
+	var i int
+	for i = getResultAreaSize(results) - 8; i >= 0; i = i - 8 {
+		fmtPrintf("  movq %d(%%rsp), %%rax\n", Itoa(i))
+		fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(i+size))
+	}
+	emitRevertStackPointer(size)
+}
+
+func getFuncResults(funcType *astFuncType) []*astField {
+	var results []*astField
+	if funcType.Results != nil {
+		results = funcType.Results.List
+	}
+	return results
+}
+
+// The results of runtime functions which return a slice as (uintptr, int, int).
+// The result area has the same layout as a pushed slice.
+func getRuntimeSliceResults() []*astField {
+	return []*astField{
+		&astField{
+			Type: tUintptr.e,
+		},
+		&astField{
+			Type: tInt.e,
+		},
+		&astField{
+			Type: tInt.e,
+		},
+	}
+}
+
+func emitReturnedValue(resultList []*astField) {
+	switch len(resultList) {
+	case 0:
+		// do nothing
+	case 1:
+		var retval0 = resultList[0]
+		var knd = kind(e2t(retval0.Type))
+		switch knd {
+		case T_STRING, T_INTERFACE:
+			fmtPrintf("  pushq %%rdi # str len\n")
+			fmtPrintf("  pushq %%rax # str ptr\n")
+		case T_BOOL, T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_FLOAT32, T_FLOAT64, T_UINTPTR, T_POINTER, T_MAP, T_CHAN, T_FUNC:
+			fmtPrintf("  pushq %%rax\n")
+		case T_STRUCT, T_ARRAY:
+			fmtPrintf("  pushq %%rax # addr of the copy\n")
+		case T_SLICE:
+			fmtPrintf("  pushq %%rsi # slice cap\n")
+			fmtPrintf("  pushq %%rdi # slice len\n")
+			fmtPrintf("  pushq %%rax # slice ptr\n")
+		default:
+			panic2(__func__, "Unexpected kind="+knd)
+		}
+	default:
+		// the result area is left on the stack
+	}
+}
+
+// []byte(s) for the runtime, which takes slices of any element type
+func newBytesOfString(e *astExpr) *astExpr {
+	return &astExpr{
+		dtype: "*astCallExpr",
+		callExpr: &astCallExpr{
+			Fun:  tSliceOfBytes.e,
+			Args: []*astExpr{e},
+		},
+	}
+}
+
+// append(s, x, y) makes room for the elements in the runtime, and then stores them in place.
+// append(s, t...) and append(b, str...) are done in the runtime.
+func emitAppend(eArgs []*astExpr, hasEllipsis bool) {
+	var sliceArg = eArgs[0]
+	var elmType = getElementTypeOfListType(getTypeOfExpr(sliceArg))
+	var elmSize = getSizeOfType(elmType)
+	if hasEllipsis {
+		var src = eArgs[1]
+		if kind(getTypeOfExpr(src)) == T_STRING {
+			src = newBytesOfString(src)
+		}
+		var args = []*Arg{
+			&Arg{e: sliceArg},
+			&Arg{e: newIntLiteralExpr(elmSize), t: tInt},
+			&Arg{e: src},
+		}
+		emitCall("runtime.appendslice", args, getRuntimeSliceResults())
+		return
+	}
+
+	var elms = eArgs[1:len(eArgs)]
+	var args = []*Arg{
+		&Arg{e: sliceArg},
+		&Arg{e: newIntLiteralExpr(elmSize), t: tInt},
+		&Arg{e: newIntLiteralExpr(len(elms)), t: tInt},
+	}
+	emitCall("runtime.growslice", args, getRuntimeSliceResults())
+	var i int
+	var elm *astExpr
+	for i, elm = range elms {
+		fmtPrintf("  movq 8(%%rsp), %%rax # new len\n")
+		fmtPrintf("  subq $%d, %%rax # index of the element\n", Itoa(len(elms)-i))
+		fmtPrintf("  imulq $%d, %%rax\n", Itoa(elmSize))
+		fmtPrintf("  addq 0(%%rsp), %%rax # addr of the element\n")
+		fmtPrintf("  pushq %%rax\n")
+		emitExpr(elm, elmType)
+		emitStore(elmType)
+	}
+}
+
+// copy(dst, src)
+func emitCopy(dst *astExpr, src *astExpr) {
+	var elmType = getElementTypeOfListType(getTypeOfExpr(dst))
+	if kind(getTypeOfExpr(src)) == T_STRING {
+		src = newBytesOfString(src)
+	}
+	var args = []*Arg{
+		&Arg{e: dst},
+		&Arg{e: src},
+		&Arg{e: newIntLiteralExpr(getSizeOfType(elmType)), t: tInt},
+	}
+	emitCall("runtime.slicecopy", args, []*astField{&astField{Type: tInt.e}})
+}
+
+func emitFuncall(fun *astExpr, eArgs []*astExpr, hasEllipsis bool) {
+	var symbol string
+	var receiver *astExpr
+	var funcType *astFuncType
+	switch fun.dtype {
+	case "*astIdent":
+		emitComment(0, "[%s][*astIdent]\n", __func__)
+		var fnIdent = fun.ident
+		switch fnIdent.Obj {
+		case gLen:
+			var arg = eArgs[0]
+			emitLen(arg)
+			return
+		case gCap:
+			var arg = eArgs[0]
+			emitCap(arg)
+			return
+		case gNew:
+			var typeArg = e2t(eArgs[0])
+			var size = getSizeOfType(typeArg)
+			emitCallMalloc(size)
+			return
+		case gMake:
+			var typeArg = e2t(eArgs[0])
+			switch kind(typeArg) {
+			case T_SLICE:
+				// make([]T, ...)
+				var arrayType = getUnderlyingType(typeArg).e.arrayType
+				//assert(ok, "should be *ast.ArrayType")
+				var elmSize = getSizeOfType(e2t(arrayType.Elt))
+				var numlit = newNumberLiteral(elmSize)
+				var eNumLit = &astExpr{}
+				eNumLit.dtype = "*astBasicLit"
+				eNumLit.basicLit = numlit
+
+				var args []*Arg = []*Arg{
+					// elmSize
+					&Arg{
+						e: eNumLit,
+						t: tInt,
+					},
+					// len
+					&Arg{
+						e: eArgs[1],
+						t: tInt,
+					},
+					// cap
+					&Arg{
+						e: eArgs[2],
+						t: tInt,
+					},
+				}
+
+				emitCall("runtime.makeSlice", args, getRuntimeSliceResults())
+				return
+			case T_MAP:
+				// make(map[K]V) or make(map[K]V, n)
+				emitMakeMap(typeArg)
+				return
+			case T_CHAN:
+				// make(chan T) or make(chan T, n)
+				var elemSize = newNumberLiteral(getSizeOfType(getChanElemType(typeArg)))
+				var eSize = newNumberLiteral(0)
+				var eBuf = &astExpr{
+					dtype:    "*astBasicLit",
+					basicLit: eSize,
+				}
+				if len(eArgs) > 1 {
+					eBuf = eArgs[1]
+				}
+				var chanArgs = []*Arg{
+					&Arg{
+						e: &astExpr{
+							dtype:    "*astBasicLit",
+							basicLit: elemSize,
+						},
+						t: tInt,
+					},
+					&Arg{
+						e: eBuf,
+						t: tInt,
+					},
+				}
+				var chanResults = []*astField{
+					&astField{
+						Type: typeArg.e,
+					},
+				}
+				emitCall("runtime.makechan", chanArgs, chanResults)
+				return
+			default:
+				panic2(__func__, "TBI")
+			}
+
+			return
+		case gAppend:
+			emitAppend(eArgs, hasEllipsis)
+			return
+		case gCopy:
+			emitCopy(eArgs[0], eArgs[1])
+			return
+		case gPanic:
+			symbol = "runtime.gopanic"
+			_args := []*Arg{&Arg{
+				e: eArgs[0],
+				t: tEmptyInterface,
+			}}
+			emitCall(symbol, _args, nil)
+			return
+		case gRecover:
+			var errorType = getRuntimeErrorTypeDescriptor()
+			fmtPrintf("  leaq %s(%%rip), %%rax # runtime error type\n", errorType.label)
+			fmtPrintf("  pushq %%rax\n")
+			fmtPrintf("  pushq %%rbp # frame of the caller of recover\n")
+			fmtPrintf("  callq runtime.gorecover\n")
+			emitRevertStackPointer(ptrSize * 2)
+			fmtPrintf("  pushq %%rax # *eface\n")
+			emitLoad(tEmptyInterface)
+			return
+		case gDelete:
+			var mapType = getTypeOfExpr(eArgs[0])
+			var keyType = getMapKeyType(mapType)
+			emitExpr(eArgs[0], nil)
+			emitExpr(eArgs[1], keyType)
+			emitCallMapRuntime("runtime.mapdelete", keyType, 0)
+			emitRevertStackPointer(ptrSize + getPushSizeOfType(keyType))
+			return
+		case gClose:
+			emitExpr(eArgs[0], nil)
+			fmtPrintf("  callq runtime.closechan\n")
+			emitRevertStackPointer(ptrSize)
+			return
+		}
+
+		var fn = fun.ident
+		if fn.Name == "print" {
+			emitExpr(eArgs[0], nil)
+			fmtPrintf("  callq runtime.printstring\n")
+			fmtPrintf("  addq $%s, %%rsp # revert \n", Itoa(16))
+			return
+		}
+
+		if fn.Obj.Kind == astVar {
+			emitFuncValueCall(fun, eArgs, hasEllipsis)
+			return
+		}
+
+		// general function call
+		symbol = getFuncSymbol(pkg.name, fn.Name)
+		emitComment(0, "[%s][*astIdent][default] start\n", __func__)
+
+		var obj = fn.Obj
+		var decl = obj.Decl
+		if decl == nil {
+			panic2(__func__, "[*astCallExpr] decl is nil")
+		}
+		if decl.dtype != "*astFuncDecl" {
+			panic2(__func__, "[*astCallExpr] decl.dtype is invalid")
+		}
+		var fndecl = decl.funcDecl
+		if fndecl == nil {
+			panic2(__func__, "[*astCallExpr] fndecl is nil")
+		}
+		if fndecl.Type == nil {
+			panic2(__func__, "[*astCallExpr] fndecl.Type is nil")
+		}
+		funcType = fndecl.Type
+	case "*astSelectorExpr":
+		var selectorExpr = fun.selectorExpr
+		if selectorExpr.X.dtype == "*astIdent" {
+			symbol = selectorExpr.X.ident.Name + "." + selectorExpr.Sel.Name
+		}
+		if symbol == "unsafe.Pointer" {
+			emitExpr(eArgs[0], nil)
+			return
+		}
+		if isType(selectorExpr.X) {
+			// T.m(x, args)
+			emitFuncValueCall(fun, eArgs, hasEllipsis)
+			return
+		}
+		funcType = getStdFuncType(symbol)
+		if funcType == nil {
+			// Assume method call
+			receiver = selectorExpr.X
+			var receiverType = getTypeOfExpr(receiver)
+			if kind(receiverType) == T_INTERFACE {
+				emitInterfaceMethodCall(receiverType, receiver, selectorExpr.Sel, eArgs, hasEllipsis)
+				return
+			}
+			if isFieldSelector(selectorExpr) {
+				emitFuncValueCall(fun, eArgs, hasEllipsis)
+				return
+			}
+			var method = lookupMethod(receiverType, selectorExpr.Sel)
+			receiver = adjustReceiver(receiver, method)
+			funcType = method.funcType
+			var subsymbol = getMethodSymbol(method)
+			symbol = getFuncSymbol(pkg.name, subsymbol)
+		}
+	default:
+		emitFuncValueCall(fun, eArgs, hasEllipsis)
+		return
+	}
+
+	var args = prepareArgs(funcType, receiver, eArgs, hasEllipsis)
+	emitCall(symbol, args, getFuncResults(funcType))
+}
+
+// Functions of the standard packages, which are implemented in runtime.s
+func getStdFuncType(symbol string) *astFuncType {
+	switch symbol {
+	case "os.Exit":
+		return funcTypeOsExit
+	case "syscall.Open":
+		return funcTypeSyscallOpen
+	case "syscall.Read":
+		return funcTypeSyscallRead
+	case "syscall.Write":
+		return funcTypeSyscallWrite
+	case "syscall.Syscall":
+		return funcTypeSyscallSyscall
+	case "runtime.Gosched":
+		return funcTypeRuntimeGosched
+	}
+	var r *astFuncType
+	return r
+}
+
+// Results of the function called by e. Builtins and conversions have none here.
+func getCallResults(e *astCallExpr) []*astField {
+	var fun = e.Fun
+	var results []*astField
+	if isType(fun) {
+		return results
+	}
+	var funcType *astFuncType
+	switch fun.dtype {
+	case "*astIdent":
+		if fun.ident.Obj.Kind == astFun {
+			if fun.ident.Obj.Decl == nil {
+				return results
+			}
+			funcType = fun.ident.Obj.Decl.funcDecl.Type
+		}
+	case "*astSelectorExpr":
+		var selectorExpr = fun.selectorExpr
+		if selectorExpr.X.dtype == "*astIdent" && selectorExpr.X.ident.Obj.Kind == "Pkg" {
+			funcType = getStdFuncType(selectorExpr.X.ident.Name + "." + selectorExpr.Sel.Name)
+			if funcType == nil {
+				return results
+			}
+		} else if !isType(selectorExpr.X) && !isFieldSelector(selectorExpr) {
+			funcType = lookupMethod(getTypeOfExpr(selectorExpr.X), selectorExpr.Sel).funcType
+		}
+	}
+	if funcType == nil {
+		funcType = getUnderlyingType(getTypeOfExpr(fun)).e.funcType
+	}
+	return getFuncResults(funcType)
+}
+
+// f() which returns multiple values
+func isMultiValueCall(e *astExpr) bool {
+	return e.dtype == "*astCallExpr" && hasResultArea(getCallResults(e.callExpr))
+}
+
+func emitExpr(e *astExpr, forceType *Type) {
+	emitComment(2, "[emitExpr] dtype=%s\n", e.dtype)
+	if forceType != nil && kind(forceType) == T_INTERFACE && !isNil(e) {
+		var t = getTypeOfExpr(e)
+		if kind(t) != T_INTERFACE {
+			emitConvertToInterface(e, t, forceType)
+			return
+		}
+		if typeString(t) != typeString(forceType) {
+			emitExpr(e, nil)
+			emitConvertInterface(t, forceType)
+			return
+		}
+	}
+	switch e.dtype {
+	case "*astIdent":
+		var ident = e.ident
+		if ident.Obj == nil {
+			panic2(__func__, "ident unresolved:"+ident.Name)
+		}
+		switch e.ident.Obj {
+		case gTrue:
+			emitTrue()
+			return
+		case gFalse:
+			emitFalse()
+			return
+		case gNil:
+			if forceType == nil {
+				panic2(__func__, "Type is required to emit nil")
+			}
+			switch kind(forceType) {
+			case T_SLICE, T_POINTER, T_MAP, T_CHAN, T_FUNC, T_INTERFACE:
+				emitZeroValue(forceType)
+			default:
+				panic2(__func__, "Unexpected kind="+kind(forceType))
+			}
+			return
+		}
+		switch ident.Obj.Kind {
+		case astVar:
+			emitAddr(e)
+			var t = getTypeOfExpr(e)
+			emitLoad(t)
+		case astCon:
+			var valSpec = ident.Obj.Decl.valueSpec
+			assert(valSpec != nil, "valSpec should not be nil", __func__)
+			assert(valSpec.Value != nil, "valSpec should not be nil", __func__)
+			assert(isConstLiteral(valSpec.Value), "const value should be folded", __func__)
+			var t *Type
+			if valSpec.Type != nil {
+				t = e2t(valSpec.Type)
+			} else {
+				t = forceType
+			}
+			emitExpr(valSpec.Value, t)
+		case astFun:
+			var symbol = getFuncSymbol(pkg.name, ident.Name)
+			fmtPrintf("  leaq %s(%%rip), %%rax # func value\n", getFuncValueSymbol(symbol))
+			fmtPrintf("  pushq %%rax\n")
+		case astTyp:
+			panic2(__func__, "[*astIdent] Kind Typ should not come here")
+		default:
+			panic2(__func__, "[*astIdent] unknown Kind="+ident.Obj.Kind+" Name="+ident.Obj.Name)
+		}
+	case "*astIndexExpr":
+		if kind(getTypeOfExpr(e.indexExpr.X)) == T_MAP {
+			emitMapGet(e.indexExpr)
+			return
+		}
+		emitAddr(e)
+		emitLoad(getTypeOfExpr(e))
+	case "*astStarExpr":
+		emitAddr(e)
+		emitLoad(getTypeOfExpr(e))
+	case "*astSelectorExpr":
+		if isType(e.selectorExpr.X) {
+			emitMethodExpr(e.selectorExpr)
+			return
+		}
+		resolvePromotedSelector(e.selectorExpr)
+		var method = lookupSelectedMethod(e.selectorExpr)
+		if method != nil {
+			emitMethodValue(e.selectorExpr, method)
+			return
+		}
+		emitAddr(e)
+		emitLoad(getTypeOfExpr(e))
+	case "*astBasicLit":
+		//		emitComment(0, "basicLit.Kind = %s \n", e.basicLit.Kind)
+		switch e.basicLit.Kind {
+		case "INT":
+			if forceType != nil && isFloatKind(kind(forceType)) {
+				// untyped constant converted to a float
+				emitFloatLiteral(e.basicLit.Value, forceType)
+				return
+			}
+			// an untyped constant has the default type int
+			var t = tInt
+			if forceType != nil && isIntegerKind(kind(forceType)) {
+				t = forceType
+			}
+			checkConstRepresentable(parseIntLiteral(e.basicLit.Value), e.basicLit.Value, t)
+			var ival = Atoi(e.basicLit.Value)
+			if ival > 2147483647 || ival < -2147483648 {
+				// does not fit in a 32 bit immediate
+				fmtPrintf("  movabsq $%d, %%rax # number literal\n", Itoa(ival))
+				fmtPrintf("  pushq %%rax\n")
+			} else {
+				fmtPrintf("  pushq $%d # number literal\n", Itoa(ival))
+			}
+		case "STRING":
+			var sl = getStringLiteral(e.basicLit)
+			if sl.strlen == 0 {
+				// zero value
+				emitZeroValue(tString)
+			} else {
+				fmtPrintf("  pushq $%d # str len\n", Itoa(sl.strlen))
+				fmtPrintf("  leaq %s, %%rax # str ptr\n", sl.label)
+				fmtPrintf("  pushq %%rax # str ptr\n")
+			}
+		case "FLOAT":
+			if forceType != nil && isIntegerKind(kind(forceType)) {
+				// untyped float constant converted to an integer
+				emitExpr(&astExpr{
+					dtype:    "*astBasicLit",
+					basicLit: newNumberLiteral(evalInt(e)),
+				}, forceType)
+				return
+			}
+			if forceType != nil && kind(forceType) == T_FLOAT32 {
+				emitFloatLiteral(e.basicLit.Value, forceType)
+			} else {
+				emitFloatLiteral(e.basicLit.Value, tFloat64)
+			}
+		case "CHAR":
+			var char = getCharValue(e.basicLit)
+			fmtPrintf("  pushq $%d # convert char literal to int\n", Itoa(char))
+		default:
+			panic2(__func__, "[*astBasicLit] TBI : "+e.basicLit.Kind)
+		}
+	case "*astCallExpr":
+		var fun = e.callExpr.Fun
+		emitComment(0, "[%s][*astCallExpr]\n", __func__)
+		if isType(fun) {
+			emitConversion(e2t(fun), e.callExpr.Args[0])
+			return
+		}
+		if e.callExpr.tupleArgs != nil {
+			emitAssignTuple(e.callExpr.tupleArgs.Lhs, e.callExpr.tupleArgs.Rhs)
+		}
+		emitFuncall(fun, e.callExpr.Args, e.callExpr.Ellipsis)
+	case "*astParenExpr":
+		emitExpr(e.parenExpr.X, nil)
+	case "*astTypeAssertExpr":
+		emitTypeAssertExpr(e.typeAssertExpr)
+	case "*astFuncLit":
+		emitFuncLit(e.funcLit)
+	case "*astSliceExpr":
+		emitSliceExpr(e.sliceExpr)
+	case "*astUnaryExpr":
+		emitComment(0, "[DEBUG] unary op = %s\n", e.unaryExpr.Op)
+		switch e.unaryExpr.Op {
+		case "+":
+			emitExpr(e.unaryExpr.X, nil)
+		case "-":
+			emitExpr(e.unaryExpr.X, forceType)
+			fmtPrintf("  popq %%rax # e.X\n")
+			switch kind(getTypeOfExpr(e.unaryExpr.X)) {
+			case T_FLOAT32:
+				fmtPrintf("  btcl $31, %%eax # flip the sign bit\n")
+			case T_FLOAT64:
+				fmtPrintf("  btcq $63, %%rax # flip the sign bit\n")
+			default:
+				fmtPrintf("  imulq $-1, %%rax\n")
+			}
+			fmtPrintf("  pushq %%rax\n")
+			emitTruncate(getTypeOfExpr(e.unaryExpr.X))
+		case "^":
+			emitExpr(e.unaryExpr.X, nil)
+			fmtPrintf("  popq %%rax # e.X\n")
+			fmtPrintf("  notq %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+			emitTruncate(getTypeOfExpr(e.unaryExpr.X))
+		case "&":
+			emitAddr(e.unaryExpr.X)
+		case "!":
+			emitExpr(e.unaryExpr.X, nil)
+			emitInvertBoolValue()
+		case "<-":
+			emitChanRecv(e.unaryExpr.X)
+		default:
+			panic2(__func__, "TBI:astUnaryExpr:"+e.unaryExpr.Op)
+		}
+	case "*astBinaryExpr":
+		if e.binaryExpr.Op == "==" || e.binaryExpr.Op == "!=" {
+			checkComparable(e.binaryExpr)
+		}
+		var ifaceType = getInterfaceTypeOfComparison(e.binaryExpr)
+		if ifaceType != nil {
+			emitExpr(e.binaryExpr.X, ifaceType)
+			emitExpr(e.binaryExpr.Y, ifaceType)
+			emitCompEq(ifaceType)
+			if e.binaryExpr.Op == "!=" {
+				emitInvertBoolValue()
+			}
+			return
+		}
+		if kind(getTypeOfExpr(e.binaryExpr.X)) == T_STRING {
+			var args []*Arg
+			var argX = &Arg{}
+			var argY = &Arg{}
+			argX.e = e.binaryExpr.X
+			argY.e = e.binaryExpr.Y
+			args = append(args, argX)
+			args = append(args, argY)
+			switch e.binaryExpr.Op {
+			case "+":
+				var resultList = []*astField{
+					&astField{
+						Type:    tString.e,
+					},
+				}
+				emitCall("runtime.catstrings", args, resultList)
+			case "==":
+				emitArgs(args)
+				emitCompEq(getTypeOfExpr(e.binaryExpr.X))
+			case "!=":
+				emitArgs(args)
+				emitCompEq(getTypeOfExpr(e.binaryExpr.X))
+				emitInvertBoolValue()
+			default:
+				panic2(__func__, "[emitExpr][*astBinaryExpr] string : TBI T_STRING")
+			}
+			return
+		}
+
+		switch e.binaryExpr.Op {
+		case "&&":
+			labelid++
+			var labelExitWithFalse = fmtSprintf(".L.%s.false", []string{Itoa(labelid)})
+			var labelExit = fmtSprintf(".L.%d.exit", []string{Itoa(labelid)})
+			emitExpr(e.binaryExpr.X, nil) // left
+			emitPopBool("left")
+			fmtPrintf("  cmpq $1, %%rax\n")
+			// exit with false if left is false
+			fmtPrintf("  jne %s\n", labelExitWithFalse)
+
+			// if left is true, then eval right and exit
+			emitExpr(e.binaryExpr.Y, nil) // right
+			fmtPrintf("  jmp %s\n", labelExit)
+
+			fmtPrintf("  %s:\n", labelExitWithFalse)
+			emitFalse()
+			fmtPrintf("  %s:\n", labelExit)
+			return
+		case "||":
+			labelid++
+			var labelExitWithTrue = fmtSprintf(".L.%d.true", []string{Itoa(labelid)})
+			var labelExit = fmtSprintf(".L.%d.exit", []string{Itoa(labelid)})
+			emitExpr(e.binaryExpr.X, nil) // left
+			emitPopBool("left")
+			fmtPrintf("  cmpq $1, %%rax\n")
+			// exit with true if left is true
+			fmtPrintf("  je %s\n", labelExitWithTrue)
+
+			// if left is false, then eval right and exit
+			emitExpr(e.binaryExpr.Y, nil) // right
+			fmtPrintf("  jmp %s\n", labelExit)
+
+			fmtPrintf("  %s:\n", labelExitWithTrue)
+			emitTrue()
+			fmtPrintf("  %s:\n", labelExit)
+			return
+		}
+
+		var t = getTypeOfExpr(e.binaryExpr.X)
+		var unsigned = isUnsignedKind(kind(t))
+		if e.binaryExpr.Op == "<<" || e.binaryExpr.Op == ">>" {
+			emitExpr(e.binaryExpr.X, nil) // left
+			emitExpr(e.binaryExpr.Y, nil) // shift count
+			var countType = getTypeOfExpr(e.binaryExpr.Y)
+			var signedCount = !isUnsignedKind(kind(countType)) && evalConst(e.binaryExpr.Y, 0) == nil
+			emitShift(e.binaryExpr.Op, unsigned, signedCount)
+			emitTruncate(t)
+			return
+		}
+		t = getOperandType(e.binaryExpr)
+		unsigned = isUnsignedKind(kind(t))
+		emitExpr(e.binaryExpr.X, t) // left
+		emitExpr(e.binaryExpr.Y, t) // right
+		if isFloatKind(kind(t)) {
+			emitFloatBinaryExpr(e.binaryExpr.Op, t)
+			return
+		}
+		switch e.binaryExpr.Op {
+		case "+":
+			fmtPrintf("  popq %%rcx # right\n")
+			fmtPrintf("  popq %%rax # left\n")
+			fmtPrintf("  addq %%rcx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+			emitTruncate(t)
+		case "-":
+			fmtPrintf("  popq %%rcx # right\n")
+			fmtPrintf("  popq %%rax # left\n")
+			fmtPrintf("  subq %%rcx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+			emitTruncate(t)
+		case "*":
+			fmtPrintf("  popq %%rcx # right\n")
+			fmtPrintf("  popq %%rax # left\n")
+			fmtPrintf("  imulq %%rcx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+			emitTruncate(t)
+		case "%":
+			emitDiv(unsigned)
+			fmtPrintf("  movq %%rdx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+			emitTruncate(t)
+		case "/":
+			emitDiv(unsigned)
+			fmtPrintf("  pushq %%rax\n")
+			emitTruncate(t)
+		case "&":
+			fmtPrintf("  popq %%rcx # right\n")
+			fmtPrintf("  popq %%rax # left\n")
+			fmtPrintf("  andq %%rcx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+		case "|":
+			fmtPrintf("  popq %%rcx # right\n")
+			fmtPrintf("  popq %%rax # left\n")
+			fmtPrintf("  orq %%rcx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+		case "^":
+			fmtPrintf("  popq %%rcx # right\n")
+			fmtPrintf("  popq %%rax # left\n")
+			fmtPrintf("  xorq %%rcx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+		case "&^":
+			fmtPrintf("  popq %%rcx # right\n")
+			fmtPrintf("  popq %%rax # left\n")
+			fmtPrintf("  notq %%rcx\n")
+			fmtPrintf("  andq %%rcx, %%rax\n")
+			fmtPrintf("  pushq %%rax\n")
+		case "==":
+			emitCompEq(t)
+		case "!=":
+			emitCompEq(t)
+			emitInvertBoolValue()
+		case "<":
+			if unsigned {
+				emitCompExpr("setb")
+			} else {
+				emitCompExpr("setl")
+			}
+		case "<=":
+			if unsigned {
+				emitCompExpr("setbe")
+			} else {
+				emitCompExpr("setle")
+			}
+		case ">":
+			if unsigned {
+				emitCompExpr("seta")
+			} else {
+				emitCompExpr("setg")
+			}
+		case ">=":
+			if unsigned {
+				emitCompExpr("setae")
+			} else {
+				emitCompExpr("setge")
+			}
+		default:
+			panic2(__func__, "# TBI: binary operation for "+e.binaryExpr.Op)
+		}
+	case "*astCompositeLit":
+		// slice , array, map or struct
+		var k = kind(e2t(e.compositeLit.Type))
+		switch k {
+		case T_STRUCT:
+			emitStructLiteral(e.compositeLit)
+		case T_ARRAY:
+			var arrayType = getUnderlyingType(e2t(e.compositeLit.Type)).e.arrayType
+			var arrayLen = evalInt(arrayType.Len)
+			emitArrayLiteral(arrayType, arrayLen, e.compositeLit.Elts)
+		case T_SLICE:
+			var arrayType = getUnderlyingType(e2t(e.compositeLit.Type)).e.arrayType
+			var length = getListLiteralLength(e.compositeLit.Elts)
+			emitArrayLiteral(arrayType, length, e.compositeLit.Elts)
+			emitPopAddress("malloc")
+			fmtPrintf("  pushq $%d # slice.cap\n", Itoa(length))
+			fmtPrintf("  pushq $%d # slice.len\n", Itoa(length))
+			fmtPrintf("  pushq %%rax # slice.ptr\n")
+		case T_MAP:
+			emitMapLiteral(e.compositeLit)
+		default:
+			panic2(__func__, "Unexpected kind="+k)
+		}
+	default:
+		panic2(__func__, "[emitExpr] `TBI:"+e.dtype)
+	}
+}
+
+// The rune which a char literal represents
+func getCharValue(lit *astBasicLit) int {
+	var val = lit.Value
+	var r int
+	if val[1] == '\\' {
+		r, _, _ = unquoteEscape(val, 1)
+		return r
+	}
+	r, _ = decodeRune(val, 1)
+	return r
+}
+
+// Decode the escape sequence at lit[i].
+// It returns the value, whether it is a single byte (\x or octal) and the index after it.
+func unquoteEscape(lit string, i int) (int, bool, int) {
+	var ch = lit[i+1]
+	switch ch {
+	case 'a':
+		return 7, false, i + 2
+	case 'b':
+		return 8, false, i + 2
+	case 'f':
+		return 12, false, i + 2
+	case 'n':
+		return 10, false, i + 2
+	case 'r':
+		return 13, false, i + 2
+	case 't':
+		return 9, false, i + 2
+	case 'v':
+		return 11, false, i + 2
+	case '\\', '\'', '"':
+		return int(ch), false, i + 2
+	case 'x':
+		return parseHexDigits(lit, i+2, 2), true, i + 4
+	case 'u':
+		return parseHexDigits(lit, i+2, 4), false, i + 6
+	case 'U':
+		return parseHexDigits(lit, i+2, 8), false, i + 10
+	}
+	// \ooo
+	var v int
+	var j int
+	for j = i + 1; j < i+4; j++ {
+		v = v*8 + int(lit[j]-'0')
+	}
+	return v, true, i + 4
+}
+
+func parseHexDigits(s string, i int, n int) int {
+	var v int
+	var j int
+	for j = i; j < i+n; j++ {
+		v = v*16 + hexDigit(s[j])
+	}
+	return v
+}
+
+const runeError int = 0xFFFD
+
+// Decode the UTF-8 encoded rune at s[i]. It returns the rune and its size in bytes.
+// An invalid encoding is decoded as runeError of size 1.
+func decodeRune(s string, i int) (int, int) {
+	var b0 = int(s[i])
+	if b0 < 0x80 {
+		return b0, 1
+	}
+	var size int
+	var r int
+	var min int
+	if b0&0xE0 == 0xC0 {
+		size = 2
+		r = b0 & 0x1F
+		min = 0x80
+	} else if b0&0xF0 == 0xE0 {
+		size = 3
+		r = b0 & 0x0F
+		min = 0x800
+	} else if b0&0xF8 == 0xF0 {
+		size = 4
+		r = b0 & 0x07
+		min = 0x10000
+	} else {
+		return runeError, 1
+	}
+	if i+size > len(s) {
+		return runeError, 1
+	}
+	var j int
+	for j = i + 1; j < i+size; j++ {
+		var b = int(s[j])
+		if b&0xC0 != 0x80 {
+			return runeError, 1
+		}
+		r = r<<6 | b&0x3F
+	}
+	if r < min || r > 0x10FFFF || (0xD800 <= r && r <= 0xDFFF) {
+		return runeError, 1
+	}
+	return r, size
+}
+
+// Append the UTF-8 encoding of r to buf
+func appendRune(buf []uint8, r int) []uint8 {
+	if r < 0 || r > 0x10FFFF || (0xD800 <= r && r <= 0xDFFF) {
+		r = runeError
+	}
+	if r < 0x80 {
+		return append(buf, uint8(r))
+	}
+	if r < 0x800 {
+		buf = append(buf, uint8(0xC0|r>>6))
+	} else if r < 0x10000 {
+		buf = append(buf, uint8(0xE0|r>>12))
+		buf = append(buf, uint8(0x80|r>>6&0x3F))
+	} else {
+		buf = append(buf, uint8(0xF0|r>>18))
+		buf = append(buf, uint8(0x80|r>>12&0x3F))
+		buf = append(buf, uint8(0x80|r>>6&0x3F))
+	}
+	return append(buf, uint8(0x80|r&0x3F))
+}
+
+// The bytes which a quoted string literal represents
+func unquote(lit string) string {
+	var buf []uint8
+	var i = 1
+	if lit[0] == '`' {
+		// Carriage returns are discarded from raw strings
+		for i = 1; i < len(lit)-1; i++ {
+			if lit[i] != '\r' {
+				buf = append(buf, lit[i])
+			}
+		}
+		return string(buf)
+	}
+	var v int
+	var isByte bool
+	for i < len(lit)-1 {
+		if lit[i] != '\\' {
+			buf = append(buf, lit[i])
+			i++
+			continue
+		}
+		v, isByte, i = unquoteEscape(lit, i)
+		if isByte {
+			buf = append(buf, uint8(v))
+		} else {
+			buf = appendRune(buf, v)
+		}
+	}
+	return string(buf)
+}
+
+// Quote s with octal escapes, which both Go and the assembler understand
+func quote(s string) string {
+	var buf []uint8
+	buf = append(buf, '"')
+	var i int
+	var ch uint8
+	for i = 0; i < len(s); i++ {
+		ch = s[i]
+		if ch < ' ' || ch >= 0x7F || ch == '"' || ch == '\\' {
+			buf = append(buf, '\\')
+			buf = append(buf, '0'+ch>>6)
+			buf = append(buf, '0'+ch>>3&7)
+			buf = append(buf, '0'+ch&7)
+		} else {
+			buf = append(buf, ch)
+		}
+	}
+	buf = append(buf, '"')
+	return string(buf)
+}
+
+func newNumberLiteral(x int) *astBasicLit {
+	var r = &astBasicLit{}
+	r.Kind = "INT"
+	r.Value = Itoa(x)
+	return r
+}
+
+func newIntLiteralExpr(x int) *astExpr {
+	return &astExpr{
+		dtype:    "*astBasicLit",
+		basicLit: newNumberLiteral(x),
+	}
+}
+
+// Panic if the pointer on the stack top is nil, which is not dereferenced right away.
+func emitNilCheck() {
+	labelid++
+	var labelOk = ".L.nonnil." + Itoa(labelid)
+	fmtPrintf("  cmpq $0, (%%rsp) # nil check\n")
+	fmtPrintf("  jne %s\n", labelOk)
+	fmtPrintf("  callq runtime.panicmem\n")
+	fmtPrintf("%s:\n", labelOk)
+}
+
+// s[low:high:max] is done by the runtime, which also checks the bounds unless -B is given.
+// The operand is pushed as (ptr, len, cap) words,
+// from which the omitted bounds are taken.
+func emitSliceExpr(e *astSliceExpr) {
+	var listType = getTypeOfExpr(e.X)
+	var elmType *Type
+	var hasCap int // 1 if the operand is a slice
+	var slice3 int
+	if e.Slice3 {
+		slice3 = 1
+	}
+	emitAllocResultArea(getRuntimeSliceResults())
+	switch kind(listType) {
+	case T_SLICE:
+		hasCap = 1
+		elmType = getElementTypeOfListType(listType)
+		emitExpr(e.X, nil)
+	case T_STRING:
+		if e.Slice3 {
+			panic2(__func__, "invalid operation: 3-index slice of string")
+		}
+		elmType = tUint8
+		emitExpr(e.X, nil)
+		emitPopString()
+		fmtPrintf("  pushq %%rcx # cap\n")
+		fmtPrintf("  pushq %%rcx # len\n")
+		fmtPrintf("  pushq %%rax # ptr\n")
+	case T_ARRAY, T_POINTER:
+		var arrayType = listType
+		if kind(listType) == T_ARRAY {
+			emitAddr(e.X)
+		} else {
+			// a pointer to an array
+			arrayType = e2t(listType.e.starExpr.X)
+			assert(kind(arrayType) == T_ARRAY, "cannot slice "+typeString(listType), __func__)
+			emitExpr(e.X, nil)
+			emitNilCheck()
+		}
+		arrayType = getUnderlyingType(arrayType)
+		elmType = getElementTypeOfListType(arrayType)
+		var arrayLen = evalInt(arrayType.e.arrayType.Len)
+		fmtPrintf("  popq %%rax # array head\n")
+		fmtPrintf("  pushq $%d # cap\n", Itoa(arrayLen))
+		fmtPrintf("  pushq $%d # len\n", Itoa(arrayLen))
+		fmtPrintf("  pushq %%rax # ptr\n")
+	default:
+		panic2(__func__, "cannot slice "+typeString(listType))
+	}
+
+	if e.Low == nil {
+		fmtPrintf("  pushq $0 # low\n")
+	} else {
+		emitExpr(e.Low, nil)
+	}
+	if e.High == nil {
+		fmtPrintf("  pushq 16(%%rsp) # high = len\n")
+	} else {
+		emitExpr(e.High, nil)
+	}
+	if e.Max == nil {
+		fmtPrintf("  pushq 32(%%rsp) # max = cap\n")
+	} else {
+		emitExpr(e.Max, nil)
+	}
+	var check = 1
+	if noBoundsCheck {
+		check = 0
+	}
+	fmtPrintf("  pushq $%d # elmSize\n", Itoa(getSizeOfType(elmType)))
+	fmtPrintf("  pushq $%d # hasCap\n", Itoa(hasCap))
+	fmtPrintf("  pushq $%d # slice3\n", Itoa(slice3))
+	fmtPrintf("  pushq $%d # check\n", Itoa(check))
+	fmtPrintf("  callq runtime.slicing\n")
+	emitRevertStackPointer(ptrSize * 10)
+	if kind(listType) == T_STRING {
+		emitPopSlice()
+		fmtPrintf("  pushq %%rcx # str len\n")
+		fmtPrintf("  pushq %%rax # str ptr\n")
+	}
+}
+
+// The index is on the stack top
+func emitListElementAddr(list *astExpr, elmType *Type) {
+	emitListHeadAddr(list)
+	emitPopAddress("list head")
+	fmtPrintf("  popq %%rdx # list length\n")
+	fmtPrintf("  popq %%rcx # index id\n")
+	if !noBoundsCheck {
+		labelid++
+		var labelOk = ".L.inbounds." + Itoa(labelid)
+		fmtPrintf("  cmpq %%rdx, %%rcx # negative index is a large unsigned\n")
+		fmtPrintf("  jb %s\n", labelOk)
+		fmtPrintf("  pushq %%rdx # length\n")
+		fmtPrintf("  pushq %%rcx # index\n")
+		fmtPrintf("  callq runtime.panicIndex\n")
+		fmtPrintf("%s:\n", labelOk)
+	}
+	fmtPrintf("  movq $%s, %%rdx # elm size\n", Itoa(getSizeOfType(elmType)))
+	fmtPrintf("  imulq %%rdx, %%rcx\n")
+	fmtPrintf("  addq %%rcx, %%rax\n")
+	fmtPrintf("  pushq %%rax # addr of element\n")
+}
+
+func emitCompEq(t *Type) {
+	switch kind(t) {
+	case T_STRING:
+		var resultList = []*astField{
+			&astField{
+				Type:    tBool.e,
+			},
+		}
+		fmtPrintf("  callq runtime.cmpstrings\n")
+		emitRevertStackPointer(stringSize * 2)
+		emitReturnedValue(resultList)
+	case T_BOOL, T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_UINTPTR, T_POINTER, T_MAP, T_CHAN, T_FUNC:
+		emitCompExpr("sete")
+	case T_INTERFACE:
+		var resultList = []*astField{
+			&astField{
+				Type:    tBool.e,
+			},
+		}
+		fmtPrintf("  callq runtime.ifaceeq\n")
+		emitRevertStackPointer(interfaceSize * 2)
+		emitReturnedValue(resultList)
+	case T_FLOAT32, T_FLOAT64:
+		emitFloatCompExpr("==", t)
+	case T_SLICE:
+		emitCompExpr("sete") // @FIXME this is not correct
+	case T_STRUCT, T_ARRAY:
+		emitCompEqComposite(t)
+	default:
+		panic2(__func__, "Unexpected kind="+kind(t))
+	}
+}
+
+// Whether values of t can be compared by their bytes in memory
+func isMemComparable(t *Type) bool {
+	switch kind(t) {
+	case T_STRING, T_INTERFACE, T_FLOAT32, T_FLOAT64, T_SLICE, T_MAP, T_FUNC:
+		return false
+	case T_ARRAY:
+		return isMemComparable(e2t(getUnderlyingType(t).e.arrayType.Elt))
+	case T_STRUCT:
+		var field *astField
+		for _, field = range getUnderlyingType(t).e.structType.Fields.List {
+			if !isMemComparable(e2t(field.Type)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Whether == and != are defined on t. Slices, maps and funcs can be compared only to nil.
+func isComparable(t *Type) bool {
+	switch kind(t) {
+	case T_SLICE, T_MAP, T_FUNC:
+		return false
+	case T_ARRAY:
+		return isComparable(e2t(getUnderlyingType(t).e.arrayType.Elt))
+	case T_STRUCT:
+		var field *astField
+		for _, field = range getUnderlyingType(t).e.structType.Fields.List {
+			if !isComparable(e2t(field.Type)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func checkComparable(e *astBinaryExpr) {
+	if isNil(e.X) || isNil(e.Y) {
+		return
+	}
+	var t = getTypeOfExpr(e.X)
+	switch kind(t) {
+	case T_SLICE, T_MAP, T_FUNC:
+		panic2(__func__, "invalid operation: "+e.Op+" on "+typeString(t)+" (can only be compared to nil)")
+	}
+	if !isComparable(t) {
+		panic2(__func__, "invalid operation: "+e.Op+" on "+typeString(t)+" (cannot be compared)")
+	}
+}
+
+// Compare two structs or arrays whose addresses are pushed
+func emitCompEqComposite(t *Type) {
+	if isMemComparable(t) {
+		fmtPrintf("  popq %%rcx # right\n")
+		fmtPrintf("  popq %%rax # left\n")
+		fmtPrintf("  pushq $%d # size\n", Itoa(getSizeOfType(t)))
+		fmtPrintf("  pushq %%rcx\n")
+		fmtPrintf("  pushq %%rax\n")
+		fmtPrintf("  callq runtime.memequal\n")
+		emitRevertStackPointer(ptrSize*2 + intSize)
+		fmtPrintf("  pushq %%rax\n")
+		return
+	}
+	labelid++
+	var labelFalse = ".L.compeq.false." + Itoa(labelid)
+	var labelEnd = ".L.compeq.end." + Itoa(labelid)
+	if kind(t) == T_ARRAY {
+		var arrayType = getUnderlyingType(t).e.arrayType
+		var elemType = e2t(arrayType.Elt)
+		var elemSize = getSizeOfType(elemType)
+		var i int
+		for i = 0; i < evalInt(arrayType.Len); i++ {
+			emitCompEqElement(elemType, i*elemSize, labelFalse)
+		}
+	} else {
+		var field *astField
+		for _, field = range getUnderlyingType(t).e.structType.Fields.List {
+			emitCompEqElement(e2t(field.Type), getStructFieldOffset(field), labelFalse)
+		}
+	}
+	emitRevertStackPointer(ptrSize * 2)
+	emitTrue()
+	fmtPrintf("  jmp %s\n", labelEnd)
+	fmtPrintf("  %s:\n", labelFalse)
+	emitRevertStackPointer(ptrSize * 2)
+	emitFalse()
+	fmtPrintf("  %s:\n", labelEnd)
+}
+
+// Compare the fields or elements at the offset of two structs or arrays whose addresses are on the stack top.
+// Jump to labelFalse if they differ.
+func emitCompEqElement(t *Type, offset int, labelFalse string) {
+	fmtPrintf("  movq 8(%%rsp), %%rax # left\n")
+	fmtPrintf("  addq $%d, %%rax\n", Itoa(offset))
+	fmtPrintf("  pushq %%rax\n")
+	emitLoad(t)
+	fmtPrintf("  movq %d(%%rsp), %%rax # right\n", Itoa(getPushSizeOfType(t)))
+	fmtPrintf("  addq $%d, %%rax\n", Itoa(offset))
+	fmtPrintf("  pushq %%rax\n")
+	emitLoad(t)
+	emitCompEq(t)
+	emitPopBool("element comparison")
+	fmtPrintf("  cmpq $1, %%rax\n")
+	fmtPrintf("  jne %s\n", labelFalse)
+}
+
+// Shift the left operand by the count on the stack top.
+// A count of 64 or more shifts all the bits out, unlike the x86 instructions which mask it.
+// A negative count of a signed type panics.
+func emitShift(op string, unsigned bool, signedCount bool) {
+	labelid++
+	var labelInRange = ".L.shift.inrange." + Itoa(labelid)
+	fmtPrintf("  popq %%rcx # count\n")
+	fmtPrintf("  popq %%rax # left\n")
+	if signedCount {
+		var labelNonNegative = ".L.shift.nonneg." + Itoa(labelid)
+		fmtPrintf("  testq %%rcx, %%rcx\n")
+		fmtPrintf("  jns %s\n", labelNonNegative)
+		fmtPrintf("  callq runtime.panicshift\n")
+		fmtPrintf("  %s:\n", labelNonNegative)
+	}
+	fmtPrintf("  cmpq $64, %%rcx\n")
+	fmtPrintf("  jb %s\n", labelInRange)
+	if op == ">>" && !unsigned {
+		fmtPrintf("  movq $63, %%rcx # fill with the sign bit\n")
+	} else {
+		fmtPrintf("  movq $0, %%rax\n")
+	}
+	fmtPrintf("  %s:\n", labelInRange)
+	switch op {
+	case "<<":
+		fmtPrintf("  shlq %%cl, %%rax\n")
+	case ">>":
+		if unsigned {
+			fmtPrintf("  shrq %%cl, %%rax\n")
+		} else {
+			fmtPrintf("  sarq %%cl, %%rax\n")
+		}
+	}
+	fmtPrintf("  pushq %%rax\n")
+}
+
+// Divide left by right, leaving the quotient in %rax and the remainder in %rdx
+func emitDiv(unsigned bool) {
+	fmtPrintf("  popq %%rcx # right\n")
+	fmtPrintf("  popq %%rax # left\n")
+	labelid++
+	var labelNonZero = ".L.div.nonzero." + Itoa(labelid)
+	var labelDiv = ".L.div.div." + Itoa(labelid)
+	var labelEnd = ".L.div.end." + Itoa(labelid)
+	fmtPrintf("  testq %%rcx, %%rcx\n")
+	fmtPrintf("  jne %s\n", labelNonZero)
+	fmtPrintf("  callq runtime.panicdivide\n")
+	fmtPrintf("%s:\n", labelNonZero)
+	if unsigned {
+		fmtPrintf("  movq $0, %%rdx # init %%rdx\n")
+		fmtPrintf("  divq %%rcx\n")
+		return
+	}
+	// x / -1 overflows idivq when x is the most negative value, which wraps around to itself
+	fmtPrintf("  cmpq $-1, %%rcx\n")
+	fmtPrintf("  jne %s\n", labelDiv)
+	fmtPrintf("  negq %%rax\n")
+	fmtPrintf("  movq $0, %%rdx\n")
+	fmtPrintf("  jmp %s\n", labelEnd)
+	fmtPrintf("%s:\n", labelDiv)
+	fmtPrintf("  cqto # sign extend %%rax into %%rdx\n")
+	fmtPrintf("  idivq %%rcx\n")
+	fmtPrintf("%s:\n", labelEnd)
+}
+
+// T(e) where T is a numeric type
+func emitNumericConversion(tp *Type, arg0 *astExpr) {
+	if isUntypedConst(arg0) {
+		emitExpr(arg0, tp)
+		emitTruncate(tp)
+		return
+	}
+	var fromKind = kind(getTypeOfExpr(arg0))
+	var toKind = kind(tp)
+	emitExpr(arg0, nil)
+	if isFloatKind(toKind) {
+		if !isFloatKind(fromKind) {
+			emitIntToFloat(fromKind, toKind)
+		} else if fromKind != toKind {
+			fmtPrintf("  popq %%rax\n")
+			fmtPrintf("  movq %%rax, %%xmm0\n")
+			if toKind == T_FLOAT32 {
+				fmtPrintf("  cvtsd2ss %%xmm0, %%xmm0\n")
+			} else {
+				fmtPrintf("  cvtss2sd %%xmm0, %%xmm0\n")
+			}
+			emitPushFloat(tp)
+		}
+		return
+	}
+	if isFloatKind(fromKind) {
+		emitFloatToInt(fromKind, toKind)
+	}
+	emitTruncate(tp)
+}
+
+// Truncate the float on the stack top toward zero
+func emitFloatToInt(fromKind string, toKind string) {
+	var sfx = getFloatSuffix(fromKind)
+	fmtPrintf("  popq %%rax\n")
+	fmtPrintf("  movq %%rax, %%xmm0\n")
+	switch toKind {
+	case T_UINT, T_UINT64, T_UINTPTR:
+		labelid++
+		var labelEnd = ".L.cvt.end." + Itoa(labelid)
+		var labelBig = ".L.cvt.big." + Itoa(labelid)
+		// values >= 2^63 are converted after subtracting 2^63, which is put back as the top bit
+		if fromKind == T_FLOAT32 {
+			fmtPrintf("  movq $0x5f000000, %%rcx # 2^63\n")
+		} else {
+			fmtPrintf("  movabsq $0x43e0000000000000, %%rcx # 2^63\n")
+		}
+		fmtPrintf("  movq %%rcx, %%xmm1\n")
+		fmtPrintf("  comi%s %%xmm1, %%xmm0\n", sfx)
+		fmtPrintf("  jae %s\n", labelBig)
+		fmtPrintf("  cvtt%s2si %%xmm0, %%rax\n", sfx)
+		fmtPrintf("  jmp %s\n", labelEnd)
+		fmtPrintf("  %s:\n", labelBig)
+		fmtPrintf("  sub%s %%xmm1, %%xmm0\n", sfx)
+		fmtPrintf("  cvtt%s2si %%xmm0, %%rax\n", sfx)
+		fmtPrintf("  btcq $63, %%rax\n")
+		fmtPrintf("  %s:\n", labelEnd)
+	default:
+		fmtPrintf("  cvtt%s2si %%xmm0, %%rax\n", sfx)
+	}
+	fmtPrintf("  pushq %%rax\n")
+}
+
+func emitIntToFloat(fromKind string, toKind string) {
+	var sfx = getFloatSuffix(toKind)
+	fmtPrintf("  popq %%rax\n")
+	switch fromKind {
+	case T_UINT, T_UINT64, T_UINTPTR:
+		labelid++
+		var labelEnd = ".L.cvt.end." + Itoa(labelid)
+		var labelSigned = ".L.cvt.signed." + Itoa(labelid)
+		fmtPrintf("  testq %%rax, %%rax\n")
+		fmtPrintf("  jns %s\n", labelSigned)
+		// halve the value keeping the lowest bit for rounding, and double it after the conversion
+		fmtPrintf("  movq %%rax, %%rcx\n")
+		fmtPrintf("  shrq $1, %%rcx\n")
+		fmtPrintf("  andq $1, %%rax\n")
+		fmtPrintf("  orq %%rax, %%rcx\n")
+		fmtPrintf("  cvtsi2%sq %%rcx, %%xmm0\n", sfx)
+		fmtPrintf("  add%s %%xmm0, %%xmm0\n", sfx)
+		fmtPrintf("  jmp %s\n", labelEnd)
+		fmtPrintf("  %s:\n", labelSigned)
+		fmtPrintf("  cvtsi2%sq %%rax, %%xmm0\n", sfx)
+		fmtPrintf("  %s:\n", labelEnd)
+	default:
+		fmtPrintf("  cvtsi2%sq %%rax, %%xmm0\n", sfx)
+	}
+	if toKind == T_FLOAT32 {
+		emitPushFloat(tFloat32)
+	} else {
+		emitPushFloat(tFloat64)
+	}
+}
+
+// Push the float in %xmm0
+func emitPushFloat(t *Type) {
+	if kind(t) == T_FLOAT32 {
+		fmtPrintf("  movd %%xmm0, %%eax\n")
+	} else {
+		fmtPrintf("  movq %%xmm0, %%rax\n")
+	}
+	fmtPrintf("  pushq %%rax\n")
+}
+
+// Suffix of SSE instructions for a float kind: ss for float32 and sd for float64
+func getFloatSuffix(knd string) string {
+	if knd == T_FLOAT32 {
+		return "ss"
+	}
+	return "sd"
+}
+
+// Pop the right operand into %xmm1 and the left operand into %xmm0
+func emitPopFloats() {
+	fmtPrintf("  popq %%rcx # right\n")
+	fmtPrintf("  popq %%rax # left\n")
+	fmtPrintf("  movq %%rcx, %%xmm1\n")
+	fmtPrintf("  movq %%rax, %%xmm0\n")
+}
+
+func emitFloatBinaryExpr(op string, t *Type) {
+	var sfx = getFloatSuffix(kind(t))
+	switch op {
+	case "+":
+		emitPopFloats()
+		fmtPrintf("  add%s %%xmm1, %%xmm0\n", sfx)
+		emitPushFloat(t)
+	case "-":
+		emitPopFloats()
+		fmtPrintf("  sub%s %%xmm1, %%xmm0\n", sfx)
+		emitPushFloat(t)
+	case "*":
+		emitPopFloats()
+		fmtPrintf("  mul%s %%xmm1, %%xmm0\n", sfx)
+		emitPushFloat(t)
+	case "/":
+		emitPopFloats()
+		fmtPrintf("  div%s %%xmm1, %%xmm0\n", sfx)
+		emitPushFloat(t)
+	case "==", "!=", "<", "<=", ">", ">=":
+		emitFloatCompExpr(op, t)
+	default:
+		panic2(__func__, "invalid operator for floats: "+op)
+	}
+}
+
+// Comparisons with NaN are false except for !=.
+// ucomis sets ZF, PF and CF when the operands are unordered.
+func emitFloatCompExpr(op string, t *Type) {
+	var sfx = getFloatSuffix(kind(t))
+	emitPopFloats()
+	switch op {
+	case "==":
+		fmtPrintf("  ucomi%s %%xmm1, %%xmm0\n", sfx)
+		fmtPrintf("  sete %%al\n")
+		fmtPrintf("  setnp %%cl\n")
+		fmtPrintf("  andb %%cl, %%al\n")
+	case "!=":
+		fmtPrintf("  ucomi%s %%xmm1, %%xmm0\n", sfx)
+		fmtPrintf("  setne %%al\n")
+		fmtPrintf("  setp %%cl\n")
+		fmtPrintf("  orb %%cl, %%al\n")
+	case "<":
+		fmtPrintf("  ucomi%s %%xmm0, %%xmm1\n", sfx)
+		fmtPrintf("  seta %%al\n")
+	case "<=":
+		fmtPrintf("  ucomi%s %%xmm0, %%xmm1\n", sfx)
+		fmtPrintf("  setae %%al\n")
+	case ">":
+		fmtPrintf("  ucomi%s %%xmm1, %%xmm0\n", sfx)
+		fmtPrintf("  seta %%al\n")
+	case ">=":
+		fmtPrintf("  ucomi%s %%xmm1, %%xmm0\n", sfx)
+		fmtPrintf("  setae %%al\n")
+	}
+	fmtPrintf("  movzbq %%al, %%rax\n")
+	fmtPrintf("  pushq %%rax\n")
+}
+
+// Push a float constant of type t
+func emitFloatLiteral(value string, t *Type) {
+	labelid++
+	var label = ".L.float." + Itoa(labelid)
+	fmtPrintf(".data\n")
+	fmtPrintf("%s:\n", label)
+	fmtPrintf("  %s\n", getFloatData(value, t))
+	fmtPrintf(".text\n")
+	if kind(t) == T_FLOAT32 {
+		fmtPrintf("  movl %s(%%rip), %%eax # float literal %s\n", label, value)
+	} else {
+		fmtPrintf("  movq %s(%%rip), %%rax # float literal %s\n", label, value)
+	}
+	fmtPrintf("  pushq %%rax\n")
+}
+
+// Assembler directive of a float constant like ".quad 4609434218613702656" for 1.5.
+// The literal is rounded here, since the assembler does not round decimal literals
+// correctly and does not know hexadecimal ones.
+func getFloatData(value string, t *Type) string {
+	var num *bigInt
+	var den *bigInt
+	num, den = parseFloatLiteral(value)
+	var bits int
+	if kind(t) == T_FLOAT32 {
+		bits = encodeRat(num.mag, den.mag, 23, 127)
+		if num.neg {
+			bits = bits + 2147483648 // sign bit
+		}
+		return ".long " + Itoa(bits)
+	}
+	bits = encodeRat(num.mag, den.mag, 52, 1023)
+	if num.neg {
+		bits = bits - 9223372036854775807 - 1 // sign bit
+	}
+	return ".quad " + Itoa(bits)
+}
+
+// IEEE 754 representation of num / den like encodeFloat
+func encodeRat(num []int, den []int, fracBits int, bias int) int {
+	if len(num) == 0 {
+		return 0
+	}
+	// a quotient of 62 or 63 bits, and a sticky lowest bit if it is inexact,
+	// rounds like the exact value
+	var shift = 62 - natBitLen(num) + natBitLen(den)
+	if shift > 0 {
+		num = natShl(num, shift)
+	} else {
+		den = natShl(den, -shift)
+	}
+	var q []int
+	var rem []int
+	q, rem = natDivMod(num, den)
+	var mant = bigToInt(newBigInt(false, q))
+	if len(rem) > 0 {
+		mant = mant | 1
+	}
+	return encodeFloat(mant, -shift, fracBits, bias)
+}
+
+// IEEE 754 representation of mant * 2^exp with fracBits bits of fraction,
+// rounding to nearest even
+func encodeFloat(mant int, exp int, fracBits int, bias int) int {
+	if mant == 0 {
+		return 0
+	}
+	var top = 1 << (fracBits + 1)
+	for mant < top>>1 {
+		mant = mant << 1
+		exp--
+	}
+	var half int
+	var sticky int
+	// a subnormal has the exponent of the smallest normal
+	for mant >= top || exp+fracBits+bias < 1 {
+		sticky = sticky | half
+		half = mant & 1
+		mant = mant >> 1
+		exp++
+	}
+	if half == 1 && (sticky == 1 || mant&1 == 1) {
+		mant++
+		if mant == top {
+			mant = mant >> 1
+			exp++
+		}
+	}
+	if mant < top>>1 {
+		// subnormal or zero
+		return mant
+	}
+	var biased = exp + fracBits + bias
+	if biased > 2*bias {
+		panic2(__func__, "float constant out of range")
+	}
+	return biased<<fracBits | (mant - top>>1)
+}
+
+func isFloatKind(knd string) bool {
+	return knd == T_FLOAT32 || knd == T_FLOAT64
+}
+
+func isIntegerKind(knd string) bool {
+	return isNumericKind(knd) && !isFloatKind(knd)
+}
+
+func isNumericKind(knd string) bool {
+	switch knd {
+	case T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_UINTPTR, T_FLOAT32, T_FLOAT64:
+		return true
+	}
+	return false
+}
+
+// Wrap the integer on the stack top around to the size of t,
+// by sign or zero extending its low bits.
+func emitTruncate(t *Type) {
+	switch kind(t) {
+	case T_INT8:
+		fmtPrintf("  popq %%rax\n")
+		fmtPrintf("  movsbq %%al, %%rax # truncate to int8\n")
+		fmtPrintf("  pushq %%rax\n")
+	case T_UINT8:
+		fmtPrintf("  popq %%rax\n")
+		fmtPrintf("  movzbq %%al, %%rax # truncate to uint8\n")
+		fmtPrintf("  pushq %%rax\n")
+	case T_INT16:
+		fmtPrintf("  popq %%rax\n")
+		fmtPrintf("  movswq %%ax, %%rax # truncate to int16\n")
+		fmtPrintf("  pushq %%rax\n")
+	case T_UINT16:
+		fmtPrintf("  popq %%rax\n")
+		fmtPrintf("  movzwq %%ax, %%rax # truncate to uint16\n")
+		fmtPrintf("  pushq %%rax\n")
+	case T_INT32:
+		fmtPrintf("  popq %%rax\n")
+		fmtPrintf("  movslq %%eax, %%rax # truncate to int32\n")
+		fmtPrintf("  pushq %%rax\n")
+	case T_UINT32:
+		fmtPrintf("  popq %%rax\n")
+		fmtPrintf("  movl %%eax, %%eax # truncate to uint32\n")
+		fmtPrintf("  pushq %%rax\n")
+	}
+}
+
+func isUnsignedKind(knd string) bool {
+	switch knd {
+	case T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_UINTPTR:
+		return true
+	}
+	return false
+}
+
+//@TODO handle larger types than int
+func emitCompExpr(inst string) {
+	fmtPrintf("  popq %%rcx # right\n")
+	fmtPrintf("  popq %%rax # left\n")
+	fmtPrintf("  cmpq %%rcx, %%rax\n")
+	fmtPrintf("  %s %%al\n", inst)
+	fmtPrintf("  movzbq %%al, %%rax\n") // true:1, false:0
+	fmtPrintf("  pushq %%rax\n")
+}
+
+func emitStore(t *Type) {
+	emitComment(2, "emitStore(%s)\n", kind(t))
+	switch kind(t) {
+	case T_SLICE:
+		emitPopSlice()
+		fmtPrintf("  popq %%rsi # lhs ptr addr\n")
+		fmtPrintf("  movq %%rax, %d(%%rsi) # ptr to ptr\n", Itoa(0))
+		fmtPrintf("  movq %%rcx, %d(%%rsi) # len to len\n", Itoa(8))
+		fmtPrintf("  movq %%rdx, %d(%%rsi) # cap to cap\n", Itoa(16))
+	case T_STRING, T_INTERFACE:
+		emitPopString()
+		fmtPrintf("  popq %%rsi # lhs ptr addr\n")
+		fmtPrintf("  movq %%rax, %d(%%rsi) # ptr to ptr\n", Itoa(0))
+		fmtPrintf("  movq %%rcx, %d(%%rsi) # len to len\n", Itoa(8))
+	case T_INT, T_INT64, T_UINT, T_UINT64, T_FLOAT64, T_BOOL, T_UINTPTR, T_POINTER, T_MAP, T_CHAN, T_FUNC:
+		fmtPrintf("  popq %%rdi # rhs evaluated\n")
+		fmtPrintf("  popq %%rax # lhs addr\n")
+		fmtPrintf("  movq %%rdi, (%%rax) # assign\n")
+	case T_INT8, T_UINT8:
+		fmtPrintf("  popq %%rdi # rhs evaluated\n")
+		fmtPrintf("  popq %%rax # lhs addr\n")
+		fmtPrintf("  movb %%dil, (%%rax) # assign byte\n")
+	case T_INT16, T_UINT16:
+		fmtPrintf("  popq %%rdi # rhs evaluated\n")
+		fmtPrintf("  popq %%rax # lhs addr\n")
+		fmtPrintf("  movw %%di, (%%rax) # assign word\n")
+	case T_INT32, T_UINT32, T_FLOAT32:
+		fmtPrintf("  popq %%rdi # rhs evaluated\n")
+		fmtPrintf("  popq %%rax # lhs addr\n")
+		fmtPrintf("  movl %%edi, (%%rax) # assign long\n")
+	case T_STRUCT, T_ARRAY:
+		fmtPrintf("  popq %%rdi # rhs: addr of data\n")
+		fmtPrintf("  popq %%rax # lhs: addr to store\n")
+		fmtPrintf("  pushq $%d # size\n", Itoa(getSizeOfType(t)))
+		fmtPrintf("  pushq %%rax # dst lhs\n")
+		fmtPrintf("  pushq %%rdi # src rhs\n")
+		fmtPrintf("  callq runtime.memcopy\n")
+		emitRevertStackPointer(ptrSize*2 + intSize)
+	default:
+		panic2(__func__, "TBI:"+kind(t))
+	}
+}
+
+func emitAssign(lhs *astExpr, rhs *astExpr) {
+	if isBlankIdent(lhs) {
+		// _ = x evaluates x and discards it
+		emitExpr(rhs, nil)
+		emitRevertStackTop(getTypeOfExpr(rhs))
+		return
+	}
+	if lhs.dtype == "*astIndexExpr" && kind(getTypeOfExpr(lhs.indexExpr.X)) == T_MAP {
+		emitMapSet(lhs.indexExpr, rhs)
+		return
+	}
+	emitComment(2, "Assignment: emitAddr(lhs:%s)\n", lhs.dtype)
+	emitAddr(lhs)
+	emitComment(2, "Assignment: emitExpr(rhs)\n")
+	emitExpr(rhs, getTypeOfExpr(lhs))
+	emitStore(getTypeOfExpr(lhs))
+}
+
+// a, b = x, y or a, b = f()
+// All the lhs addresses and the rhs values are pushed first, and then assigned from left to right.
+func emitAssignTuple(lhs []*astExpr, rhs []*astExpr) {
+	var e *astExpr
+	for _, e = range lhs {
+		if isBlankIdent(e) {
+			fmtPrintf("  pushq $0 # blank\n")
+		} else {
+			emitAddr(e)
+		}
+	}
+
+	// offsets of the values from the stack top
+	var types []*Type
+	var offsets []int
+	var valuesSize int
+	var inResultArea = len(lhs) > len(rhs)
+	var i int
+	if inResultArea {
+		var results = getCallResults(rhs[0].callExpr)
+		emitExpr(rhs[0], nil)
+		for i = 0; i < len(lhs); i++ {
+			types = append(types, e2t(results[i].Type))
+			offsets = append(offsets, getResultOffset(results, i))
+		}
+		valuesSize = getResultAreaSize(results)
+	} else {
+		for i, e = range rhs {
+			var t *Type
+			if isBlankIdent(lhs[i]) {
+				t = getTypeOfExpr(e)
+			} else {
+				t = getTypeOfExpr(lhs[i])
+			}
+			emitExpr(e, t)
+			types = append(types, t)
+			offsets = append(offsets, 0)
+			var j int
+			for j = 0; j < i; j++ {
+				offsets[j] = offsets[j] + getPushSizeOfType(t)
+			}
+			valuesSize = valuesSize + getPushSizeOfType(t)
+		}
+	}
+
+	for i, e = range lhs {
+		if !isBlankIdent(e) {
+			var t = types[i]
+			fmtPrintf("  movq %d(%%rsp), %%rax # lhs addr\n", Itoa(valuesSize+(len(lhs)-1-i)*ptrSize))
+			fmtPrintf("  pushq %%rax\n")
+			if inResultArea {
+				fmtPrintf("  leaq %d(%%rsp), %%rax # value addr\n", Itoa(offsets[i]+ptrSize))
+			} else {
+				emitAddrOfPushedValue(t, offsets[i]+ptrSize)
+			}
+			fmtPrintf("  pushq %%rax\n")
+			emitLoad(t)
+			emitStore(t)
+		}
+	}
+	emitRevertStackPointer(valuesSize + len(lhs)*ptrSize)
+}
+
+// Expressions which can return an extra bool value, like "v, ok = m[k]"
+func isCommaOkExpr(e *astExpr) bool {
+	switch e.dtype {
+	case "*astIndexExpr":
+		return kind(getTypeOfExpr(e.indexExpr.X)) == T_MAP
+	case "*astTypeAssertExpr":
+		return true
+	case "*astUnaryExpr":
+		return e.unaryExpr.Op == "<-"
+	}
+	return false
+}
+
+func emitCommaOkAssign(lhs0 *astExpr, lhs1 *astExpr, rhs *astExpr) {
+	switch rhs.dtype {
+	case "*astIndexExpr":
+		emitMapCommaOk(lhs0, lhs1, rhs.indexExpr)
+	case "*astTypeAssertExpr":
+		emitTypeAssertCommaOk(lhs0, lhs1, rhs.typeAssertExpr)
+	case "*astUnaryExpr":
+		emitChanRecvCommaOk(lhs0, lhs1, rhs.unaryExpr)
+	default:
+		panic2(__func__, "Unexpected dtype="+rhs.dtype)
+	}
+}
+
+func emitStmt(stmt *astStmt) {
+	emitComment(2, "\n")
+	emitComment(2, "== Stmt %s ==\n", stmt.dtype)
+	switch stmt.dtype {
+	case "*astBlockStmt":
+		var stmt2 *astStmt
+		for _, stmt2 = range stmt.blockStmt.List {
+			emitStmt(stmt2)
+		}
+	case "*astExprStmt":
+		var x = stmt.exprStmt.X
+		emitExpr(x, nil)
+		if x.dtype == "*astUnaryExpr" && x.unaryExpr.Op == "<-" {
+			// discard the received value
+			emitRevertStackTop(getTypeOfExpr(x))
+		} else if isMultiValueCall(x) {
+			emitRevertStackPointer(getResultAreaSize(getCallResults(x.callExpr)))
+		}
+	case "*astSendStmt":
+		emitChanSend(stmt.sendStmt)
+	case "*astSelectStmt":
+		emitSelectStmt(stmt.selectStmt)
+	case "*astDeclStmt":
+		var decl *astDecl = stmt.DeclStmt.Decl
+		if decl.dtype != "*astGenDecl" {
+			panic2(__func__, "[*astDeclStmt] internal error")
+		}
+		var spec *astSpec
+		for _, spec = range decl.genDecl.Specs {
+			if spec.valueSpec.Name.Obj.Kind == astVar {
+				emitLocalVarSpec(spec.valueSpec)
+			}
+		}
+
+	case "*astAssignStmt":
+		switch stmt.assignStmt.Tok {
+		case "=", ":=":
+			var lhs = stmt.assignStmt.Lhs
+			var rhs = stmt.assignStmt.Rhs
+			if stmt.assignStmt.Tok == ":=" {
+				var e *astExpr
+				for _, e = range lhs {
+					if isNewShortVar(e, stmt.assignStmt) {
+						emitNewBox(e.ident.Obj.Variable, getTypeOfExpr(e))
+					}
+				}
+			}
+			if len(lhs) == 2 && len(rhs) == 1 && isCommaOkExpr(rhs[0]) {
+				emitCommaOkAssign(lhs[0], lhs[1], rhs[0])
+			} else if len(lhs) == 1 || (len(rhs) == 1 && !isMultiValueCall(rhs[0])) {
+				// The syscall functions of runtime.s return only the first value,
+				// as in "fd, _ = syscall.Open(...)".
+				emitAssign(lhs[0], rhs[0])
+			} else {
+				emitAssignTuple(lhs, rhs)
+			}
+		default:
+			panic2(__func__, "TBI: assignment of "+stmt.assignStmt.Tok)
+		}
+	case "*astReturnStmt":
+		emitReturnStmt(stmt.returnStmt)
+	case "*astGoStmt":
+		emitGoOrDeferStmt("go", stmt.goStmt.Call)
+	case "*astDeferStmt":
+		emitGoOrDeferStmt("defer", stmt.deferStmt.Call)
+	case "*astIfStmt":
+		emitComment(2, "if\n")
+		if stmt.ifStmt.Init != nil {
+			emitStmt(stmt.ifStmt.Init)
+		}
+
+		labelid++
+		var labelEndif = ".L.endif." + Itoa(labelid)
+		var labelElse = ".L.else." + Itoa(labelid)
+
+		emitExpr(stmt.ifStmt.Cond, nil)
+		emitPopBool("if condition")
+		fmtPrintf("  cmpq $1, %%rax\n")
+		var bodyStmt = &astStmt{}
+		bodyStmt.dtype = "*astBlockStmt"
+		bodyStmt.blockStmt = stmt.ifStmt.Body
+		if stmt.ifStmt.Else != nil {
+			fmtPrintf("  jne %s # jmp if false\n", labelElse)
+			emitStmt(bodyStmt) // then
+			fmtPrintf("  jmp %s\n", labelEndif)
+			fmtPrintf("  %s:\n", labelElse)
+			emitStmt(stmt.ifStmt.Else) // then
+		} else {
+			fmtPrintf("  jne %s # jmp if false\n", labelEndif)
+			emitStmt(bodyStmt) // then
+		}
+		fmtPrintf("  %s:\n", labelEndif)
+		emitComment(2, "end if\n")
+	case "*astForStmt":
+		labelid++
+		var labelCond = ".L.for.cond." + Itoa(labelid)
+		var labelPost = ".L.for.post." + Itoa(labelid)
+		var labelExit = ".L.for.exit." + Itoa(labelid)
+		//forStmt, ok := mapForNodeToFor[s]
+		//assert(ok, "map value should exist")
+		stmt.forStmt.labelPost = labelPost
+		stmt.forStmt.labelExit = labelExit
+
+		if stmt.forStmt.Init != nil {
+			emitStmt(stmt.forStmt.Init)
+		}
+
+		fmtPrintf("  %s:\n", labelCond)
+		if stmt.forStmt.Cond != nil {
+			emitExpr(stmt.forStmt.Cond, nil)
+			emitPopBool("for condition")
+			fmtPrintf("  cmpq $1, %%rax\n")
+			fmtPrintf("  jne %s # jmp if false\n", labelExit)
+		}
+		emitStmt(blockStmt2Stmt(stmt.forStmt.Body))
+		fmtPrintf("  %s:\n", labelPost) // used for "continue"
+		if stmt.forStmt.Init != nil && stmt.forStmt.Init.dtype == "*astAssignStmt" && stmt.forStmt.Init.assignStmt.Tok == ":=" {
+			// each iteration has its own copy of the variables declared by the init statement
+			var e *astExpr
+			for _, e = range stmt.forStmt.Init.assignStmt.Lhs {
+				if isNewShortVar(e, stmt.forStmt.Init.assignStmt) {
+					emitRenewBox(e.ident.Obj.Variable, getTypeOfExpr(e))
+				}
+			}
+		}
+		if stmt.forStmt.Post != nil {
+			emitStmt(stmt.forStmt.Post)
+		}
+		fmtPrintf("  jmp %s\n", labelCond)
+		fmtPrintf("  %s:\n", labelExit)
+	case "*astRangeStmt":
+		switch kind(getTypeOfExpr(stmt.rangeStmt.X)) {
+		case T_MAP:
+			emitRangeMap(stmt.rangeStmt)
+		case T_CHAN:
+			emitRangeChan(stmt.rangeStmt)
+		case T_STRING:
+			emitRangeString(stmt.rangeStmt)
+		default:
+			emitRangeList(stmt.rangeStmt)
+		}
+
+
+	case "*astIncDecStmt":
+		var addValue int
+		switch stmt.incDecStmt.Tok {
+		case "++":
+			addValue = 1
+		case "--":
+			addValue = -1
+		default:
+			panic2(__func__, "Unexpected Tok="+stmt.incDecStmt.Tok)
+		}
+		var t = getTypeOfExpr(stmt.incDecStmt.X)
+		emitAddr(stmt.incDecStmt.X)
+		emitExpr(stmt.incDecStmt.X, nil)
+		if isFloatKind(kind(t)) {
+			emitFloatLiteral(Itoa(addValue), t)
+			emitFloatBinaryExpr("+", t)
+		} else {
+			emitAddConst(addValue, "rhs ++ or --")
+		}
+		emitStore(t)
+	case "*astSwitchStmt":
+		labelid++
+		var labelEnd = fmtSprintf(".L.switch.%s.exit", []string{Itoa(labelid)})
+		stmt.switchStmt.labelExit = labelEnd
+		if stmt.switchStmt.Init != nil {
+			emitStmt(stmt.switchStmt.Init)
+		}
+		var condType *Type
+		if stmt.switchStmt.Tag != nil {
+			emitExpr(stmt.switchStmt.Tag, nil)
+			condType = getTypeOfExpr(stmt.switchStmt.Tag)
+		}
+		var cases = stmt.switchStmt.Body.List
+		emitComment(2, "[DEBUG] cases len=%s\n", Itoa(len(cases)))
+		var labels = make([]string, len(cases), len(cases))
+		var defaultLabel string
+		var i int
+		var c *astStmt
+		emitComment(2, "Start comparison with cases\n")
+		for i, c = range cases {
+			emitComment(2, "CASES idx=%s\n", Itoa(i))
+			assert(c.dtype == "*astCaseClause", "should be *astCaseClause", __func__)
+			var cc = c.caseClause
+			labelid++
+			var labelCase = ".L.case." + Itoa(labelid)
+			labels[i] = labelCase
+			cc.label = labelCase
+			if len(cc.List) == 0 { // @TODO implement slice nil comparison
+				defaultLabel = labelCase
+				continue
+			}
+			var e *astExpr
+			for _, e = range cc.List {
+				if condType == nil {
+					// switch { case cond: }
+					emitExpr(e, tBool)
+					emitPopBool("switch-case condition")
+					fmtPrintf("  cmpq $1, %%rax\n")
+					fmtPrintf("  je %s # jump if true\n", labelCase)
+					continue
+				}
+				assert(isComparable(condType), "switch on "+typeString(condType)+" which cannot be compared", __func__)
+				labelid++
+				var labelNext = ".L.case.next." + Itoa(labelid)
+				emitPushStackTop(condType, "switch expr")
+				emitExpr(e, condType)
+				emitCompEq(condType)
+				emitPopBool(" of switch-case comparison")
+				fmtPrintf("  cmpq $1, %%rax\n")
+				fmtPrintf("  jne %s\n", labelNext)
+				emitRevertStackTop(condType)
+				fmtPrintf("  jmp %s # jump if match\n", labelCase)
+				fmtPrintf("  %s:\n", labelNext)
+			}
+		}
+		emitComment(2, "End comparison with cases\n")
+		if condType != nil {
+			emitRevertStackTop(condType)
+		}
+
+		// if no case matches, then jump to
+		if defaultLabel != "" {
+			// default
+			fmtPrintf("  jmp %s\n", defaultLabel)
+		} else {
+			// exit
+			fmtPrintf("  jmp %s\n", labelEnd)
+		}
+
+		for i, c = range cases {
+			assert(c.dtype == "*astCaseClause", "should be *astCaseClause", __func__)
+			var cc = c.caseClause
+			fmtPrintf("%s:\n", labels[i])
+			var _s *astStmt
+			for _, _s = range cc.Body {
+				emitStmt(_s)
+			}
+			fmtPrintf("  jmp %s\n", labelEnd)
+		}
+		fmtPrintf("%s:\n", labelEnd)
+	case "*astTypeSwitchStmt":
+		var typeSwitch = stmt.typeSwitchStmt
+		var subject = getTypeSwitchSubject(typeSwitch)
+		var subjectType = getTypeOfExpr(subject)
+		labelid++
+		var labelEnd = ".L.typeswitch." + Itoa(labelid) + ".exit"
+		typeSwitch.labelExit = labelEnd
+		if typeSwitch.Init != nil {
+			emitStmt(typeSwitch.Init)
+		}
+		emitVariableAddr(typeSwitch.subject)
+		emitExpr(subject, nil)
+		emitStore(subjectType)
+		var cases = typeSwitch.Body.List
+		var labels = make([]string, len(cases), len(cases))
+		var defaultLabel string
+		var i int
+		var c *astStmt
+		for i, c = range cases {
+			var cc = c.caseClause
+			labelid++
+			var labelCase = ".L.case." + Itoa(labelid)
+			labels[i] = labelCase
+			cc.label = labelCase
+			if len(cc.List) == 0 {
+				defaultLabel = labelCase
+				continue
+			}
+			var e *astExpr
+			for _, e = range cc.List {
+				emitTypeSwitchCaseMatch(typeSwitch.subject, subjectType, e, labelCase)
+			}
+		}
+		if defaultLabel != "" {
+			fmtPrintf("  jmp %s\n", defaultLabel)
+		} else {
+			fmtPrintf("  jmp %s\n", labelEnd)
+		}
+		for i, c = range cases {
+			var cc = c.caseClause
+			fmtPrintf("%s:\n", labels[i])
+			if cc.implicit != nil {
+				emitTypeSwitchVar(typeSwitch.subject, subjectType, cc.implicit)
+			}
+			var _s *astStmt
+			for _, _s = range cc.Body {
+				emitStmt(_s)
+			}
+			fmtPrintf("  jmp %s\n", labelEnd)
+		}
+		fmtPrintf("%s:\n", labelEnd)
+	case "*astLabeledStmt":
+		fmtPrintf("%s:\n", stmt.labeledStmt.label)
+		emitStmt(stmt.labeledStmt.Stmt)
+	case "*astBranchStmt":
+		var target = stmt.branchStmt.target
+		var labelToGo string
+		switch stmt.branchStmt.Tok {
+		case "continue":
+			switch target.dtype {
+			case "*astForStmt":
+				labelToGo = target.forStmt.labelPost
+			case "*astRangeStmt":
+				labelToGo = target.rangeStmt.labelPost
+			default:
+				panic2(__func__, "unexpected container dtype="+target.dtype)
+			}
+			fmtPrintf("jmp %s # continue\n", labelToGo)
+		case "break":
+			switch target.dtype {
+			case "*astForStmt":
+				labelToGo = target.forStmt.labelExit
+			case "*astRangeStmt":
+				labelToGo = target.rangeStmt.labelExit
+			case "*astSwitchStmt":
+				labelToGo = target.switchStmt.labelExit
+			case "*astTypeSwitchStmt":
+				labelToGo = target.typeSwitchStmt.labelExit
+			case "*astSelectStmt":
+				labelToGo = target.selectStmt.labelExit
+			default:
+				panic2(__func__, "unexpected container dtype="+target.dtype)
+			}
+			fmtPrintf("jmp %s # break\n", labelToGo)
+		case "goto":
+			fmtPrintf("jmp %s # goto\n", target.labeledStmt.label)
+		case "fallthrough":
+			fmtPrintf("jmp %s # fallthrough\n", target.caseClause.label)
+		default:
+			panic2(__func__, "unexpected tok="+stmt.branchStmt.Tok)
+		}
+	default:
+		panic2(__func__, "TBI:"+stmt.dtype)
+	}
+}
+
+// for k, v := range x (array, slice or string)
+func emitRangeList(stmt *astRangeStmt) {
+	labelid++
+	var labelCond = ".L.range.cond." + Itoa(labelid)
+	var labelPost = ".L.range.post." + Itoa(labelid)
+	var labelExit = ".L.range.exit." + Itoa(labelid)
+
+	stmt.labelPost = labelPost
+	stmt.labelExit = labelExit
+	// initialization: store len(rangeexpr)
+	emitComment(2, "ForRange Initialization\n")
+
+	emitComment(2, "  assign length to lenvar\n")
+	// lenvar = len(s.X)
+	emitVariableAddr(stmt.lenvar)
+	emitLen(stmt.X)
+	emitStore(tInt)
+
+	emitComment(2, "  assign 0 to indexvar\n")
+	// indexvar = 0
+	emitVariableAddr(stmt.indexvar)
+	emitZeroValue(tInt)
+	emitStore(tInt)
+
+	// Condition
+	// if (indexvar < lenvar) then
+	//   execute body
+	// else
+	//   exit
+	emitComment(2, "ForRange Condition\n")
+	fmtPrintf("  %s:\n", labelCond)
+
+	emitVariableAddr(stmt.indexvar)
+	emitLoad(tInt)
+	emitVariableAddr(stmt.lenvar)
+	emitLoad(tInt)
+	emitCompExpr("setl")
+	emitPopBool(" indexvar < lenvar")
+	fmtPrintf("  cmpq $1, %%rax\n")
+	fmtPrintf("  jne %s # jmp if false\n", labelExit)
+
+	if stmt.Key != nil && !isBlankIdent(stmt.Key) {
+		emitComment(2, "assign indexvar to the key variable\n")
+		if stmt.Tok == ":=" {
+			emitNewBox(stmt.Key.ident.Obj.Variable, tInt)
+		}
+		emitAddr(stmt.Key)              // lhs
+		emitVariableAddr(stmt.indexvar) // rhs
+		emitLoad(tInt)
+		emitStore(tInt)
+	}
+
+	if stmt.Value != nil && !isBlankIdent(stmt.Value) {
+		emitComment(2, "assign list[indexvar] value variables\n")
+		var elemType = getTypeOfExpr(stmt.Value)
+		if stmt.Tok == ":=" {
+			emitNewBox(stmt.Value.ident.Obj.Variable, elemType)
+		}
+		emitAddr(stmt.Value) // lhs
+
+		emitVariableAddr(stmt.indexvar)
+		emitLoad(tInt) // index value
+		emitListElementAddr(stmt.X, elemType)
+
+		emitLoad(elemType)
+		emitStore(elemType)
+	}
+
+	// Body
+	emitComment(2, "ForRange Body\n")
+	emitStmt(blockStmt2Stmt(stmt.Body))
+
+	// Post statement: Increment indexvar and go next
+	emitComment(2, "ForRange Post statement\n")
+	fmtPrintf("  %s:\n", labelPost)           // used for "continue"
+	emitVariableAddr(stmt.indexvar) // lhs
+	emitVariableAddr(stmt.indexvar) // rhs
+	emitLoad(tInt)
+	emitAddConst(1, "indexvar value ++")
+	emitStore(tInt)
+
+	fmtPrintf("  jmp %s\n", labelCond)
+
+	fmtPrintf("  %s:\n", labelExit)
+}
+
+// for i, r := range s
+// The index variable is advanced by runtime.decoderune, so that i is the byte offset of r.
+func emitRangeString(stmt *astRangeStmt) {
+	labelid++
+	var labelCond = ".L.range.cond." + Itoa(labelid)
+	var labelPost = ".L.range.post." + Itoa(labelid)
+	var labelExit = ".L.range.exit." + Itoa(labelid)
+
+	stmt.labelPost = labelPost
+	stmt.labelExit = labelExit
+
+	emitComment(2, "ForRange Initialization\n")
+	emitVariableAddr(stmt.lenvar)
+	emitLen(stmt.X)
+	emitStore(tInt)
+
+	emitVariableAddr(stmt.indexvar)
+	emitZeroValue(tInt)
+	emitStore(tInt)
+
+	emitComment(2, "ForRange Condition\n")
+	fmtPrintf("  %s:\n", labelCond)
+	emitVariableAddr(stmt.indexvar)
+	emitLoad(tInt)
+	emitVariableAddr(stmt.lenvar)
+	emitLoad(tInt)
+	emitCompExpr("setl")
+	emitPopBool(" indexvar < lenvar")
+	fmtPrintf("  cmpq $1, %%rax\n")
+	fmtPrintf("  jne %s # jmp if false\n", labelExit)
+
+	if stmt.Key != nil && !isBlankIdent(stmt.Key) {
+		emitComment(2, "assign indexvar to the key variable\n")
+		if stmt.Tok == ":=" {
+			emitNewBox(stmt.Key.ident.Obj.Variable, tInt)
+		}
+		emitAddr(stmt.Key)
+		emitVariableAddr(stmt.indexvar)
+		emitLoad(tInt)
+		emitStore(tInt)
+	}
+
+	// decoderune(s string, k *int) rune
+	emitComment(2, "decode the rune at indexvar\n")
+	var hasValue = stmt.Value != nil && !isBlankIdent(stmt.Value)
+	var elemType *Type
+	if hasValue {
+		elemType = getTypeOfExpr(stmt.Value)
+		if stmt.Tok == ":=" {
+			emitNewBox(stmt.Value.ident.Obj.Variable, elemType)
+		}
+		emitAddr(stmt.Value) // lhs
+	}
+	emitVariableAddr(stmt.indexvar)
+	emitExpr(stmt.X, nil)
+	fmtPrintf("  callq runtime.decoderune\n")
+	emitRevertStackPointer(stringSize + ptrSize)
+	if hasValue {
+		fmtPrintf("  pushq %%rax\n")
+		emitStore(elemType)
+	}
+
+	emitComment(2, "ForRange Body\n")
+	emitStmt(blockStmt2Stmt(stmt.Body))
+
+	// indexvar has already been advanced
+	fmtPrintf("  %s:\n", labelPost) // used for "continue"
+	fmtPrintf("  jmp %s\n", labelCond)
+	fmtPrintf("  %s:\n", labelExit)
+}
+
+// for k, v := range m
+func emitRangeMap(stmt *astRangeStmt) {
+	labelid++
+	var labelCond = ".L.range.cond." + Itoa(labelid)
+	var labelPost = ".L.range.post." + Itoa(labelid)
+	var labelExit = ".L.range.exit." + Itoa(labelid)
+
+	stmt.labelPost = labelPost
+	stmt.labelExit = labelExit
+	var mapType = getTypeOfExpr(stmt.X)
+
+	// it = mapiterinit(m)
+	emitComment(2, "ForRange Initialization\n")
+	emitVariableAddr(stmt.indexvar)
+	emitExpr(stmt.X, nil)
+	fmtPrintf("  callq runtime.mapiterinit\n")
+	emitRevertStackPointer(ptrSize)
+	fmtPrintf("  pushq %%rax # iterator\n")
+	emitStore(tUintptr)
+
+	// Condition: mapiternext(it)
+	emitComment(2, "ForRange Condition\n")
+	fmtPrintf("  %s:\n", labelCond)
+	emitVariableAddr(stmt.indexvar)
+	emitLoad(tUintptr)
+	fmtPrintf("  callq runtime.mapiternext\n")
+	emitRevertStackPointer(ptrSize)
+	fmtPrintf("  cmpq $1, %%rax\n")
+	fmtPrintf("  jne %s # jmp if false\n", labelExit)
+
+	// assign it.key and it.value to variables
+	if stmt.Key != nil && !isBlankIdent(stmt.Key) {
+		var keyType = getMapKeyType(mapType)
+		if stmt.Tok == ":=" {
+			emitNewBox(stmt.Key.ident.Obj.Variable, keyType)
+		}
+		emitAddr(stmt.Key)
+		emitVariableAddr(stmt.indexvar)
+		emitLoad(tUintptr)
+		fmtPrintf("  popq %%rax # iterator\n")
+		fmtPrintf("  pushq 16(%%rax) # it.key\n")
+		emitLoad(keyType)
+		emitStore(keyType)
+	}
+	if stmt.Value != nil && !isBlankIdent(stmt.Value) {
+		var valueType = getMapValueType(mapType)
+		if stmt.Tok == ":=" {
+			emitNewBox(stmt.Value.ident.Obj.Variable, valueType)
+		}
+		emitAddr(stmt.Value)
+		emitVariableAddr(stmt.indexvar)
+		emitLoad(tUintptr)
+		fmtPrintf("  popq %%rax # iterator\n")
+		fmtPrintf("  pushq 24(%%rax) # it.value\n")
+		emitLoad(valueType)
+		emitStore(valueType)
+	}
+
+	// Body
+	emitComment(2, "ForRange Body\n")
+	emitStmt(blockStmt2Stmt(stmt.Body))
+
+	// Post statement
+	emitComment(2, "ForRange Post statement\n")
+	fmtPrintf("  %s:\n", labelPost) // used for "continue"
+	fmtPrintf("  jmp %s\n", labelCond)
+	fmtPrintf("  %s:\n", labelExit)
+}
+
+func blockStmt2Stmt(block *astBlockStmt) *astStmt {
+	var stmt = &astStmt{}
+	stmt.dtype = "*astBlockStmt"
+	stmt.blockStmt = block
+	return stmt
+}
+
+// var x T or var x = v in a function
+func emitLocalVarSpec(valSpec *astValueSpec) {
+	if valSpec.tuple != nil {
+		var lhs *astExpr
+		for _, lhs = range valSpec.tuple.Lhs {
+			if !isBlankIdent(lhs) {
+				emitNewBox(lhs.ident.Obj.Variable, getTypeOfExpr(lhs))
+			}
+		}
+		emitStmt(&astStmt{
+			dtype:      "*astAssignStmt",
+			assignStmt: valSpec.tuple,
+		})
+		return
+	}
+	var t = e2t(valSpec.Type)
+	var ident = valSpec.Name
+	var lhs = &astExpr{}
+	lhs.dtype = "*astIdent"
+	lhs.ident = ident
+	emitNewBox(ident.Obj.Variable, t)
+	var rhs *astExpr
+	if valSpec.Value == nil {
+		emitComment(2, "lhs addresss\n")
+		emitAddr(lhs)
+		emitComment(2, "emitZeroValue for %s\n", t.e.dtype)
+		emitZeroValue(t)
+		emitComment(2, "Assignment: zero value\n")
+		emitStore(t)
+	} else {
+		rhs = valSpec.Value
+		emitAssign(lhs, rhs)
+	}
+}
+
+func emitRevertStackTop(t *Type) {
+	fmtPrintf("  addq $%s, %%rsp # revert stack top\n", Itoa(getPushSizeOfType(t)))
+}
+
+var labelid int
+
+func getMethodSymbol(method *Method) string {
+	var rcvTypeName = method.rcvNamedType
+	if method.isPtrMethod {
+		return "$" + rcvTypeName.Name + "." + method.name // pointer
+	} else {
+		return rcvTypeName.Name + "." + method.name // value
+	}
+}
+
+func getFuncSubSymbol(fnc *Func) string {
+	var subsymbol string
+	if fnc.method != nil {
+		subsymbol = getMethodSymbol(fnc.method)
+	} else {
+		subsymbol = fnc.name
+	}
+	return subsymbol
+}
+
+func getFuncSymbol(pkgPrefix string, subsymbol string) string {
+	return pkgPrefix + "." + subsymbol
+}
+
+var currentFunc *Func
+
+func emitReturnStmt(s *astReturnStmt) {
+	var results = getFuncResults(currentFunc.funcType)
+	if isNamedResults(results) {
+		if len(s.Results) > 0 {
+			emitAssignTuple(getNamedResultIdents(results), s.Results)
+		}
+		emitReturnNamedResults(results)
+		return
+	}
+	switch len(results) {
+	case 0:
+		emitReturn(nil)
+	case 1:
+		var resultType = e2t(results[0].Type)
+		emitExpr(s.Results[0], resultType)
+		emitReturn(resultType)
+	default:
+		if len(s.Results) == 1 {
+			// return f()
+			var size = getResultAreaSize(results)
+			emitExpr(s.Results[0], nil)
+			var i int
+			for i = 0; i < size; i = i + 8 {
+				fmtPrintf("  movq %d(%%rsp), %%rax\n", Itoa(i))
+				fmtPrintf("  movq %%rax, %d(%%rbp) # result\n", Itoa(getResultSlotOffset(results, 0)+i))
+			}
+			emitRevertStackPointer(size)
+		} else {
+			var i int
+			var field *astField
+			for i, field = range results {
+				var t = e2t(field.Type)
+				emitResultSlotAddr(results, i)
+				emitExpr(s.Results[i], t)
+				emitStore(t)
+			}
+		}
+		emitReturn(nil)
+	}
+}
+
+// Results are named, like "func f() (x int, y int)"
+func isNamedResults(results []*astField) bool {
+	return len(results) > 0 && results[0].Name != nil
+}
+
+func getNamedResultIdents(results []*astField) []*astExpr {
+	var idents []*astExpr
+	var field *astField
+	for _, field = range results {
+		idents = append(idents, &astExpr{
+			dtype: "*astIdent",
+			ident: field.Name,
+		})
+	}
+	return idents
+}
+
+// Offset of the i-th result slot of the current function from %rbp
+func getResultSlotOffset(results []*astField, i int) int {
+	return currentFunc.argsarea + getResultOffset(results, i)
+}
+
+func emitResultSlotAddr(results []*astField, i int) {
+	fmtPrintf("  leaq %d(%%rbp), %%rax # result %d\n", Itoa(getResultSlotOffset(results, i)), Itoa(i))
+	fmtPrintf("  pushq %%rax\n")
+}
+
+// Named results are read after the deferred calls, which may modify them.
+func emitReturnNamedResults(results []*astField) {
+	if currentFunc.hasDefer {
+		emitDeferReturn()
+	}
+	var idents = getNamedResultIdents(results)
+	if len(results) == 1 {
+		var resultType = e2t(results[0].Type)
+		emitExpr(idents[0], resultType)
+		emitCopyResultToHeap(resultType)
+		emitPopResult(resultType)
+	} else {
+		var i int
+		var field *astField
+		for i, field = range results {
+			var t = e2t(field.Type)
+			emitResultSlotAddr(results, i)
+			emitExpr(idents[i], t)
+			emitStore(t)
+		}
+	}
+	fmtPrintf("  leave\n")
+	fmtPrintf("  ret\n")
+}
+
+// Return the value on the stack top, after running deferred calls
+func emitReturn(resultType *Type) {
+	emitCopyResultToHeap(resultType)
+	if currentFunc.hasDefer {
+		emitDeferReturn()
+	}
+	emitPopResult(resultType)
+	fmtPrintf("  leave\n")
+	fmtPrintf("  ret\n")
+}
+
+func emitCopyResultToHeap(resultType *Type) {
+	if resultType != nil && (kind(resultType) == T_STRUCT || kind(resultType) == T_ARRAY) {
+		// the value may be a local variable. return a copy on heap.
+		emitCallMalloc(getSizeOfType(resultType))
+		fmtPrintf("  popq %%rax # dst\n")
+		fmtPrintf("  popq %%rcx # src\n")
+		fmtPrintf("  pushq %%rax # dst\n")
+		fmtPrintf("  pushq $%d # size\n", Itoa(getSizeOfType(resultType)))
+		fmtPrintf("  pushq %%rax # dst\n")
+		fmtPrintf("  pushq %%rcx # src\n")
+		fmtPrintf("  callq runtime.memcopy\n")
+		emitRevertStackPointer(ptrSize*2 + intSize)
+	}
+}
+
+// Pop a single result into registers
+func emitPopResult(resultType *Type) {
+	if resultType != nil {
+		var knd = kind(resultType)
+		switch knd {
+		case T_BOOL, T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_FLOAT32, T_FLOAT64, T_UINTPTR, T_POINTER, T_MAP, T_CHAN, T_FUNC:
+			fmtPrintf("  popq %%rax # return 64bit\n")
+		case T_STRING, T_INTERFACE:
+			fmtPrintf("  popq %%rax # return string (ptr)\n")
+			fmtPrintf("  popq %%rdi # return string (len)\n")
+		case T_STRUCT, T_ARRAY:
+			fmtPrintf("  popq %%rax # return the addr of the copy\n")
+		case T_SLICE:
+			fmtPrintf("  popq %%rax # return string (ptr)\n")
+			fmtPrintf("  popq %%rdi # return string (len)\n")
+			fmtPrintf("  popq %%rsi # return string (cap)\n")
+		default:
+			panic2(__func__, "[*astReturnStmt] TBI:"+knd)
+		}
+	}
+}
+
+func emitDeferReturn() {
+	fmtPrintf("  pushq %%rbp # frame\n")
+	fmtPrintf("  callq runtime.deferreturn\n")
+	emitRevertStackPointer(ptrSize)
+}
+
+// Start a goroutine or register a deferred call. The function value and the arguments are evaluated now.
+func emitGoOrDeferStmt(tok string, call *astCallExpr) {
+	if call.tupleArgs != nil {
+		emitAssignTuple(call.tupleArgs.Lhs, call.tupleArgs.Rhs)
+	}
+	var fun = call.Fun
+	var receiver *astExpr
+	var funcType *astFuncType
+	switch fun.dtype {
+	case "*astIdent":
+		var obj = fun.ident.Obj
+		if obj.Kind == astFun {
+			if obj.Decl == nil {
+				panic2(__func__, "TBI: "+tok+" of builtin "+obj.Name)
+			}
+			var symbol = getFuncSymbol(pkg.name, obj.Name)
+			fmtPrintf("  leaq %s(%%rip), %%rax # func value\n", getFuncValueSymbol(symbol))
+			fmtPrintf("  pushq %%rax\n")
+			funcType = obj.Decl.funcDecl.Type
+		}
+	case "*astSelectorExpr":
+		var selectorExpr = fun.selectorExpr
+		if selectorExpr.X.dtype == "*astIdent" && selectorExpr.X.ident.Obj.Kind == "Pkg" {
+			panic2(__func__, "TBI: "+tok+" of "+selectorExpr.X.ident.Name+"."+selectorExpr.Sel.Name)
+		}
+		if !isType(selectorExpr.X) && !isFieldSelector(selectorExpr) {
+			receiver = selectorExpr.X
+			var receiverType = getTypeOfExpr(receiver)
+			if kind(receiverType) == T_INTERFACE {
+				emitGoOrDeferInterfaceMethodCall(tok, receiverType, receiver, selectorExpr.Sel, call.Args, call.Ellipsis)
+				return
+			}
+			var method = lookupMethod(receiverType, selectorExpr.Sel)
+			receiver = adjustReceiver(receiver, method)
+			funcType = method.funcType
+			var symbol = getFuncSymbol(pkg.name, getMethodSymbol(method))
+			fmtPrintf("  leaq %s(%%rip), %%rax # func value\n", getFuncValueSymbol(symbol))
+			fmtPrintf("  pushq %%rax\n")
+		}
+	}
+	if funcType == nil {
+		// func value
+		funcType = getUnderlyingType(getTypeOfExpr(fun)).e.funcType
+		emitExpr(fun, nil)
+	}
+	var args = prepareArgs(funcType, receiver, call.Args, call.Ellipsis)
+	// the result area is copied along with the args, and discarded
+	var resultList = getFuncResults(funcType)
+	emitAllocResultArea(resultList)
+	var totalSize = emitArgs(args) + getResultAreaSize(resultList)
+	fmtPrintf("  movq %d(%%rsp), %%rax # func value\n", Itoa(totalSize))
+	fmtPrintf("  leaq 0(%%rsp), %%rcx # args\n")
+	emitCallNewprocOrDeferproc(tok, totalSize)
+	emitRevertStackPointer(totalSize + ptrSize)
+}
+
+func emitGoOrDeferInterfaceMethodCall(tok string, ifaceType *Type, receiver *astExpr, methodName *astIdent, eArgs []*astExpr, hasEllipsis bool) {
+	var method = lookupMethod(ifaceType, methodName)
+	var args = prepareArgs(method.funcType, receiver, eArgs, hasEllipsis)
+	var index = getInterfaceMethodIndex(ifaceType, methodName.Name)
+	var resultList = getFuncResults(method.funcType)
+	emitAllocResultArea(resultList)
+	var totalSize = emitArgs(args) + getResultAreaSize(resultList)
+	fmtPrintf("  movq 0(%%rsp), %%rax # itab\n")
+	fmtPrintf("  addq $%d, %%rax # the method entry works as a func value\n", Itoa(ptrSize*(index+1)))
+	fmtPrintf("  leaq %d(%%rsp), %%rcx # args without itab\n", Itoa(ptrSize))
+	emitCallNewprocOrDeferproc(tok, totalSize - ptrSize)
+	emitRevertStackPointer(totalSize)
+}
+
+// rax: func value, rcx: args
+func emitCallNewprocOrDeferproc(tok string, argSize int) {
+	fmtPrintf("  pushq %%rcx # argp\n")
+	fmtPrintf("  pushq $%d # siz\n", Itoa(argSize))
+	fmtPrintf("  pushq %%rax # fn\n")
+	if tok == "go" {
+		fmtPrintf("  callq runtime.newproc\n")
+		emitRevertStackPointer(ptrSize*2 + intSize)
+		return
+	}
+	fmtPrintf("  leaq %s(%%rip), %%rax\n", getRecoverLabel(getFuncSymbol(pkg.name, getFuncSubSymbol(currentFunc))))
+	fmtPrintf("  pushq %%rax # pc\n")
+	fmtPrintf("  pushq %%rbp # frame\n")
+	fmtPrintf("  callq runtime.deferproc\n")
+	emitRevertStackPointer(ptrSize*4 + intSize)
+}
+
+// A function which recovered from a panic resumes here
+func getRecoverLabel(symbol string) string {
+	return symbol + ".recover"
+}
+
+func emitFuncDecl(pkgPrefix string, fnc *Func) {
+	currentFunc = fnc
+	var localarea = fnc.localarea
+	fmtPrintf("\n")
+	var subsymbol = getFuncSubSymbol(fnc)
+	var symbol = getFuncSymbol(pkgPrefix, subsymbol)
+	fmtPrintf("%s: # args %d, locals %d\n",
+		symbol, Itoa(int(fnc.argsarea)), Itoa(int(fnc.localarea)))
+
+	fmtPrintf("  pushq %%rbp\n")
+	fmtPrintf("  movq %%rsp, %%rbp\n")
+	if localarea != 0 {
+		fmtPrintf("  subq $%d, %%rsp # local area\n", Itoa(-localarea))
+	}
+	if fnc.outer != nil {
+		fmtPrintf("  movq %%rdx, %d(%%rbp) # closure\n", Itoa(fnc.closureOffset))
+	}
+	var param *astField
+	for _, param = range fnc.params {
+		if param.Name != nil && param.Name.Obj.Variable.isBoxed {
+			// move the param to the heap
+			var variable = param.Name.Obj.Variable
+			var t = e2t(param.Type)
+			emitNewBox(variable, t)
+			fmtPrintf("  pushq $%d # size\n", Itoa(getSizeOfType(t)))
+			fmtPrintf("  pushq %d(%%rbp) # dst\n", Itoa(variable.boxOffset))
+			fmtPrintf("  leaq %d(%%rbp), %%rax # src\n", Itoa(variable.localOffset))
+			fmtPrintf("  pushq %%rax\n")
+			fmtPrintf("  callq runtime.memcopy\n")
+			emitRevertStackPointer(ptrSize*2 + intSize)
+		}
+	}
+
+	var results = getFuncResults(fnc.funcType)
+	if isNamedResults(results) {
+		var ident *astExpr
+		for _, ident = range getNamedResultIdents(results) {
+			var t = getTypeOfExpr(ident)
+			emitNewBox(ident.ident.Obj.Variable, t)
+			emitAddr(ident)
+			emitZeroValue(t)
+			emitStore(t)
+		}
+	}
+
+	if fnc.Body != nil {
+		emitStmt(blockStmt2Stmt(fnc.Body))
+	}
+
+	if fnc.hasDefer {
+		emitDeferReturn()
+	}
+	fmtPrintf("  leave\n")
+	fmtPrintf("  ret\n")
+
+	if fnc.hasDefer {
+		// return the named results or zero values after the remaining deferred calls
+		fmtPrintf("%s:\n", getRecoverLabel(symbol))
+		fmtPrintf("  leaq %d(%%rbp), %%rsp # local area\n", Itoa(localarea))
+		if isNamedResults(results) {
+			emitReturnNamedResults(results)
+		} else if hasResultArea(results) {
+			var i int
+			var field *astField
+			for i, field = range results {
+				var t = e2t(field.Type)
+				emitResultSlotAddr(results, i)
+				emitZeroValue(t)
+				emitStore(t)
+			}
+			emitReturn(nil)
+		} else {
+			var resultType *Type
+			if len(results) > 0 {
+				resultType = e2t(results[0].Type)
+				emitZeroValue(resultType)
+			}
+			emitReturn(resultType)
+		}
+	}
+
+	if len(fnc.freeVars) == 0 {
+		// closure to use the function as a value
+		fmtPrintf(".data\n")
+		fmtPrintf("%s:\n", getFuncValueSymbol(symbol))
+		fmtPrintf("  .quad %s\n", symbol)
+		fmtPrintf(".text\n")
+	}
+}
+
+func getFuncValueSymbol(symbol string) string {
+	return symbol + ".f"
+}
+
+func emitGlobalVariable(name *astIdent, t *Type, val *astExpr) {
+	var typeKind = kind(t)
+	fmtPrintf("%s: # T %s\n", name.Name, typeKind)
+	switch typeKind {
+	case T_STRING:
+		if val != nil && val.dtype == "*astBasicLit" {
+			var sl = getStringLiteral(val.basicLit)
+			fmtPrintf("  .quad %s\n", sl.label)
+			fmtPrintf("  .quad %d\n", Itoa(sl.strlen))
+		} else {
+			fmtPrintf("  .quad 0\n")
+			fmtPrintf("  .quad 0\n")
+		}
+	case T_POINTER:
+		fmtPrintf("  .quad 0 # pointer \n") // @TODO
+	case T_MAP:
+		fmtPrintf("  .quad 0 # map \n")
+	case T_CHAN:
+		fmtPrintf("  .quad 0 # chan\n")
+	case T_FUNC:
+		fmtPrintf("  .quad 0 # func\n")
+	case T_INTERFACE:
+		fmtPrintf("  .quad 0 # itab\n")
+		fmtPrintf("  .quad 0 # data\n")
+	case T_BOOL:
+		if val != nil {
+			switch val.dtype {
+			case "*astIdent":
+				switch val.ident.Obj {
+				case gTrue:
+					fmtPrintf("  .quad 1 # bool true\n")
+				case gFalse:
+					fmtPrintf("  .quad 0 # bool false\n")
+				default:
+					panic2(__func__, "")
+				}
+			default:
+				panic2(__func__, "")
+			}
+		} else {
+			fmtPrintf("  .quad 0 # bool zero value\n")
+		}
+	case T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_UINTPTR:
+		fmtPrintf("  %s %d\n", getDataDirective(t), Itoa(evalGlobalInitialInt(val, t)))
+	case T_FLOAT32, T_FLOAT64:
+		if val != nil && val.dtype == "*astBasicLit" {
+			fmtPrintf("  %s\n", getFloatData(val.basicLit.Value, t))
+		} else {
+			fmtPrintf("  %s 0\n", getDataDirective(t))
+		}
+	case T_SLICE:
+		fmtPrintf("  .quad 0 # ptr\n")
+		fmtPrintf("  .quad 0 # len\n")
+		fmtPrintf("  .quad 0 # cap\n")
+	case T_STRUCT:
+		fmtPrintf("  .zero %d\n", Itoa(getSizeOfType(t)))
+	case T_ARRAY:
+		var arrayType = getUnderlyingType(t).e.arrayType
+		if arrayType.Len == nil {
+			panic2(__func__, "global slice is not supported")
+		}
+		var length = evalInt(arrayType.Len)
+		emitComment(0, "[emitGlobalVariable] array length uint8=%s\n", Itoa(length))
+		var zeroValue string
+		var kind string = kind(e2t(arrayType.Elt))
+		switch kind {
+		case T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_FLOAT32, T_FLOAT64, T_UINTPTR:
+			zeroValue = "  " + getDataDirective(e2t(arrayType.Elt)) + " 0 # " + kind + " zero value\n"
+		case T_STRING:
+			zeroValue = "  .quad 0 # string zero value (ptr)\n"
+			zeroValue = zeroValue + "  .quad 0 # string zero value (len)\n"
+		default:
+			zeroValue = "  .zero " + Itoa(getSizeOfType(e2t(arrayType.Elt))) + " # " + kind + " zero value\n"
+		}
+
+		var i int
+		for i = 0; i < length; i++ {
+			fmtPrintf(zeroValue)
+		}
+	default:
+		panic2(__func__, "TBI:kind="+typeKind)
+	}
+}
+
+// Assembler directive for an integer of the size of t
+func getDataDirective(t *Type) string {
+	switch getSizeOfType(t) {
+	case 1:
+		return ".byte"
+	case 2:
+		return ".word"
+	case 4:
+		return ".long"
+	}
+	return ".quad"
+}
+
+// The initial value of a global integer of type t, which is folded in walk if constant
+func evalGlobalInitialInt(val *astExpr, t *Type) int {
+	if val != nil && val.dtype == "*astBasicLit" {
+		var ival = evalInt(val)
+		checkConstRepresentable(evalConst(val, 0).num, val.basicLit.Value, t)
+		return ival
+	}
+	return 0
+}
+
+func emitData(pkgName string, vars []*astValueSpec, sliterals []*stringLiteralsContainer) {
+	fmtPrintf(".data\n")
+	emitComment(0, "string literals len = %s\n", Itoa(len(sliterals)))
+	var con *stringLiteralsContainer
+	for _, con = range sliterals {
+		emitComment(0, "string literals\n")
+		fmtPrintf("%s:\n", con.sl.label)
+		fmtPrintf("  .string %s\n", con.sl.value)
+	}
+
+	emitComment(0, "===== Global Variables =====\n")
+
+	var spec *astValueSpec
+	for _, spec = range vars {
+		if spec.tuple != nil {
+			var lhs *astExpr
+			for _, lhs = range spec.tuple.Lhs {
+				if !isBlankIdent(lhs) {
+					emitGlobalVariable(lhs.ident, getTypeOfExpr(lhs), nil)
+				}
+			}
+			continue
+		}
+		var t *Type
+		if spec.Type != nil {
+			t = e2t(spec.Type)
+		}
+		var val = spec.Value
+		if !isStaticGlobalValue(t, val) {
+			val = nil // set by the package initializer
+		}
+		emitGlobalVariable(spec.Name, t, val)
+	}
+
+	emitComment(0, "==============================\n")
+}
+
+func emitText(pkgName string, funcs []*Func) {
+	fmtPrintf(".text\n")
+	var fnc *Func
+	for _, fnc = range funcs {
+		emitFuncDecl(pkgName, fnc)
+		if fnc.method != nil && !fnc.method.isPtrMethod {
+			emitPtrMethodWrapper(pkgName, fnc)
+		}
+	}
+}
+
+func generateCode(pkgContainer *PkgContainer) {
+	emitData(pkgContainer.name, pkgContainer.vars, stringLiterals)
+	emitText(pkgContainer.name, pkgContainer.funcs)
+	var wrapper *methodValueWrapper
+	for _, wrapper = range methodValueWrappers {
+		emitMethodValueWrapper(wrapper)
+	}
+	methodValueWrappers = nil
+	var thunk *methodExprThunk
+	for _, thunk = range methodExprThunks {
+		emitMethodExprThunkBody(thunk)
+	}
+	methodExprThunks = nil
+}
+
+// Interface method tables always point to methods with pointer receivers.
+// This wrapper lets value methods be called that way.
+func emitPtrMethodWrapper(pkgPrefix string, fnc *Func) {
+	var method = fnc.method
+	var rcvType = e2t(fnc.rcvType)
+	var rcvSize = getArgSizeOfType(rcvType)
+	var paramsSize = fnc.argsarea - 16 - rcvSize
+	var resultSize = getResultAreaSize(getFuncResults(method.funcType))
+	var symbol = getFuncSymbol(pkgPrefix, "$"+method.rcvNamedType.Name+"."+method.name)
+	fmtPrintf("\n")
+	fmtPrintf("%s: # wrapper of %s\n", symbol, getFuncSymbol(pkgPrefix, getMethodSymbol(method)))
+	fmtPrintf("  pushq %%rbp\n")
+	fmtPrintf("  movq %%rsp, %%rbp\n")
+	fmtPrintf("  subq $%d, %%rsp # for args and results\n", Itoa(rcvSize+paramsSize+resultSize))
+	fmtPrintf("  movq 16(%%rbp), %%rsi # pointer receiver\n")
+	var i int
+	for i = 0; i < rcvSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rsi), %%rax\n", Itoa(i))
+		fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(i))
+	}
+	for i = 0; i < paramsSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rbp), %%rax\n", Itoa(24+i))
+		fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(rcvSize+i))
+	}
+	fmtPrintf("  callq %s\n", getFuncSymbol(pkgPrefix, getMethodSymbol(method)))
+	for i = 0; i < resultSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rsp), %%rax # result\n", Itoa(rcvSize+paramsSize+i))
+		fmtPrintf("  movq %%rax, %d(%%rbp)\n", Itoa(24+paramsSize+i))
+	}
+	fmtPrintf("  leave\n")
+	fmtPrintf("  ret\n")
+
+	// closure to use the wrapper as the method expression (*T).m
+	fmtPrintf(".data\n")
+	fmtPrintf("%s:\n", getFuncValueSymbol(symbol))
+	fmtPrintf("  .quad %s\n", symbol)
+	fmtPrintf(".text\n")
+}
+
+// The wrapper puts the receiver in the closure before the arguments, and calls the method
+func emitMethodValueWrapper(wrapper *methodValueWrapper) {
+	var paramsSize int
+	var field *astField
+	for _, field = range wrapper.funcType.Params.List {
+		paramsSize = paramsSize + getArgSizeOfType(e2t(field.Type))
+	}
+	var resultSize = getResultAreaSize(getFuncResults(wrapper.funcType))
+	var rcvSize = wrapper.rcvSize
+	fmtPrintf("\n")
+	fmtPrintf("%s: # method value\n", wrapper.label)
+	fmtPrintf("  pushq %%rbp\n")
+	fmtPrintf("  movq %%rsp, %%rbp\n")
+	fmtPrintf("  subq $%d, %%rsp # for args and results\n", Itoa(rcvSize+paramsSize+resultSize))
+	var i int
+	for i = 0; i < rcvSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rdx), %%rax # receiver\n", Itoa(wrapper.rcvOffset+i))
+		fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(i))
+	}
+	for i = 0; i < paramsSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rbp), %%rax\n", Itoa(16+i))
+		fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(rcvSize+i))
+	}
+	if wrapper.symbol == "" {
+		fmtPrintf("  callq *8(%%rdx)\n")
+	} else {
+		fmtPrintf("  callq %s\n", wrapper.symbol)
+	}
+	for i = 0; i < resultSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rsp), %%rax # result\n", Itoa(rcvSize+paramsSize+i))
+		fmtPrintf("  movq %%rax, %d(%%rbp)\n", Itoa(16+paramsSize+i))
+	}
+	fmtPrintf("  leave\n")
+	fmtPrintf("  ret\n")
+}
+
+// The thunk takes the receiver before the arguments. It calls the method with the data
+// of an interface as the receiver, or with the address of the receiver in its arguments.
+func emitMethodExprThunkBody(thunk *methodExprThunk) {
+	var paramsSize int
+	var field *astField
+	for _, field = range thunk.funcType.Params.List {
+		paramsSize = paramsSize + getArgSizeOfType(e2t(field.Type))
+	}
+	var resultSize = getResultAreaSize(getFuncResults(thunk.funcType))
+	var rcvSize = thunk.rcvSize
+	fmtPrintf("\n")
+	fmtPrintf("%s: # method expression\n", thunk.label)
+	fmtPrintf("  pushq %%rbp\n")
+	fmtPrintf("  movq %%rsp, %%rbp\n")
+	fmtPrintf("  subq $%d, %%rsp # for args and results\n", Itoa(ptrSize+paramsSize+resultSize))
+	if thunk.symbol == "" {
+		fmtPrintf("  movq 24(%%rbp), %%rax # data\n")
+	} else {
+		fmtPrintf("  leaq 16(%%rbp), %%rax # address of the receiver\n")
+	}
+	fmtPrintf("  movq %%rax, 0(%%rsp) # receiver\n")
+	var i int
+	for i = 0; i < paramsSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rbp), %%rax\n", Itoa(16+rcvSize+i))
+		fmtPrintf("  movq %%rax, %d(%%rsp)\n", Itoa(ptrSize+i))
+	}
+	if thunk.symbol == "" {
+		fmtPrintf("  movq 16(%%rbp), %%rax # itab\n")
+		fmtPrintf("  movq %d(%%rax), %%rax # method\n", Itoa(ptrSize*(thunk.index+1)))
+		fmtPrintf("  callq *%%rax\n")
+	} else {
+		fmtPrintf("  callq %s\n", thunk.symbol)
+	}
+	for i = 0; i < resultSize; i = i + 8 {
+		fmtPrintf("  movq %d(%%rsp), %%rax # result\n", Itoa(ptrSize+paramsSize+i))
+		fmtPrintf("  movq %%rax, %d(%%rbp)\n", Itoa(16+rcvSize+paramsSize+i))
+	}
+	fmtPrintf("  leave\n")
+	fmtPrintf("  ret\n")
+
+	fmtPrintf(".data\n")
+	fmtPrintf("%s:\n", getFuncValueSymbol(thunk.label))
+	fmtPrintf("  .quad %s\n", thunk.label)
+	fmtPrintf(".text\n")
+}
+
+// --- dynamic types ---
+type descriptorMethod struct {
+	name   string
+	symbol string
+}
+
+type typeDescriptor struct {
+	label     string
+	name      string
+	kind      int
+	size      int
+	methods   []*descriptorMethod
+	keyLayout string // of a struct or an array which is not compared by its bytes
+}
+
+type itabEntry struct {
+	label   string
+	key     string
+	dtype   *typeDescriptor
+	symbols []string
+}
+
+var typeDescriptors []*typeDescriptor
+var itabs []*itabEntry
+
+// reflect.Kind values
+func getKindCode(t *Type) int {
+	switch kind(t) {
+	case T_BOOL:
+		return 1
+	case T_INT:
+		return 2
+	case T_INT8:
+		return 3
+	case T_INT16:
+		return 4
+	case T_INT32:
+		return 5
+	case T_INT64:
+		return 6
+	case T_UINT:
+		return 7
+	case T_UINT8:
+		return 8
+	case T_UINT16:
+		return 9
+	case T_UINT32:
+		return 10
+	case T_UINT64:
+		return 11
+	case T_FLOAT32:
+		return 13
+	case T_FLOAT64:
+		return 14
+	case T_UINTPTR:
+		return 12
+	case T_ARRAY:
+		return 17
+	case T_CHAN:
+		return 18
+	case T_FUNC:
+		return 19
+	case T_INTERFACE:
+		return 20
+	case T_MAP:
+		return 21
+	case T_POINTER:
+		return 22
+	case T_SLICE:
+		return 23
+	case T_STRING:
+		return 24
+	case T_STRUCT:
+		return 25
+	}
+	panic2(__func__, "unexpected kind "+kind(t))
+	return 0
+}
+
+func typeString(t *Type) string {
+	var e = t.e
+	switch e.dtype {
+	case "*astIdent":
+		var obj = e.ident.Obj
+		if obj.Decl == nil || obj == gError || obj == gRuntimeError {
+			return obj.Name
+		}
+		return pkg.name + "." + obj.Name
+	case "*astParenExpr":
+		return typeString(e2t(e.parenExpr.X))
+	case "*astStarExpr":
+		return "*" + typeString(e2t(e.starExpr.X))
+	case "*astEllipsis":
+		return "[]" + typeString(e2t(e.ellipsis.Elt))
+	case "*astArrayType":
+		if e.arrayType.Len == nil {
+			return "[]" + typeString(e2t(e.arrayType.Elt))
+		}
+		return "[" + Itoa(evalInt(e.arrayType.Len)) + "]" + typeString(e2t(e.arrayType.Elt))
+	case "*astMapType":
+		return "map[" + typeString(e2t(e.mapType.Key)) + "]" + typeString(e2t(e.mapType.Value))
+	case "*astChanType":
+		switch e.chanType.Dir {
+		case astSEND:
+			return "chan<- " + typeString(e2t(e.chanType.Value))
+		case astRECV:
+			return "<-chan " + typeString(e2t(e.chanType.Value))
+		}
+		return "chan " + typeString(e2t(e.chanType.Value))
+	case "*astStructType":
+		var fields = e.structType.Fields.List
+		if len(fields) == 0 {
+			return "struct {}"
+		}
+		var r = "struct {"
+		var i int
+		var field *astField
+		for i, field = range fields {
+			if i > 0 {
+				r = r + ";"
+			}
+			r = r + " " + field.Name.Name + " " + typeString(e2t(field.Type))
+		}
+		return r + " }"
+	case "*astFuncType":
+		var r = "func("
+		var i int
+		var field *astField
+		for i, field = range e.funcType.Params.List {
+			if i > 0 {
+				r = r + ", "
+			}
+			r = r + typeString(e2t(field.Type))
+		}
+		r = r + ")"
+		if e.funcType.Results != nil {
+			var results = e.funcType.Results.List
+			if len(results) == 1 {
+				return r + " " + typeString(e2t(results[0].Type))
+			}
+			r = r + " ("
+			for i, field = range results {
+				if i > 0 {
+					r = r + ", "
+				}
+				r = r + typeString(e2t(field.Type))
+			}
+			r = r + ")"
+		}
+		return r
+	case "*astInterfaceType":
+		var names = getInterfaceMethods(t)
+		if len(names) == 0 {
+			return "interface {}"
+		}
+		var r = "interface {"
+		var i int
+		var name string
+		for i, name = range names {
+			if i > 0 {
+				r = r + ";"
+			}
+			r = r + " " + name + "()"
+		}
+		return r + " }"
+	}
+	panic2(__func__, "TBI:"+e.dtype)
+	return ""
+}
+
+// Returns method names of an interface type including embedded ones, in the order of the itab.
+func getInterfaceMethods(t *Type) []string {
+	var names []string
+	var iface = getUnderlyingType(t).e.interfaceType
+	var field *astField
+	for _, field = range iface.Methods.List {
+		if field.Name == nil { // embedded interface
+			var name string
+			for _, name = range getInterfaceMethods(e2t(field.Type)) {
+				names = append(names, name)
+			}
+		} else {
+			names = append(names, field.Name.Name)
+		}
+	}
+	return names
+}
+
+func getInterfaceMethodIndex(t *Type, name string) int {
+	var i int
+	var n string
+	for i, n = range getInterfaceMethods(t) {
+		if n == name {
+			return i
+		}
+	}
+	panic2(__func__, "method not found: "+name)
+	return 0
+}
+
+// Methods of T or *T, which can be called through interfaces
+func getMethodSetOfDynamicType(t *Type) []*Method {
+	var methods []*Method
+	var isPtr bool
+	var e = t.e
+	if e.dtype == "*astStarExpr" {
+		isPtr = true
+		e = e.starExpr.X
+	}
+	if e.dtype != "*astIdent" || e.ident.Obj.Decl == nil {
+		return methods
+	}
+	var nt = findNamedType(e.ident.Name)
+	if nt == nil {
+		nt = &namedTypeEntry{}
+	}
+	var candidates []*Method
+	var me *methodEntry
+	for _, me = range nt.methods {
+		candidates = append(candidates, me.method)
+	}
+	var method *Method
+	for _, method = range getPromotedMethods(e2t(e)) {
+		candidates = append(candidates, method)
+	}
+	for _, method = range candidates {
+		if isPtr || !method.isPtrMethod {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+func getTypeDescriptor(t *Type) *typeDescriptor {
+	var name = typeString(t)
+	var td *typeDescriptor
+	for _, td = range typeDescriptors {
+		if td.name == name {
+			return td
+		}
+	}
+	td = &typeDescriptor{
+		label: "typedesc." + Itoa(len(typeDescriptors)),
+		name:  name,
+		kind:  getKindCode(t),
+		size:  getSizeOfType(t),
+	}
+	if (kind(t) == T_STRUCT || kind(t) == T_ARRAY) && isComparable(t) && !isMemComparable(t) {
+		td.keyLayout = getKeyLayout(t).label
+	}
+	if kind(t) == T_INTERFACE {
+		var mname string
+		for _, mname = range getInterfaceMethods(t) {
+			td.methods = append(td.methods, &descriptorMethod{
+				name: mname,
+			})
+		}
+	} else {
+		var method *Method
+		for _, method = range getMethodSetOfDynamicType(t) {
+			var symbol = getFuncSymbol(pkg.name, "$"+method.rcvNamedType.Name+"."+method.name)
+			if isDirectIface(t) && kind(t) != T_POINTER {
+				// the data word is the receiver itself, like a named map or func
+				symbol = getFuncSymbol(pkg.name, getMethodSymbol(method))
+			}
+			td.methods = append(td.methods, &descriptorMethod{
+				name:   method.name,
+				symbol: symbol,
+			})
+		}
+	}
+	typeDescriptors = append(typeDescriptors, td)
+	return td
+}
+
+// The dynamic type of recovered runtime errors, which implements runtime.Error.
+// It is a string whose methods are defined as functions in the runtime.
+func getRuntimeErrorTypeDescriptor() *typeDescriptor {
+	var name = "runtime.errorString"
+	var td *typeDescriptor
+	for _, td = range typeDescriptors {
+		if td.name == name {
+			return td
+		}
+	}
+	td = &typeDescriptor{
+		label: "typedesc." + Itoa(len(typeDescriptors)),
+		name:  name,
+		kind:  getKindCode(tString),
+		size:  getSizeOfType(tString),
+	}
+	td.methods = append(td.methods, &descriptorMethod{
+		name:   "Error",
+		symbol: "runtime.runtimeErrorError",
+	})
+	td.methods = append(td.methods, &descriptorMethod{
+		name:   "RuntimeError",
+		symbol: "runtime.runtimeErrorRuntimeError",
+	})
+	typeDescriptors = append(typeDescriptors, td)
+	return td
+}
+
+// The itab of an empty interface is the type descriptor itself.
+func getItab(ifaceType *Type, t *Type) *itabEntry {
+	var td = getTypeDescriptor(t)
+	var names = getInterfaceMethods(ifaceType)
+	if len(names) == 0 {
+		return &itabEntry{
+			label: td.label,
+			dtype: td,
+		}
+	}
+	var key = typeString(ifaceType) + "," + td.name
+	var tab *itabEntry
+	for _, tab = range itabs {
+		if tab.key == key {
+			return tab
+		}
+	}
+	tab = &itabEntry{
+		label: "itab." + Itoa(len(itabs)),
+		key:   key,
+		dtype: td,
+	}
+	var name string
+	for _, name = range names {
+		var symbol string
+		var m *descriptorMethod
+		for _, m = range td.methods {
+			if m.name == name {
+				symbol = m.symbol
+			}
+		}
+		if symbol == "" {
+			var reason = "missing method " + name
+			var ptrType = &astExpr{
+				dtype:    "*astStarExpr",
+				starExpr: &astStarExpr{X: t.e},
+			}
+			var method *Method
+			for _, method = range getMethodSetOfDynamicType(e2t(ptrType)) {
+				if method.name == name {
+					reason = "method " + name + " has pointer receiver"
+				}
+			}
+			panic2(__func__, td.name+" does not implement "+typeString(ifaceType)+" ("+reason+")")
+		}
+		tab.symbols = append(tab.symbols, symbol)
+	}
+	itabs = append(itabs, tab)
+	return tab
+}
+
+func emitDynamicTypes() {
+	fmtPrintf("# ===== promoted methods =====\n")
+	fmtPrintf(".text\n")
+	var pm *promotedMethod
+	for _, pm = range promotedMethods {
+		emitPromotedMethodWrapper(pm)
+	}
+	fmtPrintf("# ===== dynamic types =====\n")
+	fmtPrintf(".data\n")
+	var kl *keyLayout
+	var i int
+	var offset int
+	for _, kl = range keyLayouts {
+		fmtPrintf("%s: # %s\n", kl.label, kl.key)
+		fmtPrintf("  .quad %d # number of parts\n", Itoa(len(kl.offsets)))
+		for i, offset = range kl.offsets {
+			fmtPrintf("  .quad %d # offset\n", Itoa(offset))
+			fmtPrintf("  .quad %d # key kind\n", Itoa(kl.kinds[i]))
+		}
+	}
+	var td *typeDescriptor
+	for _, td = range typeDescriptors {
+		var nmethods = Itoa(len(td.methods))
+		fmtPrintf("%s: # %s\n", td.label, td.name)
+		fmtPrintf("  .quad %s # self\n", td.label)
+		fmtPrintf("  .quad %s.name\n", td.label)
+		fmtPrintf("  .quad %d\n", Itoa(len(td.name)))
+		fmtPrintf("  .quad %d # kind\n", Itoa(td.kind))
+		fmtPrintf("  .quad %d # size\n", Itoa(td.size))
+		fmtPrintf("  .quad %s.methods\n", td.label)
+		fmtPrintf("  .quad %d\n", nmethods)
+		fmtPrintf("  .quad %d\n", nmethods)
+		if td.keyLayout == "" {
+			fmtPrintf("  .quad 0 # key layout\n")
+		} else {
+			fmtPrintf("  .quad %s # key layout\n", td.keyLayout)
+		}
+		fmtPrintf("%s.name:\n", td.label)
+		fmtPrintf("  .string \"%s\"\n", td.name)
+		fmtPrintf("%s.methods:\n", td.label)
+		var i int
+		var m *descriptorMethod
+		for i, m = range td.methods {
+			fmtPrintf("  .quad %s.m%d\n", td.label, Itoa(i))
+			fmtPrintf("  .quad %d\n", Itoa(len(m.name)))
+			if m.symbol == "" {
+				fmtPrintf("  .quad 0\n")
+			} else {
+				fmtPrintf("  .quad %s\n", m.symbol)
+			}
+		}
+		for i, m = range td.methods {
+			fmtPrintf("%s.m%d:\n", td.label, Itoa(i))
+			fmtPrintf("  .string \"%s\"\n", m.name)
+		}
+	}
+	var tab *itabEntry
+	for _, tab = range itabs {
+		fmtPrintf("%s: # %s\n", tab.label, tab.key)
+		fmtPrintf("  .quad %s\n", tab.dtype.label)
+		var symbol string
+		for _, symbol = range tab.symbols {
+			fmtPrintf("  .quad %s\n", symbol)
+		}
+	}
+}
+
+// --- type ---
+const sliceSize int = 24
+const stringSize int = 16
+const interfaceSize int = 16
+const intSize int = 8
+const ptrSize int = 8
+
+type Type struct {
+	//kind string
+	e *astExpr
+}
+
+const T_STRING string = "T_STRING"
+const T_SLICE string = "T_SLICE"
+const T_BOOL string = "T_BOOL"
+const T_INT string = "T_INT"
+const T_INT8 string = "T_INT8"
+const T_INT16 string = "T_INT16"
+const T_INT32 string = "T_INT32"
+const T_INT64 string = "T_INT64"
+const T_UINT string = "T_UINT"
+const T_UINT8 string = "T_UINT8"
+const T_UINT16 string = "T_UINT16"
+const T_UINT32 string = "T_UINT32"
+const T_UINT64 string = "T_UINT64"
+const T_UINTPTR string = "T_UINTPTR"
+const T_FLOAT32 string = "T_FLOAT32"
+const T_FLOAT64 string = "T_FLOAT64"
+const T_ARRAY string = "T_ARRAY"
+const T_STRUCT string = "T_STRUCT"
+const T_POINTER string = "T_POINTER"
+const T_MAP string = "T_MAP"
+const T_CHAN string = "T_CHAN"
+const T_FUNC string = "T_FUNC"
+const T_INTERFACE string = "T_INTERFACE"
+
+var tInt *Type
+var tInt32 *Type // rune
+var tUint8 *Type
+var tUint16 *Type
+var tUintptr *Type
+var tFloat32 *Type
+var tFloat64 *Type
+var tSliceOfString *Type
+var tSliceOfBytes *Type
+var tString *Type
+var tBool *Type
+var tEmptyInterface *Type
+
+var generalSlice *astExpr
+
+func getTypeOfExpr(expr *astExpr) *Type {
+	//emitComment(0, "[%s] start\n", __func__)
+	switch expr.dtype {
+	case "*astIdent":
+		switch expr.ident.Obj.Kind {
+		case astVar:
+			switch expr.ident.Obj.Decl.dtype {
+			case "*astValueSpec":
+				var decl = expr.ident.Obj.Decl.valueSpec
+				if decl.Type == nil && decl.tuple != nil {
+					return getTypeOfShortVar(decl.tuple, expr.ident.Obj)
+				}
+				if decl.Type == nil && decl.Value != nil {
+					// a global variable declared later
+					decl.Type = getTypeOfExpr(decl.Value).e
+				}
+				var t = &Type{}
+				t.e = decl.Type
+				return t
+			case "*astField":
+				var decl = expr.ident.Obj.Decl.field
+				var t = &Type{}
+				t.e = decl.Type
+				return t
+			case "*astAssignStmt": // lhs := rhs
+				return getTypeOfShortVar(expr.ident.Obj.Decl.assignment, expr.ident.Obj)
+			default:
+				panic2(__func__, "dtype:" + expr.ident.Obj.Decl.dtype )
+			}
+		case astCon:
+			switch expr.ident.Obj {
+			case gTrue, gFalse:
+				return tBool
+			}
+			switch expr.ident.Obj.Decl.dtype {
+			case "*astValueSpec":
+				var spec = expr.ident.Obj.Decl.valueSpec
+				if spec.Type == nil {
+					// the default type of the untyped constant
+					return getTypeOfExpr(spec.Value)
+				}
+				return e2t(spec.Type)
+			default:
+				panic2(__func__, "cannot decide type of cont ="+expr.ident.Obj.Name)
+			}
+		case astFun:
+			return e2t(&astExpr{
+				dtype:    "*astFuncType",
+				funcType: expr.ident.Obj.Decl.funcDecl.Type,
+			})
+		default:
+			panic2(__func__, "2:Obj.Kind="+expr.ident.Obj.Kind)
+		}
+	case "*astBasicLit":
+		switch expr.basicLit.Kind {
+		case "STRING":
+			return tString
+		case "INT":
+			return tInt
+		case "FLOAT":
+			return tFloat64
+		case "CHAR":
+			return tInt32
+		default:
+			panic2(__func__, "TBI:"+expr.basicLit.Kind)
+		}
+	case "*astIndexExpr":
+		var list = expr.indexExpr.X
+		var listType = getTypeOfExpr(list)
+		if kind(listType) == T_MAP {
+			return getMapValueType(listType)
+		}
+		return getElementTypeOfListType(listType)
+	case "*astUnaryExpr":
+		switch expr.unaryExpr.Op {
+		case "+", "-", "^":
+			return getTypeOfExpr(expr.unaryExpr.X)
+		case "!":
+			return tBool
+		case "<-":
+			return getChanElemType(getTypeOfExpr(expr.unaryExpr.X))
+		case "&":
+			var starExpr = &astStarExpr{}
+			var t = getTypeOfExpr(expr.unaryExpr.X)
+			starExpr.X = t.e
+			var eStarExpr = &astExpr{}
+			eStarExpr.dtype = "*astStarExpr"
+			eStarExpr.starExpr = starExpr
+			return e2t(eStarExpr)
+		default:
+			panic2(__func__, "TBI: Op="+expr.unaryExpr.Op)
+		}
+	case "*astCallExpr":
+		emitComment(0, "[%s] *astCallExpr\n", __func__)
+		var fun = expr.callExpr.Fun
+		switch fun.dtype {
+		case "*astIdent":
+			var fn = fun.ident
+			if fn.Obj == nil {
+				panic2(__func__, "[astCallExpr] nil Obj is not allowed")
+			}
+			switch fn.Obj.Kind {
+			case astTyp:
+				return e2t(fun)
+			case astVar:
+				return getResultTypeOfFuncValue(fun)
+			case astFun:
+				switch fn.Obj {
+				case gLen, gCap, gCopy:
+					return tInt
+				case gAppend:
+					return getTypeOfExpr(expr.callExpr.Args[0])
+				case gNew:
+					var starExpr = &astStarExpr{}
+					starExpr.X = expr.callExpr.Args[0]
+					var eStarExpr = &astExpr{}
+					eStarExpr.dtype = "*astStarExpr"
+					eStarExpr.starExpr = starExpr
+					return e2t(eStarExpr)
+				case gMake:
+					return e2t(expr.callExpr.Args[0])
+				case gRecover:
+					return tEmptyInterface
+				}
+				var decl = fn.Obj.Decl
+				if decl == nil {
+					panic2(__func__, "decl of function "+fn.Name+" is  nil")
+				}
+				switch decl.dtype {
+				case "*astFuncDecl":
+					var resultList = decl.funcDecl.Type.Results.List
+					if len(resultList) != 1 {
+						panic2(__func__, "[astCallExpr] len results.List is not 1")
+					}
+					return e2t(decl.funcDecl.Type.Results.List[0].Type)
+				default:
+					panic2(__func__, "[astCallExpr] decl.dtype="+decl.dtype)
+				}
+				panic2(__func__, "[astCallExpr] Fun ident "+fn.Name)
+			}
+		case "*astArrayType", "*astInterfaceType":
+			return e2t(fun)
+		case "*astSelectorExpr": // (X).Sel()
+			var selectorExpr = fun.selectorExpr
+			if selectorExpr.X.dtype == "*astIdent" && selectorExpr.X.ident.Obj.Kind == "Pkg" {
+				var symbol = selectorExpr.X.ident.Name + "." + selectorExpr.Sel.Name
+				if symbol == "unsafe.Pointer" {
+					return tUintptr
+				}
+				var stdFuncType = getStdFuncType(symbol)
+				assert(stdFuncType != nil && len(stdFuncType.Results.List) == 1, "unexpected function "+symbol, __func__)
+				return e2t(stdFuncType.Results.List[0].Type)
+			}
+			if isType(fun.selectorExpr.X) || isFieldSelector(fun.selectorExpr) {
+				return getResultTypeOfFuncValue(fun)
+			}
+			var xType = getTypeOfExpr(fun.selectorExpr.X)
+			var method = lookupMethod(xType, fun.selectorExpr.Sel)
+			assert(len(method.funcType.Results.List) == 1, "func is expected to return a single value", __func__)
+			return e2t(method.funcType.Results.List[0].Type)
+		default:
+			if isType(fun) {
+				return e2t(fun)
+			}
+			return getResultTypeOfFuncValue(fun)
+		}
+	case "*astSliceExpr":
+		var listType = getTypeOfExpr(expr.sliceExpr.X)
+		switch kind(listType) {
+		case T_STRING:
+			return tString
+		case T_SLICE:
+			if listType.e.dtype != "*astEllipsis" {
+				return listType
+			}
+		case T_POINTER:
+			// a pointer to an array
+			listType = e2t(listType.e.starExpr.X)
+		}
+		var t = &astArrayType{}
+		t.Len = nil
+		t.Elt = getElementTypeOfListType(getUnderlyingType(listType)).e
+		var e = &astExpr{}
+		e.dtype = "*astArrayType"
+		e.arrayType = t
+		return e2t(e)
+	case "*astStarExpr":
+		var t = getTypeOfExpr(expr.starExpr.X)
+		var ptrType = t.e.starExpr
+		if ptrType == nil {
+			panic2(__func__, "starExpr shoud not be nil")
+		}
+		return e2t(ptrType.X)
+	case "*astBinaryExpr":
+		switch expr.binaryExpr.Op {
+		case "==", "!=", "<", ">", "<=", ">=":
+			return tBool
+		case "<<", ">>":
+			return getTypeOfExpr(expr.binaryExpr.X)
+		default:
+			return getOperandType(expr.binaryExpr)
+		}
+	case "*astSelectorExpr":
+		if isOsArgs(expr.selectorExpr) {
+			// os.Args
+			return tSliceOfString
+		}
+		if isType(expr.selectorExpr.X) {
+			return getMethodExprType(expr.selectorExpr)
+		}
+		resolvePromotedSelector(expr.selectorExpr)
+		var method = lookupSelectedMethod(expr.selectorExpr)
+		if method != nil {
+			// a method value
+			return e2t(&astExpr{
+				dtype:    "*astFuncType",
+				funcType: method.funcType,
+			})
+		}
+		var structType = getStructTypeOfX(expr.selectorExpr)
+		var field = lookupStructField(getStructType(structType), expr.selectorExpr.Sel.Name)
+		return e2t(field.Type)
+	case "*astCompositeLit":
+		completeCompositeLit(expr.compositeLit)
+		return e2t(expr.compositeLit.Type)
+	case "*astParenExpr":
+		return getTypeOfExpr(expr.parenExpr.X)
+	case "*astTypeAssertExpr":
+		return e2t(expr.typeAssertExpr.Type)
+	case "*astFuncLit":
+		return e2t(&astExpr{
+			dtype:    "*astFuncType",
+			funcType: expr.funcLit.Type,
+		})
+	default:
+		panic2(__func__, "TBI:dtype="+expr.dtype)
+	}
+
+	panic2(__func__, "nil type is not allowed\n")
+	var r *Type
+	return r
+}
+
+// Type of the result of calling fun, which is a function value
+func getResultTypeOfFuncValue(fun *astExpr) *Type {
+	var funcType = getUnderlyingType(getTypeOfExpr(fun)).e.funcType
+	assert(funcType.Results != nil && len(funcType.Results.List) == 1, "func is expected to return a single value", __func__)
+	return e2t(funcType.Results.List[0].Type)
+}
+
+// Type of a variable declared by "lhs := rhs"
+func getTypeOfShortVar(as *astAssignStmt, obj *astObject) *Type {
+	var i int
+	var lhs *astExpr
+	var index int = -1
+	for i, lhs = range as.Lhs {
+		if lhs.ident.Obj == obj {
+			index = i
+		}
+	}
+	assert(index >= 0, "variable not found in lhs", __func__)
+	var rhs = as.Rhs[0]
+	if rhs.dtype == "*astUnaryExpr" && rhs.unaryExpr.Op == "range" {
+		var rangeType = getTypeOfExpr(rhs.unaryExpr.X)
+		if kind(rangeType) == T_MAP {
+			if index == 0 {
+				return getMapKeyType(rangeType)
+			}
+			return getMapValueType(rangeType)
+		}
+		if kind(rangeType) == T_CHAN {
+			return getChanElemType(rangeType)
+		}
+		if index == 0 {
+			return tInt
+		}
+		if kind(rangeType) == T_STRING {
+			return tInt32 // rune
+		}
+		return getElementTypeOfListType(rangeType)
+	}
+	if len(as.Lhs) == len(as.Rhs) {
+		return getTypeOfExpr(as.Rhs[index])
+	}
+	if isMultiValueCall(rhs) {
+		// x, y := f()
+		var results = getCallResults(rhs.callExpr)
+		return e2t(results[index].Type)
+	}
+	// v, ok := x
+	assert(len(as.Lhs) == 2 && isCommaOkExpr(rhs), "unexpected multi-value assignment", __func__)
+	if index == 0 {
+		return getTypeOfExpr(rhs)
+	}
+	return tBool
+}
+
+func e2t(typeExpr *astExpr) *Type {
+	if typeExpr == nil {
+		panic2(__func__, "nil is not allowed")
+	}
+	var r = &Type{}
+	r.e = typeExpr
+	return r
+}
+
+func kind(t *Type) string {
+	if t == nil {
+		panic2(__func__, "nil type is not expected\n")
+	}
+	if t.e == generalSlice {
+		return T_SLICE
+	}
+
+	var e = t.e
+	switch t.e.dtype {
+	case "*astIdent":
+		var ident = t.e.ident
+		switch ident.Name {
+		case "uintptr":
+			return T_UINTPTR
+		case "int":
+			return T_INT
+		case "int8":
+			return T_INT8
+		case "int16":
+			return T_INT16
+		case "int32", "rune":
+			return T_INT32
+		case "int64":
+			return T_INT64
+		case "string":
+			return T_STRING
+		case "uint":
+			return T_UINT
+		case "uint8", "byte":
+			return T_UINT8
+		case "uint16":
+			return T_UINT16
+		case "uint32":
+			return T_UINT32
+		case "uint64":
+			return T_UINT64
+		case "float32":
+			return T_FLOAT32
+		case "float64":
+			return T_FLOAT64
+		case "bool":
+			return T_BOOL
+		default:
+			// named type
+			var decl = ident.Obj.Decl
+			if decl.dtype != "*astTypeSpec" {
+				panic2(__func__, "unsupported decl :"+decl.dtype)
+			}
+			var typeSpec = decl.typeSpec
+			return kind(e2t(typeSpec.Type))
+		}
+	case "*astStructType":
+		return T_STRUCT
+	case "*astArrayType":
+		if e.arrayType.Len == nil {
+			return T_SLICE
+		} else {
+			return T_ARRAY
+		}
+	case "*astStarExpr":
+		return T_POINTER
+	case "*astMapType":
+		return T_MAP
+	case "*astChanType":
+		return T_CHAN
+	case "*astInterfaceType":
+		return T_INTERFACE
+	case "*astFuncType":
+		return T_FUNC
+	case "*astParenExpr":
+		return kind(e2t(e.parenExpr.X))
+	case "*astEllipsis": // x ...T
+		return T_SLICE // @TODO is this right ?
+	default:
+		panic2(__func__, "Unkown dtype:"+t.e.dtype)
+	}
+	panic2(__func__, "error")
+	return ""
+}
+
+// Resolve named types to their underlying type
+func getUnderlyingType(t *Type) *Type {
+	switch t.e.dtype {
+	case "*astIdent":
+		var obj = t.e.ident.Obj
+		if obj != nil && obj.Decl != nil && obj.Decl.dtype == "*astTypeSpec" {
+			return getUnderlyingType(e2t(obj.Decl.typeSpec.Type))
+		}
+	case "*astParenExpr":
+		return getUnderlyingType(e2t(t.e.parenExpr.X))
+	}
+	return t
+}
+
+// Type in which the operands of a binary expression are evaluated.
+// An untyped constant operand is converted to the type of the other,
+// and an untyped float wins over an untyped int.
+func getOperandType(e *astBinaryExpr) *Type {
+	var tx = getTypeOfExpr(e.X)
+	if !isUntypedConst(e.X) {
+		return tx
+	}
+	var ty = getTypeOfExpr(e.Y)
+	if !isUntypedConst(e.Y) || isFloatKind(kind(ty)) {
+		return ty
+	}
+	return tx
+}
+
+func getStructTypeOfX(e *astSelectorExpr) *Type {
+	var typeOfX = getTypeOfExpr(e.X)
+	var structType *Type
+	switch kind(typeOfX) {
+	case T_STRUCT:
+		// strct.field => e.X . e.Sel
+		structType = typeOfX
+	case T_POINTER:
+		// ptr.field => e.X . e.Sel
+		assert(typeOfX.e.dtype == "*astStarExpr", "should be astStarExpr", __func__)
+		var ptrType = typeOfX.e.starExpr
+		structType = e2t(ptrType.X)
+	default:
+		panic2(__func__, "TBI")
+	}
+	return structType
+}
+
+func getElementTypeOfListType(t *Type) *Type {
+	switch kind(t) {
+	case T_SLICE, T_ARRAY:
+		t = getUnderlyingType(t)
+		if t.e.dtype == "*astEllipsis" {
+			// a variadic parameter
+			return e2t(t.e.ellipsis.Elt)
+		}
+		var arrayType = t.e.arrayType
+		if arrayType == nil {
+			panic2(__func__, "should not be nil")
+		}
+		return e2t(arrayType.Elt)
+	case T_STRING:
+		return tUint8
+	case T_POINTER:
+		return getElementTypeOfListType(getPointedArrayType(t))
+	default:
+		panic2(__func__, "TBI kind="+kind(t))
+	}
+	var r *Type
+	return r
+}
+
+// The array type of a pointer to an array, which is indexed, ranged over and measured like the array
+func getPointedArrayType(t *Type) *Type {
+	var arrayType = e2t(getUnderlyingType(t).e.starExpr.X)
+	if kind(arrayType) != T_ARRAY {
+		panic2(__func__, "not a pointer to an array: "+typeString(t))
+	}
+	return arrayType
+}
+
+func getSizeOfType(t *Type) int {
+	var knd = kind(t)
+	switch kind(t) {
+	case T_SLICE:
+		return sliceSize
+	case T_STRING:
+		return 16
+	case T_INTERFACE:
+		return interfaceSize
+	case T_ARRAY:
+		var arrayType = getUnderlyingType(t).e.arrayType
+		var elemSize = getSizeOfType(e2t(arrayType.Elt))
+		return elemSize * evalInt(arrayType.Len)
+	case T_INT, T_INT64, T_UINT, T_UINT64, T_FLOAT64, T_UINTPTR, T_POINTER, T_MAP, T_CHAN, T_FUNC:
+		return 8
+	case T_INT8, T_UINT8:
+		return 1
+	case T_INT16, T_UINT16:
+		return 2
+	case T_INT32, T_UINT32, T_FLOAT32:
+		return 4
+	case T_BOOL:
+		return 8
+	case T_STRUCT:
+		return calcStructSizeAndSetFieldOffset(getStructType(t))
+	default:
+		panic2(__func__, "TBI:"+knd)
+	}
+	return 0
+}
+
+func getPushSizeOfType(t *Type) int {
+	switch kind(t) {
+	case T_SLICE:
+		return sliceSize
+	case T_STRING:
+		return stringSize
+	case T_INTERFACE:
+		return interfaceSize
+	case T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_FLOAT32, T_FLOAT64, T_BOOL:
+		return intSize
+	case T_UINTPTR, T_POINTER, T_MAP, T_CHAN, T_FUNC:
+		return ptrSize
+	case T_ARRAY, T_STRUCT:
+		return ptrSize
+	default:
+		throw(kind(t))
+	}
+	throw(kind(t))
+	return 0
+}
+
+// Size in the argument area, which is a multiple of the word size
+func getArgSizeOfType(t *Type) int {
+	switch kind(t) {
+	case T_ARRAY, T_STRUCT:
+		return (getSizeOfType(t) + 7) / 8 * 8
+	}
+	return getPushSizeOfType(t)
+}
+
+func getStructFieldOffset(field *astField) int {
+	var offset = field.Offset
+	return offset
+}
+
+func setStructFieldOffset(field *astField, offset int) {
+	field.Offset = offset
+}
+
+func getStructFields(structType *astStructType) []*astField {
+	return structType.Fields.List
+}
+
+// The struct type of a named or an anonymous struct
+func getStructType(t *Type) *astStructType {
+	if kind(t) != T_STRUCT {
+		panic2(__func__, "not T_STRUCT")
+	}
+	return getUnderlyingType(t).e.structType
+}
+
+func lookupStructField(structType *astStructType, selName string) *astField {
+	var field *astField
+	for _, field = range getStructFields(structType) {
+		if field.Name.Name == selName {
+			return field
+		}
+	}
+	panic("Unexpected flow: struct field not found:" + selName)
+	return field
+}
+
+func calcStructSizeAndSetFieldOffset(structType *astStructType) int {
+	var offset int = 0
+
+	var fields = getStructFields(structType)
+	var field *astField
+	for _, field = range fields {
+		setStructFieldOffset(field, offset)
+		var size = getSizeOfType(e2t(field.Type))
+		offset = offset + size
+	}
+	return offset
+}
+
+// --- walk ---
+type sliteral struct {
+	label  string
+	strlen int
+	value  string // raw value/pre/precompiler.go:2150
+}
+
+type stringLiteralsContainer struct {
+	lit *astBasicLit
+	sl  *sliteral
+}
+
+type Func struct {
+	localvars   []*string
+	localarea   int
+	argsarea    int
+	funcType    *astFuncType
+	rcvType     *astExpr
+	name        string
+	Body        *astBlockStmt
+	method *Method
+	params        []*astField // including the receiver
+	outer         *Func       // the enclosing function of a function literal
+	freeVars      []*Variable // variables of outer functions captured by this closure
+	closureOffset int
+	hasDefer      bool
+}
+
+type Method struct {
+	rcvNamedType *astIdent
+	isPtrMethod  bool
+	name string
+	funcType *astFuncType
+	funcDecl     *astFuncDecl
+}
+
+type Variable struct {
+	name         string
+	isGlobal     bool
+	globalSymbol string
+	localOffset  int
+	fnc          *Func // owner of a local variable
+	isBoxed      bool  // captured by a closure and moved to the heap
+	boxOffset    int   // where the pointer to the heap is stored
+}
+
+//type localoffsetint int //@TODO
+
+var stringLiterals []*stringLiteralsContainer
+var stringIndex int
+var localoffset int
+var currentFuncDecl *astFuncDecl
+
+func getStringLiteral(lit *astBasicLit) *sliteral {
+	var container *stringLiteralsContainer
+	for _, container = range stringLiterals {
+		if container.lit == lit {
+			return container.sl
+		}
+	}
+
+	panic2(__func__, "string literal not found:"+lit.Value)
+	var r *sliteral
+	return r
+}
+
+// Length of the string which a literal represents
+func getStringLiteralLength(value string) int {
+	return len(unquote(value))
+}
+
+func registerStringLiteral(lit *astBasicLit) {
+	logf(" [registerStringLiteral] begin\n")
+
+	if pkg.name == "" {
+		panic2(__func__, "no pkgName")
+	}
+
+	var strlen = getStringLiteralLength(lit.Value)
+
+	var label = fmtSprintf(".%s.S%d", []string{pkg.name, Itoa(stringIndex)})
+	stringIndex++
+
+	var sl = &sliteral{}
+	sl.label = label
+	sl.strlen = strlen
+	sl.value = quote(unquote(lit.Value))
+	logf(" [registerStringLiteral] label=%s, strlen=%s\n", sl.label, Itoa(sl.strlen))
+	var cont = &stringLiteralsContainer{}
+	cont.sl = sl
+	cont.lit = lit
+	stringLiterals = append(stringLiterals, cont)
+}
+
+func newGlobalVariable(name string) *Variable {
+	var vr = &Variable{}
+	vr.name = name
+	vr.isGlobal = true
+	vr.globalSymbol = name
+	return vr
+}
+
+func newLocalVariable(name string, localoffset int) *Variable {
+	var vr = &Variable{}
+	vr.name = name
+	vr.isGlobal = false
+	vr.localOffset = localoffset
+	vr.fnc = currentWalkFunc
+	return vr
+}
+
+
+type methodEntry struct {
+	name string
+	method *Method
+}
+
+type namedTypeEntry struct {
+	name    string
+	methods []*methodEntry
+}
+
+var typesWithMethods []*namedTypeEntry
+
+func findNamedType(typeName string) *namedTypeEntry {
+	var typ *namedTypeEntry
+	for _, typ = range typesWithMethods {
+		if typ.name == typeName {
+			return typ
+		}
+	}
+	typ = nil
+	return typ
+}
+
+func newMethod(funcDecl *astFuncDecl) *Method {
+	var rcvType = funcDecl.Recv.List[0].Type
+	var isPtr bool
+	if rcvType.dtype == "*astStarExpr" {
+		isPtr = true
+		rcvType = rcvType.starExpr.X
+	}
+	assert(rcvType.dtype == "*astIdent", "receiver type should be ident", __func__)
+	var rcvNamedType = rcvType.ident
+
+	var method = &Method{
+		rcvNamedType: rcvNamedType,
+		isPtrMethod : isPtr,
+		name: funcDecl.Name.Name,
+		funcType: funcDecl.Type,
+		funcDecl:     funcDecl,
+	}
+	return method
+}
 
-		emitExpr(e.sliceExpr.Low, nil)
-		var elmType = getElementTypeOfListType(listType)
-		emitListElementAddr(list, elmType)
-	case "*astUnaryExpr":
-		emitComment(0, "[DEBUG] unary op = %s\n", e.unaryExpr.Op)
-		switch e.unaryExpr.Op {
-		case "+":
-			emitExpr(e.unaryExpr.X, nil)
-		case "-":
-			emitExpr(e.unaryExpr.X, nil)
-			fmtPrintf("  popq %%rax # e.X\n")
-			fmtPrintf("  imulq $-1, %%rax\n")
-			fmtPrintf("  pushq %%rax\n")
-		case "&":
-			emitAddr(e.unaryExpr.X)
-		case "!":
-			emitExpr(e.unaryExpr.X, nil)
-			emitInvertBoolValue()
-		default:
-			panic2(__func__, "TBI:astUnaryExpr:"+e.unaryExpr.Op)
+func registerMethod(method *Method) {
+	var nt = findNamedType(method.rcvNamedType.Name)
+	if nt == nil {
+		nt = &namedTypeEntry{
+			name:    method.rcvNamedType.Name,
+			methods: nil,
 		}
-	case "*astBinaryExpr":
-		if kind(getTypeOfExpr(e.binaryExpr.X)) == T_STRING {
-			var args []*Arg
-			var argX = &Arg{}
-			var argY = &Arg{}
-			argX.e = e.binaryExpr.X
-			argY.e = e.binaryExpr.Y
-			args = append(args, argX)
-			args = append(args, argY)
-			switch e.binaryExpr.Op {
-			case "+":
-				var resultList = []*astField{
-					&astField{
-						Type:    tString.e,
-					},
+		typesWithMethods = append(typesWithMethods, nt)
+	}
+
+	var me *methodEntry = &methodEntry{
+		name: method.name,
+		method: method,
+	}
+	nt.methods = append(nt.methods, me)
+}
+
+func lookupMethod(rcvT *Type, methodName *astIdent) *Method {
+	if kind(rcvT) == T_INTERFACE {
+		var iface = getUnderlyingType(rcvT).e.interfaceType
+		var field *astField
+		for _, field = range iface.Methods.List {
+			if field.Name == nil {
+				var embedded = e2t(field.Type)
+				var name string
+				for _, name = range getInterfaceMethods(embedded) {
+					if name == methodName.Name {
+						return lookupMethod(embedded, methodName)
+					}
+				}
+			} else if field.Name.Name == methodName.Name {
+				return &Method{
+					name:     methodName.Name,
+					funcType: field.Type.funcType,
 				}
-				emitCall("runtime.catstrings", args, resultList)
-			case "==":
-				emitArgs(args)
-				emitCompEq(getTypeOfExpr(e.binaryExpr.X))
-			case "!=":
-				emitArgs(args)
-				emitCompEq(getTypeOfExpr(e.binaryExpr.X))
-				emitInvertBoolValue()
-			default:
-				panic2(__func__, "[emitExpr][*astBinaryExpr] string : TBI T_STRING")
 			}
-			return
 		}
+		panic2(__func__, "method not found: "+methodName.Name)
+	}
+	var rcvType = rcvT.e
+	if rcvType.dtype == "*astStarExpr" {
+		rcvType = rcvType.starExpr.X
+	}
+	assert(rcvType.dtype == "*astIdent", "receiver type should be ident", __func__)
+	var rcvTypeName = rcvType.ident
+	var nt = findNamedType(rcvTypeName.Name)
+	if nt == nil {
+		panic(rcvTypeName.Name + " has no moethodeiverTypeName:")
+	}
+	var me *methodEntry
 
-		switch e.binaryExpr.Op {
-		case "&&":
-			labelid++
-			var labelExitWithFalse = fmtSprintf(".L.%s.false", []string{Itoa(labelid)})
-			var labelExit = fmtSprintf(".L.%d.exit", []string{Itoa(labelid)})
-			emitExpr(e.binaryExpr.X, nil) // left
-			emitPopBool("left")
-			fmtPrintf("  cmpq $1, %%rax\n")
-			// exit with false if left is false
-			fmtPrintf("  jne %s\n", labelExitWithFalse)
+	for _, me = range nt.methods {
+		if me.name == methodName.Name {
+			return me.method
+		}
+	}
 
-			// if left is true, then eval right and exit
-			emitExpr(e.binaryExpr.Y, nil) // right
-			fmtPrintf("  jmp %s\n", labelExit)
+	panic("method not found: " + methodName.Name)
+	var r *Method
+	return r
+}
 
-			fmtPrintf("  %s:\n", labelExitWithFalse)
-			emitFalse()
-			fmtPrintf("  %s:\n", labelExit)
-			return
-		case "||":
-			labelid++
-			var labelExitWithTrue = fmtSprintf(".L.%d.true", []string{Itoa(labelid)})
-			var labelExit = fmtSprintf(".L.%d.exit", []string{Itoa(labelid)})
-			emitExpr(e.binaryExpr.X, nil) // left
-			emitPopBool("left")
-			fmtPrintf("  cmpq $1, %%rax\n")
-			// exit with true if left is true
-			fmtPrintf("  je %s\n", labelExitWithTrue)
+// A field or method which x.name selects, and the embedded fields to reach it from x
+type selection struct {
+	path   []*astField
+	field  *astField
+	method *Method
+}
 
-			// if left is false, then eval right and exit
-			emitExpr(e.binaryExpr.Y, nil) // right
-			fmtPrintf("  jmp %s\n", labelExit)
+// A type reached through embedded fields
+type embeddedType struct {
+	t    *Type
+	path []*astField
+}
 
-			fmtPrintf("  %s:\n", labelExitWithTrue)
-			emitTrue()
-			fmtPrintf("  %s:\n", labelExit)
-			return
+const maxEmbeddingDepth int = 16
+
+func appendPath(path []*astField, field *astField) []*astField {
+	var r []*astField
+	var f *astField
+	for _, f = range path {
+		r = append(r, f)
+	}
+	return append(r, field)
+}
+
+// The method declared with the receiver type t or *t, or nil
+func lookupDeclaredMethod(t *Type, name string) *Method {
+	if t.e.dtype != "*astIdent" {
+		return nil
+	}
+	var nt = findNamedType(t.e.ident.Name)
+	if nt == nil {
+		return nil
+	}
+	var me *methodEntry
+	for _, me = range nt.methods {
+		if me.name == name {
+			return me.method
 		}
+	}
+	return nil
+}
 
-		var t = getTypeOfExpr(e.binaryExpr.X)
-		emitExpr(e.binaryExpr.X, nil) // left
-		emitExpr(e.binaryExpr.Y, t)   // right
-		switch e.binaryExpr.Op {
-		case "+":
-			fmtPrintf("  popq %%rcx # right\n")
-			fmtPrintf("  popq %%rax # left\n")
-			fmtPrintf("  addq %%rcx, %%rax\n")
-			fmtPrintf("  pushq %%rax\n")
-		case "-":
-			fmtPrintf("  popq %%rcx # right\n")
-			fmtPrintf("  popq %%rax # left\n")
-			fmtPrintf("  subq %%rcx, %%rax\n")
-			fmtPrintf("  pushq %%rax\n")
-		case "*":
-			fmtPrintf("  popq %%rcx # right\n")
-			fmtPrintf("  popq %%rax # left\n")
-			fmtPrintf("  imulq %%rcx, %%rax\n")
-			fmtPrintf("  pushq %%rax\n")
-		case "%":
-			fmtPrintf("  popq %%rcx # right\n")
-			fmtPrintf("  popq %%rax # left\n")
-			fmtPrintf("  movq $0, %%rdx # init %%rdx\n")
-			fmtPrintf("  divq %%rcx\n")
-			fmtPrintf("  movq %%rdx, %%rax\n")
-			fmtPrintf("  pushq %%rax\n")
-		case "/":
-			fmtPrintf("  popq %%rcx # right\n")
-			fmtPrintf("  popq %%rax # left\n")
-			fmtPrintf("  movq $0, %%rdx # init %%rdx\n")
-			fmtPrintf("  divq %%rcx\n")
-			fmtPrintf("  pushq %%rax\n")
-		case "==":
-			emitCompEq(t)
-		case "!=":
-			emitCompEq(t)
-			emitInvertBoolValue()
-		case "<":
-			emitCompExpr("setl")
-		case "<=":
-			emitCompExpr("setle")
-		case ">":
-			emitCompExpr("setg")
-		case ">=":
-			emitCompExpr("setge")
-		default:
-			panic2(__func__, "# TBI: binary operation for "+e.binaryExpr.Op)
+func getEmbeddedFieldType(field *astField) *Type {
+	var t = e2t(field.Type)
+	if t.e.dtype == "*astStarExpr" {
+		return e2t(t.e.starExpr.X)
+	}
+	return t
+}
+
+// Find the fields and methods of the name at the shallowest depth of embedding.
+// The selector is legal only if exactly one is found.
+func findSelections(t *Type, name string) []*selection {
+	var r []*selection
+	if t.e.dtype == "*astStarExpr" {
+		t = e2t(t.e.starExpr.X)
+	}
+	var current = []*embeddedType{&embeddedType{t: t}}
+	var depth int
+	for depth = 0; len(current) > 0 && depth < maxEmbeddingDepth; depth++ {
+		var next []*embeddedType
+		var et *embeddedType
+		for _, et = range current {
+			var method = lookupDeclaredMethod(et.t, name)
+			if method != nil {
+				r = append(r, &selection{
+					path:   et.path,
+					method: method,
+				})
+			}
+			switch kind(et.t) {
+			case T_STRUCT:
+				var field *astField
+				for _, field = range getUnderlyingType(et.t).e.structType.Fields.List {
+					if field.Name.Name == name {
+						r = append(r, &selection{
+							path:  et.path,
+							field: field,
+						})
+					}
+					if field.Embedded {
+						next = append(next, &embeddedType{
+							t:    getEmbeddedFieldType(field),
+							path: appendPath(et.path, field),
+						})
+					}
+				}
+			case T_INTERFACE:
+				if inArray(name, getInterfaceMethods(et.t)) {
+					r = append(r, &selection{
+						path:   et.path,
+						method: lookupMethod(et.t, &astIdent{Name: name}),
+					})
+				}
+			}
 		}
-	case "*astCompositeLit":
-		// slice , array, map or struct
-		var k = kind(e2t(e.compositeLit.Type))
-		switch k {
-		case T_STRUCT:
-			emitStructLiteral(e.compositeLit)
-		case T_ARRAY:
-			assert(e.compositeLit.Type.dtype == "*astArrayType", "expect *ast.ArrayType", __func__)
-			var arrayType = e.compositeLit.Type.arrayType
-			var arrayLen = evalInt(arrayType.Len)
-			emitArrayLiteral(arrayType, arrayLen, e.compositeLit.Elts)
-		case T_SLICE:
-			assert(e.compositeLit.Type.dtype == "*astArrayType", "expect *ast.ArrayType", __func__)
-			var arrayType = e.compositeLit.Type.arrayType
-			var length = len(e.compositeLit.Elts)
-			emitArrayLiteral(arrayType, length, e.compositeLit.Elts)
-			emitPopAddress("malloc")
-			fmtPrintf("  pushq $%d # slice.cap\n", Itoa(length))
-			fmtPrintf("  pushq $%d # slice.len\n", Itoa(length))
-			fmtPrintf("  pushq %%rax # slice.ptr\n")
-		default:
-			panic2(__func__, "Unexpected kind="+k)
+		if len(r) > 0 {
+			return r
 		}
-	default:
-		panic2(__func__, "[emitExpr] `TBI:"+e.dtype)
+		current = next
 	}
+	return r
 }
 
-func newNumberLiteral(x int) *astBasicLit {
-	var r = &astBasicLit{}
-	r.Kind = "INT"
-	r.Value = Itoa(x)
-	return r
+// Make promoted fields and methods explicit: x.f is rewritten into x.E.f when f is promoted from E
+func resolvePromotedSelector(e *astSelectorExpr) {
+	if (e.X.dtype == "*astIdent" && e.X.ident.Obj.Kind == "Pkg") || isType(e.X) {
+		return
+	}
+	var sels = findSelections(getTypeOfExpr(e.X), e.Sel.Name)
+	if len(sels) == 0 {
+		return
+	}
+	if len(sels) > 1 {
+		panic2(__func__, "ambiguous selector ."+e.Sel.Name)
+	}
+	var field *astField
+	for _, field = range sels[0].path {
+		e.X = &astExpr{
+			dtype: "*astSelectorExpr",
+			selectorExpr: &astSelectorExpr{
+				X: e.X,
+				Sel: &astIdent{
+					Name: field.Name.Name,
+				},
+			},
+		}
+	}
 }
 
-func emitListElementAddr(list *astExpr, elmType *Type) {
-	emitListHeadAddr(list)
-	emitPopAddress("list head")
-	fmtPrintf("  popq %%rcx # index id\n")
-	fmtPrintf("  movq $%s, %%rdx # elm size\n", Itoa(getSizeOfType(elmType)))
-	fmtPrintf("  imulq %%rdx, %%rcx\n")
-	fmtPrintf("  addq %%rcx, %%rax\n")
-	fmtPrintf("  pushq %%rax # addr of element\n")
+// Whether a promoted method is in the method set of the value type of the outer struct.
+// It is if the method has a value receiver or any of the embedded fields is a pointer.
+func isPromotedToValue(sel *selection) bool {
+	if sel.method.rcvNamedType == nil || !sel.method.isPtrMethod {
+		return true
+	}
+	var field *astField
+	for _, field = range sel.path {
+		if kind(e2t(field.Type)) == T_POINTER {
+			return true
+		}
+	}
+	return false
 }
 
-func emitCompEq(t *Type) {
-	switch kind(t) {
-	case T_STRING:
-		var resultList = []*astField{
-			&astField{
-				Type:    tBool.e,
-			},
+// Methods of the struct type t which are promoted from its embedded fields
+func getPromotedMethods(t *Type) []*Method {
+	var methods []*Method
+	if kind(t) != T_STRUCT {
+		return methods
+	}
+	var names = collectEmbeddedMethodNames(t, nil, 0)
+	var name string
+	for _, name = range names {
+		var sels = findSelections(t, name)
+		if len(sels) != 1 || sels[0].method == nil || len(sels[0].path) == 0 {
+			continue
+		}
+		methods = append(methods, getPromotedMethod(t, sels[0]))
+	}
+	return methods
+}
+
+// Names of methods of the embedded fields of the struct type t, at any depth
+func collectEmbeddedMethodNames(t *Type, names []string, depth int) []string {
+	if depth >= maxEmbeddingDepth {
+		return names
+	}
+	var field *astField
+	for _, field = range getUnderlyingType(t).e.structType.Fields.List {
+		if !field.Embedded {
+			continue
+		}
+		var ft = getEmbeddedFieldType(field)
+		var name string
+		if kind(ft) == T_INTERFACE {
+			for _, name = range getInterfaceMethods(ft) {
+				if !inArray(name, names) {
+					names = append(names, name)
+				}
+			}
+			continue
+		}
+		if ft.e.dtype == "*astIdent" {
+			var nt = findNamedType(ft.e.ident.Name)
+			if nt != nil {
+				var me *methodEntry
+				for _, me = range nt.methods {
+					if !inArray(me.name, names) {
+						names = append(names, me.name)
+					}
+				}
+			}
+		}
+		if kind(ft) == T_STRUCT {
+			names = collectEmbeddedMethodNames(ft, names, depth+1)
 		}
-		fmtPrintf("  callq runtime.cmpstrings\n")
-		emitRevertStackPointer(stringSize * 2)
-		emitReturnedValue(resultList)
-	case T_INT, T_UINT8, T_UINT16, T_UINTPTR, T_POINTER:
-		emitCompExpr("sete")
-	case T_SLICE:
-		emitCompExpr("sete") // @FIXME this is not correct
-	default:
-		panic2(__func__, "Unexpected kind="+kind(t))
 	}
+	return names
 }
 
-//@TODO handle larger types than int
-func emitCompExpr(inst string) {
-	fmtPrintf("  popq %%rcx # right\n")
-	fmtPrintf("  popq %%rax # left\n")
-	fmtPrintf("  cmpq %%rcx, %%rax\n")
-	fmtPrintf("  %s %%al\n", inst)
-	fmtPrintf("  movzbq %%al, %%rax\n") // true:1, false:0
-	fmtPrintf("  pushq %%rax\n")
+// A promoted method which is called through interfaces or method expressions
+type promotedMethod struct {
+	method  *Method
+	sel     *selection
+	pkgName string
+	symbol  string
 }
 
-func emitStore(t *Type) {
-	emitComment(2, "emitStore(%s)\n", kind(t))
-	switch kind(t) {
-	case T_SLICE:
-		emitPopSlice()
-		fmtPrintf("  popq %%rsi # lhs ptr addr\n")
-		fmtPrintf("  movq %%rax, %d(%%rsi) # ptr to ptr\n", Itoa(0))
-		fmtPrintf("  movq %%rcx, %d(%%rsi) # len to len\n", Itoa(8))
-		fmtPrintf("  movq %%rdx, %d(%%rsi) # cap to cap\n", Itoa(16))
-	case T_STRING:
-		emitPopString()
-		fmtPrintf("  popq %%rsi # lhs ptr addr\n")
-		fmtPrintf("  movq %%rax, %d(%%rsi) # ptr to ptr\n", Itoa(0))
-		fmtPrintf("  movq %%rcx, %d(%%rsi) # len to len\n", Itoa(8))
-	case T_INT, T_BOOL, T_UINTPTR, T_POINTER:
-		fmtPrintf("  popq %%rdi # rhs evaluated\n")
-		fmtPrintf("  popq %%rax # lhs addr\n")
-		fmtPrintf("  movq %%rdi, (%%rax) # assign\n")
-	case T_UINT8:
-		fmtPrintf("  popq %%rdi # rhs evaluated\n")
-		fmtPrintf("  popq %%rax # lhs addr\n")
-		fmtPrintf("  movb %%dil, (%%rax) # assign byte\n")
-	case T_UINT16:
-		fmtPrintf("  popq %%rdi # rhs evaluated\n")
-		fmtPrintf("  popq %%rax # lhs addr\n")
-		fmtPrintf("  movw %%di, (%%rax) # assign word\n")
-	case T_STRUCT, T_ARRAY:
-		fmtPrintf("  popq %%rdi # rhs: addr of data\n")
-		fmtPrintf("  popq %%rax # lhs: addr to store\n")
-		fmtPrintf("  pushq $%d # size\n", Itoa(getSizeOfType(t)))
-		fmtPrintf("  pushq %%rax # dst lhs\n")
-		fmtPrintf("  pushq %%rdi # src rhs\n")
-		fmtPrintf("  callq runtime.memcopy\n")
-		emitRevertStackPointer(ptrSize*2 + intSize)
-	default:
-		panic2(__func__, "TBI:"+kind(t))
-	}
+var promotedMethods []*promotedMethod
+
+func getPromotedMethod(t *Type, sel *selection) *Method {
+	var method = &Method{
+		rcvNamedType: t.e.ident,
+		isPtrMethod:  !isPromotedToValue(sel),
+		name:         sel.method.name,
+		funcType:     sel.method.funcType,
+	}
+	var symbol = getFuncSymbol(pkg.name, "$"+method.rcvNamedType.Name+"."+method.name)
+	var pm *promotedMethod
+	for _, pm = range promotedMethods {
+		if pm.symbol == symbol {
+			return pm.method
+		}
+	}
+	promotedMethods = append(promotedMethods, &promotedMethod{
+		method:  method,
+		sel:     sel,
+		pkgName: pkg.name,
+		symbol:  symbol,
+	})
+	return method
 }
 
-func emitAssign(lhs *astExpr, rhs *astExpr) {
-	emitComment(2, "Assignment: emitAddr(lhs:%s)\n", lhs.dtype)
-	emitAddr(lhs)
-	emitComment(2, "Assignment: emitExpr(rhs)\n")
-	emitExpr(rhs, getTypeOfExpr(lhs))
-	emitStore(getTypeOfExpr(lhs))
+// The wrapper takes the pointer receiver of the outer struct.
+// It replaces the receiver with the embedded field and jumps to the method of the field.
+func emitPromotedMethodWrapper(pm *promotedMethod) {
+	var sel = pm.sel
+	// closure to use the wrapper as the method expression (*T).m
+	fmtPrintf(".data\n")
+	fmtPrintf("%s:\n", getFuncValueSymbol(pm.symbol))
+	fmtPrintf("  .quad %s\n", pm.symbol)
+	fmtPrintf(".text\n")
+	fmtPrintf("\n")
+	fmtPrintf("%s: # promoted method\n", pm.symbol)
+	fmtPrintf("  movq 8(%%rsp), %%rax # pointer receiver\n")
+	var field *astField
+	for _, field = range sel.path {
+		fmtPrintf("  addq $%d, %%rax # .%s\n", Itoa(getStructFieldOffset(field)), field.Name.Name)
+		if kind(e2t(field.Type)) == T_POINTER {
+			fmtPrintf("  movq 0(%%rax), %%rax\n")
+		}
+	}
+	if sel.method.rcvNamedType == nil {
+		// the embedded field is an interface
+		var ifaceType = getEmbeddedFieldType(sel.path[len(sel.path)-1])
+		var index = getInterfaceMethodIndex(ifaceType, sel.method.name)
+		fmtPrintf("  movq 8(%%rax), %%rcx # data\n")
+		fmtPrintf("  movq %%rcx, 8(%%rsp)\n")
+		fmtPrintf("  movq 0(%%rax), %%rax # itab\n")
+		fmtPrintf("  jmp *%d(%%rax) # method %s\n", Itoa(ptrSize*(index+1)), sel.method.name)
+		return
+	}
+	var target = "$" + sel.method.rcvNamedType.Name + "." + sel.method.name
+	fmtPrintf("  movq %%rax, 8(%%rsp)\n")
+	fmtPrintf("  jmp %s\n", getFuncSymbol(pm.pkgName, target))
 }
 
-func emitStmt(stmt *astStmt) {
-	emitComment(2, "\n")
-	emitComment(2, "== Stmt %s ==\n", stmt.dtype)
+func walkStmt(stmt *astStmt) {
+	logf(" [%s] begin dtype=%s\n", __func__, stmt.dtype)
 	switch stmt.dtype {
-	case "*astBlockStmt":
-		var stmt2 *astStmt
-		for _, stmt2 = range stmt.blockStmt.List {
-			emitStmt(stmt2)
-		}
-	case "*astExprStmt":
-		emitExpr(stmt.exprStmt.X, nil)
 	case "*astDeclStmt":
-		var decl *astDecl = stmt.DeclStmt.Decl
-		if decl.dtype != "*astGenDecl" {
-			panic2(__func__, "[*astDeclStmt] internal error")
+		logf(" [%s] *ast.DeclStmt\n", __func__)
+		if stmt.DeclStmt == nil {
+			panic2(__func__, "nil pointer exception\n")
 		}
-		var genDecl = decl.genDecl
-		var valSpec = genDecl.Spec.valueSpec
-		var t = e2t(valSpec.Type)
-		var ident = valSpec.Name
-		var lhs = &astExpr{}
-		lhs.dtype = "*astIdent"
-		lhs.ident = ident
-		var rhs *astExpr
-		if valSpec.Value == nil {
-			emitComment(2, "lhs addresss\n")
-			emitAddr(lhs)
-			emitComment(2, "emitZeroValue for %s\n", t.e.dtype)
-			emitZeroValue(t)
-			emitComment(2, "Assignment: zero value\n")
-			emitStore(t)
-		} else {
-			rhs = valSpec.Value
-			emitAssign(lhs, rhs)
+		var declStmt = stmt.DeclStmt
+		if declStmt.Decl == nil {
+			panic2(__func__, "ERROR\n")
+		}
+		var dcl = declStmt.Decl
+		if dcl.dtype != "*astGenDecl" {
+			panic2(__func__, "[dcl.dtype] internal error")
+		}
+		var spec *astSpec
+		for _, spec = range dcl.genDecl.Specs {
+			if spec.valueSpec.Name.Obj.Kind == astCon {
+				foldConstValue(spec.valueSpec)
+				walkExpr(spec.valueSpec.Value)
+			} else {
+				walkLocalVarSpec(spec.valueSpec)
+			}
 		}
-
-		//var valueSpec *astValueSpec = genDecl.Specs[0]
-		//var obj *astObject = valueSpec.Name.Obj
-		//var typ *astExpr = valueSpec.Type
-		//fmtPrintf("[emitStmt] TBI declSpec:%s\n", valueSpec.Name.Name)
-		//os.Exit(1)
-
 	case "*astAssignStmt":
-		switch stmt.assignStmt.Tok {
-		case "=":
-			var lhs = stmt.assignStmt.Lhs
-			var rhs = stmt.assignStmt.Rhs
-			emitAssign(lhs[0], rhs[0])
-		case ":=":
-			var lhs = stmt.assignStmt.Lhs
-			var rhs = stmt.assignStmt.Rhs
-			emitAssign(lhs[0], rhs[0])
-		default:
-			panic2(__func__, "TBI: assignment of "+stmt.assignStmt.Tok)
+		var rhs *astExpr
+		for _, rhs = range stmt.assignStmt.Rhs {
+			walkExpr(rhs)
 		}
-	case "*astReturnStmt":
-		if len(stmt.returnStmt.Results) == 0 {
-			fmtPrintf("  leave\n")
-			fmtPrintf("  ret\n")
-		} else if len(stmt.returnStmt.Results) == 1 {
-			emitExpr(stmt.returnStmt.Results[0], nil) // @TODO forceType should be fetched from func decl
-			var knd = kind(getTypeOfExpr(stmt.returnStmt.Results[0]))
-			switch knd {
-			case T_BOOL, T_INT, T_UINTPTR, T_POINTER:
-				fmtPrintf("  popq %%rax # return 64bit\n")
-			case T_STRING:
-				fmtPrintf("  popq %%rax # return string (ptr)\n")
-				fmtPrintf("  popq %%rdi # return string (len)\n")
-			case T_SLICE:
-				fmtPrintf("  popq %%rax # return string (ptr)\n")
-				fmtPrintf("  popq %%rdi # return string (len)\n")
-				fmtPrintf("  popq %%rsi # return string (cap)\n")
-			default:
-				panic2(__func__, "[*astReturnStmt] TBI:"+knd)
-			}
-			fmtPrintf("  leave\n")
-			fmtPrintf("  ret\n")
-		} else if len(stmt.returnStmt.Results) == 3 {
-			// Special treatment to return a slice
-			emitExpr(stmt.returnStmt.Results[2], nil) // @FIXME
-			emitExpr(stmt.returnStmt.Results[1], nil) // @FIXME
-			emitExpr(stmt.returnStmt.Results[0], nil) // @FIXME
-			fmtPrintf("  popq %%rax # return 64bit\n")
-			fmtPrintf("  popq %%rdi # return 64bit\n")
-			fmtPrintf("  popq %%rsi # return 64bit\n")
+		if stmt.assignStmt.Tok == ":=" {
+			var lhs *astExpr
+			for _, lhs = range stmt.assignStmt.Lhs {
+				assert(lhs.dtype == "*astIdent", "should be ident", __func__)
+				if isNewShortVar(lhs, stmt.assignStmt) {
+					declareLocalVariableOfShortVar(lhs.ident.Obj)
+				}
+			}
 		} else {
-			panic2(__func__, "[*astReturnStmt] TBI\n")
+			var lhs *astExpr
+			for _, lhs = range stmt.assignStmt.Lhs {
+				walkExpr(lhs)
+			}
+		}
+	case "*astExprStmt":
+		walkExpr(stmt.exprStmt.X)
+	case "*astReturnStmt":
+		var rt *astExpr
+		for _, rt = range stmt.returnStmt.Results {
+			walkExpr(rt)
 		}
+	case "*astGoStmt":
+		walkExpr(&astExpr{
+			dtype:    "*astCallExpr",
+			callExpr: stmt.goStmt.Call,
+		})
+	case "*astDeferStmt":
+		currentWalkFunc.hasDefer = true
+		walkExpr(&astExpr{
+			dtype:    "*astCallExpr",
+			callExpr: stmt.deferStmt.Call,
+		})
 	case "*astIfStmt":
-		emitComment(2, "if\n")
-
-		labelid++
-		var labelEndif = ".L.endif." + Itoa(labelid)
-		var labelElse = ".L.else." + Itoa(labelid)
-
-		emitExpr(stmt.ifStmt.Cond, nil)
-		emitPopBool("if condition")
-		fmtPrintf("  cmpq $1, %%rax\n")
-		var bodyStmt = &astStmt{}
-		bodyStmt.dtype = "*astBlockStmt"
-		bodyStmt.blockStmt = stmt.ifStmt.Body
+		if stmt.ifStmt.Init != nil {
+			walkStmt(stmt.ifStmt.Init)
+		}
+		walkExpr(stmt.ifStmt.Cond)
+		var s *astStmt
+		for _, s = range stmt.ifStmt.Body.List {
+			walkStmt(s)
+		}
 		if stmt.ifStmt.Else != nil {
-			fmtPrintf("  jne %s # jmp if false\n", labelElse)
-			emitStmt(bodyStmt) // then
-			fmtPrintf("  jmp %s\n", labelEndif)
-			fmtPrintf("  %s:\n", labelElse)
-			emitStmt(stmt.ifStmt.Else) // then
-		} else {
-			fmtPrintf("  jne %s # jmp if false\n", labelEndif)
-			emitStmt(bodyStmt) // then
+			walkStmt(stmt.ifStmt.Else)
 		}
-		fmtPrintf("  %s:\n", labelEndif)
-		emitComment(2, "end if\n")
 	case "*astForStmt":
-		labelid++
-		var labelCond = ".L.for.cond." + Itoa(labelid)
-		var labelPost = ".L.for.post." + Itoa(labelid)
-		var labelExit = ".L.for.exit." + Itoa(labelid)
-		//forStmt, ok := mapForNodeToFor[s]
-		//assert(ok, "map value should exist")
-		stmt.forStmt.labelPost = labelPost
-		stmt.forStmt.labelExit = labelExit
-
+		var outerFor = currentFor
+		var outerBreakable = currentBreakable
+		currentFor = stmt
+		currentBreakable = stmt
 		if stmt.forStmt.Init != nil {
-			emitStmt(stmt.forStmt.Init)
+			walkStmt(stmt.forStmt.Init)
 		}
-
-		fmtPrintf("  %s:\n", labelCond)
 		if stmt.forStmt.Cond != nil {
-			emitExpr(stmt.forStmt.Cond, nil)
-			emitPopBool("for condition")
-			fmtPrintf("  cmpq $1, %%rax\n")
-			fmtPrintf("  jne %s # jmp if false\n", labelExit)
+			walkExpr(stmt.forStmt.Cond)
 		}
-		emitStmt(blockStmt2Stmt(stmt.forStmt.Body))
-		fmtPrintf("  %s:\n", labelPost) // used for "continue"
 		if stmt.forStmt.Post != nil {
-			emitStmt(stmt.forStmt.Post)
+			walkStmt(stmt.forStmt.Post)
 		}
-		fmtPrintf("  jmp %s\n", labelCond)
-		fmtPrintf("  %s:\n", labelExit)
-	case "*astRangeStmt": // only for array and slice
-		labelid++
-		var labelCond = ".L.range.cond." + Itoa(labelid)
-		var labelPost = ".L.range.post." + Itoa(labelid)
-		var labelExit = ".L.range.exit." + Itoa(labelid)
-
-		stmt.rangeStmt.labelPost = labelPost
-		stmt.rangeStmt.labelExit = labelExit
-		// initialization: store len(rangeexpr)
-		emitComment(2, "ForRange Initialization\n")
-
-		emitComment(2, "  assign length to lenvar\n")
-		// lenvar = len(s.X)
-		emitVariableAddr(stmt.rangeStmt.lenvar)
-		emitLen(stmt.rangeStmt.X)
-		emitStore(tInt)
-
-		emitComment(2, "  assign 0 to indexvar\n")
-		// indexvar = 0
-		emitVariableAddr(stmt.rangeStmt.indexvar)
-		emitZeroValue(tInt)
-		emitStore(tInt)
-
-		// init key variable with 0
-		if stmt.rangeStmt.Key != nil {
-			assert(stmt.rangeStmt.Key.dtype == "*astIdent", "key expr should be an ident", __func__)
-			var keyIdent = stmt.rangeStmt.Key.ident
-			if keyIdent.Name != "_" {
-				emitAddr(stmt.rangeStmt.Key) // lhs
-				emitZeroValue(tInt)
-				emitStore(tInt)
+		var _s = &astStmt{}
+		_s.dtype = "*astBlockStmt"
+		_s.blockStmt = stmt.forStmt.Body
+		walkStmt(_s)
+		currentFor = outerFor
+		currentBreakable = outerBreakable
+	case "*astRangeStmt":
+		walkExpr(stmt.rangeStmt.X)
+		if stmt.rangeStmt.Tok == "=" {
+			if stmt.rangeStmt.Key != nil {
+				walkExpr(stmt.rangeStmt.Key)
+			}
+			if stmt.rangeStmt.Value != nil {
+				walkExpr(stmt.rangeStmt.Value)
 			}
 		}
-
-		// Condition
-		// if (indexvar < lenvar) then
-		//   execute body
-		// else
-		//   exit
-		emitComment(2, "ForRange Condition\n")
-		fmtPrintf("  %s:\n", labelCond)
-
-		emitVariableAddr(stmt.rangeStmt.indexvar)
-		emitLoad(tInt)
-		emitVariableAddr(stmt.rangeStmt.lenvar)
-		emitLoad(tInt)
-		emitCompExpr("setl")
-		emitPopBool(" indexvar < lenvar")
-		fmtPrintf("  cmpq $1, %%rax\n")
-		fmtPrintf("  jne %s # jmp if false\n", labelExit)
-
-		emitComment(2, "assign list[indexvar] value variables\n")
-		var elemType = getTypeOfExpr(stmt.rangeStmt.Value)
-		emitAddr(stmt.rangeStmt.Value) // lhs
-
-		emitVariableAddr(stmt.rangeStmt.indexvar)
-		emitLoad(tInt) // index value
-		emitListElementAddr(stmt.rangeStmt.X, elemType)
-
-		emitLoad(elemType)
-		emitStore(elemType)
-
-		// Body
-		emitComment(2, "ForRange Body\n")
-		emitStmt(blockStmt2Stmt(stmt.rangeStmt.Body))
-
-		// Post statement: Increment indexvar and go next
-		emitComment(2, "ForRange Post statement\n")
-		fmtPrintf("  %s:\n", labelPost)           // used for "continue"
-		emitVariableAddr(stmt.rangeStmt.indexvar) // lhs
-		emitVariableAddr(stmt.rangeStmt.indexvar) // rhs
-		emitLoad(tInt)
-		emitAddConst(1, "indexvar value ++")
-		emitStore(tInt)
-
-		if stmt.rangeStmt.Key != nil {
-			assert(stmt.rangeStmt.Key.dtype == "*astIdent", "key expr should be an ident", __func__)
-			var keyIdent = stmt.rangeStmt.Key.ident
-			if keyIdent.Name != "_" {
-				emitAddr(stmt.rangeStmt.Key)              // lhs
-				emitVariableAddr(stmt.rangeStmt.indexvar) // rhs
-				emitLoad(tInt)
-				emitStore(tInt)
+		if stmt.rangeStmt.Tok == ":=" {
+			if stmt.rangeStmt.Key != nil && !isBlankIdent(stmt.rangeStmt.Key) {
+				declareLocalVariableOfShortVar(stmt.rangeStmt.Key.ident.Obj)
+			}
+			if stmt.rangeStmt.Value != nil && !isBlankIdent(stmt.rangeStmt.Value) {
+				declareLocalVariableOfShortVar(stmt.rangeStmt.Value.ident.Obj)
 			}
 		}
-
-		fmtPrintf("  jmp %s\n", labelCond)
-
-		fmtPrintf("  %s:\n", labelExit)
-
+		var outerFor = currentFor
+		var outerBreakable = currentBreakable
+		currentFor = stmt
+		currentBreakable = stmt
+		var _s = blockStmt2Stmt(stmt.rangeStmt.Body)
+		walkStmt(_s)
+		localoffset = localoffset - intSize
+		var lenvar = newLocalVariable(".range.len", localoffset)
+		localoffset = localoffset - intSize
+		var indexvar = newLocalVariable(".range.index", localoffset)
+		stmt.rangeStmt.lenvar = lenvar
+		stmt.rangeStmt.indexvar = indexvar
+		currentFor = outerFor
+		currentBreakable = outerBreakable
 	case "*astIncDecStmt":
-		var addValue int
-		switch stmt.incDecStmt.Tok {
-		case "++":
-			addValue = 1
-		case "--":
-			addValue = -1
-		default:
-			panic2(__func__, "Unexpected Tok="+stmt.incDecStmt.Tok)
+		walkExpr(stmt.incDecStmt.X)
+	case "*astBlockStmt":
+		var s *astStmt
+		for _, s = range stmt.blockStmt.List {
+			walkStmt(s)
 		}
-		emitAddr(stmt.incDecStmt.X)
-		emitExpr(stmt.incDecStmt.X, nil)
-		emitAddConst(addValue, "rhs ++ or --")
-		emitStore(getTypeOfExpr(stmt.incDecStmt.X))
+	case "*astBranchStmt":
+		walkBranchStmt(stmt.branchStmt)
+	case "*astLabeledStmt":
+		currentLabels = append(currentLabels, stmt.labeledStmt)
+		walkStmt(stmt.labeledStmt.Stmt)
+		currentLabels = currentLabels[0 : len(currentLabels)-1]
 	case "*astSwitchStmt":
-		labelid++
-		var labelEnd = fmtSprintf(".L.switch.%s.exit", []string{Itoa(labelid)})
-		if stmt.switchStmt.Tag == nil {
-			panic2(__func__, "Omitted tag is not supported yet")
+		if stmt.switchStmt.Init != nil {
+			walkStmt(stmt.switchStmt.Init)
 		}
-		emitExpr(stmt.switchStmt.Tag, nil)
-		var condType = getTypeOfExpr(stmt.switchStmt.Tag)
-		var cases = stmt.switchStmt.Body.List
-		emitComment(2, "[DEBUG] cases len=%s\n", Itoa(len(cases)))
-		var labels = make([]string, len(cases), len(cases))
-		var defaultLabel string
+		if stmt.switchStmt.Tag != nil {
+			walkExpr(stmt.switchStmt.Tag)
+		}
+		var clauses = stmt.switchStmt.Body.List
 		var i int
 		var c *astStmt
-		emitComment(2, "Start comparison with cases\n")
-		for i, c = range cases {
-			emitComment(2, "CASES idx=%s\n", Itoa(i))
-			assert(c.dtype == "*astCaseClause", "should be *astCaseClause", __func__)
-			var cc = c.caseClause
-			labelid++
-			var labelCase = ".L.case." + Itoa(labelid)
-			labels[i] = labelCase
-			if len(cc.List) == 0 { // @TODO implement slice nil comparison
-				defaultLabel = labelCase
+		for i, c = range clauses {
+			var body = c.caseClause.Body
+			if len(body) == 0 {
 				continue
 			}
-			var e *astExpr
-			for _, e = range cc.List {
-				assert(getSizeOfType(condType) <= 8 || kind(condType) == T_STRING, "should be one register size or string", __func__)
-				emitPushStackTop(condType, "switch expr")
-				emitExpr(e, nil)
-				emitCompEq(condType)
-				emitPopBool(" of switch-case comparison")
-				fmtPrintf("  cmpq $1, %%rax\n")
-				fmtPrintf("  je %s # jump if match\n", labelCase)
+			var last = body[len(body)-1]
+			if last.dtype == "*astBranchStmt" && last.branchStmt.Tok == "fallthrough" {
+				if i == len(clauses)-1 {
+					panic2(__func__, "cannot fallthrough final case in switch")
+				}
+				last.branchStmt.target = clauses[i+1]
 			}
 		}
-		emitComment(2, "End comparison with cases\n")
+		var outerBreakable = currentBreakable
+		currentBreakable = stmt
+		walkStmt(blockStmt2Stmt(stmt.switchStmt.Body))
+		currentBreakable = outerBreakable
+	case "*astTypeSwitchStmt":
+		var typeSwitch = stmt.typeSwitchStmt
+		var subject = getTypeSwitchSubject(typeSwitch)
+		if typeSwitch.Init != nil {
+			walkStmt(typeSwitch.Init)
+		}
+		var outerBreakable = currentBreakable
+		currentBreakable = stmt
+		walkExpr(subject)
+		var subjectType = getTypeOfExpr(subject)
+		localoffset = localoffset - interfaceSize
+		typeSwitch.subject = newLocalVariable(".typeswitch.subject", localoffset)
+		var c *astStmt
+		for _, c = range typeSwitch.Body.List {
+			var spec = c.caseClause.implicit
+			if spec != nil {
+				if spec.Type == nil || isNil(spec.Type) {
+					spec.Type = subjectType.e
+				}
+				localoffset = localoffset - getSizeOfType(e2t(spec.Type))
+				spec.Name.Obj.Variable = newLocalVariable(spec.Name.Name, localoffset)
+			}
+			walkStmt(c)
+		}
+		currentBreakable = outerBreakable
+	case "*astSendStmt":
+		walkExpr(stmt.sendStmt.Chan)
+		walkExpr(stmt.sendStmt.Value)
+	case "*astSelectStmt":
+		var outerBreakable = currentBreakable
+		currentBreakable = stmt
+		walkStmt(blockStmt2Stmt(stmt.selectStmt.Body))
+		currentBreakable = outerBreakable
+	case "*astCommClause":
+		if stmt.commClause.Comm != nil {
+			walkStmt(stmt.commClause.Comm)
+		}
+		var s_ *astStmt
+		for _, s_ = range stmt.commClause.Body {
+			walkStmt(s_)
+		}
+	case "*astCaseClause":
+		var e_ *astExpr
+		var s_ *astStmt
+		for _, e_ = range stmt.caseClause.List {
+			walkExpr(e_)
+		}
+		for _, s_ = range stmt.caseClause.Body {
+			walkStmt(s_)
+		}
+	default:
+		panic2(__func__, "TBI: stmt.dtype="+stmt.dtype)
+	}
+}
 
-		// if no case matches, then jump to
-		if defaultLabel != "" {
-			// default
-			fmtPrintf("  jmp %s\n", defaultLabel)
+// var x T or var x = v in a function
+func walkLocalVarSpec(valSpec *astValueSpec) {
+	if valSpec.tuple != nil {
+		walkExpr(valSpec.Value)
+		var lhs *astExpr
+		for _, lhs = range valSpec.tuple.Lhs {
+			if !isBlankIdent(lhs) {
+				declareLocalVariableOfShortVar(lhs.ident.Obj)
+			}
+		}
+		return
+	}
+	if valSpec.Type == nil {
+		if valSpec.Value == nil {
+			panic2(__func__, "type inference requires a value")
+		}
+		var _typ = getTypeOfExpr(valSpec.Value)
+		if _typ != nil && _typ.e != nil {
+			valSpec.Type = _typ.e
 		} else {
-			// exit
-			fmtPrintf("  jmp %s\n", labelEnd)
+			panic2(__func__, "type inference failed")
 		}
+	}
+	var typ = valSpec.Type // Type can be nil
+	logf(" [walkStmt] valSpec Name=%s, Type=%s\n",
+		valSpec.Name.Name, typ.dtype)
 
-		emitRevertStackTop(condType)
-		for i, c = range cases {
-			assert(c.dtype == "*astCaseClause", "should be *astCaseClause", __func__)
-			var cc = c.caseClause
-			fmtPrintf("%s:\n", labels[i])
-			var _s *astStmt
-			for _, _s = range cc.Body {
-				emitStmt(_s)
+	var t = e2t(typ)
+	var sizeOfType = getSizeOfType(t)
+	localoffset = localoffset - sizeOfType
+
+	valSpec.Name.Obj.Variable = newLocalVariable(valSpec.Name.Name, localoffset)
+	logf(" var %s offset = %d\n", valSpec.Name.Obj.Name,
+		Itoa(valSpec.Name.Obj.Variable.localOffset))
+	if valSpec.Value != nil {
+		walkExpr(valSpec.Value)
+	}
+}
+
+// Statements that break, continue and labels refer to
+var currentFor *astStmt       // the innermost loop
+var currentBreakable *astStmt // the innermost loop, switch or select
+var currentLabels []*astLabeledStmt
+
+func walkBranchStmt(branch *astBranchStmt) {
+	switch branch.Tok {
+	case "break":
+		if branch.Label == "" {
+			if currentBreakable == nil {
+				panic2(__func__, "break is not in a loop, switch, or select")
 			}
-			fmtPrintf("  jmp %s\n", labelEnd)
+			branch.target = currentBreakable
+			return
 		}
-		fmtPrintf("%s:\n", labelEnd)
-	case "*astBranchStmt":
-		var containerFor = stmt.branchStmt.currentFor
-		var labelToGo string
-		switch stmt.branchStmt.Tok {
-		case "continue":
-			switch containerFor.dtype {
-			case "*astForStmt":
-				labelToGo = containerFor.forStmt.labelPost
-			case "*astRangeStmt":
-				labelToGo = containerFor.rangeStmt.labelPost
-			default:
-				panic2(__func__, "unexpected container dtype="+containerFor.dtype)
-			}
-			fmtPrintf("jmp %s # continue\n", labelToGo)
-		case "break":
-			switch containerFor.dtype {
-			case "*astForStmt":
-				labelToGo = containerFor.forStmt.labelExit
-			case "*astRangeStmt":
-				labelToGo = containerFor.rangeStmt.labelExit
-			default:
-				panic2(__func__, "unexpected container dtype="+containerFor.dtype)
+		var labeled = lookupEnclosingLabel(branch.Label)
+		if labeled == nil || !isBreakTarget(labeled.Stmt) {
+			panic2(__func__, "invalid break label "+branch.Label)
+		}
+		branch.target = labeled.Stmt
+	case "continue":
+		if branch.Label == "" {
+			if currentFor == nil {
+				panic2(__func__, "continue is not in a loop")
 			}
-			fmtPrintf("jmp %s # break\n", labelToGo)
-		default:
-			panic2(__func__, "unexpected tok="+stmt.branchStmt.Tok)
+			branch.target = currentFor
+			return
+		}
+		var labeled = lookupEnclosingLabel(branch.Label)
+		if labeled == nil || !isLoop(labeled.Stmt) {
+			panic2(__func__, "invalid continue label "+branch.Label)
+		}
+		branch.target = labeled.Stmt
+	case "goto":
+		// resolved by checkLabels
+	case "fallthrough":
+		// set by the enclosing switch statement
+		if branch.target == nil {
+			panic2(__func__, "fallthrough statement out of place")
 		}
-	default:
-		panic2(__func__, "TBI:"+stmt.dtype)
 	}
 }
 
-func blockStmt2Stmt(block *astBlockStmt) *astStmt {
-	var stmt = &astStmt{}
-	stmt.dtype = "*astBlockStmt"
-	stmt.blockStmt = block
-	return stmt
+func lookupEnclosingLabel(name string) *astLabeledStmt {
+	var i int
+	for i = len(currentLabels) - 1; i >= 0; i-- {
+		if currentLabels[i].Label.Name == name {
+			return currentLabels[i]
+		}
+	}
+	return nil
 }
 
-func emitRevertStackTop(t *Type) {
-	fmtPrintf("  addq $%s, %%rsp # revert stack top\n", Itoa(getSizeOfType(t)))
+func isLoop(stmt *astStmt) bool {
+	return stmt.dtype == "*astForStmt" || stmt.dtype == "*astRangeStmt"
 }
 
-var labelid int
-
-func getMethodSymbol(method *Method) string {
-	var rcvTypeName = method.rcvNamedType
-	if method.isPtrMethod {
-		return "$" + rcvTypeName.Name + "." + method.name // pointer
-	} else {
-		return rcvTypeName.Name + "." + method.name // value
+func isBreakTarget(stmt *astStmt) bool {
+	switch stmt.dtype {
+	case "*astForStmt", "*astRangeStmt", "*astSwitchStmt", "*astTypeSwitchStmt", "*astSelectStmt":
+		return true
 	}
+	return false
 }
 
-func getFuncSubSymbol(fnc *Func) string {
-	var subsymbol string
-	if fnc.method != nil {
-		subsymbol = getMethodSymbol(fnc.method)
-	} else {
-		subsymbol = fnc.name
+// --- goto ---
+// Gotos are checked by blocks as the spec says:
+// a goto must not jump over variable declarations, or into a block.
+type labelBlock struct {
+	parent   *labelBlock
+	labels   []*astStmt       // labeled statements declared so far in the block
+	fwdJumps []*astBranchStmt // gotos to labels not declared yet
+	badJumps []*astBranchStmt // forward gotos followed by a variable declaration
+}
+
+var funcLabels []*astLabeledStmt // all the labels of the current function
+var labelRefs []*astBranchStmt   // branch statements with a label
+
+// Resolve the gotos of a function body, and check its labels
+func checkLabels(body *astBlockStmt) {
+	funcLabels = nil
+	labelRefs = nil
+	var jmp *astBranchStmt
+	for _, jmp = range blockBranches(nil, body.List) {
+		if lookupLabel(funcLabels, jmp.Label) != nil {
+			panic2(__func__, "goto "+jmp.Label+" jumps into block")
+		}
+		panic2(__func__, "label "+jmp.Label+" not defined")
+	}
+	var labeled *astLabeledStmt
+	for _, labeled = range funcLabels {
+		var used bool
+		for _, jmp = range labelRefs {
+			if jmp.Label == labeled.Label.Name {
+				used = true
+			}
+		}
+		if !used {
+			panic2(__func__, "label "+labeled.Label.Name+" defined and not used")
+		}
 	}
-	return subsymbol
 }
 
-func getFuncSymbol(pkgPrefix string, subsymbol string) string {
-	return pkgPrefix + "." + subsymbol
+func lookupLabel(labels []*astLabeledStmt, name string) *astLabeledStmt {
+	var labeled *astLabeledStmt
+	for _, labeled = range labels {
+		if labeled.Label.Name == name {
+			return labeled
+		}
+	}
+	return nil
 }
 
-func emitFuncDecl(pkgPrefix string, fnc *Func) {
-	var localarea = fnc.localarea
-	fmtPrintf("\n")
-	var subsymbol = getFuncSubSymbol(fnc)
-	var symbol = getFuncSymbol(pkgPrefix, subsymbol)
-	fmtPrintf("%s: # args %d, locals %d\n",
-		symbol, Itoa(int(fnc.argsarea)), Itoa(int(fnc.localarea)))
-
-	fmtPrintf("  pushq %%rbp\n")
-	fmtPrintf("  movq %%rsp, %%rbp\n")
-	if localarea != 0 {
-		fmtPrintf("  subq $%d, %%rsp # local area\n", Itoa(-localarea))
+// Returns the forward gotos that are not resolved in the block
+func blockBranches(parent *labelBlock, list []*astStmt) []*astBranchStmt {
+	var b = &labelBlock{}
+	b.parent = parent
+	var s *astStmt
+	for _, s = range list {
+		stmtBranches(b, s)
 	}
+	return b.fwdJumps
+}
 
-	if fnc.Body != nil {
-		emitStmt(blockStmt2Stmt(fnc.Body))
+func nestedBranches(b *labelBlock, list []*astStmt) {
+	var jmp *astBranchStmt
+	for _, jmp = range blockBranches(b, list) {
+		b.fwdJumps = append(b.fwdJumps, jmp)
 	}
-
-	fmtPrintf("  leave\n")
-	fmtPrintf("  ret\n")
 }
 
-func emitGlobalVariable(name *astIdent, t *Type, val *astExpr) {
-	var typeKind = kind(t)
-	fmtPrintf("%s: # T %s\n", name.Name, typeKind)
-	switch typeKind {
-	case T_STRING:
-		if val != nil && val.dtype == "*astBasicLit" {
-			var sl = getStringLiteral(val.basicLit)
-			fmtPrintf("  .quad %s\n", sl.label)
-			fmtPrintf("  .quad %d\n", Itoa(sl.strlen))
-		} else {
-			fmtPrintf("  .quad 0\n")
-			fmtPrintf("  .quad 0\n")
-		}
-	case T_POINTER:
-		fmtPrintf("  .quad 0 # pointer \n") // @TODO
-	case T_UINTPTR:
-		fmtPrintf("  .quad 0\n")
-	case T_BOOL:
-		if val != nil {
-			switch val.dtype {
-			case "*astIdent":
-				switch val.ident.Obj {
-				case gTrue:
-					fmtPrintf("  .quad 1 # bool true\n")
-				case gFalse:
-					fmtPrintf("  .quad 0 # bool false\n")
-				default:
-					panic2(__func__, "")
-				}
-			default:
-				panic2(__func__, "")
+func stmtBranches(b *labelBlock, s *astStmt) {
+	switch s.dtype {
+	case "*astDeclStmt":
+		var spec *astSpec
+		for _, spec = range s.DeclStmt.Decl.genDecl.Specs {
+			if spec.dtype == "*astValueSpec" && spec.valueSpec.Name.Obj.Kind == astVar {
+				b.badJumps = b.fwdJumps
 			}
-		} else {
-			fmtPrintf("  .quad 0 # bool zero value\n")
 		}
-	case T_INT:
-		fmtPrintf("  .quad 0\n")
-	case T_UINT8:
-		fmtPrintf("  .byte 0\n")
-	case T_UINT16:
-		fmtPrintf("  .word 0\n")
-	case T_SLICE:
-		fmtPrintf("  .quad 0 # ptr\n")
-		fmtPrintf("  .quad 0 # len\n")
-		fmtPrintf("  .quad 0 # cap\n")
-	case T_ARRAY:
-		if val != nil {
-			panic2(__func__, "TBI")
+	case "*astAssignStmt":
+		if s.assignStmt.Tok == ":=" {
+			b.badJumps = b.fwdJumps
 		}
-		if t.e.dtype != "*astArrayType" {
-			panic2(__func__, "Unexpected type:"+t.e.dtype)
+	case "*astLabeledStmt":
+		var labeled = s.labeledStmt
+		var name = labeled.Label.Name
+		if lookupLabel(funcLabels, name) != nil {
+			panic2(__func__, "label "+name+" already defined")
 		}
-		var arrayType = t.e.arrayType
-		if arrayType.Len == nil {
-			panic2(__func__, "global slice is not supported")
+		labelid++
+		labeled.label = ".L.label." + name + "." + Itoa(labelid)
+		funcLabels = append(funcLabels, labeled)
+		b.labels = append(b.labels, s)
+		var fwdJumps []*astBranchStmt
+		var jmp *astBranchStmt
+		for _, jmp = range b.fwdJumps {
+			if jmp.Label != name {
+				fwdJumps = append(fwdJumps, jmp)
+				continue
+			}
+			var j *astBranchStmt
+			for _, j = range b.badJumps {
+				if j == jmp {
+					panic2(__func__, "goto "+name+" jumps over variable declaration")
+				}
+			}
+			jmp.target = s
 		}
-		if arrayType.Len.dtype != "*astBasicLit" {
-			panic2(__func__, "shoulbe basic literal")
+		b.fwdJumps = fwdJumps
+		stmtBranches(b, labeled.Stmt)
+	case "*astBranchStmt":
+		var jmp = s.branchStmt
+		if jmp.Label == "" {
+			return
 		}
-		var basicLit = arrayType.Len.basicLit
-		if len(basicLit.Value) > 1 {
-			panic2(__func__, "array length >= 10 is not supported yet.")
+		labelRefs = append(labelRefs, jmp)
+		if jmp.Tok != "goto" {
+			return
 		}
-		var length = evalInt(arrayType.Len)
-		emitComment(0, "[emitGlobalVariable] array length uint8=%s\n", Itoa(length))
-		var zeroValue string
-		var kind string = kind(e2t(arrayType.Elt))
-		switch kind {
-		case T_INT:
-			zeroValue = "  .quad 0 # int zero value\n"
-		case T_UINT8:
-			zeroValue = "  .byte 0 # uint8 zero value\n"
-		case T_STRING:
-			zeroValue = "  .quad 0 # string zero value (ptr)\n"
-			zeroValue = zeroValue + "  .quad 0 # string zero value (len)\n"
-		default:
-			panic2(__func__, "Unexpected kind:"+kind)
+		var blk *labelBlock
+		for blk = b; blk != nil; blk = blk.parent {
+			var labeled *astStmt
+			for _, labeled = range blk.labels {
+				if labeled.labeledStmt.Label.Name == jmp.Label {
+					// a backward jump
+					jmp.target = labeled
+					return
+				}
+			}
+		}
+		b.fwdJumps = append(b.fwdJumps, jmp)
+	case "*astBlockStmt":
+		nestedBranches(b, s.blockStmt.List)
+	case "*astIfStmt":
+		nestedBranches(b, s.ifStmt.Body.List)
+		if s.ifStmt.Else != nil {
+			nestedBranches(b, []*astStmt{s.ifStmt.Else})
 		}
+	case "*astForStmt":
+		nestedBranches(b, s.forStmt.Body.List)
+	case "*astRangeStmt":
+		nestedBranches(b, s.rangeStmt.Body.List)
+	case "*astSwitchStmt":
+		nestedBranches(b, s.switchStmt.Body.List)
+	case "*astTypeSwitchStmt":
+		nestedBranches(b, s.typeSwitchStmt.Body.List)
+	case "*astSelectStmt":
+		nestedBranches(b, s.selectStmt.Body.List)
+	case "*astCaseClause":
+		nestedBranches(b, s.caseClause.Body)
+	case "*astCommClause":
+		nestedBranches(b, s.commClause.Body)
+	}
+}
+
+// Is lhs a variable newly declared by this ":=" statement ?
+func isNewShortVar(lhs *astExpr, as *astAssignStmt) bool {
+	if isBlankIdent(lhs) {
+		return false
+	}
+	var decl = lhs.ident.Obj.Decl
+	return decl.dtype == "*astAssignStmt" && decl.assignment == as
+}
+
+// A hidden local variable of type t
+func newTempVariable(name string, t *Type) *astExpr {
+	var ident = &astIdent{
+		Name: name,
+	}
+	ident.Obj = &astObject{
+		Kind: astVar,
+		Name: name,
+		Decl: &ObjDecl{
+			dtype: "*astValueSpec",
+			valueSpec: &astValueSpec{
+				Name: ident,
+				Type: t.e,
+			},
+		},
+	}
+	localoffset = localoffset - getSizeOfType(t)
+	ident.Obj.Variable = newLocalVariable(name, localoffset)
+	return &astExpr{
+		dtype: "*astIdent",
+		ident: ident,
+	}
+}
+
+// f(g()) where g returns multiple values is f(t1, t2, ...) after "t1, t2, ... = g()"
+func walkTupleArgs(call *astCallExpr) {
+	if len(call.Args) != 1 || !isMultiValueCall(call.Args[0]) || isType(call.Fun) {
+		return
+	}
+	if call.Fun.dtype == "*astIdent" && call.Fun.ident.Obj.Kind == astFun && call.Fun.ident.Obj.Decl == nil {
+		// builtin
+		return
+	}
+	var temps []*astExpr
+	var field *astField
+	for _, field = range getCallResults(call.Args[0].callExpr) {
+		temps = append(temps, newTempVariable(".tuple.arg", e2t(field.Type)))
+	}
+	call.tupleArgs = &astAssignStmt{
+		Lhs: temps,
+		Tok: "=",
+		Rhs: call.Args,
+	}
+	call.Args = temps
+}
+
+func declareLocalVariableOfShortVar(obj *astObject) {
+	assert(obj.Kind == astVar, "should be ast.Var", __func__)
+	var ident = &astIdent{
+		Name: obj.Name,
+		Obj:  obj,
+	}
+	var e = &astExpr{
+		dtype: "*astIdent",
+		ident: ident,
+	}
+	// infer type
+	var typ = getTypeOfExpr(e)
+	localoffset = localoffset - getSizeOfType(typ)
+	obj.Variable = newLocalVariable(obj.Name, localoffset)
+}
+
+// --- big integers ---
+// Untyped constants are exact, so they are computed with integers of any size.
+// A natural number is a []int of digits in base 2^30, the least significant first,
+// without leading zeros.
+
+const natDigitBits int = 30
+const natDigitMask int = 1<<30 - 1
+
+type bigInt struct {
+	neg bool
+	mag []int
+}
+
+func newBigInt(neg bool, mag []int) *bigInt {
+	var x = &bigInt{}
+	x.mag = natNorm(mag)
+	x.neg = neg && len(x.mag) > 0
+	return x
+}
 
-		var i int
-		for i = 0; i < length; i++ {
-			fmtPrintf(zeroValue)
+func bigFromInt(ival int) *bigInt {
+	var mag []int
+	var neg = ival < 0
+	// digits are taken without negating ival, which overflows for the minimum int
+	for ival != 0 {
+		var d = ival % (natDigitMask + 1)
+		if d < 0 {
+			d = -d
 		}
-	default:
-		panic2(__func__, "TBI:kind="+typeKind)
+		mag = append(mag, d)
+		ival = ival / (natDigitMask + 1)
 	}
+	return newBigInt(neg, mag)
 }
 
-func emitData(pkgName string, vars []*astValueSpec, sliterals []*stringLiteralsContainer) {
-	fmtPrintf(".data\n")
-	emitComment(0, "string literals len = %s\n", Itoa(len(sliterals)))
-	var con *stringLiteralsContainer
-	for _, con = range sliterals {
-		emitComment(0, "string literals\n")
-		fmtPrintf("%s:\n", con.sl.label)
-		fmtPrintf("  .string %s\n", con.sl.value)
+// The low 64 bits of x in two's complement
+func bigToInt(x *bigInt) int {
+	var ival int
+	var i int
+	for i = len(x.mag) - 1; i >= 0; i-- {
+		ival = ival<<natDigitBits | x.mag[i]
+	}
+	if x.neg {
+		return -ival
 	}
+	return ival
+}
 
-	emitComment(0, "===== Global Variables =====\n")
+func bigNeg(x *bigInt) *bigInt {
+	return newBigInt(!x.neg, x.mag)
+}
 
-	var spec *astValueSpec
-	for _, spec = range vars {
-		var t *Type
-		if spec.Type != nil {
-			t = e2t(spec.Type)
-		}
-		emitGlobalVariable(spec.Name, t, spec.Value)
+func bigSign(x *bigInt) int {
+	if len(x.mag) == 0 {
+		return 0
 	}
+	if x.neg {
+		return -1
+	}
+	return 1
+}
 
-	emitComment(0, "==============================\n")
+func bigCmp(x *bigInt, y *bigInt) int {
+	return bigSign(bigSub(x, y))
 }
 
-func emitText(pkgName string, funcs []*Func) {
-	fmtPrintf(".text\n")
-	var fnc *Func
-	for _, fnc = range funcs {
-		emitFuncDecl(pkgName, fnc)
+func bigAdd(x *bigInt, y *bigInt) *bigInt {
+	if x.neg == y.neg {
+		return newBigInt(x.neg, natAdd(x.mag, y.mag))
 	}
+	if natCmp(x.mag, y.mag) >= 0 {
+		return newBigInt(x.neg, natSub(x.mag, y.mag))
+	}
+	return newBigInt(y.neg, natSub(y.mag, x.mag))
 }
 
-func generateCode(pkgContainer *PkgContainer) {
-	emitData(pkgContainer.name, pkgContainer.vars, stringLiterals)
-	emitText(pkgContainer.name, pkgContainer.funcs)
+func bigSub(x *bigInt, y *bigInt) *bigInt {
+	return bigAdd(x, bigNeg(y))
 }
 
-// --- type ---
-const sliceSize int = 24
-const stringSize int = 16
-const intSize int = 8
-const ptrSize int = 8
+func bigMul(x *bigInt, y *bigInt) *bigInt {
+	return newBigInt(x.neg != y.neg, natMul(x.mag, y.mag))
+}
 
-type Type struct {
-	//kind string
-	e *astExpr
+// x / y truncated toward zero, and the remainder
+func bigQuoRem(x *bigInt, y *bigInt) (*bigInt, *bigInt) {
+	var q []int
+	var r []int
+	q, r = natDivMod(x.mag, y.mag)
+	return newBigInt(x.neg != y.neg, q), newBigInt(x.neg, r)
 }
 
-const T_STRING string = "T_STRING"
-const T_SLICE string = "T_SLICE"
-const T_BOOL string = "T_BOOL"
-const T_INT string = "T_INT"
-const T_UINT8 string = "T_UINT8"
-const T_UINT16 string = "T_UINT16"
-const T_UINTPTR string = "T_UINTPTR"
-const T_ARRAY string = "T_ARRAY"
-const T_STRUCT string = "T_STRUCT"
-const T_POINTER string = "T_POINTER"
+func bigShl(x *bigInt, n int) *bigInt {
+	return newBigInt(x.neg, natShl(x.mag, n))
+}
 
-var tInt *Type
-var tUint8 *Type
-var tUint16 *Type
-var tUintptr *Type
-var tSliceOfString *Type
-var tString *Type
-var tBool *Type
+// x >> n rounds toward negative infinity like an arithmetic shift
+func bigShr(x *bigInt, n int) *bigInt {
+	if !x.neg {
+		return newBigInt(false, natShr(x.mag, n))
+	}
+	var one = natFromInt(1)
+	return newBigInt(true, natAdd(natShr(natSub(x.mag, one), n), one))
+}
 
-var generalSlice *astExpr
+// x op y for op &, |, ^ or &^ in two's complement
+func bigBitwise(op string, x *bigInt, y *bigInt) *bigInt {
+	// a digit more than the operands for the sign
+	var n = len(x.mag)
+	if len(y.mag) > n {
+		n = len(y.mag)
+	}
+	n++
+	var a = bigToDigits(x, n)
+	var b = bigToDigits(y, n)
+	var r = make([]int, n, n)
+	var i int
+	for i = 0; i < n; i++ {
+		switch op {
+		case "&":
+			r[i] = a[i] & b[i]
+		case "|":
+			r[i] = a[i] | b[i]
+		case "^":
+			r[i] = a[i] ^ b[i]
+		case "&^":
+			r[i] = a[i] &^ b[i]
+		}
+	}
+	if r[n-1]>>(natDigitBits-1) == 0 {
+		return newBigInt(false, r)
+	}
+	// -(^r + 1)
+	for i = 0; i < n; i++ {
+		r[i] = ^r[i] & natDigitMask
+	}
+	return newBigInt(true, natAdd(r, natFromInt(1)))
+}
 
-func getTypeOfExpr(expr *astExpr) *Type {
-	//emitComment(0, "[%s] start\n", __func__)
-	switch expr.dtype {
-	case "*astIdent":
-		switch expr.ident.Obj.Kind {
-		case astVar:
-			switch expr.ident.Obj.Decl.dtype {
-			case "*astValueSpec":
-				var decl = expr.ident.Obj.Decl.valueSpec
-				var t = &Type{}
-				t.e = decl.Type
-				return t
-			case "*astField":
-				var decl = expr.ident.Obj.Decl.field
-				var t = &Type{}
-				t.e = decl.Type
-				return t
-			case "*astAssignStmt": // lhs := rhs
-				return getTypeOfExpr(expr.ident.Obj.Decl.assignment.Rhs[0])
-			default:
-				panic2(__func__, "dtype:" + expr.ident.Obj.Decl.dtype )
-			}
-		case astCon:
-			switch expr.ident.Obj {
-			case gTrue, gFalse:
-				return tBool
-			}
-			switch expr.ident.Obj.Decl.dtype {
-			case "*astValueSpec":
-				return e2t(expr.ident.Obj.Decl.valueSpec.Type)
-			default:
-				panic2(__func__, "cannot decide type of cont ="+expr.ident.Obj.Name)
-			}
-		default:
-			panic2(__func__, "2:Obj.Kind="+expr.ident.Obj.Kind)
+// The n lowest digits of x in two's complement
+func bigToDigits(x *bigInt, n int) []int {
+	var mag = x.mag
+	if x.neg {
+		mag = natSub(mag, natFromInt(1))
+	}
+	var r = make([]int, n, n)
+	var i int
+	for i = 0; i < n; i++ {
+		if i < len(mag) {
+			r[i] = mag[i]
 		}
-	case "*astBasicLit":
-		switch expr.basicLit.Kind {
-		case "STRING":
-			return tString
-		case "INT":
-			return tInt
-		case "CHAR":
-			return tInt
-		default:
-			panic2(__func__, "TBI:"+expr.basicLit.Kind)
+		if x.neg {
+			r[i] = ^r[i] & natDigitMask
 		}
-	case "*astIndexExpr":
-		var list = expr.indexExpr.X
-		return getElementTypeOfListType(getTypeOfExpr(list))
-	case "*astUnaryExpr":
-		switch expr.unaryExpr.Op {
-		case "-":
-			return getTypeOfExpr(expr.unaryExpr.X)
-		case "!":
-			return tBool
-		case "&":
-			var starExpr = &astStarExpr{}
-			var t = getTypeOfExpr(expr.unaryExpr.X)
-			starExpr.X = t.e
-			var eStarExpr = &astExpr{}
-			eStarExpr.dtype = "*astStarExpr"
-			eStarExpr.starExpr = starExpr
-			return e2t(eStarExpr)
-		default:
-			panic2(__func__, "TBI: Op="+expr.unaryExpr.Op)
+	}
+	return r
+}
+
+// Whether x is an integer of the given bits
+func bigFitsBits(x *bigInt, bits int, signed bool) bool {
+	var n = natBitLen(x.mag)
+	if !signed {
+		return !x.neg && n <= bits
+	}
+	if n < bits {
+		return true
+	}
+	// the minimum -2^(bits-1)
+	return x.neg && n == bits && natCmp(x.mag, natShl(natFromInt(1), bits-1)) == 0
+}
+
+func natFromInt(ival int) []int {
+	return bigFromInt(ival).mag
+}
+
+func natNorm(a []int) []int {
+	var n = len(a)
+	for n > 0 && a[n-1] == 0 {
+		n--
+	}
+	return a[0:n]
+}
+
+func natCmp(a []int, b []int) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
 		}
-	case "*astCallExpr":
-		emitComment(0, "[%s] *astCallExpr\n", __func__)
-		var fun = expr.callExpr.Fun
-		switch fun.dtype {
-		case "*astIdent":
-			var fn = fun.ident
-			if fn.Obj == nil {
-				panic2(__func__, "[astCallExpr] nil Obj is not allowed")
-			}
-			switch fn.Obj.Kind {
-			case astTyp:
-				return e2t(fun)
-			case astFun:
-				switch fn.Obj {
-				case gLen, gCap:
-					return tInt
-				case gNew:
-					var starExpr = &astStarExpr{}
-					starExpr.X = expr.callExpr.Args[0]
-					var eStarExpr = &astExpr{}
-					eStarExpr.dtype = "*astStarExpr"
-					eStarExpr.starExpr = starExpr
-					return e2t(eStarExpr)
-				case gMake:
-					return e2t(expr.callExpr.Args[0])
-				}
-				var decl = fn.Obj.Decl
-				if decl == nil {
-					panic2(__func__, "decl of function "+fn.Name+" is  nil")
-				}
-				switch decl.dtype {
-				case "*astFuncDecl":
-					var resultList = decl.funcDecl.Type.Results.List
-					if len(resultList) != 1 {
-						panic2(__func__, "[astCallExpr] len results.List is not 1")
-					}
-					return e2t(decl.funcDecl.Type.Results.List[0].Type)
-				default:
-					panic2(__func__, "[astCallExpr] decl.dtype="+decl.dtype)
-				}
-				panic2(__func__, "[astCallExpr] Fun ident "+fn.Name)
+		return 1
+	}
+	var i int
+	for i = len(a) - 1; i >= 0; i-- {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
 			}
-		case "*astArrayType":
-			return e2t(fun)
-		case "*astSelectorExpr": // (X).Sel()
-			var xType = getTypeOfExpr(fun.selectorExpr.X)
-			var method = lookupMethod(xType, fun.selectorExpr.Sel)
-			assert(len(method.funcType.Results.List) == 1, "func is expected to return a single value", __func__)
-			return e2t(method.funcType.Results.List[0].Type)
-		default:
-			panic2(__func__, "[astCallExpr] dtype="+expr.callExpr.Fun.dtype)
+			return 1
 		}
-	case "*astSliceExpr":
-		var underlyingCollectionType = getTypeOfExpr(expr.sliceExpr.X)
-		var elementTyp *astExpr
-		switch underlyingCollectionType.e.dtype {
-		case "*astArrayType":
-			elementTyp = underlyingCollectionType.e.arrayType.Elt
+	}
+	return 0
+}
+
+func natAdd(a []int, b []int) []int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	var r []int
+	var carry int
+	var i int
+	for i = 0; i < len(a); i++ {
+		var d = a[i] + carry
+		if i < len(b) {
+			d = d + b[i]
 		}
-		var t = &astArrayType{}
-		t.Len = nil
-		t.Elt = elementTyp
-		var e = &astExpr{}
-		e.dtype = "*astArrayType"
-		e.arrayType = t
-		return e2t(e)
-	case "*astStarExpr":
-		var t = getTypeOfExpr(expr.starExpr.X)
-		var ptrType = t.e.starExpr
-		if ptrType == nil {
-			panic2(__func__, "starExpr shoud not be nil")
+		r = append(r, d&natDigitMask)
+		carry = d >> natDigitBits
+	}
+	if carry > 0 {
+		r = append(r, carry)
+	}
+	return r
+}
+
+// a - b, where a >= b
+func natSub(a []int, b []int) []int {
+	var r []int
+	var borrow int
+	var i int
+	for i = 0; i < len(a); i++ {
+		var d = a[i] - borrow
+		if i < len(b) {
+			d = d - b[i]
 		}
-		return e2t(ptrType.X)
-	case "*astBinaryExpr":
-		switch expr.binaryExpr.Op {
-		case "==", "!=", "<", ">", "<=", ">=":
-			return tBool
-		default:
-			return getTypeOfExpr(expr.binaryExpr.X)
+		borrow = 0
+		if d < 0 {
+			d = d + natDigitMask + 1
+			borrow = 1
 		}
-	case "*astSelectorExpr":
-		if isOsArgs(expr.selectorExpr) {
-			// os.Args
-			return tSliceOfString
+		r = append(r, d)
+	}
+	return natNorm(r)
+}
+
+func natMul(a []int, b []int) []int {
+	var r = make([]int, len(a)+len(b), len(a)+len(b))
+	var i int
+	var j int
+	for i = 0; i < len(a); i++ {
+		var carry int
+		for j = 0; j < len(b); j++ {
+			var d = r[i+j] + a[i]*b[j] + carry
+			r[i+j] = d & natDigitMask
+			carry = d >> natDigitBits
 		}
-		var structType = getStructTypeOfX(expr.selectorExpr)
-		var field = lookupStructField(getStructTypeSpec(structType), expr.selectorExpr.Sel.Name)
-		return e2t(field.Type)
-	case "*astCompositeLit":
-		return e2t(expr.compositeLit.Type)
-	case "*astParenExpr":
-		return getTypeOfExpr(expr.parenExpr.X)
-	default:
-		panic2(__func__, "TBI:dtype="+expr.dtype)
+		r[i+len(b)] = carry
+	}
+	return natNorm(r)
+}
+
+// a * m + d for small m and d
+func natMulAdd(a []int, m int, d int) []int {
+	var r []int
+	var carry = d
+	var i int
+	for i = 0; i < len(a); i++ {
+		var x = a[i]*m + carry
+		r = append(r, x&natDigitMask)
+		carry = x >> natDigitBits
+	}
+	for carry > 0 {
+		r = append(r, carry&natDigitMask)
+		carry = carry >> natDigitBits
 	}
-
-	panic2(__func__, "nil type is not allowed\n")
-	var r *Type
 	return r
 }
 
-func e2t(typeExpr *astExpr) *Type {
-	if typeExpr == nil {
-		panic2(__func__, "nil is not allowed")
+func natShl(a []int, n int) []int {
+	if len(a) == 0 {
+		return a
+	}
+	var r []int
+	var i int
+	for i = 0; i < n/natDigitBits; i++ {
+		r = append(r, 0)
+	}
+	var s = n % natDigitBits
+	var carry int
+	for i = 0; i < len(a); i++ {
+		var d = a[i]<<s | carry
+		r = append(r, d&natDigitMask)
+		carry = d >> natDigitBits
+	}
+	if carry > 0 {
+		r = append(r, carry)
 	}
-	var r = &Type{}
-	r.e = typeExpr
 	return r
 }
 
-func kind(t *Type) string {
-	if t == nil {
-		panic2(__func__, "nil type is not expected\n")
+func natShr(a []int, n int) []int {
+	var s = n % natDigitBits
+	var r []int
+	var i int
+	for i = n / natDigitBits; i < len(a); i++ {
+		var d = a[i] >> s
+		if i+1 < len(a) {
+			d = d | a[i+1]<<(natDigitBits-s)&natDigitMask
+		}
+		r = append(r, d)
 	}
-	if t.e == generalSlice {
-		return T_SLICE
+	return natNorm(r)
+}
+
+func natBitLen(a []int) int {
+	if len(a) == 0 {
+		return 0
 	}
+	var n = (len(a) - 1) * natDigitBits
+	var top = a[len(a)-1]
+	for top > 0 {
+		top = top >> 1
+		n++
+	}
+	return n
+}
 
-	var e = t.e
-	switch t.e.dtype {
-	case "*astIdent":
-		var ident = t.e.ident
-		switch ident.Name {
-		case "uintptr":
-			return T_UINTPTR
-		case "int":
-			return T_INT
-		case "string":
-			return T_STRING
-		case "uint8":
-			return T_UINT8
-		case "uint16":
-			return T_UINT16
-		case "bool":
-			return T_BOOL
-		default:
-			// named type
-			var decl = ident.Obj.Decl
-			if decl.dtype != "*astTypeSpec" {
-				panic2(__func__, "unsupported decl :"+decl.dtype)
+// a / b and a % b by binary long division
+func natDivMod(a []int, b []int) ([]int, []int) {
+	if len(b) == 0 {
+		panic2(__func__, "division by zero")
+	}
+	var q = make([]int, len(a), len(a))
+	// the remainder has a digit more than b to hold 2*b
+	var r = make([]int, len(b)+1, len(b)+1)
+	var i int
+	var j int
+	for i = natBitLen(a) - 1; i >= 0; i-- {
+		var carry = a[i/natDigitBits] >> (i % natDigitBits) & 1
+		for j = 0; j < len(r); j++ {
+			var d = r[j]<<1 | carry
+			r[j] = d & natDigitMask
+			carry = d >> natDigitBits
+		}
+		if natCmp(natNorm(r), b) >= 0 {
+			var borrow int
+			for j = 0; j < len(r); j++ {
+				var d = r[j] - borrow
+				if j < len(b) {
+					d = d - b[j]
+				}
+				borrow = 0
+				if d < 0 {
+					d = d + natDigitMask + 1
+					borrow = 1
+				}
+				r[j] = d
 			}
-			var typeSpec = decl.typeSpec
-			return kind(e2t(typeSpec.Type))
-		}
-	case "*astStructType":
-		return T_STRUCT
-	case "*astArrayType":
-		if e.arrayType.Len == nil {
-			return T_SLICE
-		} else {
-			return T_ARRAY
+			q[i/natDigitBits] = q[i/natDigitBits] | 1<<(i%natDigitBits)
 		}
-	case "*astStarExpr":
-		return T_POINTER
-	case "*astEllipsis": // x ...T
-		return T_SLICE // @TODO is this right ?
-	default:
-		panic2(__func__, "Unkown dtype:"+t.e.dtype)
 	}
-	panic2(__func__, "error")
-	return ""
+	return natNorm(q), natNorm(r)
 }
 
-func getStructTypeOfX(e *astSelectorExpr) *Type {
-	var typeOfX = getTypeOfExpr(e.X)
-	var structType *Type
-	switch kind(typeOfX) {
-	case T_STRUCT:
-		// strct.field => e.X . e.Sel
-		structType = typeOfX
-	case T_POINTER:
-		// ptr.field => e.X . e.Sel
-		assert(typeOfX.e.dtype == "*astStarExpr", "should be astStarExpr", __func__)
-		var ptrType = typeOfX.e.starExpr
-		structType = e2t(ptrType.X)
-	default:
-		panic2(__func__, "TBI")
+func natGcd(a []int, b []int) []int {
+	var rem []int
+	for len(b) > 0 {
+		_, rem = natDivMod(a, b)
+		a = b
+		b = rem
 	}
-	return structType
+	return a
 }
 
-func getElementTypeOfListType(t *Type) *Type {
-	switch kind(t) {
-	case T_SLICE, T_ARRAY:
-		var arrayType = t.e.arrayType
-		if arrayType == nil {
-			panic2(__func__, "should not be nil")
+func natFromDecimal(lit string) []int {
+	var r []int
+	var i int
+	for i = 0; i < len(lit); i++ {
+		if lit[i] != '_' {
+			r = natMulAdd(r, 10, int(lit[i]-'0'))
 		}
-		return e2t(arrayType.Elt)
-	case T_STRING:
-		return tUint8
-	default:
-		panic2(__func__, "TBI kind="+kind(t))
 	}
-	var r *Type
 	return r
 }
 
-func getSizeOfType(t *Type) int {
-	var knd = kind(t)
-	switch kind(t) {
-	case T_SLICE:
-		return sliceSize
-	case T_STRING:
-		return 16
-	case T_ARRAY:
-		var elemSize = getSizeOfType(e2t(t.e.arrayType.Elt))
-		return elemSize * evalInt(t.e.arrayType.Len)
-	case T_INT, T_UINTPTR, T_POINTER:
-		return 8
-	case T_UINT8:
-		return 1
-	case T_BOOL:
-		return 8
-	case T_STRUCT:
-		return calcStructSizeAndSetFieldOffset(getStructTypeSpec(t))
-	default:
-		panic2(__func__, "TBI:"+knd)
+func natToDecimal(a []int) string {
+	if len(a) == 0 {
+		return "0"
 	}
-	return 0
-}
-
-func getPushSizeOfType(t *Type) int {
-	switch kind(t) {
-	case T_SLICE:
-		return sliceSize
-	case T_STRING:
-		return stringSize
-	case T_UINT8, T_UINT16, T_INT, T_BOOL:
-		return intSize
-	case T_UINTPTR, T_POINTER:
-		return ptrSize
-	case T_ARRAY, T_STRUCT:
-		return ptrSize
-	default:
-		throw(kind(t))
+	var chunk = natFromInt(1000000000)
+	var s string
+	for len(a) > 0 {
+		var rem []int
+		a, rem = natDivMod(a, chunk)
+		var n int
+		if len(rem) > 0 {
+			n = rem[0]
+		}
+		var digits = Itoa(n)
+		for len(a) > 0 && len(digits) < 9 {
+			digits = "0" + digits
+		}
+		s = digits + s
 	}
-	throw(kind(t))
-	return 0
+	return s
 }
 
-func getStructFieldOffset(field *astField) int {
-	var offset = field.Offset
-	return offset
+// --- constants ---
+
+// A value of a constant expression
+type constValue struct {
+	kind string // "INT", "FLOAT", "STRING" or "BOOL"
+	ival int
+	num  *bigInt // exact value of an integer, or of a float as num / den
+	den  *bigInt
+	sval string // string literal including the quotes, or float literal if any
+	bval bool
+	typ  *astExpr // nil if untyped
 }
 
-func setStructFieldOffset(field *astField, offset int) {
-	field.Offset = offset
+func newIntConst(ival int, typ *astExpr) *constValue {
+	var v = &constValue{}
+	v.kind = "INT"
+	v.ival = ival
+	v.num = bigFromInt(ival)
+	v.typ = typ
+	return v
 }
 
-func getStructFields(structTypeSpec *astTypeSpec) []*astField {
-	if structTypeSpec.Type.dtype != "*astStructType" {
-		panic2(__func__, "Unexpected dtype")
+// An integer constant of any size, which must be representable by its type if any
+func newBigIntConst(x *bigInt, typ *astExpr) *constValue {
+	if natBitLen(x.mag) > 512 {
+		panic2(__func__, "constant overflow")
 	}
-	var structType = structTypeSpec.Type.structType
-	return structType.Fields.List
+	if typ != nil {
+		checkConstRepresentable(x, formatBigInt(x), e2t(typ))
+	}
+	var v = newIntConst(bigToInt(x), typ)
+	v.num = x
+	return v
 }
 
-func getStructTypeSpec(namedStructType *Type) *astTypeSpec {
-	if kind(namedStructType) != T_STRUCT {
-		panic2(__func__, "not T_STRUCT")
+// An integer constant must fit in its type like gc's "constant 300 overflows byte"
+func checkConstRepresentable(x *bigInt, lit string, t *Type) {
+	if !isIntegerKind(kind(t)) {
+		return
 	}
-	if namedStructType.e.dtype != "*astIdent" {
-		panic2(__func__, "astIdent expected got " + namedStructType.e.dtype)
+	if !bigFitsBits(x, getSizeOfType(t)*8, !isUnsignedKind(kind(t))) {
+		panic2(__func__, "constant "+lit+" overflows "+typeString(t))
 	}
+}
 
-	var ident = namedStructType.e.ident
+func newBoolConst(bval bool) *constValue {
+	var v = &constValue{}
+	v.kind = "BOOL"
+	v.bval = bval
+	return v
+}
 
-	if ident.Obj.Decl.dtype != "*astTypeSpec" {
-		panic2(__func__, "not *astTypeSpec")
-	}
+func newStringConst(sval string, typ *astExpr) *constValue {
+	var v = &constValue{}
+	v.kind = "STRING"
+	v.sval = sval
+	v.typ = typ
+	return v
+}
 
-	var typeSpec = ident.Obj.Decl.typeSpec
-	return typeSpec
+// lit is the literal of the value, or "" if it is computed
+func newFloatConst(num *bigInt, den *bigInt, lit string, typ *astExpr) *constValue {
+	var v = &constValue{}
+	v.kind = "FLOAT"
+	v.num, v.den = newRat(num, den)
+	v.sval = lit
+	v.typ = typ
+	return v
 }
 
-func lookupStructField(structTypeSpec *astTypeSpec, selName string) *astField {
-	var field *astField
-	for _, field = range getStructFields(structTypeSpec) {
-		if field.Name.Name == selName {
-			return field
+// Evaluate a constant expression. It returns nil if e is not a constant.
+func evalConst(e *astExpr, iota int) *constValue {
+	var r *constValue
+	switch e.dtype {
+	case "*astBasicLit":
+		switch e.basicLit.Kind {
+		case "INT":
+			return newBigIntConst(parseIntLiteral(e.basicLit.Value), nil)
+		case "FLOAT":
+			var num *bigInt
+			var den *bigInt
+			num, den = parseFloatLiteral(e.basicLit.Value)
+			return newFloatConst(num, den, e.basicLit.Value, nil)
+		case "CHAR":
+			return newIntConst(getCharValue(e.basicLit), nil)
+		case "STRING":
+			return newStringConst(quote(unquote(e.basicLit.Value)), nil)
+		}
+	case "*astIdent":
+		var obj = e.ident.Obj
+		if obj == nil || obj.Kind != astCon {
+			return r
 		}
+		switch obj {
+		case gTrue:
+			return newBoolConst(true)
+		case gFalse:
+			return newBoolConst(false)
+		case gIota:
+			return newIntConst(iota, nil)
+		case gNil:
+			return r
+		}
+		var spec = obj.Decl.valueSpec
+		r = evalConst(spec.Value, spec.iota)
+		if r != nil && spec.Type != nil {
+			r.typ = spec.Type
+		}
+		return r
+	case "*astParenExpr":
+		return evalConst(e.parenExpr.X, iota)
+	case "*astCallExpr":
+		var fun = e.callExpr.Fun
+		if isType(fun) {
+			// conversion like T(iota)
+			r = evalConst(e.callExpr.Args[0], iota)
+			if r == nil {
+				return r
+			}
+			if r.kind == "FLOAT" && !isFloatKind(kind(e2t(fun))) {
+				floatConstToInt(r)
+				return newBigIntConst(r.num, fun)
+			}
+			if r.kind == "INT" && kind(e2t(fun)) == T_STRING {
+				// string(r)
+				var buf []uint8
+				return newStringConst(quote(string(appendRune(buf, r.ival))), fun)
+			}
+			if r.kind == "INT" {
+				return newBigIntConst(r.num, fun)
+			}
+			r.typ = fun
+			return r
+		}
+		if fun.dtype == "*astIdent" && fun.ident.Obj == gLen {
+			return evalConstLen(e.callExpr.Args[0])
+		}
+	case "*astUnaryExpr":
+		return evalConstUnary(e.unaryExpr, iota)
+	case "*astBinaryExpr":
+		return evalConstBinary(e.binaryExpr, iota)
 	}
-	panic("Unexpected flow: struct field not found:" + selName)
-	return field
+	return r
 }
 
-func calcStructSizeAndSetFieldOffset(structTypeSpec *astTypeSpec) int {
-	var offset int = 0
-
-	var fields = getStructFields(structTypeSpec)
-	var field *astField
-	for _, field = range fields {
-		setStructFieldOffset(field, offset)
-		var size = getSizeOfType(e2t(field.Type))
-		offset = offset + size
+// len of a constant string or an array
+func evalConstLen(arg *astExpr) *constValue {
+	var r *constValue
+	var v = evalConst(arg, 0)
+	if v != nil && v.kind == "STRING" {
+		return newIntConst(getStringLiteralLength(v.sval), nil)
 	}
-	return offset
-}
-
-// --- walk ---
-type sliteral struct {
-	label  string
-	strlen int
-	value  string // raw value/pre/precompiler.go:2150
-}
-
-type stringLiteralsContainer struct {
-	lit *astBasicLit
-	sl  *sliteral
+	if v != nil {
+		return r
+	}
+	if !isType(arg) && arg.dtype != "*astCallExpr" {
+		var t = getTypeOfExpr(arg)
+		if kind(t) == T_ARRAY {
+			return newIntConst(evalInt(getUnderlyingType(t).e.arrayType.Len), nil)
+		}
+	}
+	return r
 }
 
-type Func struct {
-	localvars   []*string
-	localarea   int
-	argsarea    int
-	funcType    *astFuncType
-	rcvType     *astExpr
-	name        string
-	Body        *astBlockStmt
-	method *Method
+func evalConstUnary(e *astUnaryExpr, iota int) *constValue {
+	var r *constValue
+	var x = evalConst(e.X, iota)
+	if x == nil {
+		return r
+	}
+	switch e.Op {
+	case "+":
+		return x
+	case "-":
+		if x.kind == "INT" {
+			return newBigIntConst(bigNeg(x.num), x.typ)
+		}
+		if x.kind == "FLOAT" {
+			var lit string
+			if len(x.sval) > 0 && x.sval[0] == '-' {
+				lit = x.sval[1:len(x.sval)]
+			} else if len(x.sval) > 0 {
+				lit = "-" + x.sval
+			}
+			return newFloatConst(bigNeg(x.num), x.den, lit, x.typ)
+		}
+	case "!":
+		if x.kind == "BOOL" {
+			return newBoolConst(!x.bval)
+		}
+	case "^":
+		if x.kind == "INT" && x.typ != nil && isUnsignedKind(kind(e2t(x.typ))) {
+			// ^uint8(0) is 255
+			var max = bigSub(bigShl(bigFromInt(1), getSizeOfType(e2t(x.typ))*8), bigFromInt(1))
+			return newBigIntConst(bigSub(max, x.num), x.typ)
+		}
+		if x.kind == "INT" {
+			return newBigIntConst(bigSub(bigNeg(x.num), bigFromInt(1)), x.typ)
+		}
+	}
+	return r
 }
 
-type Method struct {
-	rcvNamedType *astIdent
-	isPtrMethod  bool
-	name string
-	funcType *astFuncType
+func evalConstBinary(e *astBinaryExpr, iota int) *constValue {
+	var r *constValue
+	var x = evalConst(e.X, iota)
+	if x == nil {
+		return r
+	}
+	var y = evalConst(e.Y, iota)
+	if y == nil {
+		return r
+	}
+	// the result is typed if either operand is typed
+	var typ = x.typ
+	if typ == nil {
+		typ = y.typ
+	}
+	if x.kind == "FLOAT" || y.kind == "FLOAT" || (typ != nil && isFloatKind(kind(e2t(typ)))) {
+		return evalConstFloatBinary(e.Op, x, y, typ)
+	}
+	if x.kind != y.kind {
+		return r
+	}
+	switch x.kind {
+	case "INT":
+		switch e.Op {
+		case "+":
+			return newBigIntConst(bigAdd(x.num, y.num), typ)
+		case "-":
+			return newBigIntConst(bigSub(x.num, y.num), typ)
+		case "*":
+			return newBigIntConst(bigMul(x.num, y.num), typ)
+		case "/", "%":
+			if bigSign(y.num) == 0 {
+				panic2(__func__, "division by zero")
+			}
+			var q *bigInt
+			var rem *bigInt
+			q, rem = bigQuoRem(x.num, y.num)
+			if e.Op == "/" {
+				return newBigIntConst(q, typ)
+			}
+			return newBigIntConst(rem, typ)
+		case "&", "|", "^", "&^":
+			return newBigIntConst(bigBitwise(e.Op, x.num, y.num), typ)
+		case "<<", ">>":
+			if bigSign(y.num) < 0 {
+				panic2(__func__, "invalid negative shift count")
+			}
+			var count = y.ival
+			if natBitLen(y.num.mag) > 16 {
+				// too large for any nonzero result of <<
+				count = 1 << 16
+			}
+			// the type of a shift is that of the left operand
+			if e.Op == "<<" {
+				return newBigIntConst(bigShl(x.num, count), x.typ)
+			}
+			return newBigIntConst(bigShr(x.num, count), x.typ)
+		}
+		var cmp = bigCmp(x.num, y.num)
+		switch e.Op {
+		case "==":
+			return newBoolConst(cmp == 0)
+		case "!=":
+			return newBoolConst(cmp != 0)
+		case "<":
+			return newBoolConst(cmp < 0)
+		case "<=":
+			return newBoolConst(cmp <= 0)
+		case ">":
+			return newBoolConst(cmp > 0)
+		case ">=":
+			return newBoolConst(cmp >= 0)
+		}
+	case "STRING":
+		switch e.Op {
+		case "+":
+			return newStringConst(x.sval[0:len(x.sval)-1]+y.sval[1:len(y.sval)], typ)
+		case "==":
+			return newBoolConst(unquote(x.sval) == unquote(y.sval))
+		case "!=":
+			return newBoolConst(unquote(x.sval) != unquote(y.sval))
+		}
+	case "BOOL":
+		switch e.Op {
+		case "&&":
+			return newBoolConst(x.bval && y.bval)
+		case "||":
+			return newBoolConst(x.bval || y.bval)
+		case "==":
+			return newBoolConst(x.bval && y.bval || !x.bval && !y.bval)
+		case "!=":
+			return newBoolConst(x.bval && !y.bval || !x.bval && y.bval)
+		}
+	}
+	return r
 }
 
-type Variable struct {
-	name         string
-	isGlobal     bool
-	globalSymbol string
-	localOffset  int
+// The denominator of an integer or float constant
+func getFloatConstDen(v *constValue) *bigInt {
+	if v.kind == "INT" {
+		return bigFromInt(1)
+	}
+	return v.den
 }
 
-//type localoffsetint int //@TODO
-
-var stringLiterals []*stringLiteralsContainer
-var stringIndex int
-var localoffset int
-var currentFuncDecl *astFuncDecl
-
-func getStringLiteral(lit *astBasicLit) *sliteral {
-	var container *stringLiteralsContainer
-	for _, container = range stringLiterals {
-		if container.lit == lit {
-			return container.sl
+func evalConstFloatBinary(op string, x *constValue, y *constValue, typ *astExpr) *constValue {
+	var r *constValue
+	if (x.kind != "INT" && x.kind != "FLOAT") || (y.kind != "INT" && y.kind != "FLOAT") {
+		return r
+	}
+	// compare and compute a/b and c/d exactly
+	var a = x.num
+	var b = getFloatConstDen(x)
+	var c = y.num
+	var d = getFloatConstDen(y)
+	switch op {
+	case "+":
+		return newFloatConst(bigAdd(bigMul(a, d), bigMul(c, b)), bigMul(b, d), "", typ)
+	case "-":
+		return newFloatConst(bigSub(bigMul(a, d), bigMul(c, b)), bigMul(b, d), "", typ)
+	case "*":
+		return newFloatConst(bigMul(a, c), bigMul(b, d), "", typ)
+	case "/":
+		if bigSign(c) == 0 {
+			panic2(__func__, "division by zero")
 		}
+		return newFloatConst(bigMul(a, d), bigMul(b, c), "", typ)
+	}
+	var cmp = bigCmp(bigMul(a, d), bigMul(c, b))
+	switch op {
+	case "==":
+		return newBoolConst(cmp == 0)
+	case "!=":
+		return newBoolConst(cmp != 0)
+	case "<":
+		return newBoolConst(cmp < 0)
+	case "<=":
+		return newBoolConst(cmp <= 0)
+	case ">":
+		return newBoolConst(cmp > 0)
+	case ">=":
+		return newBoolConst(cmp >= 0)
 	}
-
-	panic2(__func__, "string literal not found:"+lit.Value)
-	var r *sliteral
 	return r
 }
 
-func registerStringLiteral(lit *astBasicLit) {
-	logf(" [registerStringLiteral] begin\n")
-
-	if pkg.name == "" {
-		panic2(__func__, "no pkgName")
+// Exact value of a float literal as num / den.
+// The literal may also be a computed constant like "1/3" (see formatFloatConst).
+func parseFloatLiteral(lit string) (*bigInt, *bigInt) {
+	var neg = lit[0] == '-'
+	if neg {
+		lit = lit[1:len(lit)]
 	}
-
-	var strlen int
-	var c uint8
-	var vl = []uint8(lit.Value)
-	for _, c = range vl {
-		if c != '\\' {
-			strlen++
+	var num []int
+	var den = natFromInt(1)
+	var exp int
+	var i int
+	if isHexLiteral(lit) {
+		num, exp, i = parseMantissa(lit, 2, 16)
+		if i < len(lit) {
+			exp = exp + Atoi(getExponentLiteral(lit[i+1:len(lit)]))
+		}
+	} else {
+		num, exp, i = parseMantissa(lit, 0, 10)
+		if i < len(lit) && lit[i] == '/' {
+			den = natFromDecimal(lit[i+1 : len(lit)])
+		} else if i < len(lit) {
+			exp = exp + Atoi(getExponentLiteral(lit[i+1:len(lit)]))
 		}
 	}
-
-	var label = fmtSprintf(".%s.S%d", []string{pkg.name, Itoa(stringIndex)})
-	stringIndex++
-
-	var sl = &sliteral{}
-	sl.label = label
-	sl.strlen = strlen - 2
-	sl.value = lit.Value
-	logf(" [registerStringLiteral] label=%s, strlen=%s\n", sl.label, Itoa(sl.strlen))
-	var cont = &stringLiteralsContainer{}
-	cont.sl = sl
-	cont.lit = lit
-	stringLiterals = append(stringLiterals, cont)
-}
-
-func newGlobalVariable(name string) *Variable {
-	var vr = &Variable{}
-	vr.name = name
-	vr.isGlobal = true
-	vr.globalSymbol = name
-	return vr
-}
-
-func newLocalVariable(name string, localoffset int) *Variable {
-	var vr = &Variable{}
-	vr.name = name
-	vr.isGlobal = false
-	vr.localOffset = localoffset
-	return vr
-}
-
-
-type methodEntry struct {
-	name string
-	method *Method
-}
-
-type namedTypeEntry struct {
-	name    string
-	methods []*methodEntry
+	var base = natFromInt(10)
+	if isHexLiteral(lit) {
+		base = natFromInt(2)
+	}
+	for exp > 0 {
+		num = natMul(num, base)
+		exp--
+	}
+	for exp < 0 {
+		den = natMul(den, base)
+		exp++
+	}
+	return newRat(newBigInt(neg, num), newBigInt(false, den))
 }
 
-var typesWithMethods []*namedTypeEntry
-
-func findNamedType(typeName string) *namedTypeEntry {
-	var typ *namedTypeEntry
-	for _, typ = range typesWithMethods {
-		if typ.name == typeName {
-			return typ
+// Digits of a literal from lit[start] up to the exponent or a slash.
+// It returns them as an integer, the exponent of the last digit in base 10
+// for a decimal literal or in base 2 for a hexadecimal one, and where it stopped.
+func parseMantissa(lit string, start int, base int) ([]int, int, int) {
+	var mant []int
+	var exp int
+	var afterPoint bool
+	var i int
+	for i = start; i < len(lit); i++ {
+		var c = lit[i]
+		if c == '_' {
+			continue
+		}
+		if c == '.' {
+			afterPoint = true
+			continue
+		}
+		if c == '/' || c == 'p' || c == 'P' || (base == 10 && (c == 'e' || c == 'E')) {
+			break
+		}
+		mant = natMulAdd(mant, base, hexDigit(c))
+		if afterPoint && base == 16 {
+			exp = exp - 4
+		} else if afterPoint {
+			exp--
 		}
 	}
-	typ = nil
-	return typ
+	return mant, exp, i
 }
 
-func newMethod(funcDecl *astFuncDecl) *Method {
-	var rcvType = funcDecl.Recv.List[0].Type
-	var isPtr bool
-	if rcvType.dtype == "*astStarExpr" {
-		isPtr = true
-		rcvType = rcvType.starExpr.X
+// "-5" or "+5" of an exponent like e-5 without the letter
+func getExponentLiteral(lit string) string {
+	if lit[0] == '+' {
+		return lit[1:len(lit)]
 	}
-	assert(rcvType.dtype == "*astIdent", "receiver type should be ident", __func__)
-	var rcvNamedType = rcvType.ident
+	return lit
+}
 
-	var method = &Method{
-		rcvNamedType: rcvNamedType,
-		isPtrMethod : isPtr,
-		name: funcDecl.Name.Name,
-		funcType: funcDecl.Type,
+// Exact value of an integer literal like 0xff, or a computed one like -300
+func parseIntLiteral(lit string) *bigInt {
+	var neg = lit[0] == '-'
+	if neg {
+		lit = lit[1:len(lit)]
 	}
-	return method
+	var mag []int
+	if isHexLiteral(lit) {
+		mag, _, _ = parseMantissa(lit, 2, 16)
+	} else {
+		mag = natFromDecimal(lit)
+	}
+	return newBigInt(neg, mag)
 }
 
-func registerMethod(method *Method) {
-	var nt = findNamedType(method.rcvNamedType.Name)
-	if nt == nil {
-		nt = &namedTypeEntry{
-			name:    method.rcvNamedType.Name,
-			methods: nil,
-		}
-		typesWithMethods = append(typesWithMethods, nt)
+// Literal of a computed integer constant like "-300"
+func formatBigInt(x *bigInt) string {
+	if x.neg {
+		return "-" + natToDecimal(x.mag)
 	}
+	return natToDecimal(x.mag)
+}
 
-	var me *methodEntry = &methodEntry{
-		name: method.name,
-		method: method,
+// Literal of a computed float constant like "-1/3".
+// It is parsed back by parseFloatLiteral, while the scanner never produces it.
+func formatFloatConst(v *constValue) string {
+	var lit = natToDecimal(v.num.mag)
+	if v.num.neg {
+		lit = "-" + lit
 	}
-	nt.methods = append(nt.methods, me)
+	return lit + "/" + natToDecimal(v.den.mag)
 }
 
-func lookupMethod(rcvT *Type, methodName *astIdent) *Method {
-	var rcvType = rcvT.e
-	if rcvType.dtype == "*astStarExpr" {
-		rcvType = rcvType.starExpr.X
+// num / den with a positive den, reduced to lowest terms
+func newRat(num *bigInt, den *bigInt) (*bigInt, *bigInt) {
+	if len(den.mag) == 0 {
+		panic2(__func__, "division by zero")
 	}
-	assert(rcvType.dtype == "*astIdent", "receiver type should be ident", __func__)
-	var rcvTypeName = rcvType.ident
-	var nt = findNamedType(rcvTypeName.Name)
-	if nt == nil {
-		panic(rcvTypeName.Name + " has no moethodeiverTypeName:")
+	if den.neg {
+		num = bigNeg(num)
+		den = bigNeg(den)
 	}
-	var me *methodEntry
+	var g = natGcd(num.mag, den.mag)
+	var numMag []int
+	var denMag []int
+	numMag, _ = natDivMod(num.mag, g)
+	denMag, _ = natDivMod(den.mag, g)
+	return newBigInt(num.neg, numMag), newBigInt(false, denMag)
+}
 
-	for _, me = range nt.methods {
-		if me.name == methodName.Name {
-			return me.method
+
+// A literal or true/false which represents the constant
+func newConstLiteral(v *constValue) *astExpr {
+	var r = &astExpr{}
+	switch v.kind {
+	case "INT":
+		r.dtype = "*astBasicLit"
+		r.basicLit = &astBasicLit{
+			Kind:  "INT",
+			Value: formatBigInt(v.num),
+		}
+	case "FLOAT":
+		var lit = v.sval
+		if lit == "" {
+			lit = formatFloatConst(v)
+		}
+		r.dtype = "*astBasicLit"
+		r.basicLit = &astBasicLit{
+			Kind:  "FLOAT",
+			Value: lit,
+		}
+	case "STRING":
+		r.dtype = "*astBasicLit"
+		r.basicLit = &astBasicLit{
+			Kind:  "STRING",
+			Value: v.sval,
+		}
+	case "BOOL":
+		r.dtype = "*astIdent"
+		if v.bval {
+			r.ident = &astIdent{
+				Name: "true",
+				Obj:  gTrue,
+			}
+		} else {
+			r.ident = &astIdent{
+				Name: "false",
+				Obj:  gFalse,
+			}
 		}
 	}
-
-	panic("method not found: " + methodName.Name)
-	var r *Method
 	return r
 }
 
-func walkStmt(stmt *astStmt) {
-	logf(" [%s] begin dtype=%s\n", __func__, stmt.dtype)
-	switch stmt.dtype {
-	case "*astDeclStmt":
-		logf(" [%s] *ast.DeclStmt\n", __func__)
-		if stmt.DeclStmt == nil {
-			panic2(__func__, "nil pointer exception\n")
-		}
-		var declStmt = stmt.DeclStmt
-		if declStmt.Decl == nil {
-			panic2(__func__, "ERROR\n")
-		}
-		var dcl = declStmt.Decl
-		if dcl.dtype != "*astGenDecl" {
-			panic2(__func__, "[dcl.dtype] internal error")
-		}
-		var valSpec = dcl.genDecl.Spec.valueSpec
-		if valSpec.Type == nil {
-			if valSpec.Value == nil {
-				panic2(__func__, "type inference requires a value")
-			}
-			var _typ = getTypeOfExpr(valSpec.Value)
-			if _typ != nil && _typ.e != nil {
-				valSpec.Type = _typ.e
-			} else {
-				panic2(__func__, "type inference failed")
-			}
-		}
-		var typ = valSpec.Type // Type can be nil
-		logf(" [walkStmt] valSpec Name=%s, Type=%s\n",
-			valSpec.Name.Name, typ.dtype)
+func isUntypedConst(e *astExpr) bool {
+	var v = evalConst(e, 0)
+	return v != nil && v.typ == nil
+}
 
-		var t = e2t(typ)
-		var sizeOfType = getSizeOfType(t)
-		localoffset = localoffset - sizeOfType
+func isConstLiteral(e *astExpr) bool {
+	if e.dtype == "*astBasicLit" {
+		return true
+	}
+	return e.dtype == "*astIdent" && (e.ident.Obj == gTrue || e.ident.Obj == gFalse)
+}
 
-		valSpec.Name.Obj.Variable = newLocalVariable(valSpec.Name.Name, localoffset)
-		logf(" var %s offset = %d\n", valSpec.Name.Obj.Name,
-			Itoa(valSpec.Name.Obj.Variable.localOffset))
-		if valSpec.Value != nil {
-			walkExpr(valSpec.Value)
-		}
-	case "*astAssignStmt":
-		var lhs = stmt.assignStmt.Lhs[0]
-		var rhs = stmt.assignStmt.Rhs[0]
-		if stmt.assignStmt.Tok == ":=" {
-			assert(lhs.dtype == "*astIdent", "should be ident", __func__)
-			var obj = lhs.ident.Obj
-			assert(obj.Kind == astVar, "should be ast.Var", __func__)
-			walkExpr(rhs)
-			// infer type
-			var typ = getTypeOfExpr(rhs)
-			if typ != nil && typ.e != nil {
-			} else {
-				panic("type inference is not supported: " + obj.Name)
-			}
-			localoffset = localoffset - getSizeOfType(typ)
-			obj.Variable = newLocalVariable(obj.Name, localoffset)
-		} else {
-			walkExpr(rhs)
-		}
-	case "*astExprStmt":
-		walkExpr(stmt.exprStmt.X)
-	case "*astReturnStmt":
-		var rt *astExpr
-		for _, rt = range stmt.returnStmt.Results {
-			walkExpr(rt)
-		}
-	case "*astIfStmt":
-		if stmt.ifStmt.Init != nil {
-			walkStmt(stmt.ifStmt.Init)
-		}
-		walkExpr(stmt.ifStmt.Cond)
-		var s *astStmt
-		for _, s = range stmt.ifStmt.Body.List {
-			walkStmt(s)
-		}
-		if stmt.ifStmt.Else != nil {
-			walkStmt(stmt.ifStmt.Else)
-		}
-	case "*astForStmt":
-		stmt.forStmt.Outer = currentFor
-		currentFor = stmt
-		if stmt.forStmt.Init != nil {
-			walkStmt(stmt.forStmt.Init)
-		}
-		if stmt.forStmt.Cond != nil {
-			walkExpr(stmt.forStmt.Cond)
-		}
-		if stmt.forStmt.Post != nil {
-			walkStmt(stmt.forStmt.Post)
-		}
-		var _s = &astStmt{}
-		_s.dtype = "*astBlockStmt"
-		_s.blockStmt = stmt.forStmt.Body
-		walkStmt(_s)
-		currentFor = stmt.forStmt.Outer
-	case "*astRangeStmt":
-		walkExpr(stmt.rangeStmt.X)
-		stmt.rangeStmt.Outer = currentFor
-		currentFor = stmt
-		var _s = blockStmt2Stmt(stmt.rangeStmt.Body)
-		walkStmt(_s)
-		localoffset = localoffset - intSize
-		var lenvar = newLocalVariable(".range.len", localoffset)
-		localoffset = localoffset - intSize
-		var indexvar = newLocalVariable(".range.index", localoffset)
-		stmt.rangeStmt.lenvar = lenvar
-		stmt.rangeStmt.indexvar = indexvar
-		currentFor = stmt.rangeStmt.Outer
-	case "*astIncDecStmt":
-		walkExpr(stmt.incDecStmt.X)
-	case "*astBlockStmt":
-		var s *astStmt
-		for _, s = range stmt.blockStmt.List {
-			walkStmt(s)
-		}
-	case "*astBranchStmt":
-		stmt.branchStmt.currentFor = currentFor
-	case "*astSwitchStmt":
-		if stmt.switchStmt.Tag != nil {
-			walkExpr(stmt.switchStmt.Tag)
-		}
-		walkStmt(blockStmt2Stmt(stmt.switchStmt.Body))
-	case "*astCaseClause":
-		var e_ *astExpr
-		var s_ *astStmt
-		for _, e_ = range stmt.caseClause.List {
-			walkExpr(e_)
-		}
-		for _, s_ = range stmt.caseClause.Body {
-			walkStmt(s_)
+// Replace the value of a constant by a literal, evaluating iota and other constants.
+// An untyped constant gets the type of a conversion or a typed constant in the value.
+func foldConstValue(spec *astValueSpec) {
+	var v = evalConst(spec.Value, spec.iota)
+	if v == nil {
+		panic2(__func__, "not a constant: "+spec.Name.Name)
+	}
+	if spec.Type != nil && v.kind == "INT" {
+		checkConstRepresentable(v.num, formatBigInt(v.num), e2t(spec.Type))
+	}
+	if isConstLiteral(spec.Value) {
+		return
+	}
+	if spec.Type == nil && v.typ != nil {
+		spec.Type = v.typ
+	}
+	spec.Value = newConstLiteral(v)
+}
+
+// Settle the length of [...]T and the types of elided elements like {1, 2} of []Point{{1, 2}}
+func completeCompositeLit(lit *astCompositeLit) {
+	var t = getUnderlyingType(e2t(lit.Type))
+	var elmType *Type
+	var keyType *Type
+	switch kind(t) {
+	case T_ARRAY, T_SLICE:
+		if t.e.arrayType.Len != nil && t.e.arrayType.Len.dtype == "*astEllipsis" {
+			t.e.arrayType.Len = &astExpr{
+				dtype:    "*astBasicLit",
+				basicLit: newNumberLiteral(getListLiteralLength(lit.Elts)),
+			}
 		}
+		elmType = e2t(t.e.arrayType.Elt)
+	case T_MAP:
+		keyType = e2t(t.e.mapType.Key)
+		elmType = e2t(t.e.mapType.Value)
 	default:
-		panic2(__func__, "TBI: stmt.dtype="+stmt.dtype)
+		return
+	}
+	var elm *astExpr
+	for _, elm = range lit.Elts {
+		if elm.dtype == "*astKeyValueExpr" {
+			if keyType != nil {
+				setElidedType(elm.keyValueExpr.Key, keyType)
+			}
+			setElidedType(elm.keyValueExpr.Value, elmType)
+		} else {
+			setElidedType(elm, elmType)
+		}
 	}
 }
 
-var currentFor *astStmt
+// {...} of type T is T{...}, and {...} of type *T is &T{...}
+func setElidedType(e *astExpr, t *Type) {
+	if e.dtype != "*astCompositeLit" || e.compositeLit.Type != nil {
+		return
+	}
+	if kind(t) != T_POINTER {
+		e.compositeLit.Type = t.e
+		return
+	}
+	var lit = &astExpr{
+		dtype:        "*astCompositeLit",
+		compositeLit: e.compositeLit,
+	}
+	lit.compositeLit.Type = getUnderlyingType(t).e.starExpr.X
+	e.dtype = "*astUnaryExpr"
+	e.compositeLit = nil
+	e.unaryExpr = &astUnaryExpr{
+		Op: "&",
+		X:  lit,
+	}
+}
 
 func walkExpr(expr *astExpr) {
 	logf(" [walkExpr] dtype=%s\n", expr.dtype)
+	if expr.dtype == "*astBinaryExpr" || expr.dtype == "*astUnaryExpr" {
+		// evaluate untyped constant expressions like 1 << 10 at compile time
+		var v = evalConst(expr, 0)
+		if v != nil && v.typ == nil {
+			var lit = newConstLiteral(v)
+			expr.dtype = lit.dtype
+			expr.basicLit = lit.basicLit
+			expr.ident = lit.ident
+		}
+	}
 	switch expr.dtype {
 	case "*astIdent":
-		// what to do ?
+		var obj = expr.ident.Obj
+		if obj != nil && obj.Kind == astVar && obj.Variable != nil && !obj.Variable.isGlobal && obj.Variable.fnc != currentWalkFunc {
+			captureVariable(obj.Variable)
+		}
+		if obj != nil && currentRefs != nil {
+			addDeclRef(obj)
+		}
+	case "*astFuncLit":
+		walkFuncLit(expr.funcLit)
+	case "*astFuncType":
+		// do nothing
 	case "*astCallExpr":
 		var arg *astExpr
 		walkExpr(expr.callExpr.Fun)
@@ -4649,12 +11105,14 @@ func walkExpr(expr *astExpr) {
 			}
 			walkExpr(arg)
 		}
+		walkTupleArgs(expr.callExpr)
 	case "*astBasicLit":
 		switch expr.basicLit.Kind {
 		case "STRING":
 			registerStringLiteral(expr.basicLit)
 		}
 	case "*astCompositeLit":
+		completeCompositeLit(expr.compositeLit)
 		var v *astExpr
 		for _, v = range expr.compositeLit.Elts {
 			walkExpr(v)
@@ -4682,8 +11140,21 @@ func walkExpr(expr *astExpr) {
 		walkExpr(expr.starExpr.X)
 	case "*astSelectorExpr":
 		walkExpr(expr.selectorExpr.X)
+		resolvePromotedSelector(expr.selectorExpr)
+		setStructOperandTemp(expr.selectorExpr)
+		if currentRefs != nil {
+			addMethodRef(expr.selectorExpr)
+		}
 	case "*astArrayType": // []T(e)
 		// do nothing ?
+	case "*astMapType": // make(map[K]V)
+		// do nothing
+	case "*astChanType": // make(chan T)
+		// do nothing
+	case "*astInterfaceType": // interface{}(e)
+		// do nothing
+	case "*astTypeAssertExpr":
+		walkExpr(expr.typeAssertExpr.X)
 	case "*astParenExpr":
 		walkExpr(expr.parenExpr.X)
 	case "*astKeyValueExpr":
@@ -4694,11 +11165,274 @@ func walkExpr(expr *astExpr) {
 	}
 }
 
+// Whether e is a struct value that has no address of its own, like x.(T) or m[k]
+func isNonAddressableStruct(e *astExpr) bool {
+	switch e.dtype {
+	case "*astParenExpr":
+		return isNonAddressableStruct(e.parenExpr.X)
+	case "*astTypeAssertExpr":
+		return kind(getTypeOfExpr(e)) == T_STRUCT
+	case "*astIndexExpr":
+		return kind(getTypeOfExpr(e.indexExpr.X)) == T_MAP && kind(getTypeOfExpr(e)) == T_STRUCT
+	}
+	return false
+}
+
+// x.(T).f or m[k].f selects the field of a copy of the struct.
+// The selectors of embedded fields are added by resolvePromotedSelector.
+func setStructOperandTemp(sel *astSelectorExpr) {
+	for sel.tmp == nil {
+		if isNonAddressableStruct(sel.X) {
+			sel.tmp = newTempVariable(".struct.tmp", getTypeOfExpr(sel.X))
+			return
+		}
+		if sel.X.dtype != "*astSelectorExpr" {
+			return
+		}
+		sel = sel.X.selectorExpr
+	}
+}
+
+var currentWalkFunc *Func
+var funcLitCount int // in the current top level function
+
+// Params are located above the return address and the saved %rbp
+func declareParams(fnc *Func, fields []*astField) {
+	var paramoffset = 16
+	var field *astField
+	for _, field = range fields {
+		if field.Name != nil {
+			var obj = field.Name.Obj
+			obj.Variable = newLocalVariable(obj.Name, paramoffset)
+			logf(" field.Name.Obj.Name=%s\n", obj.Name)
+		}
+		var varSize = getArgSizeOfType(e2t(field.Type))
+		paramoffset = paramoffset + varSize
+	}
+	fnc.params = fields
+	fnc.argsarea = paramoffset
+}
+
+// Named results are local variables, which are copied out at return
+func declareNamedResults(fields []*astField) {
+	var field *astField
+	for _, field = range fields {
+		if field.Name != nil {
+			var obj = field.Name.Obj
+			localoffset = localoffset - getSizeOfType(e2t(field.Type))
+			obj.Variable = newLocalVariable(obj.Name, localoffset)
+		}
+	}
+}
+
+// A function literal is compiled as a function of its own,
+// which gets its closure in %rdx when called.
+func walkFuncLit(lit *astFuncLit) {
+	var outer = currentWalkFunc
+	outer.localarea = localoffset
+	funcLitCount++
+	var fnc = &Func{}
+	fnc.name = outer.name + ".func" + Itoa(funcLitCount)
+	fnc.funcType = lit.Type
+	fnc.Body = lit.Body
+	fnc.outer = outer
+	currentWalkFunc = fnc
+	localoffset = 0
+	declareParams(fnc, lit.Type.Params.List)
+	localoffset = localoffset - ptrSize
+	fnc.closureOffset = localoffset
+	declareNamedResults(getFuncResults(lit.Type))
+	checkLabels(lit.Body)
+	var outerFor = currentFor
+	var outerBreakable = currentBreakable
+	var outerLabels = currentLabels
+	currentFor = nil
+	currentBreakable = nil
+	currentLabels = nil
+	var stmt *astStmt
+	for _, stmt = range lit.Body.List {
+		walkStmt(stmt)
+	}
+	currentFor = outerFor
+	currentBreakable = outerBreakable
+	currentLabels = outerLabels
+	fnc.localarea = localoffset
+	lit.fnc = fnc
+	pkg.funcs = append(pkg.funcs, fnc)
+	currentWalkFunc = outer
+	localoffset = outer.localarea
+}
+
+// A local variable of an outer function is referred to in the current function.
+// It is moved to the heap, and the closures of every function in between get a pointer to it.
+func captureVariable(v *Variable) {
+	var owner = v.fnc
+	if !v.isBoxed {
+		// The owner is being suspended in walkFuncLit, so its frame can still grow.
+		v.isBoxed = true
+		owner.localarea = owner.localarea - ptrSize
+		v.boxOffset = owner.localarea
+	}
+	var fnc *Func
+	for fnc = currentWalkFunc; fnc != owner; fnc = fnc.outer {
+		if getFreeVarIndex(fnc, v) < 0 {
+			fnc.freeVars = append(fnc.freeVars, v)
+		}
+	}
+}
+
+func getFreeVarIndex(fnc *Func, v *Variable) int {
+	var i int
+	var fv *Variable
+	for i, fv = range fnc.freeVars {
+		if fv == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// Package-level variables and functions referred to by a function body or a variable initializer
+type declRefs struct {
+	vars  []*astValueSpec
+	funcs []*astFuncDecl
+}
+
+var currentRefs *declRefs
+
+func addDeclRef(obj *astObject) {
+	switch obj.Kind {
+	case astVar:
+		if obj.Variable != nil && obj.Variable.isGlobal && obj.Decl != nil && obj.Decl.dtype == "*astValueSpec" {
+			currentRefs.vars = append(currentRefs.vars, obj.Decl.valueSpec)
+		}
+	case astFun:
+		if obj.Decl != nil && obj.Decl.dtype == "*astFuncDecl" {
+			currentRefs.funcs = append(currentRefs.funcs, obj.Decl.funcDecl)
+		}
+	}
+}
+
+// A method of a concrete type is referred to by x.m
+func addMethodRef(e *astSelectorExpr) {
+	var method = lookupSelectedMethod(e)
+	if method != nil && method.funcDecl != nil {
+		currentRefs.funcs = append(currentRefs.funcs, method.funcDecl)
+	}
+}
+
+// Collect the variables that refs depends on, directly or through functions
+func collectVarDeps(deps *declRefs, refs *declRefs) {
+	var v *astValueSpec
+	for _, v = range refs.vars {
+		deps.vars = append(deps.vars, v)
+	}
+	var fd *astFuncDecl
+	var seen *astFuncDecl
+	for _, fd = range refs.funcs {
+		var found bool
+		for _, seen = range deps.funcs {
+			if seen == fd {
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		deps.funcs = append(deps.funcs, fd)
+		if fd.refs != nil {
+			collectVarDeps(deps, fd.refs)
+		}
+	}
+}
+
+func isValueSpecIn(spec *astValueSpec, list []*astValueSpec) bool {
+	var s *astValueSpec
+	for _, s = range list {
+		if s == spec {
+			return true
+		}
+	}
+	return false
+}
+
+// Package-level variables are initialized in declaration order,
+// but after the variables they depend on.
+func sortInitVars(specs []*astValueSpec) []*astValueSpec {
+	var sorted []*astValueSpec
+	var spec *astValueSpec
+	for len(sorted) < len(specs) {
+		var next *astValueSpec
+		var pending *astValueSpec
+		for _, spec = range specs {
+			if isValueSpecIn(spec, sorted) {
+				continue
+			}
+			if pending == nil {
+				pending = spec
+			}
+			var deps = &declRefs{}
+			collectVarDeps(deps, spec.refs)
+			var ready = true
+			var dep *astValueSpec
+			for _, dep = range deps.vars {
+				if isValueSpecIn(dep, specs) && !isValueSpecIn(dep, sorted) {
+					ready = false
+				}
+			}
+			if ready {
+				next = spec
+				break
+			}
+		}
+		if next == nil {
+			panic2(__func__, "initialization cycle: "+pending.Name.Name)
+		}
+		sorted = append(sorted, next)
+	}
+	return sorted
+}
+
+// Whether the initial value of a global variable can be put in the data section
+func isStaticGlobalValue(t *Type, val *astExpr) bool {
+	if val == nil {
+		return true
+	}
+	switch kind(t) {
+	case T_STRING:
+		return val.dtype == "*astBasicLit" && val.basicLit.Kind == "STRING"
+	case T_BOOL:
+		return val.dtype == "*astIdent" && (val.ident.Obj == gTrue || val.ident.Obj == gFalse)
+	case T_INT, T_INT8, T_INT16, T_INT32, T_INT64, T_UINT, T_UINT8, T_UINT16, T_UINT32, T_UINT64, T_UINTPTR, T_FLOAT32, T_FLOAT64:
+		return val.dtype == "*astBasicLit"
+	}
+	return false
+}
+
+// Constants and the types of global variables are settled before functions are walked,
+// since they can be used before declared.
+func walkGlobalValueSpec(valSpec *astValueSpec) {
+	if valSpec.Name.Obj.Kind == astCon {
+		foldConstValue(valSpec)
+		return
+	}
+	if valSpec.Value == nil || valSpec.tuple != nil {
+		return
+	}
+	if valSpec.Type == nil {
+		valSpec.Type = getTypeOfExpr(valSpec.Value).e
+	}
+	var v = evalConst(valSpec.Value, 0)
+	if v != nil {
+		valSpec.Value = newConstLiteral(v)
+	}
+}
+
 func walk(pkgContainer *PkgContainer, file *astFile) {
 	var decl *astDecl
+	// methods are registered before global values, which can call them like T{}.m()
 	for _, decl = range file.Decls {
-		switch decl.dtype {
-		case "*astFuncDecl":
+		if decl.dtype == "*astFuncDecl" {
 			var funcDecl = decl.funcDecl
 			if funcDecl.Body != nil {
 				if funcDecl.Recv != nil { // is Method
@@ -4711,76 +11445,177 @@ func walk(pkgContainer *PkgContainer, file *astFile) {
 	for _, decl = range file.Decls {
 		switch decl.dtype {
 		case "*astGenDecl":
-			var genDecl = decl.genDecl
-			switch genDecl.Spec.dtype {
-			case "*astValueSpec":
-				var valSpec = genDecl.Spec.valueSpec
-				var nameIdent = valSpec.Name
-				if nameIdent.Obj.Kind == astVar {
-					nameIdent.Obj.Variable = newGlobalVariable(nameIdent.Obj.Name)
-					pkgContainer.vars = append(pkgContainer.vars, valSpec)
-				}
-				if valSpec.Value != nil {
-					walkExpr(valSpec.Value)
+			var spec *astSpec
+			for _, spec = range decl.genDecl.Specs {
+				if spec.dtype == "*astValueSpec" {
+					walkGlobalValueSpec(spec.valueSpec)
+					var nameIdent = spec.valueSpec.Name
+					if spec.valueSpec.tuple != nil {
+						var lhs *astExpr
+						for _, lhs = range spec.valueSpec.tuple.Lhs {
+							lhs.ident.Obj.Variable = newGlobalVariable(lhs.ident.Obj.Name)
+						}
+						pkgContainer.vars = append(pkgContainer.vars, spec.valueSpec)
+					} else if nameIdent.Obj.Kind == astVar {
+						nameIdent.Obj.Variable = newGlobalVariable(nameIdent.Obj.Name)
+						pkgContainer.vars = append(pkgContainer.vars, spec.valueSpec)
+					}
 				}
-			case "*astTypeSpec":
-				// do nothing
-				var typeSpec = genDecl.Spec.typeSpec
-				switch kind(e2t(typeSpec.Type)) {
-				case T_STRUCT:
-					calcStructSizeAndSetFieldOffset(typeSpec)
-				default:
+			}
+		}
+	}
+
+	var initFnc = &Func{}
+	initFnc.name = "init"
+	initFnc.funcType = &astFuncType{
+		Params: &astFieldList{},
+	}
+	var initDecl = &astFuncDecl{}
+	initDecl.Name = &astIdent{
+		Name: initFnc.name,
+	}
+	initDecl.Type = initFnc.funcType
+	var initVars []*astValueSpec
+	var initFuncs []*Func
+	for _, decl = range file.Decls {
+		switch decl.dtype {
+		case "*astGenDecl":
+			var spec *astSpec
+			for _, spec = range decl.genDecl.Specs {
+				switch spec.dtype {
+				case "*astValueSpec":
+					var valSpec = spec.valueSpec
+					if valSpec.tuple != nil || valSpec.Name.Obj.Kind == astVar && !isStaticGlobalValue(e2t(valSpec.Type), valSpec.Value) {
+						// evaluated in the package initializer
+						currentFuncDecl = initDecl
+						currentWalkFunc = initFnc
+						localoffset = initFnc.localarea
+						valSpec.refs = &declRefs{}
+						currentRefs = valSpec.refs
+						walkExpr(valSpec.Value)
+						initFnc.localarea = localoffset
+						currentRefs = nil
+						currentWalkFunc = nil
+						initVars = append(initVars, valSpec)
+					} else if valSpec.Value != nil {
+						walkExpr(valSpec.Value)
+					}
+				case "*astTypeSpec":
 					// do nothing
+					var typeSpec = spec.typeSpec
+					switch kind(e2t(typeSpec.Type)) {
+					case T_STRUCT:
+						calcStructSizeAndSetFieldOffset(getStructType(e2t(typeSpec.Type)))
+					default:
+						// do nothing
+					}
+				default:
+					panic2(__func__, "Unexpected dtype="+spec.dtype)
 				}
-			default:
-				panic2(__func__, "Unexpected dtype="+genDecl.Spec.dtype)
 			}
 		case "*astFuncDecl":
 			var funcDecl = decl.funcDecl
 			currentFuncDecl = funcDecl
 			logf(" [sema] == astFuncDecl %s ==\n", funcDecl.Name.Name)
+			var fnc = &Func{}
+			fnc.name = funcDecl.Name.Name
+			if funcDecl.Recv == nil && fnc.name == "init" {
+				fnc.name = "init." + Itoa(len(initFuncs))
+				initFuncs = append(initFuncs, fnc)
+			}
+			fnc.funcType =  funcDecl.Type
+			fnc.Body = funcDecl.Body
+			funcDecl.refs = &declRefs{}
+			currentRefs = funcDecl.refs
+			currentWalkFunc = fnc
+			funcLitCount = 0
 			localoffset = 0
-			var paramoffset = 16
-			var field *astField
 			var paramFields []*astField
 
 			if funcDecl.Recv != nil { // Method
 				paramFields = append(paramFields, funcDecl.Recv.List[0])
 			}
+			var field *astField
 			for _, field = range funcDecl.Type.Params.List {
 				paramFields = append(paramFields, field)
 			}
-
-			for _, field = range paramFields {
-				var obj = field.Name.Obj
-				obj.Variable = newLocalVariable(obj.Name, paramoffset)
-				var varSize = getSizeOfType(e2t(field.Type))
-				paramoffset = paramoffset + varSize
-				logf(" field.Name.Obj.Name=%s\n", obj.Name)
-				//logf("   field.Type=%#v\n", field.Type)
-			}
+			declareParams(fnc, paramFields)
+			declareNamedResults(getFuncResults(funcDecl.Type))
 			if funcDecl.Body != nil {
+				checkLabels(funcDecl.Body)
 				var stmt *astStmt
 				for _, stmt = range funcDecl.Body.List {
 					walkStmt(stmt)
 				}
-				var fnc = &Func{}
-				fnc.name = funcDecl.Name.Name
-				fnc.funcType =  funcDecl.Type
-				fnc.Body = funcDecl.Body
 				fnc.localarea = localoffset
-				fnc.argsarea = paramoffset
 
 				if funcDecl.Recv != nil { // Method
 					fnc.method = newMethod(funcDecl)
+					fnc.rcvType = funcDecl.Recv.List[0].Type
 				}
 				pkgContainer.funcs = append(pkgContainer.funcs, fnc)
 			}
+			var _nil *Func
+			currentWalkFunc = _nil
+			currentRefs = nil
 		default:
 			panic2(__func__, "TBI: "+decl.dtype)
 		}
 	}
 
+	// The package initializer
+	var body = &astBlockStmt{}
+	var valSpec *astValueSpec
+	for _, valSpec = range sortInitVars(initVars) {
+		if valSpec.tuple != nil {
+			body.List = append(body.List, &astStmt{
+				dtype:      "*astAssignStmt",
+				assignStmt: valSpec.tuple,
+			})
+			continue
+		}
+		var as = &astAssignStmt{}
+		as.Tok = "="
+		as.Lhs = []*astExpr{&astExpr{
+			dtype: "*astIdent",
+			ident: valSpec.Name,
+		}}
+		as.Rhs = []*astExpr{valSpec.Value}
+		body.List = append(body.List, &astStmt{
+			dtype:      "*astAssignStmt",
+			assignStmt: as,
+		})
+	}
+	var fnc *Func
+	for _, fnc = range initFuncs {
+		var call = &astCallExpr{}
+		call.Fun = &astExpr{
+			dtype: "*astIdent",
+			ident: &astIdent{
+				Name: fnc.name,
+				Obj: &astObject{
+					Kind: astFun,
+					Name: fnc.name,
+					Decl: &ObjDecl{
+						dtype:    "*astFuncDecl",
+						funcDecl: &astFuncDecl{Type: fnc.funcType},
+					},
+				},
+			},
+		}
+		var exprStmt = &astExprStmt{}
+		exprStmt.X = &astExpr{
+			dtype:    "*astCallExpr",
+			callExpr: call,
+		}
+		body.List = append(body.List, &astStmt{
+			dtype:    "*astExprStmt",
+			exprStmt: exprStmt,
+		})
+	}
+	initFnc.Body = body
+	pkgContainer.funcs = append(pkgContainer.funcs, initFnc)
+
 	if len(stringLiterals) == 0 {
 		panic2(__func__, "stringLiterals is empty\n")
 	}
@@ -4793,16 +11628,32 @@ var gTrue *astObject
 var gFalse *astObject
 var gString *astObject
 var gInt *astObject
+var gInt8 *astObject
+var gInt16 *astObject
+var gInt32 *astObject
+var gInt64 *astObject
+var gUint *astObject
 var gUint8 *astObject
 var gUint16 *astObject
+var gUint32 *astObject
+var gUint64 *astObject
 var gUintptr *astObject
+var gFloat32 *astObject
+var gFloat64 *astObject
 var gBool *astObject
+var gError *astObject
+var gRuntimeError *astObject
 var gNew *astObject
 var gMake *astObject
 var gAppend *astObject
+var gCopy *astObject
 var gLen *astObject
 var gCap *astObject
 var gPanic *astObject
+var gRecover *astObject
+var gDelete *astObject
+var gClose *astObject
+var gIota *astObject
 
 // func type of runtime functions
 var funcTypeOsExit *astFuncType
@@ -4810,25 +11661,45 @@ var funcTypeSyscallOpen *astFuncType
 var funcTypeSyscallRead *astFuncType
 var funcTypeSyscallWrite *astFuncType
 var funcTypeSyscallSyscall *astFuncType
+var funcTypeRuntimeGosched *astFuncType
 
 func createUniverse() *astScope {
 	var universe = new(astScope)
 
 	scopeInsert(universe, gInt)
+	scopeInsert(universe, gInt8)
+	scopeInsert(universe, gInt16)
+	scopeInsert(universe, gInt32)
+	scopeInsert(universe, gInt64)
+	scopeInsert(universe, gUint)
 	scopeInsert(universe, gUint8)
 	scopeInsert(universe, gUint16)
+	scopeInsert(universe, gUint32)
+	scopeInsert(universe, gUint64)
 	scopeInsert(universe, gUintptr)
+	scopeInsert(universe, gFloat32)
+	scopeInsert(universe, gFloat64)
+	// aliases
+	scopeInsertAlias(universe, "byte", gUint8)
+	scopeInsertAlias(universe, "rune", gInt32)
 	scopeInsert(universe, gString)
 	scopeInsert(universe, gBool)
+	scopeInsert(universe, gError)
+	scopeInsert(universe, gRuntimeError)
 	scopeInsert(universe, gNil)
 	scopeInsert(universe, gTrue)
 	scopeInsert(universe, gFalse)
 	scopeInsert(universe, gNew)
 	scopeInsert(universe, gMake)
 	scopeInsert(universe, gAppend)
+	scopeInsert(universe, gCopy)
 	scopeInsert(universe, gLen)
 	scopeInsert(universe, gCap)
 	scopeInsert(universe, gPanic)
+	scopeInsert(universe, gRecover)
+	scopeInsert(universe, gDelete)
+	scopeInsert(universe, gClose)
+	scopeInsert(universe, gIota)
 
 	logf(" [%s] scope insertion of predefined identifiers complete\n", __func__)
 
@@ -4848,6 +11719,11 @@ func createUniverse() *astScope {
 		Kind: "Pkg",
 		Name: "unsafe",
 	})
+
+	scopeInsert(universe, &astObject{
+		Kind: "Pkg",
+		Name: "runtime",
+	})
 	logf(" [%s] scope insertion complete\n", __func__)
 	return universe
 }
@@ -4942,6 +11818,44 @@ func initGlobals() {
 		},
 	}
 
+	gInt8 = &astObject{
+		Kind: astTyp,
+		Name: "int8",
+	}
+	gInt16 = &astObject{
+		Kind: astTyp,
+		Name: "int16",
+	}
+	gInt32 = &astObject{
+		Kind: astTyp,
+		Name: "int32",
+	}
+	tInt32 = &Type{
+		e: &astExpr{
+			dtype: "*astIdent",
+			ident: &astIdent{
+				Name: "int32",
+				Obj:  gInt32,
+			},
+		},
+	}
+	gInt64 = &astObject{
+		Kind: astTyp,
+		Name: "int64",
+	}
+	gUint = &astObject{
+		Kind: astTyp,
+		Name: "uint",
+	}
+	gUint32 = &astObject{
+		Kind: astTyp,
+		Name: "uint32",
+	}
+	gUint64 = &astObject{
+		Kind: astTyp,
+		Name: "uint64",
+	}
+
 	gUint8 = &astObject{
 		Kind: astTyp,
 		Name: "uint8",
@@ -4956,6 +11870,15 @@ func initGlobals() {
 		},
 	}
 
+	tSliceOfBytes = &Type{
+		e: &astExpr{
+			dtype: "*astArrayType",
+			arrayType: &astArrayType{
+				Elt: tUint8.e,
+			},
+		},
+	}
+
 	gUint16 = &astObject{
 		Kind: astTyp,
 		Name: "uint16",
@@ -4984,6 +11907,34 @@ func initGlobals() {
 		},
 	}
 
+	gFloat32 = &astObject{
+		Kind: astTyp,
+		Name: "float32",
+	}
+	tFloat32 = &Type{
+		e: &astExpr{
+			dtype: "*astIdent",
+			ident: &astIdent{
+				Name: "float32",
+				Obj:  gFloat32,
+			},
+		},
+	}
+
+	gFloat64 = &astObject{
+		Kind: astTyp,
+		Name: "float64",
+	}
+	tFloat64 = &Type{
+		e: &astExpr{
+			dtype: "*astIdent",
+			ident: &astIdent{
+				Name: "float64",
+				Obj:  gFloat64,
+			},
+		},
+	}
+
 	gBool = &astObject{
 		Kind: astTyp,
 		Name: "bool",
@@ -4998,6 +11949,92 @@ func initGlobals() {
 		},
 	}
 
+	tEmptyInterface = &Type{
+		e: &astExpr{
+			dtype: "*astInterfaceType",
+			interfaceType: &astInterfaceType{
+				Methods: &astFieldList{},
+			},
+		},
+	}
+
+	// type error interface { Error() string }
+	gError = &astObject{
+		Kind: astTyp,
+		Name: "error",
+		Decl: &ObjDecl{
+			dtype: "*astTypeSpec",
+			typeSpec: &astTypeSpec{
+				Name: &astIdent{
+					Name: "error",
+				},
+				Type: &astExpr{
+					dtype: "*astInterfaceType",
+					interfaceType: &astInterfaceType{
+						Methods: &astFieldList{
+							List: []*astField{
+								&astField{
+									Name: &astIdent{
+										Name: "Error",
+									},
+									Type: &astExpr{
+										dtype: "*astFuncType",
+										funcType: &astFuncType{
+											Params: &astFieldList{},
+											Results: &astFieldList{
+												List: []*astField{
+													&astField{
+														Type: tString.e,
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// type runtime.Error interface { Error() string; RuntimeError() }
+	gRuntimeError = &astObject{
+		Kind: astTyp,
+		Name: "runtime.Error",
+		Decl: &ObjDecl{
+			dtype: "*astTypeSpec",
+			typeSpec: &astTypeSpec{
+				Name: &astIdent{
+					Name: "runtime.Error",
+				},
+				Type: &astExpr{
+					dtype: "*astInterfaceType",
+					interfaceType: &astInterfaceType{
+						Methods: &astFieldList{
+							List: []*astField{
+								gError.Decl.typeSpec.Type.interfaceType.Methods.List[0],
+								&astField{
+									Name: &astIdent{
+										Name: "RuntimeError",
+									},
+									Type: &astExpr{
+										dtype: "*astFuncType",
+										funcType: &astFuncType{
+											Params:  &astFieldList{},
+											Results: &astFieldList{},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
 	generalSlice = &astExpr{
 		dtype: "*astIdent",
 		ident: &astIdent{},
@@ -5018,6 +12055,11 @@ func initGlobals() {
 		Name: "append",
 	}
 
+	gCopy = &astObject{
+		Kind: astFun,
+		Name: "copy",
+	}
+
 	gLen = &astObject{
 		Kind: astFun,
 		Name: "len",
@@ -5033,6 +12075,26 @@ func initGlobals() {
 		Name: "panic",
 	}
 
+	gRecover = &astObject{
+		Kind: astFun,
+		Name: "recover",
+	}
+
+	gDelete = &astObject{
+		Kind: astFun,
+		Name: "delete",
+	}
+
+	gClose = &astObject{
+		Kind: astFun,
+		Name: "close",
+	}
+
+	gIota = &astObject{
+		Kind: astCon,
+		Name: "iota",
+	}
+
 	funcTypeOsExit = &astFuncType{
 		Params: &astFieldList{
 			List: []*astField{
@@ -5128,6 +12190,10 @@ func initGlobals() {
 			},
 		},
 	}
+	funcTypeRuntimeGosched = &astFuncType{
+		Params: &astFieldList{},
+		Results: nil,
+	}
 }
 
 var pkg *PkgContainer
@@ -5141,7 +12207,8 @@ type PkgContainer struct {
 func showHelp() {
 	fmtPrintf("Usage:\n")
 	fmtPrintf("    babygo version:  show version\n")
-	fmtPrintf("    babygo [-DF] [-DG] filename\n")
+	fmtPrintf("    babygo [-DF] [-DG] [-B] filename\n")
+	fmtPrintf("        -B: disable bounds checks\n")
 }
 
 func main() {
@@ -5169,6 +12236,8 @@ func main() {
 			debugFrontEnd = true
 		case "-DG":
 			debugCodeGen = true
+		case "-B":
+			noBoundsCheck = true
 		}
 	}
 	var inputFile = arg
@@ -5187,4 +12256,5 @@ func main() {
 		walk(pkg, f)
 		generateCode(pkg)
 	}
+	emitDynamicTypes()
 }
@@ -783,33 +783,33 @@ func emitFuncall(fun ast.Expr, eArgs []ast.Expr) {
 					e: sliceArg,
 					t: nil,
 				},
-				// elm
+				// elmSize
 				&Arg{
-					e: elemArg,
-					t: elmType,
+					e: newNumberLiteral(elmSize),
+					t: tInt,
+				},
+				// number of elements
+				&Arg{
+					e: newNumberLiteral(1),
+					t: tInt,
 				},
 			}
 
-			var symbol string
-			switch elmSize {
-			case 1:
-				symbol = "runtime.append1"
-			case 8:
-				symbol = "runtime.append8"
-			case 16:
-				symbol = "runtime.append16"
-			case 24:
-				symbol = "runtime.append24"
-			default:
-				throw(elmSize)
-			}
 			var resultList = []*ast.Field{
 				&ast.Field{
 					Names: nil,
 					Type:  generalSlice,
 				},
 			}
-			emitCall(symbol, args, resultList)
+			emitCall("runtime.growslice", args, resultList)
+			// store the element at the end
+			fmtPrintf("  movq 8(%%rsp), %%rax # new len\n")
+			fmtPrintf("  subq $1, %%rax\n")
+			fmtPrintf("  imulq $%d, %%rax\n", Itoa(elmSize))
+			fmtPrintf("  addq 0(%%rsp), %%rax # addr of the element\n")
+			fmtPrintf("  pushq %%rax\n")
+			emitExpr(elemArg, elmType)
+			emitStore(elmType)
 			return
 		case gPanic:
 			symbol = "runtime.panic"
@@ -838,10 +838,6 @@ func emitFuncall(fun ast.Expr, eArgs []ast.Expr) {
 			return
 		}
 
-		if fn.Name == "makeSlice1" || fn.Name == "makeSlice8" || fn.Name == "makeSlice16" || fn.Name == "makeSlice24" {
-			fn.Name = "makeSlice"
-		}
-
 		// general function call
 		symbol = getFuncSymbol(pkgName, fn.Name)
 		obj := fn.Obj //.Kind == FN
@@ -1822,6 +1818,9 @@ func emitText(pkgName string) {
 	for _, fnc = range globalFuncs {
 		emitFuncDecl(pkgName, fnc)
 	}
+	// package-level variables are all initialized statically
+	fmt.Printf("%s.init:\n", pkgName)
+	fmt.Printf("  ret\n")
 }
 
 func generateCode(pkgName string) {
@@ -51,10 +51,11 @@ func brk(addr uintptr) uintptr {
 	return ret
 }
 
-func panic(x string) {
-	var s = "panic: " + x + "\n\n"
-	syscall.Write(2, []uint8(s))
-	syscall.Syscall(uintptr(SYS_EXIT), 1, uintptr(0), uintptr(0))
+// Panics raised by the runtime itself, which are runtime errors
+func panic(msg string) {
+	var p *_panic = &_panic{}
+	p.msg = msg
+	dopanic(p)
 }
 
 func memzeropad(addr1 uintptr, size uintptr) {
@@ -80,6 +81,36 @@ func memcopy(src uintptr, dst uintptr, length int) {
 	}
 }
 
+// memcopy for overlapping areas
+func memmove(src uintptr, dst uintptr, length int) {
+	if dst <= src {
+		memcopy(src, dst, length)
+		return
+	}
+	var i int
+	var srcp *uint8
+	var dstp *uint8
+	for i = length - 1; i >= 0; i-- {
+		srcp = (*uint8)(unsafe.Pointer(src + uintptr(i)))
+		dstp = (*uint8)(unsafe.Pointer(dst + uintptr(i)))
+		*dstp = *srcp
+	}
+}
+
+func memequal(a uintptr, b uintptr, length int) bool {
+	var i int
+	var pa *uint8
+	var pb *uint8
+	for i = 0; i < length; i++ {
+		pa = (*uint8)(unsafe.Pointer(a + uintptr(i)))
+		pb = (*uint8)(unsafe.Pointer(b + uintptr(i)))
+		if *pa != *pb {
+			return false
+		}
+	}
+	return true
+}
+
 func malloc(size uintptr) uintptr {
 	if heapCurrent+size > heapTail {
 		panic("malloc exceeds heap capacity")
@@ -98,118 +129,1520 @@ func makeSlice(elmSize int, slen int, scap int) (uintptr, int, int) {
 	return addr, slen, scap
 }
 
-// Actually this is an alias to makeSlice
-func makeSlice1(elmSize int, slen int, scap int) []uint8
-func makeSlice8(elmSize int, slen int, scap int) []int
-func makeSlice16(elmSize int, slen int, scap int) []string
-func makeSlice24(elmSize int, slen int, scap int) [][]int
+// --- slice ---
+// Slices are passed to the functions below as their (ptr, len, cap) words,
+// so that they work for any element type.
+
+// The capacity needed to grow a slice to newlen elements.
+func growcap(oldlen int, oldcap int, newlen int) int {
+	if newlen <= oldcap {
+		return oldcap
+	}
+	var newcap int = oldlen * 2
+	if newcap < newlen {
+		newcap = newlen
+	}
+	return newcap
+}
+
+// Move the elements to a new array if the capacity has changed.
+func reallocslice(ptr uintptr, oldlen int, oldcap int, newcap int, elmSize int) uintptr {
+	if newcap == oldcap {
+		return ptr
+	}
+	var newptr uintptr = malloc(uintptr(newcap * elmSize))
+	memcopy(ptr, newptr, oldlen*elmSize)
+	return newptr
+}
+
+// Make room for n more elements at the end of a slice.
+// The caller stores the new elements.
+func growslice(ptr uintptr, oldlen int, oldcap int, elmSize int, n int) (uintptr, int, int) {
+	var newlen int = oldlen + n
+	var newcap int = growcap(oldlen, oldcap, newlen)
+	var newptr uintptr = reallocslice(ptr, oldlen, oldcap, newcap, elmSize)
+	return newptr, newlen, newcap
+}
+
+// append(old, src...)
+func appendslice(ptr uintptr, oldlen int, oldcap int, elmSize int, srcptr uintptr, srclen int, srccap int) (uintptr, int, int) {
+	var newlen int = oldlen + srclen
+	var newcap int = growcap(oldlen, oldcap, newlen)
+	var newptr uintptr = reallocslice(ptr, oldlen, oldcap, newcap, elmSize)
+	memmove(srcptr, newptr+uintptr(oldlen*elmSize), srclen*elmSize)
+	return newptr, newlen, newcap
+}
+
+// s[low:high:max]
+// The operand is passed as (ptr, len, cap) words, where cap == len for strings and arrays.
+// The arguments are in the reverse order of the source, as the caller pushes them in that order.
+func slicing(check bool, slice3 bool, hasCap bool, elmSize int, max int, high int, low int, ptr uintptr, slen int, scap int) (uintptr, int, int) {
+	if check {
+		checkSliceBounds(slice3, hasCap, max, high, low, scap)
+	}
+	return ptr + uintptr(low*elmSize), high - low, max - low
+}
+
+func checkSliceBounds(slice3 bool, hasCap bool, max int, high int, low int, scap int) {
+	var bound string = " with length "
+	if hasCap {
+		bound = " with capacity "
+	}
+	if slice3 {
+		if max < 0 || max > scap {
+			panic("runtime error: slice bounds out of range [::" + itoa(max) + "]" + bound + itoa(scap))
+		}
+		if high < 0 || high > max {
+			panic("runtime error: slice bounds out of range [:" + itoa(high) + ":" + itoa(max) + "]")
+		}
+		if low < 0 || low > high {
+			panic("runtime error: slice bounds out of range [" + itoa(low) + ":" + itoa(high) + ":]")
+		}
+		return
+	}
+	if high < 0 || high > max {
+		panic("runtime error: slice bounds out of range [:" + itoa(high) + "]" + bound + itoa(max))
+	}
+	if low < 0 || low > high {
+		panic("runtime error: slice bounds out of range [" + itoa(low) + ":" + itoa(high) + "]")
+	}
+}
+
+// x[y] out of range
+func panicIndex(x int, y int) {
+	if x < 0 {
+		panic("runtime error: index out of range [" + itoa(x) + "]")
+	}
+	panic("runtime error: index out of range [" + itoa(x) + "] with length " + itoa(y))
+}
 
-func append1(old []uint8, elm uint8) (uintptr, int, int) {
-	var new_ []uint8
-	var elmSize int = 1
+// x / 0 or x % 0 of integers
+func panicdivide() {
+	panic("runtime error: integer divide by zero")
+}
+
+// x << y or x >> y with a negative y
+func panicshift() {
+	panic("runtime error: negative shift amount")
+}
+
+// Called instead of the faulting instruction by the SIGSEGV handler
+func panicmem() {
+	panic("runtime error: invalid memory address or nil pointer dereference")
+}
+
+// copy(dst, src)
+func slicecopy(dstptr uintptr, dstlen int, dstcap int, srcptr uintptr, srclen int, srccap int, elmSize int) int {
+	var n int = dstlen
+	if srclen < n {
+		n = srclen
+	}
+	memmove(srcptr, dstptr, n*elmSize)
+	return n
+}
+
+// --- map ---
+// A map is a hash table with chaining.
+// All entries are also linked in insertion order for iteration.
+type Map struct {
+	count     int // must be the first field for len(m)
+	keySize   int
+	valueSize int
+	keyKind   int
+	keyLayout uintptr // for mapKeyComposite
+	buckets   []*mapEntry
+	first     *mapEntry
+	last      *mapEntry
+}
+
+type mapEntry struct {
+	key     uintptr
+	value   uintptr
+	hash    uintptr
+	chain   *mapEntry // next entry in the same bucket
+	prev    *mapEntry // insertion order
+	next    *mapEntry // insertion order. Kept after deletion for iterators.
+	deleted bool
+}
+
+// The layout is known by the compiler
+type mapIter struct {
+	m     *Map
+	next  *mapEntry
+	key   uintptr
+	value uintptr
+}
 
-	var oldlen int = len(old)
-	var newlen int = oldlen + 1
+// key kinds
+const mapKeyMem int = 0
+const mapKeyString int = 1
+const mapKeyComposite int = 2
+const mapKeyFloat32 int = 3
+const mapKeyFloat64 int = 4
+const mapKeyInterface int = 5
 
-	if cap(old) >= newlen {
-		new_ = old[0:newlen]
+const mapInitialBuckets int = 8
+
+func makeMap(keySize int, valueSize int, keyKind int, keyLayout uintptr) *Map {
+	var m *Map = &Map{}
+	m.keySize = keySize
+	m.valueSize = valueSize
+	m.keyKind = keyKind
+	m.keyLayout = keyLayout
+	m.buckets = make([]*mapEntry, mapInitialBuckets, mapInitialBuckets)
+	return m
+}
+
+func hashBytes(h uintptr, addr uintptr, length int) uintptr {
+	var i int
+	var p *uint8
+	for i = 0; i < length; i++ {
+		p = (*uint8)(unsafe.Pointer(addr + uintptr(i)))
+		h = h*31 + uintptr(*p)
+	}
+	return h
+}
+
+func hashString(h uintptr, addr uintptr) uintptr {
+	var sp *string = (*string)(unsafe.Pointer(addr))
+	var s string = *sp
+	var i int
+	for i = 0; i < len(s); i++ {
+		h = h*31 + uintptr(s[i])
+	}
+	return h
+}
+
+func mapHash(m *Map, key uintptr) uintptr {
+	var h uintptr = 17
+	if m.keyKind == mapKeyMem {
+		return hashBytes(h, key, m.keySize)
+	}
+	if m.keyKind == mapKeyComposite {
+		return hashCompositeKey(m.keyLayout, m.keySize, h, key)
+	}
+	return hashKeyPart(m.keyKind, h, key)
+}
+
+func mapKeyEqual(m *Map, a uintptr, b uintptr) bool {
+	if m.keyKind == mapKeyMem {
+		return memequal(a, b, m.keySize)
+	}
+	if m.keyKind == mapKeyComposite {
+		return compositeKeyEqual(m.keyLayout, m.keySize, a, b)
+	}
+	return keyPartEqual(m.keyKind, a, b)
+}
+
+func stringsAtEqual(a uintptr, b uintptr) bool {
+	var sa *string = (*string)(unsafe.Pointer(a))
+	var sb *string = (*string)(unsafe.Pointer(b))
+	return cmpstrings(*sa, *sb)
+}
+
+// A key part is a string, a float or an interface, which is not compared by its bytes
+func keyPartSize(keyKind int) int {
+	if keyKind == mapKeyString || keyKind == mapKeyInterface {
+		return 16
+	}
+	if keyKind == mapKeyFloat32 {
+		return 4
+	}
+	return 8
+}
+
+func hashKeyPart(keyKind int, h uintptr, addr uintptr) uintptr {
+	if keyKind == mapKeyString {
+		return hashString(h, addr)
+	}
+	if keyKind == mapKeyInterface {
+		// equal interfaces have the same dynamic type
+		var itab uintptr = uintptr(readInt(addr, 8, false))
+		if itab == 0 {
+			return h
+		}
+		return h*31 + uintptr(unsafe.Pointer(itabType(itab)))
+	}
+	return h*31 + uintptr(floatKeyBits(addr, keyPartSize(keyKind)))
+}
+
+func keyPartEqual(keyKind int, a uintptr, b uintptr) bool {
+	if keyKind == mapKeyString {
+		return stringsAtEqual(a, b)
+	}
+	if keyKind == mapKeyInterface {
+		return ifaceeq(uintptr(readInt(a, 8, false)), uintptr(readInt(a+8, 8, false)), uintptr(readInt(b, 8, false)), uintptr(readInt(b+8, 8, false)))
+	}
+	var size int = keyPartSize(keyKind)
+	var bits int = floatKeyBits(a, size)
+	if isNaNBits(bits, size) {
+		// NaN is not equal to anything
+		return false
+	}
+	return bits == floatKeyBits(b, size)
+}
+
+// The bits of a float key, where -0 is normalized to +0
+func floatKeyBits(addr uintptr, size int) int {
+	var bits int = readInt(addr, size, true)
+	if floatMagnitude(bits, size) == 0 {
+		return 0
+	}
+	return bits
+}
+
+// The bits of a float without the sign bit
+func floatMagnitude(bits int, size int) int {
+	if bits < 0 {
+		return bits + pow2(size*8-1)
+	}
+	return bits
+}
+
+func isNaNBits(bits int, size int) bool {
+	var fracBits int = 52
+	var expBits int = 11
+	if size == 4 {
+		fracBits = 23
+		expBits = 8
+	}
+	var inf int = (pow2(expBits) - 1) * pow2(fracBits)
+	return floatMagnitude(bits, size) > inf
+}
+
+// A composite key is a struct or an array which contains strings, floats or interfaces.
+// Its layout is the number of such parts followed by the offset and the key kind of each part.
+// The other bytes of the key are compared as they are.
+func getKeyPartOffset(layout uintptr, i int) int {
+	return readInt(layout+uintptr(8*(2*i+1)), 8, true)
+}
+
+func getKeyPartKind(layout uintptr, i int) int {
+	return readInt(layout+uintptr(8*(2*i+2)), 8, true)
+}
+
+func hashCompositeKey(layout uintptr, size int, h uintptr, key uintptr) uintptr {
+	var n int = readInt(layout, 8, true)
+	var pos int
+	var offset int
+	var keyKind int
+	var i int
+	for i = 0; i < n; i++ {
+		offset = getKeyPartOffset(layout, i)
+		keyKind = getKeyPartKind(layout, i)
+		h = hashBytes(h, key+uintptr(pos), offset-pos)
+		h = hashKeyPart(keyKind, h, key+uintptr(offset))
+		pos = offset + keyPartSize(keyKind)
+	}
+	return hashBytes(h, key+uintptr(pos), size-pos)
+}
+
+func compositeKeyEqual(layout uintptr, size int, a uintptr, b uintptr) bool {
+	var n int = readInt(layout, 8, true)
+	var pos int
+	var offset int
+	var keyKind int
+	var i int
+	for i = 0; i < n; i++ {
+		offset = getKeyPartOffset(layout, i)
+		keyKind = getKeyPartKind(layout, i)
+		if !memequal(a+uintptr(pos), b+uintptr(pos), offset-pos) {
+			return false
+		}
+		if !keyPartEqual(keyKind, a+uintptr(offset), b+uintptr(offset)) {
+			return false
+		}
+		pos = offset + keyPartSize(keyKind)
+	}
+	return memequal(a+uintptr(pos), b+uintptr(pos), size-pos)
+}
+
+func mapBucketIndex(m *Map, h uintptr) int {
+	return int(h % uintptr(len(m.buckets)))
+}
+
+func mapLookup(m *Map, key uintptr) *mapEntry {
+	var e *mapEntry
+	if m == nil {
+		return e
+	}
+	var h uintptr = mapHash(m, key)
+	for e = m.buckets[mapBucketIndex(m, h)]; e != nil; e = e.chain {
+		if e.hash == h && mapKeyEqual(m, e.key, key) {
+			return e
+		}
+	}
+	return e
+}
+
+func mapGrow(m *Map) {
+	var n int = len(m.buckets) * 2
+	m.buckets = make([]*mapEntry, n, n)
+	var e *mapEntry
+	var i int
+	for e = m.first; e != nil; e = e.next {
+		i = mapBucketIndex(m, e.hash)
+		e.chain = m.buckets[i]
+		m.buckets[i] = e
+	}
+}
+
+// Returns the address of the value for key, or 0 if not found
+func mapaccess1(m *Map, key uintptr) uintptr {
+	var e *mapEntry = mapLookup(m, key)
+	if e == nil {
+		return 0
+	}
+	return e.value
+}
+
+// Returns the address of the value for key. A new entry is created if not found.
+func mapassign(m *Map, key uintptr) uintptr {
+	if m == nil {
+		panic("assignment to entry in nil map")
+	}
+	var e *mapEntry = mapLookup(m, key)
+	if e != nil {
+		return e.value
+	}
+	if m.count >= len(m.buckets) {
+		mapGrow(m)
+	}
+	e = &mapEntry{}
+	e.hash = mapHash(m, key)
+	e.key = malloc(uintptr(m.keySize))
+	memcopy(key, e.key, m.keySize)
+	e.value = malloc(uintptr(m.valueSize))
+
+	var i int = mapBucketIndex(m, e.hash)
+	e.chain = m.buckets[i]
+	m.buckets[i] = e
+
+	if m.last == nil {
+		m.first = e
 	} else {
-		var newcap int
-		if oldlen == 0 {
-			newcap = 1
-		} else {
-			newcap = oldlen * 2
+		m.last.next = e
+		e.prev = m.last
+	}
+	m.last = e
+	m.count++
+	return e.value
+}
+
+func mapdelete(m *Map, key uintptr) {
+	var e *mapEntry = mapLookup(m, key)
+	if e == nil {
+		return
+	}
+	var i int = mapBucketIndex(m, e.hash)
+	if m.buckets[i] == e {
+		m.buckets[i] = e.chain
+	} else {
+		var c *mapEntry
+		for c = m.buckets[i]; c.chain != e; c = c.chain {
+		}
+		c.chain = e.chain
+	}
+
+	if e.prev == nil {
+		m.first = e.next
+	} else {
+		e.prev.next = e.next
+	}
+	if e.next == nil {
+		m.last = e.prev
+	} else {
+		e.next.prev = e.prev
+	}
+	e.deleted = true
+	m.count--
+}
+
+func mapiterinit(m *Map) *mapIter {
+	var it *mapIter = &mapIter{}
+	it.m = m
+	if m != nil {
+		it.next = m.first
+	}
+	return it
+}
+
+// Advance the iterator. Entries deleted during iteration are skipped.
+func mapiternext(it *mapIter) bool {
+	var e *mapEntry = it.next
+	for e != nil && e.deleted {
+		e = e.next
+	}
+	if e == nil {
+		return false
+	}
+	it.key = e.key
+	it.value = e.value
+	it.next = e.next
+	return true
+}
+
+// --- interface ---
+// An interface value is a pair of an itab and a data word.
+// The data word is the value itself for pointer shaped types,
+// and a pointer to a copy of the value for other types.
+//
+// An itab is a pointer to the dynamic type followed by method addresses.
+// Type descriptors and itabs are emitted by the compiler.
+// A type descriptor begins with a pointer to itself,
+// so that it also serves as an itab of the empty interface.
+type _type struct {
+	self      uintptr
+	name      string
+	kind      int // same as reflect.Kind
+	size      int
+	methods   []typeMethod
+	keyLayout uintptr // of a struct or an array which is not compared by its bytes, or 0
+}
+
+// For interface types, fn is 0
+type typeMethod struct {
+	name string
+	fn   uintptr
+}
+
+const kindBool int = 1
+const kindInt int = 2
+const kindInt64 int = 6
+const kindUintptr int = 12
+const kindFloat32 int = 13
+const kindFloat64 int = 14
+const kindChan int = 18
+const kindFunc int = 19
+const kindMap int = 21
+const kindPtr int = 22
+const kindSlice int = 23
+const kindString int = 24
+
+func itabType(itab uintptr) *_type {
+	var p *uintptr = (*uintptr)(unsafe.Pointer(itab))
+	var t *_type = (*_type)(unsafe.Pointer(*p))
+	return t
+}
+
+func isDirectIface(t *_type) bool {
+	return t.kind == kindPtr || t.kind == kindMap || t.kind == kindChan || t.kind == kindFunc
+}
+
+func ifaceeq(itab1 uintptr, data1 uintptr, itab2 uintptr, data2 uintptr) bool {
+	if itab1 == 0 || itab2 == 0 {
+		return itab1 == itab2
+	}
+	var t *_type = itabType(itab1)
+	if t != itabType(itab2) {
+		return false
+	}
+	if t.kind == kindSlice || t.kind == kindMap || t.kind == kindFunc {
+		panic("runtime error: comparing uncomparable type " + t.name)
+	}
+	if isDirectIface(t) {
+		return data1 == data2
+	}
+	if t.kind == kindString {
+		var s1 *string = (*string)(unsafe.Pointer(data1))
+		var s2 *string = (*string)(unsafe.Pointer(data2))
+		return cmpstrings(*s1, *s2)
+	}
+	if t.kind == kindFloat32 {
+		return keyPartEqual(mapKeyFloat32, data1, data2)
+	}
+	if t.kind == kindFloat64 {
+		return keyPartEqual(mapKeyFloat64, data1, data2)
+	}
+	if t.keyLayout != 0 {
+		// a struct or an array which contains strings, floats or interfaces
+		return compositeKeyEqual(t.keyLayout, t.size, data1, data2)
+	}
+	return memequal(data1, data2, t.size)
+}
+
+func findMethod(t *_type, name string) uintptr {
+	var i int
+	var m typeMethod
+	for i = 0; i < len(t.methods); i++ {
+		m = t.methods[i]
+		if cmpstrings(m.name, name) {
+			return m.fn
 		}
-		new_ = makeSlice1(elmSize, newlen, newcap)
-		var oldSize int = oldlen * elmSize
-		if oldlen > 0 {
-			memcopy(uintptr(unsafe.Pointer(&old[0])), uintptr(unsafe.Pointer(&new_[0])), oldSize)
+	}
+	return 0
+}
+
+// Returns the name of a method of inter which t does not have, or "".
+func missingMethod(inter *_type, t *_type) string {
+	var i int
+	var m typeMethod
+	for i = 0; i < len(inter.methods); i++ {
+		m = inter.methods[i]
+		if findMethod(t, m.name) == 0 {
+			return m.name
 		}
 	}
+	return ""
+}
 
-	new_[oldlen] = elm
-	return uintptr(unsafe.Pointer(&new_[0])), newlen, cap(new_)
+// Builds an itab of the interface type inter for the dynamic type t.
+// Returns 0 if t does not implement inter.
+func getitab(inter *_type, t *_type) uintptr {
+	if len(inter.methods) == 0 {
+		return t.self
+	}
+	if missingMethod(inter, t) != "" {
+		return 0
+	}
+	var n int = len(inter.methods)
+	var itab uintptr = malloc(uintptr((n + 1) * 8))
+	var p *uintptr = (*uintptr)(unsafe.Pointer(itab))
+	*p = t.self
+	var i int
+	var m typeMethod
+	for i = 0; i < n; i++ {
+		m = inter.methods[i]
+		p = (*uintptr)(unsafe.Pointer(itab + uintptr((i+1)*8)))
+		*p = findMethod(t, m.name)
+	}
+	return itab
 }
 
-func append8(old []int, elm int) (uintptr, int, int) {
-	var new_ []int
-	var elmSize int = 8
+// Conversion between interface types, which never fails.
+func convI2I(inter *_type, itab uintptr) uintptr {
+	if itab == 0 {
+		return 0
+	}
+	return getitab(inter, itabType(itab))
+}
 
-	var oldlen int = len(old)
-	var newlen int = oldlen + 1
+// x.(I)
+func assertI2I(inter *_type, itab uintptr) uintptr {
+	if itab == 0 {
+		panic("interface conversion: interface is nil, not " + inter.name)
+	}
+	var t *_type = itabType(itab)
+	var r uintptr = getitab(inter, t)
+	if r == 0 {
+		panic("interface conversion: " + t.name + " is not " + inter.name + ": missing method " + missingMethod(inter, t))
+	}
+	return r
+}
+
+// v, ok := x.(I)
+func assertI2I2(inter *_type, itab uintptr) uintptr {
+	if itab == 0 {
+		return 0
+	}
+	return getitab(inter, itabType(itab))
+}
+
+// Failure of x.(T) where T is not an interface type. have is nil if x is nil.
+func panicdottype(have *_type, want *_type, iface *_type) {
+	if have == nil {
+		panic("interface conversion: " + iface.name + " is nil, not " + want.name)
+	}
+	panic("interface conversion: " + iface.name + " is " + have.name + ", not " + want.name)
+}
+
+// --- goroutines ---
+// Goroutines are scheduled cooperatively in a single thread.
+// A goroutine runs until it exits or yields at Gosched, a channel operation or a blocking syscall.
+
+// The layout is known by runtime.s
+type g struct {
+	sp     uintptr // saved %rsp while the goroutine is not running. Must be the first field.
+	stack  uintptr // 0 for the main goroutine, which runs on the OS stack
+	id     int
+	defers *_defer
+	panics *_panic
+	next   *g // in the run queue or the free list
+
+	waitreason string // why the goroutine is parked
+	param      *sudog // the sudog which woke the goroutine up
+	selectDone bool   // one of the cases of the parked select has been chosen
+}
+
+const stackSize uintptr = 1048576
 
-	if cap(old) >= newlen {
-		new_ = old[0:newlen]
+var curg *g
+var maing *g
+var goidgen int
+var runqhead *g
+var runqtail *g
+var gfree *g
+
+// Allocates memory with the mmap syscall
+func mmap(size uintptr) uintptr
+
+// Saves the context of from and resumes to
+func swtch(from *g, to *g)
+
+// The address of runtime.goentry, where a new goroutine starts
+func goentryPC() uintptr
+
+func schedinit() {
+	curg = &g{}
+	maing = curg
+	goidgen = 1
+	curg.id = goidgen
+}
+
+// go fn(args)
+func newproc(fn uintptr, siz int, argp uintptr) {
+	var newg *g = gfree
+	if newg != nil {
+		gfree = newg.next
+	} else {
+		newg = &g{}
+		newg.stack = mmap(stackSize)
+	}
+	goidgen++
+	newg.id = goidgen
+
+	// The stack from the top: args, fn, the return address to goentry and %rbp
+	var sp uintptr = newg.stack + stackSize - uintptr(siz)
+	memcopy(argp, sp, siz)
+	sp = sp - 8
+	var p *uintptr = (*uintptr)(unsafe.Pointer(sp))
+	*p = fn
+	sp = sp - 8
+	p = (*uintptr)(unsafe.Pointer(sp))
+	*p = goentryPC()
+	sp = sp - 8
+	p = (*uintptr)(unsafe.Pointer(sp))
+	*p = 0
+	newg.sp = sp
+	runqput(newg)
+}
+
+func runqput(gp *g) {
+	gp.next = nil
+	if runqtail == nil {
+		runqhead = gp
 	} else {
-		var newcap int
-		if oldlen == 0 {
-			newcap = 1
+		runqtail.next = gp
+	}
+	runqtail = gp
+}
+
+func runqget() *g {
+	var gp *g = runqhead
+	if gp != nil {
+		runqhead = gp.next
+		if runqhead == nil {
+			runqtail = nil
+		}
+		gp.next = nil
+	}
+	return gp
+}
+
+// Switches to the next runnable goroutine
+func schedule() {
+	var gp *g = runqget()
+	if gp == nil {
+		// The main goroutine never exits, so it is parked too.
+		fatal("all goroutines are asleep - deadlock!\n\ngoroutine 1 [" + maing.waitreason + "]:")
+	}
+	var old *g = curg
+	curg = gp
+	swtch(old, gp)
+}
+
+func Gosched() {
+	if runqhead == nil {
+		return
+	}
+	runqput(curg)
+	schedule()
+}
+
+// The function of a goroutine has returned. Its stack is reused by a later goroutine.
+func goexit() {
+	curg.next = gfree
+	gfree = curg
+	schedule()
+}
+
+// Parks the current goroutine until goready is called for it
+func gopark(reason string) {
+	curg.waitreason = reason
+	schedule()
+}
+
+func goready(gp *g) {
+	runqput(gp)
+}
+
+func fatal(msg string) {
+	printstring("fatal error: " + msg + "\n")
+	syscall.Syscall(uintptr(SYS_EXIT), 2, uintptr(0), uintptr(0))
+}
+
+// --- channels ---
+// The layout is known by the compiler
+type hchan struct {
+	qcount   int // number of elements in the buffer. Must be the first field for len().
+	dataqsiz int // size of the buffer. Must be the second field for cap().
+	elemsize int
+	buf      uintptr
+	sendx    int
+	recvx    int
+	closed   bool
+	recvq    *waitq
+	sendq    *waitq
+}
+
+type waitq struct {
+	first *sudog
+	last  *sudog
+}
+
+// A goroutine parked on a channel
+type sudog struct {
+	g        *g
+	elem     uintptr // the value to send or where to receive into. 0 for a discarded receive.
+	c        *hchan
+	success  bool // false if woken up by close
+	isSelect bool
+	next     *sudog // in the wait queue
+	waitlink *sudog // the other cases of the select
+}
+
+// The layout is known by the compiler
+type scase struct {
+	c        *hchan
+	elem     uintptr
+	dir      int
+	received bool // false if the channel has been closed
+}
+
+const caseSend int = 1
+const caseRecv int = 2
+
+// The results of chanrecv
+const recvNotReady int = 0
+const recvOK int = 1
+const recvClosed int = 2
+
+var fastrandState uintptr
+
+// make(chan T, size)
+func makechan(elemSize int, size int) *hchan {
+	if size < 0 {
+		panic("makechan: size out of range")
+	}
+	var c *hchan = &hchan{}
+	c.elemsize = elemSize
+	c.dataqsiz = size
+	if size > 0 {
+		c.buf = malloc(uintptr(elemSize * size))
+	}
+	c.recvq = &waitq{}
+	c.sendq = &waitq{}
+	return c
+}
+
+func enqueue(q *waitq, sg *sudog) {
+	sg.next = nil
+	if q.last == nil {
+		q.first = sg
+	} else {
+		q.last.next = sg
+	}
+	q.last = sg
+}
+
+// Takes the first waiter, skipping the cases of a select which has already been chosen
+func dequeue(q *waitq) *sudog {
+	var sg *sudog
+	for q.first != nil {
+		sg = q.first
+		q.first = sg.next
+		if q.first == nil {
+			q.last = nil
+		}
+		sg.next = nil
+		if sg.isSelect {
+			if !sg.g.selectDone {
+				sg.g.selectDone = true
+				sg.g.param = sg
+				return sg
+			}
 		} else {
-			newcap = oldlen * 2
+			sg.g.param = sg
+			return sg
+		}
+	}
+	sg = nil
+	return sg
+}
+
+func removeSudog(q *waitq, sg *sudog) {
+	var prev *sudog
+	var s *sudog
+	for s = q.first; s != nil; s = s.next {
+		if s == sg {
+			if prev == nil {
+				q.first = s.next
+			} else {
+				prev.next = s.next
+			}
+			if q.last == s {
+				q.last = prev
+			}
+			return
+		}
+		prev = s
+	}
+}
+
+func chanbuf(c *hchan, i int) uintptr {
+	return c.buf + uintptr(i*c.elemsize)
+}
+
+// ch <- *elem
+// A non-blocking send returns false if the channel is not ready.
+func chansend(c *hchan, elem uintptr, block bool) bool {
+	if c == nil {
+		if !block {
+			return false
+		}
+		gopark("chan send (nil chan)")
+	}
+	if c.closed {
+		panic("send on closed channel")
+	}
+	var sg *sudog = dequeue(c.recvq)
+	if sg != nil {
+		// hand the value directly to the receiver
+		if sg.elem != 0 {
+			memcopy(elem, sg.elem, c.elemsize)
 		}
-		new_ = makeSlice8(elmSize, newlen, newcap)
-		var oldSize int = oldlen * elmSize
-		if oldlen > 0 {
-			memcopy(uintptr(unsafe.Pointer(&old[0])), uintptr(unsafe.Pointer(&new_[0])), oldSize)
+		sg.success = true
+		goready(sg.g)
+		return true
+	}
+	if c.qcount < c.dataqsiz {
+		memcopy(elem, chanbuf(c, c.sendx), c.elemsize)
+		c.sendx++
+		if c.sendx == c.dataqsiz {
+			c.sendx = 0
 		}
+		c.qcount++
+		return true
 	}
+	if !block {
+		return false
+	}
+	// The value is copied since the receiver reads it later
+	var mysg *sudog = &sudog{}
+	mysg.g = curg
+	mysg.elem = malloc(uintptr(c.elemsize))
+	memcopy(elem, mysg.elem, c.elemsize)
+	mysg.c = c
+	enqueue(c.sendq, mysg)
+	gopark("chan send")
+	if !mysg.success {
+		panic("send on closed channel")
+	}
+	return true
+}
 
-	new_[oldlen] = elm
-	return uintptr(unsafe.Pointer(&new_[0])), newlen, cap(new_)
+// *elem = <-ch
+// The element is zeroed if the channel is closed.
+func chanrecv(c *hchan, elem uintptr, block bool) int {
+	if c == nil {
+		if !block {
+			return recvNotReady
+		}
+		gopark("chan receive (nil chan)")
+	}
+	if c.closed && c.qcount == 0 {
+		if elem != 0 {
+			memzeropad(elem, uintptr(c.elemsize))
+		}
+		return recvClosed
+	}
+	var sg *sudog = dequeue(c.sendq)
+	if sg != nil {
+		if c.dataqsiz == 0 {
+			if elem != 0 {
+				memcopy(sg.elem, elem, c.elemsize)
+			}
+		} else {
+			// The buffer is full. Take the head and put the sender's value at the tail.
+			if elem != 0 {
+				memcopy(chanbuf(c, c.recvx), elem, c.elemsize)
+			}
+			memcopy(sg.elem, chanbuf(c, c.recvx), c.elemsize)
+			c.recvx++
+			if c.recvx == c.dataqsiz {
+				c.recvx = 0
+			}
+			c.sendx = c.recvx
+		}
+		sg.success = true
+		goready(sg.g)
+		return recvOK
+	}
+	if c.qcount > 0 {
+		if elem != 0 {
+			memcopy(chanbuf(c, c.recvx), elem, c.elemsize)
+		}
+		memzeropad(chanbuf(c, c.recvx), uintptr(c.elemsize))
+		c.recvx++
+		if c.recvx == c.dataqsiz {
+			c.recvx = 0
+		}
+		c.qcount--
+		return recvOK
+	}
+	if !block {
+		return recvNotReady
+	}
+	var mysg *sudog = &sudog{}
+	mysg.g = curg
+	mysg.elem = elem
+	mysg.c = c
+	enqueue(c.recvq, mysg)
+	gopark("chan receive")
+	if mysg.success {
+		return recvOK
+	}
+	if elem != 0 {
+		memzeropad(elem, uintptr(c.elemsize))
+	}
+	return recvClosed
 }
 
-func append16(old []string, elm string) (uintptr, int, int) {
-	var new_ []string
-	var elmSize int = 16
+func closechan(c *hchan) {
+	if c == nil {
+		panic("close of nil channel")
+	}
+	if c.closed {
+		panic("close of closed channel")
+	}
+	c.closed = true
+	var sg *sudog
+	for {
+		sg = dequeue(c.recvq)
+		if sg == nil {
+			break
+		}
+		sg.success = false
+		goready(sg.g)
+	}
+	for {
+		sg = dequeue(c.sendq)
+		if sg == nil {
+			break
+		}
+		sg.success = false
+		goready(sg.g)
+	}
+}
 
-	var oldlen int = len(old)
-	var newlen int = oldlen + 1
+func getcase(cases uintptr, i int) *scase {
+	var cas *scase = (*scase)(unsafe.Pointer(cases + uintptr(i*32)))
+	return cas
+}
 
-	if cap(old) >= newlen {
-		new_ = old[0:newlen]
-	} else {
-		var newcap int
-		if oldlen == 0 {
-			newcap = 1
+// Runs one of the n cases which is ready, chosen at random.
+// Returns the index of the case, or -1 if block is false and no case is ready.
+func selectgo(cases uintptr, n int, block bool) int {
+	var start int
+	if n > 0 {
+		fastrandState = fastrandState*1103515245 + 12345
+		start = int((fastrandState / 65536) % uintptr(n))
+	}
+	var i int
+	var j int
+	var cas *scase
+	var r int
+	for j = 0; j < n; j++ {
+		i = (start + j) % n
+		cas = getcase(cases, i)
+		if cas.dir == caseSend {
+			if chansend(cas.c, cas.elem, false) {
+				return i
+			}
 		} else {
-			newcap = oldlen * 2
+			r = chanrecv(cas.c, cas.elem, false)
+			if r != recvNotReady {
+				cas.received = r == recvOK
+				return i
+			}
 		}
-		new_ = makeSlice16(elmSize, newlen, newcap)
-		var oldSize int = oldlen * elmSize
-		if oldlen > 0 {
-			memcopy(uintptr(unsafe.Pointer(&old[0])), uintptr(unsafe.Pointer(&new_[0])), oldSize)
+	}
+	if !block {
+		return -1
+	}
+	if n == 0 {
+		gopark("select (no cases)")
+	}
+
+	// park on every channel
+	var sgs *sudog
+	var sg *sudog
+	curg.selectDone = false
+	for i = 0; i < n; i++ {
+		cas = getcase(cases, i)
+		sg = &sudog{}
+		sg.g = curg
+		sg.elem = cas.elem
+		sg.c = cas.c
+		sg.isSelect = true
+		if cas.c != nil {
+			if cas.dir == caseSend {
+				enqueue(cas.c.sendq, sg)
+			} else {
+				enqueue(cas.c.recvq, sg)
+			}
+		}
+		// the list is in the reverse order of the cases
+		sg.waitlink = sgs
+		sgs = sg
+	}
+	gopark("select")
+
+	var chosen int = -1
+	i = n - 1
+	for sg = sgs; sg != nil; sg = sgs {
+		sgs = sg.waitlink
+		cas = getcase(cases, i)
+		if sg == curg.param {
+			chosen = i
+			if cas.dir == caseSend {
+				if !sg.success {
+					panic("send on closed channel")
+				}
+			} else {
+				cas.received = sg.success
+				if !sg.success && cas.elem != 0 {
+					memzeropad(cas.elem, uintptr(cas.c.elemsize))
+				}
+			}
+		} else if cas.c != nil {
+			if cas.dir == caseSend {
+				removeSudog(cas.c.sendq, sg)
+			} else {
+				removeSudog(cas.c.recvq, sg)
+			}
 		}
+		i--
 	}
+	curg.param = nil
+	return chosen
+}
+
+// --- defer, panic and recover ---
+// A deferred call. The arguments are evaluated and copied at the defer statement.
+type _defer struct {
+	fn    uintptr // func value
+	argp  uintptr
+	siz   int
+	frame uintptr // %rbp of the function which deferred the call
+	pc    uintptr // where the function resumes when a panic is recovered
+	link  *_defer
+}
 
-	new_[oldlen] = elm
-	return uintptr(unsafe.Pointer(&new_[0])), newlen, cap(new_)
+// The layout is known by runtime.s
+type _panic struct {
+	deferFrame uintptr // %rbp of calldefer while running a deferred call. Must be the first field.
+	arg        *eface  // nil for runtime errors
+	msg        string  // message of a runtime error
+	recovered  bool
+	repanicked bool
+	sp         uintptr // stack address of dopanic, to know whether a recovery unwinds the panic
+	link       *_panic
 }
 
-func append24(old [][]int, elm []int) (uintptr, int, int) {
-	var new_ [][]int
-	var elmSize int = 24
+// The layout of interface{}
+type eface struct {
+	typ  *_type
+	data uintptr
+}
+
+// Calls the func value fn with a copy of the arguments.
+// The frame of the call is stored in p.deferFrame unless p is nil.
+func calldefer(fn uintptr, argp uintptr, siz int, p *_panic)
+
+// Resumes the function of frame at pc, discarding the stack below it.
+func recovery(frame uintptr, pc uintptr)
 
-	var oldlen int = len(old)
-	var newlen int = oldlen + 1
+// Calls a method like Error() string
+func callStringMethod(fn uintptr, recv uintptr) string
 
-	if cap(old) >= newlen {
-		new_ = old[0:newlen]
+// Writes to stderr without yielding
+func printstring(s string)
+
+func deferproc(frame uintptr, pc uintptr, fn uintptr, siz int, argp uintptr) {
+	var d *_defer = &_defer{}
+	d.fn = fn
+	d.siz = siz
+	d.argp = malloc(uintptr(siz))
+	memcopy(argp, d.argp, siz)
+	d.frame = frame
+	d.pc = pc
+	d.link = curg.defers
+	curg.defers = d
+}
+
+// Runs the deferred calls of a returning function
+func deferreturn(frame uintptr) {
+	var d *_defer
+	for curg.defers != nil && curg.defers.frame == frame {
+		d = curg.defers
+		curg.defers = d.link
+		calldefer(d.fn, d.argp, d.siz, nil)
+	}
+}
+
+// panic(v)
+func gopanic(typ *_type, data uintptr) {
+	var p *_panic = &_panic{}
+	if typ == nil {
+		p.msg = "panic called with nil argument"
 	} else {
-		var newcap int
-		if oldlen == 0 {
-			newcap = 1
+		p.arg = &eface{}
+		p.arg.typ = typ
+		p.arg.data = data
+	}
+	dopanic(p)
+}
+
+func dopanic(p *_panic) {
+	p.sp = uintptr(unsafe.Pointer(&p))
+	var top *_panic = curg.panics
+	if top != nil && top.recovered && top.arg != nil && p.arg != nil && top.arg.typ == p.arg.typ && top.arg.data == p.arg.data {
+		// panic(recover())
+		p.repanicked = true
+		top = top.link
+	}
+	p.link = top
+	curg.panics = p
+
+	var d *_defer
+	for curg.defers != nil {
+		d = curg.defers
+		curg.defers = d.link
+		calldefer(d.fn, d.argp, d.siz, p)
+		p.deferFrame = 0
+		if p.recovered {
+			// drop panics whose deferred calls are unwound by the recovery
+			for curg.panics != nil && curg.panics.sp < d.frame {
+				curg.panics = curg.panics.link
+			}
+			recovery(d.frame, d.pc)
+		}
+	}
+
+	printpanics(p)
+	printstring("\ngoroutine " + itoa(curg.id) + " [running]:\n")
+	syscall.Syscall(uintptr(SYS_EXIT), 2, uintptr(0), uintptr(0))
+}
+
+// recover() returns nil unless it is called directly by a deferred call of a panic.
+// A runtime error is recovered as a runtime.Error, whose type descriptor is given by the compiler.
+func gorecover(fp uintptr, errorType *_type) *eface {
+	var r *eface = &eface{}
+	var p *_panic = curg.panics
+	if p == nil || p.recovered || p.deferFrame == 0 {
+		return r
+	}
+	var callerFrame *uintptr = (*uintptr)(unsafe.Pointer(fp))
+	if *callerFrame != p.deferFrame {
+		return r
+	}
+	p.recovered = true
+	if p.arg == nil {
+		var s *string = (*string)(unsafe.Pointer(malloc(uintptr(16))))
+		*s = p.msg
+		p.arg = &eface{}
+		p.arg.typ = errorType
+		p.arg.data = uintptr(unsafe.Pointer(s))
+	}
+	return p.arg
+}
+
+// The methods of a recovered runtime error, which holds the message as a string
+func runtimeErrorError(msg *string) string {
+	return *msg
+}
+
+func runtimeErrorRuntimeError(msg *string) {
+}
+
+func printpanics(p *_panic) {
+	if p.link != nil {
+		printpanics(p.link)
+		printstring("\t")
+	}
+	var s string = "panic: " + printpanicval(p)
+	if p.repanicked {
+		s = s + " [recovered, repanicked]"
+	} else if p.recovered {
+		s = s + " [recovered]"
+	}
+	s = s + "\n"
+	printstring(s)
+}
+
+// Formats the panic value as gc does
+func printpanicval(p *_panic) string {
+	if p.arg == nil {
+		if p.msg == "panic called with nil argument" {
+			return "runtime error: " + p.msg
+		}
+		return p.msg
+	}
+	var t *_type = p.arg.typ
+	var data uintptr = p.arg.data
+	// methods are looked up only by name
+	var fn uintptr = findMethod(t, "Error")
+	if fn == 0 {
+		fn = findMethod(t, "String")
+	}
+	if fn != 0 {
+		return callStringMethod(fn, data)
+	}
+
+	var v string
+	if t.kind == kindBool {
+		var bp *bool = (*bool)(unsafe.Pointer(data))
+		if *bp {
+			v = "true"
 		} else {
-			newcap = oldlen * 2
+			v = "false"
+		}
+	} else if t.kind >= kindInt && t.kind <= kindUintptr {
+		v = itoa(readInt(data, t.size, t.kind <= kindInt64))
+	} else if t.kind == kindFloat32 || t.kind == kindFloat64 {
+		v = formatFloat(readInt(data, t.size, false), t.size)
+	} else if t.kind == kindString {
+		var sp *string = (*string)(unsafe.Pointer(data))
+		if !isNamedType(t) {
+			return *sp
+		}
+		v = "\"" + *sp + "\""
+	} else {
+		return "(" + t.name + ") " + hex(data)
+	}
+	if isNamedType(t) {
+		return t.name + "(" + v + ")"
+	}
+	return v
+}
+
+func isNamedType(t *_type) bool {
+	var i int
+	for i = 0; i < len(t.name); i++ {
+		if t.name[i] == '.' {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads an integer of size bytes, sign extended if signed
+func readInt(addr uintptr, size int, signed bool) int {
+	if size == 8 {
+		var ip *int = (*int)(unsafe.Pointer(addr))
+		return *ip
+	}
+	var v int
+	var base int = 1
+	var i int
+	var p *uint8
+	for i = 0; i < size; i++ {
+		p = (*uint8)(unsafe.Pointer(addr + uintptr(i)))
+		v = v + int(*p)*base
+		base = base * 256
+	}
+	if signed && v >= base/2 {
+		v = v - base
+	}
+	return v
+}
+
+func itoa(ival int) string {
+	if ival == 0 {
+		return "0"
+	}
+	var buf []uint8 = make([]uint8, 20, 20)
+	var minus bool = ival < 0
+	if minus {
+		ival = -ival
+	}
+	var i int = 20
+	for ival != 0 {
+		i--
+		buf[i] = uint8('0' + ival%10)
+		ival = ival / 10
+	}
+	if minus {
+		return "-" + string(buf[i:20])
+	}
+	return string(buf[i:20])
+}
+
+func pow2(n int) int {
+	var r int = 1
+	var i int
+	for i = 0; i < n; i++ {
+		r = r * 2
+	}
+	return r
+}
+
+// Formats a float of size bytes like +1.500000e+000, as print does.
+// The bits are decoded with integer arithmetic into exact decimal digits.
+func formatFloat(bits int, size int) string {
+	var fracBits int = 52
+	var expBits int = 11
+	if size == 4 {
+		fracBits = 23
+		expBits = 8
+	}
+	var sign string = "+"
+	var signBit int = pow2(fracBits + expBits) // the minimum int for float64
+	if bits < 0 || (size == 4 && bits >= signBit) {
+		sign = "-"
+		bits = bits - signBit
+	}
+	var fracUnit int = pow2(fracBits)
+	var exp int = bits / fracUnit
+	var frac int = bits - exp*fracUnit
+	if exp == pow2(expBits)-1 {
+		if frac != 0 {
+			return "NaN"
+		}
+		return sign + "Inf"
+	}
+	if exp == 0 && frac == 0 {
+		return sign + "0.000000e+000"
+	}
+
+	// the value is mant * 2^e2
+	var bias int = pow2(expBits-1) - 1
+	var mant int = frac
+	var e2 int = 1 - bias - fracBits
+	if exp > 0 {
+		mant = frac + fracUnit
+		e2 = exp - bias - fracBits
+	}
+
+	// decimal digits, most significant first. The value is 0.d0d1d2... * 10^dp
+	var digits []int = make([]int, 1100, 1100)
+	var nd int = 0
+	var m int
+	for m = mant; m > 0; m = m / 10 {
+		nd++
+	}
+	var i int
+	m = mant
+	for i = nd - 1; i >= 0; i-- {
+		digits[i] = m - m/10*10
+		m = m / 10
+	}
+	var dp int = nd
+	var d int
+	for e2 > 0 {
+		e2--
+		var carry int = 0
+		for i = nd - 1; i >= 0; i-- {
+			d = digits[i]*2 + carry
+			carry = d / 10
+			digits[i] = d - carry*10
+		}
+		if carry > 0 {
+			for i = nd; i > 0; i-- {
+				digits[i] = digits[i-1]
+			}
+			digits[0] = carry
+			nd++
+			dp++
 		}
-		new_ = makeSlice24(elmSize, newlen, newcap)
-		var oldSize int = oldlen * elmSize
-		if oldlen > 0 {
-			memcopy(uintptr(unsafe.Pointer(&old[0])), uintptr(unsafe.Pointer(&new_[0])), oldSize)
+	}
+	for e2 < 0 {
+		e2++
+		var rem int = 0
+		for i = 0; i < nd; i++ {
+			d = rem*10 + digits[i]
+			digits[i] = d / 2
+			rem = d - digits[i]*2
+		}
+		if rem > 0 {
+			digits[nd] = 5
+			nd++
+		}
+		if digits[0] == 0 {
+			for i = 0; i < nd-1; i++ {
+				digits[i] = digits[i+1]
+			}
+			nd--
+			dp--
 		}
 	}
 
-	new_[oldlen] = elm
-	return uintptr(unsafe.Pointer(&new_[0])), newlen, cap(new_)
+	// round half up to 7 digits
+	var n int = 7
+	for i = nd; i <= n; i++ {
+		digits[i] = 0
+	}
+	if digits[n] >= 5 {
+		i = n - 1
+		for i >= 0 {
+			if digits[i] != 9 {
+				break
+			}
+			digits[i] = 0
+			i--
+		}
+		if i < 0 {
+			digits[0] = 1
+			dp++
+		} else {
+			digits[i] = digits[i] + 1
+		}
+	}
+
+	var buf []uint8 = make([]uint8, n+7, n+7)
+	buf[0] = sign[0]
+	buf[1] = uint8('0' + digits[0])
+	buf[2] = '.'
+	for i = 1; i < n; i++ {
+		buf[i+2] = uint8('0' + digits[i])
+	}
+	var e10 int = dp - 1
+	buf[n+2] = 'e'
+	buf[n+3] = '+'
+	if e10 < 0 {
+		buf[n+3] = '-'
+		e10 = -e10
+	}
+	buf[n+4] = uint8('0' + e10/100)
+	buf[n+5] = uint8('0' + e10/10%10)
+	buf[n+6] = uint8('0' + e10%10)
+	return string(buf)
+}
+
+func hex(v uintptr) string {
+	var digits string = "0123456789abcdef"
+	var buf []uint8 = make([]uint8, 16, 16)
+	var i int = 16
+	for {
+		i--
+		buf[i] = digits[int(v%16)]
+		v = v / 16
+		if v == 0 {
+			break
+		}
+	}
+	return "0x" + string(buf[i:16])
 }
 
 func catstrings(a string, b string) string {
@@ -238,3 +1671,129 @@ func cmpstrings(a string, b string) bool {
 	}
 	return true
 }
+
+const runeError int = 65533
+
+// Decode the UTF-8 encoded rune at s[*k] and advance *k past it.
+// An invalid encoding is decoded as runeError, advancing one byte.
+func decoderune(s string, k *int) int {
+	var i int = *k
+	var b0 int = int(s[i])
+	*k = i + 1
+	if b0 < 128 {
+		return b0
+	}
+	var size int
+	var r int
+	var min int
+	if b0 >= 192 && b0 < 224 {
+		size = 2
+		r = b0 - 192
+		min = 128
+	} else if b0 >= 224 && b0 < 240 {
+		size = 3
+		r = b0 - 224
+		min = 2048
+	} else if b0 >= 240 && b0 < 248 {
+		size = 4
+		r = b0 - 240
+		min = 65536
+	} else {
+		return runeError
+	}
+	if i+size > len(s) {
+		return runeError
+	}
+	var j int
+	var b int
+	for j = 1; j < size; j++ {
+		b = int(s[i+j])
+		if b < 128 || b >= 192 {
+			return runeError
+		}
+		r = r*64 + b - 128
+	}
+	if r < min || r > 1114111 || (r >= 55296 && r <= 57343) {
+		return runeError
+	}
+	*k = i + size
+	return r
+}
+
+// Write the UTF-8 encoding of r at buf[i] and return the index after it
+func encoderune(buf []uint8, i int, r int) int {
+	if r < 0 || r > 1114111 || (r >= 55296 && r <= 57343) {
+		r = runeError
+	}
+	if r < 128 {
+		buf[i] = uint8(r)
+		return i + 1
+	}
+	var size int
+	var lead int
+	if r < 2048 {
+		size = 2
+		lead = 192
+	} else if r < 65536 {
+		size = 3
+		lead = 224
+	} else {
+		size = 4
+		lead = 240
+	}
+	var j int
+	for j = size - 1; j > 0; j = j - 1 {
+		buf[i+j] = uint8(128 + r%64)
+		r = r / 64
+	}
+	buf[i] = uint8(lead + r)
+	return i + size
+}
+
+// string(r)
+func intstring(v int) string {
+	var buf []uint8 = make([]uint8, 4, 4)
+	var n int = encoderune(buf, 0, v)
+	return string(buf[0:n])
+}
+
+// []rune(s). Each rune is stored as 4 bytes in little endian.
+func stringtoslicerune(s string) (uintptr, int, int) {
+	var n int
+	var k int
+	for k < len(s) {
+		decoderune(s, &k)
+		n++
+	}
+	var addr uintptr = malloc(uintptr(n * 4))
+	var i int
+	var j int
+	var r int
+	var p *uint8
+	k = 0
+	for i = 0; i < n; i++ {
+		r = decoderune(s, &k)
+		for j = 0; j < 4; j++ {
+			p = (*uint8)(unsafe.Pointer(addr + uintptr(i*4+j)))
+			*p = uint8(r % 256)
+			r = r / 256
+		}
+	}
+	return addr, n, n
+}
+
+// string(runes). The elements of runes are actually int32.
+func slicerunetostring(runes []int) string {
+	var n int = len(runes)
+	if n == 0 {
+		return ""
+	}
+	var addr uintptr = uintptr(unsafe.Pointer(&runes[0]))
+	var buf []uint8 = make([]uint8, n*4, n*4)
+	var i int
+	var j int
+	for i = 0; i < n; i++ {
+		j = encoderune(buf, j, readInt(addr+uintptr(i*4), 4, true))
+	}
+	return string(buf[0:j])
+}
@@ -22,7 +22,11 @@ runtime.rt0_go:
   movq %rax, __argv__+16(%rip) # cap
 
   callq runtime.heapInit
+  callq runtime.siginit
   callq runtime.argsInit # this must be after heap init
+  callq runtime.schedinit
+  callq runtime.init # package initializers
+  callq main.init
   callq main.main
 
   movq $0, %rdi  # status 0
@@ -30,11 +34,112 @@ runtime.rt0_go:
   syscall
 # End of program
 
+// Install the SIGSEGV handler
+runtime.siginit:
+  subq $32, %rsp # struct sigaction
+  leaq runtime.sigsegv(%rip), %rax
+  movq %rax, 0(%rsp)        # sa_handler
+  movq $0x04000004, 8(%rsp) # sa_flags: SA_RESTORER|SA_SIGINFO
+  leaq runtime.sigreturn(%rip), %rax
+  movq %rax, 16(%rsp)       # sa_restorer
+  movq $0, 24(%rsp)         # sa_mask
+  movq $11, %rdi            # SIGSEGV
+  movq %rsp, %rsi           # act
+  movq $0, %rdx             # oldact
+  movq $8, %r10             # sizeof(sa_mask)
+  movq $13, %rax            # sys_rt_sigaction
+  syscall
+  addq $32, %rsp
+  ret
+
+// The handler makes the faulting function look like it has called runtime.panicmem,
+// so that the panic unwinds the stack as usual.
+// rdi: signo, rsi: siginfo, rdx: ucontext
+runtime.sigsegv:
+  movq 160(%rdx), %rax # uc_mcontext.rsp
+  subq $8, %rax
+  movq 168(%rdx), %rcx # uc_mcontext.rip
+  movq %rcx, 0(%rax)   # return address
+  movq %rax, 160(%rdx)
+  leaq runtime.panicmem(%rip), %rcx
+  movq %rcx, 168(%rdx)
+  ret # to runtime.sigreturn, which resumes in runtime.panicmem
+
+runtime.sigreturn:
+  movq $15, %rax # sys_rt_sigreturn
+  syscall
+
 os.Exit:
   movq  8(%rsp), %rdi # arg0:status
   movq $60, %rax      # sys_exit
   syscall
 
+// func swtch(from *g, to *g)
+runtime.swtch:
+  movq  8(%rsp), %rax # from
+  movq 16(%rsp), %rcx # to
+  pushq %rbp
+  movq %rsp, 0(%rax) # from.sp
+  movq 0(%rcx), %rsp # to.sp
+  popq %rbp
+  ret
+
+// func goentryPC() uintptr
+runtime.goentryPC:
+  leaq runtime.goentry(%rip), %rax
+  ret
+
+// A new goroutine starts here with its fn and args on the stack
+runtime.goentry:
+  popq %rdx # fn (closure)
+  callq *0(%rdx)
+  callq runtime.goexit
+
+// func mmap(size uintptr) uintptr
+runtime.mmap:
+  movq $0, %rdi       # addr
+  movq 8(%rsp), %rsi  # length
+  movq $3, %rdx       # PROT_READ|PROT_WRITE
+  movq $0x22, %r10    # MAP_PRIVATE|MAP_ANONYMOUS
+  movq $-1, %r8       # fd
+  movq $0, %r9        # offset
+  movq $9, %rax       # sys_mmap
+  syscall
+  ret
+
+// func calldefer(fn uintptr, argp uintptr, siz int, p *_panic)
+runtime.calldefer:
+  pushq %rbp
+  movq %rsp, %rbp
+  movq 40(%rbp), %rax # p
+  cmpq $0, %rax
+  je runtime.calldefer.copy
+  movq %rbp, 0(%rax) # p.deferFrame
+runtime.calldefer.copy:
+  movq 32(%rbp), %rcx # siz
+  subq %rcx, %rsp
+  movq %rsp, %rdi     # dst
+  movq 24(%rbp), %rsi # argp
+  cld
+  rep movsb
+  movq 16(%rbp), %rdx # fn (closure)
+  callq *0(%rdx)
+  leave
+  ret
+
+// func recovery(frame uintptr, pc uintptr)
+runtime.recovery:
+  movq  8(%rsp), %rbp # frame
+  movq 16(%rsp), %rax # pc
+  jmp *%rax
+
+// func callStringMethod(fn uintptr, recv uintptr) string
+runtime.callStringMethod:
+  pushq 16(%rsp) # recv
+  callq *16(%rsp) # fn
+  addq $8, %rsp
+  ret
+
 runtime.printstring:
   movq  8(%rsp), %rdi # arg0:ptr
   movq 16(%rsp), %rsi # arg1:len
@@ -63,6 +168,7 @@ syscall.Open:
 
 // func Read(fd int, p []byte) (n int)
 syscall.Read:
+  callq runtime.Gosched # yield before blocking
   movq  8(%rsp), %rax # arg0:fd
   movq 16(%rsp), %rdi # arg1:ptr
   movq 24(%rsp), %rsi # arg1:len (ignored)
@@ -77,6 +183,7 @@ syscall.Read:
 
 // func Write(fd int, p []byte) int
 syscall.Write:
+  callq runtime.Gosched # yield before blocking
   movq  8(%rsp), %rax # arg0:fd
   movq 16(%rsp), %rdi # arg1:ptr
   movq 24(%rsp), %rsi # arg2:len
@@ -0,0 +1,2806 @@
+package main
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// Tests of language features which the precompiler does not support.
+
+// --- utils ---
+func write(s string) {
+	var slc []uint8 = []uint8(s)
+	syscall.Write(1, slc)
+}
+
+func writeln(s string) {
+	var s2 string = s + "\n"
+	write(s2)
+}
+
+func itoa(ival int) string {
+	if ival == 0 {
+		return "0"
+	}
+
+	var buf []uint8 = make([]uint8, 100, 100)
+	var r []uint8 = make([]uint8, 100, 100)
+
+	var next int
+	var right int
+	var ix int = 0
+	var minus bool
+	minus = false
+	for ix = 0; ival != 0; ix = ix + 1 {
+		if ival < 0 {
+			ival = -1 * ival
+			minus = true
+			r[0] = '-'
+		} else {
+			next = ival / 10
+			right = ival - next*10
+			ival = next
+			buf[ix] = uint8('0' + right)
+		}
+	}
+
+	var j int
+	var c uint8
+	for j = 0; j < ix; j = j + 1 {
+		c = buf[ix-j-1]
+		if minus {
+			r[j+1] = c
+		} else {
+			r[j] = c
+		}
+	}
+
+	return string(r[0:ix])
+}
+
+func btoa(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// --- test funcs ---
+type Shape interface {
+	Area() int
+	Name() string
+}
+
+type Named interface {
+	Name() string
+}
+
+type Rect struct {
+	w int
+	h int
+}
+
+func (r Rect) Area() int {
+	return r.w * r.h
+}
+
+func (r Rect) Name() string {
+	return "rect"
+}
+
+type Square struct {
+	side int
+}
+
+func (s *Square) Area() int {
+	return s.side * s.side
+}
+
+func (s *Square) Name() string {
+	return "square"
+}
+
+func (s *Square) Grow(n int) {
+	s.side = s.side + n
+}
+
+type MyError struct {
+	msg string
+}
+
+func (e *MyError) Error() string {
+	return "MyError: " + e.msg
+}
+
+func findError(fail bool) error {
+	if fail {
+		return &MyError{msg: "failed"}
+	}
+	return nil
+}
+
+func describe(x interface{}) string {
+	_, ok := x.(int)
+	if ok {
+		return "int " + itoa(x.(int))
+	}
+	s, ok := x.(string)
+	if ok {
+		return "string " + s
+	}
+	r, ok := x.(Rect)
+	if ok {
+		return "Rect " + itoa(r.w) + "x" + itoa(r.h)
+	}
+	sh, ok := x.(Shape)
+	if ok {
+		return "Shape " + sh.Name()
+	}
+	if x == nil {
+		return "nil"
+	}
+	return "unknown"
+}
+
+func totalArea(shapes []Shape) int {
+	var total int
+	for _, sh := range shapes {
+		total = total + sh.Area()
+	}
+	return total
+}
+
+func makeRect(w int, h int) Rect {
+	return Rect{w: w, h: h}
+}
+
+func rectArea(r Rect) int {
+	return r.w * r.h
+}
+
+// formats f with 6 decimal places
+func ftoa(f float64) string {
+	if f != f {
+		return "NaN"
+	}
+	var sign string
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+	if f > 1e18 {
+		return sign + "Inf"
+	}
+	var ip = int(f)
+	var frac = int((f-float64(ip))*1000000 + 0.5)
+	if frac >= 1000000 {
+		ip = ip + 1
+		frac = frac - 1000000
+	}
+	var fs = itoa(frac)
+	for len(fs) < 6 {
+		fs = "0" + fs
+	}
+	return sign + itoa(ip) + "." + fs
+}
+
+type Vec struct {
+	x float64
+	y float32
+}
+
+func (v Vec) Scale(k float64) Vec {
+	return Vec{x: v.x * k, y: v.y * float32(k)}
+}
+
+func average(xs []float64) float64 {
+	var total float64
+	for _, x := range xs {
+		total += x
+	}
+	return total / float64(len(xs))
+}
+
+func half32(f float32) float32 {
+	return f / 2
+}
+
+type Celsius2 float64
+
+const pi = 3.14159265358979
+const tau = 2 * pi
+const quarter float32 = 1.0 / 4
+const hexFloat = 0x1.8p3
+const million = 1e6
+
+var globalFloat = 2.5
+var globalFloat32 float32 = 0.1
+
+const integralFloat = 4.0
+const halfFloat = 1.5
+
+var globalIntFromFloat int = 2.0
+var globalFloatArray [integralFloat]int
+
+const 漢字 = "漢字"
+
+/* A block comment
+   on multiple lines */
+const rawConst = `raw\n` + "cooked\n"
+
+func rawMultiLine() string {
+	return `line1
+	"line2"\t
+\x41`
+}
+
+type EmbBase struct {
+	id   int
+	name string
+}
+
+func (b EmbBase) Name() string {
+	return b.name
+}
+
+func (b *EmbBase) SetName(name string) {
+	b.name = name
+}
+
+type EmbLogger struct {
+	prefix string
+}
+
+func (l *EmbLogger) Log(s string) {
+	writeln(l.prefix + s)
+}
+
+type EmbDerived struct {
+	EmbBase
+	*EmbLogger
+	extra int
+}
+
+func (d *EmbDerived) Describe() string {
+	return d.Name() + "/" + itoa(d.id)
+}
+
+type EmbDeeper struct {
+	EmbDerived
+	id int `tag:"shadows EmbBase.id"`
+}
+
+type EmbNamer interface {
+	Name() string
+}
+
+type EmbRenamer interface {
+	EmbNamer
+	SetName(name string)
+}
+
+type EmbLog interface {
+	Log(s string)
+}
+
+type EmbWrapper struct {
+	EmbNamer
+	n int
+}
+
+func newEmbDerived() EmbDerived {
+	return EmbDerived{EmbBase: EmbBase{id: 2, name: "two"}, EmbLogger: &EmbLogger{prefix: "# "}}
+}
+
+type EqPoint struct {
+	x int
+	y int
+}
+
+type EqPerson struct {
+	name  string
+	age   uint8
+	point EqPoint
+	tags  [2]string
+}
+
+type EqFloats struct {
+	f float64
+	b bool
+}
+
+type EqBoxed struct {
+	v interface{}
+	n int
+}
+
+func newEqPerson(name string, age uint8) EqPerson {
+	return EqPerson{name: name, age: age, point: EqPoint{x: 1, y: 2}, tags: [2]string{"a", "b"}}
+}
+
+type AppItem struct {
+	id    int
+	name  string
+	flags [3]uint8
+	score int
+}
+
+func appSum(prefix string, nums ...int) string {
+	var total int
+	for _, n := range nums {
+		total = total + n
+	}
+	return prefix + itoa(len(nums)) + ":" + itoa(total)
+}
+
+func testAppend() {
+	var items []AppItem
+	for i := 0; i < 5; i++ {
+		items = append(items, AppItem{id: i, name: "item" + itoa(i), flags: [3]uint8{1, 2, uint8(i)}, score: i * 10})
+	}
+	for _, it := range items {
+		writeln(itoa(it.id) + " " + it.name + " " + itoa(int(it.flags[2])) + " " + itoa(it.score))
+	}
+
+	var nums = []int{1}
+	nums = append(nums, 2, 3, 4)
+	nums = append(nums)
+	var more = []int{5, 6}
+	nums = append(nums, more...)
+	nums = append(nums, nums...)
+	writeln(itoa(len(nums)) + " " + itoa(nums[3]) + " " + itoa(nums[6]) + " " + itoa(nums[11]))
+
+	var buf []byte
+	buf = append(buf, 'h', 'i')
+	buf = append(buf, " there"...)
+	writeln(string(buf))
+
+	var ifcs []interface{}
+	ifcs = append(ifcs, 1, "two", AppItem{id: 3})
+	for _, x := range ifcs {
+		switch v := x.(type) {
+		case int:
+			writeln("int " + itoa(v))
+		case string:
+			writeln("string " + v)
+		case AppItem:
+			writeln("AppItem " + itoa(v.id))
+		}
+	}
+
+	// copy returns the number of the copied elements
+	var dst = make([]int, 3, 3)
+	var n = copy(dst, nums)
+	writeln(itoa(n) + " " + itoa(dst[0]) + " " + itoa(dst[2]))
+	var big = make([]int, 10, 10)
+	n = copy(big, more)
+	writeln(itoa(n) + " " + itoa(big[1]) + " " + itoa(big[2]))
+	var bytes = make([]byte, 5, 5)
+	n = copy(bytes, "abcdefg")
+	writeln(itoa(n) + " " + string(bytes))
+
+	// overlapping copy
+	var ov = []int{1, 2, 3, 4, 5}
+	copy(ov[1:5], ov[0:4])
+	writeln(itoa(ov[0]) + itoa(ov[1]) + itoa(ov[2]) + itoa(ov[3]) + itoa(ov[4]))
+	ov = []int{1, 2, 3, 4, 5}
+	copy(ov[0:4], ov[1:5])
+	writeln(itoa(ov[0]) + itoa(ov[1]) + itoa(ov[2]) + itoa(ov[3]) + itoa(ov[4]))
+
+	var itemsCopy = make([]AppItem, 2, 2)
+	copy(itemsCopy, items[3:5])
+	writeln(itemsCopy[0].name + " " + itemsCopy[1].name)
+
+	// variadic spreading
+	writeln(appSum("sum", 1, 2, 3))
+	writeln(appSum("sum", more...))
+	writeln(appSum("sum"))
+}
+
+// Runtime errors are recovered as runtime.Error values
+func runtimeErrorMessage(r interface{}) string {
+	if e, ok := r.(runtime.Error); ok {
+		return e.Error()
+	}
+	return "not a runtime error"
+}
+
+func describeRecovered(r interface{}) string {
+	var e runtime.Error
+	switch v := r.(type) {
+	case runtime.Error:
+		e = v
+	case error:
+		return "error " + v.Error()
+	default:
+		return "not an error"
+	}
+	var err error = e
+	return "runtime error " + err.Error()
+}
+
+func recoverIndex(s []int, i int) {
+	defer func() {
+		var r = recover()
+		if r != nil {
+			writeln("recovered from index " + itoa(i) + ": " + runtimeErrorMessage(r))
+		}
+	}()
+	writeln(itoa(s[i]))
+}
+
+func recoverNilPointer(p *EqPoint) {
+	defer func() {
+		var r = recover()
+		if r != nil {
+			writeln("recovered from a nil pointer: " + describeRecovered(r))
+		}
+	}()
+	writeln(itoa(p.x))
+}
+
+type LitPoint struct {
+	x int
+	y int
+}
+
+type LitLine struct {
+	from LitPoint
+	to   LitPoint
+}
+
+type LitNames [3]string
+
+var namedArr = LitNames{"a", "b"}
+
+type LitPoints []LitPoint
+
+func testCompositeLit() {
+	// anonymous structs
+	var anon = struct {
+		name string
+		age  int
+	}{"gopher", 10}
+	anon.age = anon.age + 1
+	writeln(anon.name + " " + itoa(anon.age))
+	var pt = &struct {
+		x int
+		y int
+	}{y: 2}
+	writeln(itoa(pt.x) + " " + itoa(pt.y))
+	var table = []struct {
+		in  string
+		out int
+	}{
+		{"one", 1},
+		{in: "two", out: 2},
+	}
+	var i int
+	for i = 0; i < len(table); i++ {
+		writeln(table[i].in + "=" + itoa(table[i].out))
+	}
+
+	// elided element types
+	var points = []LitPoint{{1, 2}, {x: 3}, LitPoint{5, 6}}
+	writeln(itoa(len(points)) + " " + itoa(points[0].y) + " " + itoa(points[1].x) + " " + itoa(points[2].x))
+	var ptrs = []*LitPoint{{7, 8}, {y: 9}, nil}
+	ptrs[0].x = ptrs[0].x * 10
+	writeln(itoa(ptrs[0].x) + " " + itoa(ptrs[1].y))
+	if ptrs[2] == nil {
+		writeln("nil element")
+	}
+	var lines = [2]LitLine{{LitPoint{1, 1}, LitPoint{2, 2}}, {to: LitPoint{3, 3}}}
+	writeln(itoa(lines[0].to.y) + " " + itoa(lines[1].from.x) + " " + itoa(lines[1].to.x))
+	var grid = [][]int{{1, 2}, {3}, {}}
+	writeln(itoa(len(grid)) + " " + itoa(len(grid[1])) + " " + itoa(grid[0][1]))
+	var byName = map[string]LitPoint{"a": {1, 2}, "b": {y: 3}}
+	writeln(itoa(byName["a"].x) + " " + itoa(byName["b"].y))
+	if len([]LitPoint{{1, 2}, {3, 4}}) == 2 && byName["a"] == (LitPoint{1, 2}) {
+		writeln("literals in a condition")
+	}
+
+	// keyed elements and [...]T
+	var letters = [...]string{2: "b", 4: "d", "e"}
+	writeln(itoa(len(letters)) + " [" + letters[0] + "] " + letters[2] + letters[4] + letters[5])
+	var primes = [...]int{2, 3, 5, 7}
+	writeln(itoa(len(primes)) + " " + itoa(primes[3]))
+	var sparse = []int{5: 50, 1: 10, 20}
+	writeln(itoa(len(sparse)) + " " + itoa(sparse[1]) + " " + itoa(sparse[2]) + " " + itoa(sparse[5]))
+	var pa = &[...]int{1, 2, 3}
+	writeln(itoa(len(*pa)) + " " + itoa((*pa)[2]))
+
+	// named array and slice types
+	var names = LitNames{"x", "y", "z"}
+	writeln(itoa(len(names)) + " " + names[1])
+	var named = LitNames{1: "only"}
+	writeln("[" + named[0] + "] " + named[1])
+	var lp = LitPoints{{1, 1}, {2, 4}}
+	lp = append(lp, LitPoint{3, 9})
+	writeln(itoa(len(lp)) + " " + itoa(lp[2].y))
+	var list = IntList{1, 2, 3}
+	writeln(itoa(list.Sum()))
+}
+
+type IntList []int
+
+func (l IntList) Sum() int {
+	var sum int
+	var x int
+	for _, x = range l {
+		sum = sum + x
+	}
+	return sum
+}
+
+func (l *IntList) Push(x int) {
+	*l = append(*l, x)
+}
+
+type Transform func(int) int
+
+func (f Transform) Twice(x int) int {
+	return f(f(x))
+}
+
+type Summer interface {
+	Sum() int
+}
+
+type Twicer interface {
+	Twice(x int) int
+}
+
+func applyInt(f func(int) int, x int) int {
+	return f(x)
+}
+
+func testMethodValues() {
+	var r = Rect{w: 2, h: 3}
+	var area = r.Area
+	r.w = 10
+	writeln(itoa(area()) + " " + itoa(r.Area()))
+
+	var sq = &Square{side: 2}
+	var grow = sq.Grow
+	var sqArea = sq.Area
+	grow(3)
+	writeln(itoa(sqArea()))
+
+	var sq2 Square
+	var grow2 = sq2.Grow // &sq2
+	grow2(4)
+	writeln(itoa(sq2.side))
+
+	var pr = &Pair{key: "k", value: 1}
+	var split = pr.Split // *pr is copied
+	var bump = pr.Bump
+	old, now := bump(5)
+	k, v := split()
+	writeln(itoa(old) + " " + itoa(now) + " " + k + " " + itoa(v))
+
+	var shape Shape = sq
+	var name = shape.Name
+	var shapeArea = shape.Area
+	sq.Grow(1)
+	writeln(name() + " " + itoa(shapeArea()))
+
+	var rectArea = Rect.Area
+	writeln(itoa(rectArea(Rect{w: 4, h: 5})) + " " + itoa(Rect.Area(r)))
+	var squareGrow = (*Square).Grow
+	squareGrow(sq, 10)
+	writeln(itoa((*Square).Area(sq)))
+	var rectName = (*Rect).Name
+	writeln(rectName(&r))
+	k2, v2 := Pair.Split(*pr)
+	writeln(k2 + itoa(v2))
+
+	var l IntList
+	l.Push(1)
+	var push = l.Push
+	push(2)
+	push(3)
+	var lsum = l.Sum
+	writeln(itoa(l.Sum()) + " " + itoa(IntList.Sum(l)) + " " + itoa(len(l)))
+	l.Push(4)
+	writeln(itoa(lsum()))
+	var summer Summer = l
+	writeln(itoa(summer.Sum()))
+
+	var double Transform = func(x int) int { return x * 2 }
+	writeln(itoa(double.Twice(3)) + " " + itoa(applyInt(double.Twice, 5)))
+	var twicer Twicer = double
+	writeln(itoa(twicer.Twice(1)) + " " + itoa(Transform.Twice(double, 4)))
+
+	// method expressions of interfaces
+	var shapeName = Shape.Name
+	writeln(shapeName(shape) + " " + itoa(Shape.Area(Rect{w: 2, h: 3})) + " " + itoa(Twicer.Twice(twicer, 6)))
+	var splitter = Splitter.Split
+	k3, v3 := splitter(*pr)
+	writeln(k3 + itoa(v3) + " " + describeQR(divmod(Summer.Sum(l), 4)))
+	var renamer EmbRenamer = &EmbBase{name: "base"}
+	var setName = EmbRenamer.SetName
+	setName(renamer, "renamed")
+	writeln(EmbRenamer.Name(renamer) + " " + EmbNamer.Name(renamer))
+
+	// method expressions of promoted methods
+	var derived = newEmbDerived()
+	var derivedName = EmbDerived.Name
+	var derivedSet = (*EmbDerived).SetName
+	derivedSet(&derived, "set")
+	EmbDerived.Log(derived, "logged")
+	var deeper = EmbDeeper{EmbDerived: derived}
+	var deeperName = (*EmbDeeper).Name
+	var wrapperName = EmbWrapper.Name
+	writeln(derivedName(derived) + " " + deeperName(&deeper) + " " + wrapperName(EmbWrapper{EmbNamer: derived}) + " " + (*EmbDerived).Describe(&derived))
+	func() {
+		defer func() {
+			writeln(runtimeErrorMessage(recover()))
+		}()
+		var nilShape Shape
+		writeln(shapeName(nilShape))
+	}()
+
+	var methods []func() int
+	methods = append(methods, r.Area, sq.Area, l.Sum)
+	var m func() int
+	for _, m = range methods {
+		writeln(itoa(m()))
+	}
+	defer writeln("deferred " + itoa(r.Area()))
+	var deferred = r.Area
+	defer func() {
+		writeln("deferred method value " + itoa(deferred()))
+	}()
+}
+
+var initLog string
+
+func logInit(s string) int {
+	initLog = initLog + s + " "
+	return len(initLog)
+}
+
+var initFirst = initSecond + logInit("first")
+var initSecond = logInit("second")
+var initThird = readFirst()
+
+func readFirst() int {
+	logInit("third")
+	return initFirst * 10
+}
+
+func init() {
+	logInit("init1")
+}
+
+type InitCounter struct {
+	n int
+}
+
+func (c *InitCounter) next() int {
+	c.n = c.n + globalStep
+	return c.n
+}
+
+var initCounter = &InitCounter{n: 100}
+var initCounted = initCounter.next()
+var globalStep = logInit("step")
+
+var globalPoint = EqPoint{x: 3, y: 4}
+var globalPoints = []EqPoint{EqPoint{x: 1}, EqPoint{y: 2}}
+var globalStrings = [3]string{"x", "y", "z"}
+var globalTable = map[string]int{"one": 1, "two": 2}
+var globalDouble = func(x int) int { return x * 2 }
+var globalAny interface{} = 42
+var globalSum = globalTable["one"] + globalTable["two"] + globalDouble(globalPoint.y)
+
+// a method declared after its use in a global value
+var initScaled = InitScale{n: 21}.double()
+
+type InitScale struct {
+	n int
+}
+
+func (s InitScale) double() int {
+	return s.n * 2
+}
+
+func init() {
+	logInit("init2")
+	globalStrings[0] = "w"
+}
+
+func testGlobalInit() {
+	writeln(initLog)
+	writeln(itoa(initFirst) + " " + itoa(initSecond) + " " + itoa(initThird))
+	writeln(itoa(initCounted) + " " + itoa(globalStep))
+	writeln(itoa(globalPoint.x) + " " + itoa(len(globalPoints)) + " " + itoa(globalPoints[1].y))
+	writeln(globalStrings[0] + globalStrings[1] + globalStrings[2])
+	writeln(itoa(globalSum) + " " + itoa(initScaled))
+	writeln(itoa(globalAny.(int)))
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+func twoValues() (int, string) {
+	return 2, "two"
+}
+
+func testSwitchInit() {
+	writeln(itoa(sign(-5)) + " " + itoa(sign(0)) + " " + itoa(sign(7)))
+
+	var fns []func() int
+	for i := 0; i < 3; i++ {
+		i := i * 10
+		fns = append(fns, func() int { return i })
+	}
+	var fn func() int
+	for _, fn = range fns {
+		writeln(itoa(fn()))
+	}
+
+	var n = 100
+	if n, str := twoValues(); n == 2 {
+		writeln(str + " " + itoa(n))
+	} else if n == 3 {
+		writeln("not reached")
+	} else {
+		writeln(str)
+	}
+	writeln(itoa(n))
+
+	if x := sign(n); x < 0 {
+		writeln("not reached")
+	} else if y := x + 1; y == 2 {
+		writeln("x=" + itoa(x) + " y=" + itoa(y))
+	}
+
+	switch n, _ := twoValues(); n {
+	case 1:
+		writeln("not reached")
+	case 2:
+		writeln("n is 2")
+	}
+
+	switch x := sign(-n); {
+	case x > 0:
+		writeln("not reached")
+	case x < 0:
+		writeln("negative")
+		fallthrough
+	default:
+		writeln("default")
+	}
+
+	switch {
+	}
+
+	var v interface{} = "str"
+	switch n := 5; s := v.(type) {
+	case string:
+		writeln(s + " " + itoa(n))
+	}
+
+	for i := 0; i < 1000000; i++ {
+		switch i {
+		case 1, 2:
+		default:
+		}
+		switch sign(i) {
+		case 0:
+		}
+	}
+	writeln(itoa(n))
+}
+
+func labelSwitch(x int) string {
+	var s string
+	switch x {
+	case 1:
+		s = s + "one "
+		fallthrough
+	case 2:
+		s = s + "two "
+		if x == 2 {
+			break
+		}
+		fallthrough
+	case 3:
+		s = s + "three"
+	default:
+		s = s + "other"
+	}
+	return s
+}
+
+func testLabels() {
+	var i int
+	var j int
+outer:
+	for i = 0; i < 3; i++ {
+		for j = 0; j < 3; j++ {
+			if j == 1 {
+				continue outer
+			}
+			if i == 2 {
+				break outer
+			}
+			writeln(itoa(i*10 + j))
+		}
+	}
+	writeln(itoa(i))
+
+	var words = []string{"a", "b", "stop", "c"}
+loop:
+	for _, w := range words {
+		switch w {
+		case "stop":
+			break loop
+		case "b":
+			continue loop
+		}
+		writeln(w)
+	}
+
+	var ch = make(chan int, 1)
+	ch <- 7
+	for {
+		select {
+		case v := <-ch:
+			writeln(itoa(v))
+			if v == 7 {
+				break
+			}
+			writeln("not reached")
+		}
+		break
+	}
+
+	var n = 0
+again:
+	n++
+	if n < 5 {
+		goto again
+	}
+	writeln(itoa(n))
+	if n == 5 {
+		goto done
+	}
+	writeln("not reached")
+done:
+	writeln("done")
+
+	writeln(labelSwitch(1))
+	writeln(labelSwitch(2))
+	writeln(labelSwitch(3))
+	writeln(labelSwitch(4))
+
+	var x interface{} = 3
+	switch x.(type) {
+	case int:
+		if n > 0 {
+			break
+		}
+		writeln("not reached")
+	}
+	func() {
+		var k int
+	inner:
+		for k = 0; k < 10; k++ {
+			if k == 2 {
+				break inner
+			}
+		}
+		writeln(itoa(k))
+	}()
+}
+
+func testRuntimeChecks() {
+	var s = []int{1, 2, 3}
+	recoverIndex(s, 2)
+	recoverIndex(s, 3)
+	recoverIndex(s, -1)
+	recoverIndex(s[:1], 1)
+
+	var str = "abc"
+	var i = 3
+	func() {
+		defer func() {
+			if recover() != nil {
+				writeln("recovered from string index")
+			}
+		}()
+		writeln(itoa(int(str[i])))
+	}()
+
+	var arr [2]string
+	func() {
+		defer func() {
+			if recover() != nil {
+				writeln("recovered from array index")
+			}
+		}()
+		arr[i] = "x"
+	}()
+
+	recoverNilPointer(&EqPoint{x: 5})
+	recoverNilPointer(nil)
+	var fn func() int
+	func() {
+		defer func() {
+			if recover() != nil {
+				writeln("recovered from a nil func")
+			}
+		}()
+		writeln(itoa(fn()))
+	}()
+	writeln("after recovery")
+	func() {
+		defer func() {
+			writeln(describeRecovered(recover()))
+		}()
+		panic(findError(true))
+	}()
+
+	var minInt = -1 << 63
+	var minusOne = -1
+	if minInt/minusOne == minInt && minInt%minusOne == 0 {
+		writeln("most negative / -1")
+	}
+	writeln(itoa(7/minusOne) + " " + itoa(-7%minusOne))
+	var min8 int8 = -128
+	var minus8 int8 = -1
+	writeln(itoa(int(min8/minus8)) + " " + itoa(int(min8%minus8)))
+	recoverDivide(7, 2)
+	recoverDivide(7, 0)
+	recoverDivide(minInt+1, 0)
+	recoverShift(3, 2)
+	recoverShift(3, 70)
+	recoverShift(3, -5)
+	var count8 int8 = -1
+	var ucount uint = 2
+	func() {
+		defer func() {
+			writeln(runtimeErrorMessage(recover()))
+		}()
+		writeln(itoa(12>>ucount) + " " + itoa(-12>>ucount))
+		writeln(itoa(1 >> count8))
+	}()
+
+	var np *[3]int
+	func() {
+		defer func() {
+			writeln("recovered from slicing a nil array pointer: " + runtimeErrorMessage(recover()))
+		}()
+		var s = np[0:2]
+		writeln(itoa(len(s)))
+	}()
+	var count int
+	for range np {
+		count++
+	}
+	writeln(itoa(len(np)) + " " + itoa(count))
+	func() {
+		defer func() {
+			writeln("recovered from indexing a nil array pointer: " + runtimeErrorMessage(recover()))
+		}()
+		writeln(itoa(np[1]))
+	}()
+	var nilMap map[string]int
+	func() {
+		defer func() {
+			writeln(runtimeErrorMessage(recover()))
+		}()
+		nilMap["x"] = 1
+	}()
+	var any interface{} = "str"
+	func() {
+		defer func() {
+			writeln(runtimeErrorMessage(recover()))
+		}()
+		writeln(itoa(any.(int)))
+	}()
+	func() {
+		defer func() {
+			writeln(runtimeErrorMessage(recover()))
+		}()
+		panic("not from the runtime")
+	}()
+}
+
+func recoverDivide(a int, b int) {
+	defer func() {
+		if err, ok := recover().(error); ok {
+			writeln("recovered from " + itoa(a) + " / 0: " + err.Error())
+		}
+	}()
+	writeln(itoa(a/b) + " " + itoa(a%b))
+}
+
+func recoverShift(x int, m int) {
+	defer func() {
+		if err, ok := recover().(error); ok {
+			writeln("recovered from shifting by " + itoa(m) + ": " + err.Error())
+		}
+	}()
+	writeln(itoa(x<<m) + " " + itoa(x>>m) + " " + itoa(-x>>m))
+}
+
+func sliceInfo(s []int) string {
+	var r = itoa(len(s)) + "/" + itoa(cap(s)) + ":"
+	for _, v := range s {
+		r = r + " " + itoa(v)
+	}
+	return r
+}
+
+func recoverSliceBounds(s []int, lo int, hi int) {
+	defer func() {
+		var r = recover()
+		if r != nil {
+			writeln("recovered from slice bounds " + itoa(lo) + ":" + itoa(hi) + ": " + runtimeErrorMessage(r))
+		}
+	}()
+	writeln(sliceInfo(s[lo:hi]))
+}
+
+func testSlice() {
+	var s = make([]int, 5, 8)
+	for i := 0; i < 5; i++ {
+		s[i] = i * 10
+	}
+	writeln(sliceInfo(s[1:3]))
+	writeln(sliceInfo(s[2:]))
+	writeln(sliceInfo(s[:2]))
+	writeln(sliceInfo(s[:]))
+	writeln(sliceInfo(s[1:3:4]))
+	writeln(sliceInfo(s[:0:0]))
+
+	// reslicing up to the capacity
+	var t = s[1:3]
+	t = t[0:5]
+	writeln(sliceInfo(t))
+	t = t[:cap(t)]
+	writeln(sliceInfo(t))
+
+	// appending to a 3-index slice does not overwrite the original
+	var u = s[0:2:2]
+	u = append(u, 99)
+	writeln(itoa(s[2]) + " " + itoa(u[2]))
+
+	var arr = [5]int{1, 2, 3, 4, 5}
+	writeln(sliceInfo(arr[1:3]))
+	writeln(sliceInfo(arr[3:]))
+	var pa = &arr
+	var fromPtr = pa[:4]
+	fromPtr[0] = 100
+	writeln(sliceInfo(fromPtr) + " " + itoa(arr[0]))
+	writeln(sliceInfo(pa[2:3:4]))
+
+	// pointers to arrays are indexed, measured and ranged over like the arrays
+	pa[1] = pa[0] + 20
+	pa[4]++
+	var elems string
+	for i := range pa {
+		elems = elems + itoa(pa[i]) + ","
+	}
+	for i, v := range pa {
+		if i == len(pa)-1 {
+			elems = elems + itoa(v*10)
+		}
+	}
+	writeln(elems + " " + itoa(len(pa)) + " " + itoa(cap(pa)) + " " + itoa(arr[1]))
+	var namedPa = &namedArr
+	namedPa[2] = "c"
+	writeln(namedPa[0] + namedArr[2] + itoa(len(namedPa)))
+
+	var str = "hello, world"
+	writeln(str[7:] + "|" + str[:5] + "|" + str[:] + "|" + str[3:4])
+
+	recoverSliceBounds(s, 2, 9)
+	recoverSliceBounds(s, 3, 2)
+	recoverSliceBounds(s, 0, 8)
+}
+
+func testEquality() {
+	var p1 = EqPoint{x: 1, y: 2}
+	var p2 = EqPoint{x: 1, y: 2}
+	var p3 = EqPoint{x: 2, y: 1}
+	writeln(btoa(p1 == p2) + " " + btoa(p1 != p2) + " " + btoa(p1 == p3) + " " + btoa(p1 != p3))
+
+	// strings are compared by their contents
+	var name = "bo"
+	var a = newEqPerson("bob", 30)
+	var b = newEqPerson(name+"b", 30)
+	writeln(btoa(a == b) + " " + btoa(a == newEqPerson("bob", 31)))
+	b.tags[1] = "c"
+	writeln(btoa(a == b))
+	b.tags[1] = "b"
+	b.point.y = 3
+	writeln(btoa(a == b) + " " + btoa(a.point == EqPoint{x: 1, y: 2}))
+
+	var arr1 = [3]int{1, 2, 3}
+	var arr2 = [3]int{1, 2, 3}
+	writeln(btoa(arr1 == arr2))
+	arr2[2] = 4
+	writeln(btoa(arr1 == arr2) + " " + btoa(arr1 != arr2))
+	var sarr1 = [2]string{"x", name}
+	var sarr2 = [2]string{"x", "bo"}
+	writeln(btoa(sarr1 == sarr2))
+
+	// floats are compared as floats
+	var nan = 0.0
+	nan = nan / nan
+	var f1 = EqFloats{f: 0.5, b: true}
+	var f2 = EqFloats{f: 0.5, b: true}
+	var f3 = EqFloats{f: nan, b: true}
+	writeln(btoa(f1 == f2) + " " + btoa(f3 == f3))
+
+	switch p3 {
+	case p1:
+		writeln("p1")
+	case EqPoint{x: 2, y: 1}:
+		writeln("p3")
+	}
+
+	// composite map keys
+	var m = map[EqPerson]int{}
+	m[a] = 1
+	m[newEqPerson("alice", 20)] = 2
+	m[newEqPerson(name+"b", 30)] = 3
+	writeln(itoa(len(m)) + " " + itoa(m[newEqPerson("bob", 30)]) + " " + itoa(m[newEqPerson("alice", 20)]))
+	var pm = map[EqPoint]string{EqPoint{x: 1, y: 2}: "a"}
+	pm[p3] = "b"
+	writeln(pm[p1] + pm[p3] + itoa(len(pm)))
+	var am = map[[2]string]int{}
+	am[sarr1] = 5
+	writeln(itoa(am[sarr2]))
+	delete(am, [2]string{"x", "bo"})
+	writeln(itoa(len(am)))
+
+	// values boxed in interfaces are compared by their parts
+	var i1 interface{} = newEqPerson("bob", 30)
+	var i2 interface{} = b
+	var i3 interface{} = [1]string{name}
+	var i4 interface{} = [1]string{"bo"}
+	writeln(btoa(i1 == i2) + " " + btoa(i1 == newEqPerson(name+"b", 30)) + " " + btoa(i3 == i4) + " " + btoa(i1 != i3))
+	b.point.y = 5
+	i2 = b
+	writeln(btoa(i1 == i2) + " " + btoa(i1 == a))
+	var if1 interface{} = f1
+	var if3 interface{} = f3
+	writeln(btoa(if1 == interface{}(f2)) + " " + btoa(if3 == if3))
+	var zero = 0.0
+	var x1, x2 interface{} = zero, -zero
+	var n1 interface{} = nan
+	var z32 float32
+	var x3, x4 interface{} = z32, -z32
+	writeln(btoa(x1 == x2) + " " + btoa(n1 == n1) + " " + btoa(x3 == x4) + " " + btoa(x1 == x3) + " " + btoa(x1 == 0.0))
+	switch i4 {
+	case [1]string{"x"}:
+		writeln("x")
+	case [1]string{name}:
+		writeln("[1]string matched")
+	}
+	var bx1 = EqBoxed{v: name + "b", n: 1}
+	var bx2 = EqBoxed{v: "bob", n: 1}
+	var ib1 interface{} = bx1
+	writeln(btoa(bx1 == bx2) + " " + btoa(ib1 == interface{}(bx2)) + " " + btoa(ib1 == EqBoxed{v: 1, n: 1}))
+	var bm = map[EqBoxed]int{}
+	bm[bx1] = 1
+	bm[bx2] = 2
+	bm[EqBoxed{v: newEqPerson("bob", 30), n: 1}] = 3
+	writeln(itoa(len(bm)) + " " + itoa(bm[EqBoxed{v: "bob", n: 1}]) + " " + itoa(bm[EqBoxed{v: a, n: 1}]))
+}
+
+func testEmbedded() {
+	var d = EmbDerived{
+		EmbBase:   EmbBase{id: 1, name: "base"},
+		EmbLogger: &EmbLogger{prefix: "> "},
+		extra:     9,
+	}
+	writeln(itoa(d.id) + " " + d.name + " " + itoa(d.extra) + " " + d.EmbBase.name)
+	writeln(d.Name())
+	d.SetName("renamed")
+	writeln(d.Name() + " " + d.Describe())
+	d.Log("log")
+	d.prefix = "$ "
+	d.EmbLogger.Log("log2")
+	var pd = &d
+	pd.id = 5
+	pd.SetName("viaptr")
+	writeln(pd.Name() + " " + itoa(d.id))
+	writeln(newEmbDerived().Name())
+	newEmbDerived().Log("from value")
+
+	var dp = EmbDeeper{}
+	dp.id = 7
+	dp.EmbBase.id = 8
+	dp.name = "deep"
+	dp.EmbLogger = &EmbLogger{prefix: "deep "}
+	writeln(itoa(dp.id) + " " + itoa(dp.EmbDerived.id) + " " + dp.Name() + " " + dp.Describe())
+	dp.Log("log")
+
+	// promoted methods in method sets
+	var namer EmbNamer = d
+	writeln(namer.Name())
+	var renamer EmbRenamer = &dp
+	renamer.SetName("set through interface")
+	writeln(renamer.Name() + " " + dp.name)
+	var logger EmbLog = dp // promoted through *EmbLogger
+	logger.Log("interface log")
+	var deepNamer EmbNamer = &dp
+	writeln(deepNamer.Name())
+
+	// embedded interface
+	var w = EmbWrapper{EmbNamer: d, n: 1}
+	writeln(w.Name())
+	var wn EmbNamer = w
+	writeln(wn.Name())
+	var x interface{} = &w
+	_, ok := x.(EmbNamer)
+	if ok {
+		writeln("*EmbWrapper is EmbNamer")
+	}
+	var y interface{} = d
+	_, ok = y.(EmbRenamer)
+	if !ok {
+		writeln("EmbDerived is not EmbRenamer")
+	}
+}
+
+func testRawString() {
+	var s = `C:\path\to\file`
+	writeln(s + " " + itoa(len(s)))
+	write(rawConst)
+	writeln(rawMultiLine())
+	writeln(itoa(len(rawMultiLine())))
+	writeln(itoa(len("a\r\nb")) + " " + itoa(len(`` + "\r")) + ` ` + `'` + "`")
+	var crlf = "`a\r\nb`"
+	writeln(itoa(len(crlf)))
+	var withCR = `a
+b`
+	writeln(itoa(len(withCR)))
+
+	var x = 1 /* inline */ + 2
+	var y = 3 /* block comment
+	acts as a newline */
+	var z /* before type */ int = 4
+	writeln(itoa(x) + itoa(y) + itoa(z)) /* trailing */
+	/**/ writeln("stars /* */ // in strings") // line comment
+	/***/
+	switch /* tag */ x {
+	case 3: /* c */
+		writeln("three")
+	}
+}
+
+func testRune() {
+	// escapes
+	var esc = "\x41\101\u00e9\U0001F600\a\v"
+	writeln(itoa(len(esc)) + " " + esc[0:4])
+	writeln(itoa(int('\x7f')) + " " + itoa(int('\377')) + " " + itoa(int('\u00e9')) + " " + itoa(int('\U0001F600')) + " " + itoa(int('\'')))
+
+	// multi-byte rune literals
+	var r = '漢'
+	writeln(itoa(int(r)) + " " + string(r) + " " + string('字'))
+	var s = "aé漢😀"
+	writeln(itoa(len(s)))
+
+	// range over a string decodes UTF-8
+	var i int
+	var c rune
+	for i, c = range s {
+		writeln(itoa(i) + ":" + itoa(int(c)))
+	}
+	var n int
+	for _, c := range 漢字 {
+		n = n + int(c)
+	}
+	writeln(itoa(n))
+	for i := range "\xffz" {
+		write(itoa(i))
+	}
+	for _, c := range "\xffz" {
+		write(" " + itoa(int(c)))
+	}
+	writeln("")
+
+	// conversions
+	var runes = []rune(s)
+	writeln(itoa(len(runes)) + " " + itoa(int(runes[2])) + " " + string(runes[1:3]))
+	runes[0] = 'A'
+	writeln(string(runes))
+	var x = 0x4e16
+	writeln(string(rune(x)) + string(rune(-1)))
+	const hira = string(0x3042)
+	writeln(hira)
+	if "é" == "\u00e9" && "\xe6\xbc\xa2" == "漢" {
+		writeln("equal")
+	}
+}
+
+func testFloat() {
+	var a = 1.5
+	var b float64 = 2
+	writeln(ftoa(a+b) + " " + ftoa(a-b) + " " + ftoa(a*b) + " " + ftoa(a/b))
+	writeln(ftoa(.25) + " " + ftoa(1e-3) + " " + ftoa(12.5e2) + " " + ftoa(hexFloat) + " " + ftoa(0x1p-2))
+	writeln(ftoa(pi) + " " + ftoa(tau) + " " + ftoa(float64(quarter)) + " " + ftoa(million))
+	writeln(ftoa(globalFloat) + " " + ftoa(float64(globalFloat32)))
+
+	// constants are rounded once from their exact values
+	var e23 = 1e23
+	var p53 float64 = 9007199254740993
+	var p24 float32 = 16777217
+	var sum = 0.1 + 0.2
+	var tiny = 5e-324
+	if e23 == 0x1.52d02c7e14af6p+76 && p53 == 0x1p53 && p24 == 0x1p24 && sum == 0x1.3333333333333p-2 && tiny == 0x1p-1074 {
+		writeln("exact constants")
+	}
+	const third = 1.0 / 3
+	writeln(itoa(int(third*3)) + " " + ftoa(third))
+
+	// float32 arithmetic rounds to single precision
+	var f32 float32 = 0.1
+	var g32 float32 = 0.2
+	if f32+g32 == 0.3 {
+		writeln("float32 0.1+0.2 == 0.3")
+	}
+	var f64 = 0.1
+	if f64+0.2 != 0.3 {
+		writeln("float64 0.1+0.2 != 0.3")
+	}
+	writeln(ftoa(float64(half32(3))) + " " + ftoa(float64(f32*10)))
+
+	// conversions
+	var n = 7
+	var fn = float64(n) / 2
+	writeln(ftoa(fn) + " " + itoa(int(fn)) + " " + itoa(int(-fn)) + " " + itoa(int(float32(-fn-0.25))))
+	var big uint64 = 1 << 63
+	writeln(ftoa(float64(big) / 1e18))
+	var huge = 1e19
+	var huge32 float32 = 1e19
+	if uint64(huge) == 10000000000000000000 && uint64(huge32) == 9999999980506447872 && uint64(1e19) == 10000000000000000000 {
+		writeln("float to uint64 beyond 2^63")
+	}
+	writeln(itoa(int(uint64(fn*2))) + " " + itoa(int(uint(huge/1e18))))
+	var u8 = uint8(fn * 57.4)
+	writeln(itoa(int(u8)))
+	var c = Celsius2(36.6)
+	writeln(ftoa(float64(c)))
+
+	// NaN and infinities
+	var zero float64
+	var nan = zero / zero
+	var inf = 1 / zero
+	if nan != nan && !(nan == nan) && !(nan < 1) && !(nan > 1) && !(nan <= nan) && !(nan >= nan) {
+		writeln("NaN is unordered")
+	}
+	if inf > 1e308 && -inf < -1e308 {
+		writeln("infinities")
+	}
+	writeln(ftoa(nan) + " " + ftoa(inf) + " " + ftoa(-inf))
+
+	// comparisons
+	if a < b && b > a && a <= 1.5 && b >= 2 && a != b {
+		writeln("comparisons ok")
+	}
+	var x = 3.0
+	x++
+	x -= 0.5
+	x *= 2
+	writeln(ftoa(x))
+
+	var v = Vec{x: 1.25, y: 0.5}
+	var w = v.Scale(4)
+	writeln(ftoa(w.x) + " " + ftoa(float64(w.y)))
+	writeln(ftoa(average([]float64{1, 2, 3.5})))
+
+	var iface interface{} = 2.75
+	switch val := iface.(type) {
+	case int:
+		writeln("int")
+	case float64:
+		writeln("float64 " + ftoa(val))
+	}
+	var m = make(map[float64]string)
+	m[0.5] = "half"
+	writeln(m[0.5] + " " + m[1.0/2])
+
+	// float keys are compared by value
+	var negZero = -zero
+	var fm = map[float64]int{}
+	fm[zero] = 1
+	fm[negZero] = 2
+	fm[nan] = 3
+	fm[nan] = 4
+	var hasNaN bool
+	_, hasNaN = fm[nan]
+	writeln(itoa(len(fm)) + " " + itoa(fm[0]) + " " + itoa(fm[negZero]))
+	if !hasNaN {
+		writeln("NaN key not found")
+	}
+	var f32m = map[float32]int{}
+	f32m[float32(zero)] = 5
+	f32m[float32(negZero)]++
+	f32m[1.5] = 7
+	writeln(itoa(len(f32m)) + " " + itoa(f32m[0]) + " " + itoa(f32m[1.5]))
+	var km = map[FloatKey]int{}
+	km[FloatKey{"a", zero}] = 1
+	km[FloatKey{"a", negZero}] = 2
+	km[FloatKey{"a", nan}] = 3
+	writeln(itoa(len(km)) + " " + itoa(km[FloatKey{"a", 0}]))
+	var arr [3]float32
+	arr[1] = 1.75
+	writeln(ftoa(float64(arr[1]) + float64(arr[0])))
+
+	// untyped float constants used as integers
+	var fromConst int = integralFloat
+	var fromLit int = 2.0
+	var assigned int
+	assigned = 1e3
+	var u8c uint8 = integralFloat * 2
+	var localFloatArray [integralFloat]int
+	writeln(itoa(fromConst) + " " + itoa(int(integralFloat)) + " " + itoa(int(halfFloat*2)) + " " + itoa(fromLit) + " " + itoa(assigned))
+	writeln(itoa(int(u8c)) + " " + itoa(globalIntFromFloat) + " " + itoa(len(globalFloatArray)) + " " + itoa(len(localFloatArray)) + " " + itoa(fromConst+2.0))
+}
+
+type FloatKey struct {
+	name string
+	x    float64
+}
+
+type Bitset uint64
+
+func (b *Bitset) Set(i int) {
+	*b |= 1 << uint(i)
+}
+
+func (b Bitset) Has(i int) bool {
+	return b&(1<<uint(i)) != 0
+}
+
+func hashString(s string) uint32 {
+	var h uint32 = 2166136261
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
+
+const (
+	flagRead = 1 << iota
+	flagWrite
+	flagExec
+)
+
+const allFlags = flagRead | flagWrite | flagExec
+const noWrite = allFlags &^ flagWrite
+
+func testBitwise() {
+	var a = 12
+	var b = 10
+	writeln(itoa(a&b) + " " + itoa(a|b) + " " + itoa(a^b) + " " + itoa(a&^b) + " " + itoa(^a))
+	writeln(itoa(a<<3) + " " + itoa(a>>2) + " " + itoa(-a>>1) + " " + itoa(1<<b))
+	writeln(itoa(allFlags) + " " + itoa(noWrite) + " " + itoa(^0) + " " + itoa(5&^3))
+
+	// shift counts of 64 or more
+	var n uint = 64
+	var big = 100
+	var neg = -100
+	writeln(itoa(big<<n) + " " + itoa(big>>n) + " " + itoa(neg>>n) + " " + itoa(neg>>(n+6)))
+	var u uint64 = 1 << 63
+	writeln(itoa(int(u>>62)) + " " + itoa(int(u>>n)))
+
+	// logical vs arithmetic right shift
+	var s8 int8 = -128
+	var u8 uint8 = 128
+	writeln(itoa(int(s8>>4)) + " " + itoa(int(u8>>4)) + " " + itoa(int(u8<<1)) + " " + itoa(int(^u8)))
+	var u16 uint16 = 240
+	writeln(itoa(int(^u16)) + " " + itoa(int(^uint8(0))))
+
+	// compound assignment
+	var x = 5
+	x += 3
+	x -= 1
+	x *= 6
+	x /= 4
+	x %= 7
+	writeln(itoa(x))
+	x = 6
+	x |= 9
+	x &= 13
+	x ^= 3
+	x <<= 2
+	x >>= 1
+	x &^= 2
+	writeln(itoa(x))
+	var str = "ab"
+	str += "cd"
+	writeln(str)
+	var arr [3]int
+	arr[1] += 4
+	arr[1] <<= 2
+	writeln(itoa(arr[1]))
+	var m = make(map[string]int)
+	m["k"] += 2
+	m["k"] *= 21
+	writeln(itoa(m["k"]))
+	var c uint8 = 250
+	c += 10
+	writeln(itoa(int(c)))
+
+	var set Bitset
+	set.Set(3)
+	set.Set(63)
+	if set.Has(3) && set.Has(63) && !set.Has(4) {
+		writeln("bitset ok")
+	}
+	writeln(itoa(int(hashString("babygo"))))
+}
+
+type Temperature int8
+
+var globalInt8 int8 = -100
+var globalUint32 uint32 = 4000000000
+var globalBytes [3]byte
+
+func sumInt16(xs []int16) int16 {
+	var total int16
+	for _, x := range xs {
+		total = total + x
+	}
+	return total
+}
+
+func testIntegers() {
+	var a int8 = 127
+	a = a + 1
+	writeln(itoa(int(a)))
+	var b uint8 = 255
+	b = b + 1
+	writeln(itoa(int(b)))
+	var c int16 = -32768
+	c = c - 1
+	writeln(itoa(int(c)))
+	var d uint16 = 0
+	d = d - 1
+	writeln(itoa(int(d)))
+	var e int32 = 2147483647
+	e = e + 1
+	writeln(itoa(int(e)))
+	var f uint32 = 0
+	f = f - 1
+	writeln(itoa(int(f)))
+	var g int64 = -9
+	var h uint64 = 9
+	var u uint = 10
+	writeln(itoa(int(g)) + " " + itoa(int(h)) + " " + itoa(int(u)))
+
+	// signed division truncates toward zero
+	var n = -7
+	writeln(itoa(n/2) + " " + itoa(n%2) + " " + itoa(7/(-2)) + " " + itoa(n/(-2)))
+	var s8 int8 = -7
+	writeln(itoa(int(s8/2)) + " " + itoa(int(s8%2)))
+
+	// unsigned comparison
+	var big uint32 = 3000000000
+	var one uint32 = 1
+	if big > one {
+		writeln("unsigned big > one")
+	}
+	var maxU uint64 = 0
+	maxU = maxU - 1
+	if maxU > 1 {
+		writeln("unsigned max > 1")
+	}
+	if maxU/2 == 9223372036854775807 {
+		writeln("unsigned division")
+	}
+	if s8 < 0 {
+		writeln("signed negative")
+	}
+
+	// conversions truncate
+	var x = 300
+	writeln(itoa(int(uint8(x))) + " " + itoa(int(int8(x))))
+	var y = 70000
+	writeln(itoa(int(uint16(y))) + " " + itoa(int(int16(y))))
+	var z = 3000000000
+	writeln(itoa(int(int32(z))) + " " + itoa(int(uint32(-z))))
+	var neg int8 = -1
+	writeln(itoa(int(uint8(neg))) + " " + itoa(int(int64(neg))))
+	var t = Temperature(x)
+	writeln(itoa(int(t)))
+	var m int8 = -128
+	m = -m
+	writeln(itoa(int(m)))
+
+	// byte and rune are aliases
+	var by byte = 'A'
+	var u8 uint8 = by
+	var r rune = 'z'
+	var i32 int32 = r
+	writeln(itoa(int(u8)) + " " + itoa(int(i32)))
+	var bs []byte = []byte("hi")
+	writeln(itoa(int(bs[0])) + " " + itoa(len(bs)))
+
+	writeln(itoa(int(globalInt8)) + " " + itoa(int(globalUint32)))
+	globalBytes[2] = 250
+	globalBytes[2] = globalBytes[2] + 10
+	writeln(itoa(int(globalBytes[2])))
+	writeln(itoa(int(sumInt16([]int16{30000, 10000, -5000}))))
+
+	var mi = make(map[int8]string)
+	mi[-1] = "minus one"
+	mi[1] = "one"
+	writeln(mi[-1] + " " + mi[1])
+}
+
+const answer = 42
+const greeting = "hello, " + "world"
+const debugMode = false
+const enabled = !debugMode && answer > 40
+
+const (
+	KB      = 1 << 10
+	MB      = KB << 10
+	lowMask = (1 << 8) - 1
+)
+
+const negShift = -17 >> 2
+const greetingLen = len(greeting)
+const small = uint8(200)
+const limit int = 3 * KB
+
+// untyped integer constants are exact beyond 64 bits
+const (
+	huge      = 1 << 100
+	hugeBack  = huge >> 98
+	wideShift = 1 << 70 >> 68
+	maxUint64 = 1<<64 - 1
+	allOnes   = ^uint64(0)
+)
+
+var constTable [KB / 64]int
+var globalSize int = MB / KB
+var globalName = greeting
+var globalFlag = enabled
+
+func sizeName(n int) string {
+	switch n {
+	case KB:
+		return "KB"
+	case MB:
+		return "MB"
+	case 1 << 11:
+		return "2KB"
+	}
+	return "?"
+}
+
+func testConst() {
+	writeln(itoa(answer) + " " + greeting + " " + itoa(greetingLen))
+	if enabled && !debugMode {
+		writeln("enabled")
+	}
+	writeln(itoa(KB) + " " + itoa(MB) + " " + itoa(lowMask) + " " + itoa(negShift))
+	var b uint8 = small
+	writeln(itoa(int(b)) + " " + itoa(limit))
+	writeln(itoa(len(constTable)) + " " + itoa(globalSize) + " " + globalName)
+	if globalFlag {
+		writeln("global flag")
+	}
+	var grid [2 * 3]int
+	const gridLen = len(grid)
+	grid[gridLen-1] = 7
+	writeln(itoa(gridLen) + " " + itoa(grid[5]))
+	writeln(sizeName(KB) + " " + sizeName(MB) + " " + sizeName(2*KB) + " " + sizeName(answer))
+	var total = answer + 1<<2
+	writeln(itoa(total))
+	var hugeFloat float64 = huge
+	var maxU uint64 = maxUint64
+	writeln(itoa(hugeBack) + " " + itoa(wideShift) + " " + itoa(huge/(1<<90)) + " " + ftoa(hugeFloat/1e30))
+	if maxU == allOnes && maxU>>63 == 1 && -7/2 == -3 && -7%2 == -1 && -6&0xff == 250 {
+		writeln("exact integer constants")
+	}
+}
+
+type Weekday int
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+const (
+	_ int = iota * 10
+	ten
+	twenty
+)
+
+const (
+	kindA, kindB int = iota, iota + 100
+	kindC, kindD
+)
+
+const letterB int = 'a' + 1
+
+var (
+	groupedCount int    = 3
+	groupedName  string = "grouped"
+)
+
+var gx, gy int = 1, 2
+
+type (
+	Celsius int
+	Point   struct {
+		x int
+		y int
+	}
+)
+
+func (d Weekday) String() string {
+	switch d {
+	case Sunday:
+		return "Sunday"
+	case Monday:
+		return "Monday"
+	}
+	return "Tuesday"
+}
+
+func testGroupedDecl() {
+	writeln(Sunday.String() + " " + Monday.String() + " " + Tuesday.String())
+	writeln(itoa(int(Tuesday)) + " " + itoa(ten) + " " + itoa(twenty))
+	writeln(itoa(kindA) + " " + itoa(kindB) + " " + itoa(kindC) + " " + itoa(kindD))
+	writeln(itoa(letterB))
+	writeln(groupedName + " " + itoa(groupedCount))
+	writeln(itoa(gx) + " " + itoa(gy))
+
+	var c Celsius = 36
+	var pt = Point{x: 1, y: 2}
+	writeln(itoa(int(c)) + " " + itoa(pt.x+pt.y))
+
+	var (
+		a int = 1
+		b string
+	)
+	var i, j int
+	var s, n = "s", 7
+	i, j = 3, 4
+	writeln(itoa(a) + "[" + b + "] " + itoa(i) + itoa(j) + " " + s + itoa(n))
+
+	const (
+		one int = iota + 1
+		two
+		three
+	)
+	writeln(itoa(one) + itoa(two) + itoa(three))
+}
+
+type Pair struct {
+	key   string
+	value int
+}
+
+func (p Pair) Split() (string, int) {
+	return p.key, p.value
+}
+
+func (p *Pair) Bump(n int) (int, int) {
+	var old = p.value
+	p.value = p.value + n
+	return old, p.value
+}
+
+type Splitter interface {
+	Split() (string, int)
+}
+
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+func forwardDivmod(a int, b int) (int, int) {
+	return divmod(a, b)
+}
+
+func lookupPair(pairs []Pair, key string) (Pair, bool) {
+	for _, p := range pairs {
+		if p.key == key {
+			return p, true
+		}
+	}
+	return Pair{}, false
+}
+
+func minMax(nums []int) (min int, max int) {
+	min = nums[0]
+	max = nums[0]
+	for _, n := range nums {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return
+}
+
+func namedOverride() (s string, n int) {
+	s = "unused"
+	return "explicit", 7
+}
+
+func doubleOnDefer() (n int) {
+	defer func() {
+		n = n * 2
+	}()
+	n = 5
+	return n + 1
+}
+
+func recoverToResults() (msg string, ok bool) {
+	defer func() {
+		var r = recover()
+		if r != nil {
+			msg = r.(string)
+		}
+	}()
+	ok = true
+	panic("boom")
+}
+
+func deferMultiValue() string {
+	var s = "before"
+	defer divmod(7, 2)
+	s = "after"
+	return s
+}
+
+func testTuple() {
+	// swap
+	a, b := 1, 2
+	a, b = b, a
+	writeln(itoa(a) + " " + itoa(b))
+	s, t := "x", "y"
+	s, t = t, s
+	writeln(s + t)
+	x, y, z := 1, 2, 3
+	x, y, z = y, z, x
+	writeln(itoa(x) + itoa(y) + itoa(z))
+
+	// index operands are evaluated before the assignment
+	var nums = make([]int, 3, 3)
+	var i int
+	i, nums[i] = 2, 5
+	writeln(itoa(i) + " " + itoa(nums[0]))
+
+	// multiple results into locals, struct fields and slice elements
+	q, r := divmod(17, 5)
+	writeln(itoa(q) + " " + itoa(r))
+	var p Pair
+	p.key, p.value = "k", 1
+	nums[1], nums[2] = divmod(23, 7)
+	writeln(p.key + itoa(p.value) + " " + itoa(nums[1]) + itoa(nums[2]))
+	_, r = forwardDivmod(9, 4)
+	writeln(itoa(r))
+	var m = make(map[string]int)
+	m["q"], m["r"] = divmod(10, 3)
+	writeln(itoa(m["q"]) + itoa(m["r"]))
+
+	// struct results
+	var pairs = []Pair{Pair{key: "a", value: 1}, Pair{key: "b", value: 2}}
+	found, ok := lookupPair(pairs, "b")
+	writeln(found.key + itoa(found.value))
+	if ok {
+		writeln("found")
+	}
+	_, ok = lookupPair(pairs, "z")
+	if !ok {
+		writeln("not found")
+	}
+
+	// named results
+	lo, hi := minMax([]int{3, 9, 1, 4})
+	writeln(itoa(lo) + " " + itoa(hi))
+	s, n := namedOverride()
+	writeln(s + " " + itoa(n))
+	writeln(itoa(doubleOnDefer()))
+	msg, recovered := recoverToResults()
+	if recovered {
+		writeln("recovered " + msg)
+	}
+	writeln(deferMultiValue())
+
+	// methods, interfaces and func values
+	var key string
+	key, n = p.Split()
+	writeln(key + itoa(n))
+	old, cur := p.Bump(10)
+	writeln(itoa(old) + " " + itoa(cur))
+	var sp Splitter = Pair{key: "i", value: 9}
+	key, n = sp.Split()
+	writeln(key + itoa(n))
+	var f func(int, int) (int, int) = divmod
+	q, r = f(20, 6)
+	writeln(itoa(q) + " " + itoa(r))
+	var exclaim = func(s string) (string, int) {
+		return s + "!", len(s)
+	}
+	s, n = exclaim(key)
+	writeln(s + " " + itoa(n))
+	divmod(1, 1)
+
+	// blank assignments
+	_ = divmod
+	_ = len(pairs)
+	_ = lookupPair
+	_ = exclaim
+	_ = Pair{key: "blank"}
+	_ = describeQR(1, 2)
+	_ = noisyLen("blank call is evaluated")
+	_ = pairs[noisyLen("a")-1].key
+
+	// multiple results as the arguments
+	writeln(describeQR(divmod(29, 8)))
+	writeln(describeFound(lookupPair(pairs, "a")))
+	writeln(joinPair(p.Split()) + " " + joinPair(sp.Split()))
+	writeln(itoa(sumAll(divmod(7, 2))) + " " + joinAny(exclaim("hey")))
+	writeln(describeQR(divmod(describeLen(divmod(9, 2)), 2)))
+
+	// multiple results in declarations
+	var dq, dr = divmod(31, 7)
+	writeln(itoa(dq) + " " + itoa(dr))
+	var tq, tr int = divmod(dq, 3)
+	var foundB, okB = lookupPair(pairs, "b")
+	writeln(itoa(tq) + " " + itoa(tr) + " " + foundB.key + " " + itoa(foundB.value))
+	if okB {
+		writeln("declared ok")
+	}
+	var _, missing = lookupPair(pairs, "zzz")
+	if !missing {
+		writeln("declared not found")
+	}
+	var counts = map[string]int{"x": 3}
+	var cx, hasX = counts["x"]
+	var _, hasY = counts["y"]
+	var iface interface{} = "str"
+	var str, isStr = iface.(string)
+	writeln(itoa(cx) + " " + str)
+	if hasX && !hasY && isStr {
+		writeln("declared comma ok")
+	}
+	var addQ = func(n int) int {
+		dq = dq + n
+		return dq
+	}
+	writeln(itoa(addQ(10)) + " " + itoa(dq))
+	writeln(itoa(globalQ) + " " + itoa(globalR) + " " + itoa(globalX) + " " + itoa(globalY))
+	if globalHasA && !globalHasB {
+		writeln("global comma ok " + itoa(globalA))
+	}
+	defer writeln(describeQR(divmod(5, 3)))
+}
+
+var globalQ, globalR = divmod(globalDividend, 5)
+var globalDividend = len("seventeen") + 8
+var globalX, globalY int = divmod(9, 4)
+var globalCounts = map[string]int{"a": 7}
+var globalA, globalHasA = globalCounts["a"]
+var _, globalHasB = globalCounts["b"]
+
+func describeQR(q int, r int) string {
+	return "q=" + itoa(q) + " r=" + itoa(r)
+}
+
+func noisyLen(s string) int {
+	writeln(s)
+	return len(s)
+}
+
+func describeLen(q int, r int) int {
+	return q*10 + r
+}
+
+func joinPair(key string, value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return key + ":" + itoa(v)
+	case bool:
+		if v {
+			return key + ":true"
+		}
+		return key + ":false"
+	}
+	return key
+}
+
+func describeFound(p Pair, ok bool) string {
+	if ok {
+		return "found " + p.key
+	}
+	return "not found"
+}
+
+func joinAny(a interface{}, b interface{}) string {
+	return a.(string) + itoa(b.(int))
+}
+
+func sumAll(nums ...int) int {
+	var sum int
+	for _, n := range nums {
+		sum = sum + n
+	}
+	return sum
+}
+
+type Item struct {
+	name  string
+	count int
+}
+
+func produce(ch chan<- int, n int) {
+	var i int
+	for i = 1; i <= n; i++ {
+		ch <- i
+	}
+	close(ch)
+}
+
+func squares(in <-chan int, out chan<- int) {
+	for v := range in {
+		out <- v * v
+	}
+	close(out)
+}
+
+func sendItem(ch chan Item, name string, count int) {
+	ch <- Item{name: name, count: count}
+}
+
+func testChannel() {
+	// unbuffered
+	var ch = make(chan int)
+	go produce(ch, 3)
+	for v := range ch {
+		writeln("received " + itoa(v))
+	}
+
+	// buffered
+	var buf = make(chan string, 3)
+	buf <- "first"
+	buf <- "second"
+	writeln("len " + itoa(len(buf)) + " cap " + itoa(cap(buf)))
+	writeln(<-buf)
+	s, ok := <-buf
+	if ok {
+		writeln(s)
+	}
+	close(buf)
+	s, ok = <-buf
+	if !ok && s == "" {
+		writeln("closed and empty")
+	}
+
+	// pipeline
+	var in = make(chan int)
+	var out = make(chan int)
+	go produce(in, 4)
+	go squares(in, out)
+	var sum int
+	for v := range out {
+		sum = sum + v
+	}
+	writeln("sum of squares " + itoa(sum))
+
+	// struct elements
+	var items = make(chan Item)
+	go sendItem(items, "apple", 3)
+	var item = <-items
+	writeln(item.name + " " + itoa(item.count))
+
+	// select
+	var ready = make(chan int, 1)
+	var names = make(chan string)
+	select {
+	case v := <-ready:
+		writeln("unexpected " + itoa(v))
+	default:
+		writeln("nothing ready")
+	}
+	ready <- 7
+	select {
+	case v := <-ready:
+		writeln("ready " + itoa(v))
+	case n := <-names:
+		writeln("unexpected " + n)
+	}
+	var nobody = make(chan int)
+	go func() {
+		names <- "gopher"
+	}()
+	select {
+	case n := <-names:
+		writeln("name " + n)
+	case nobody <- 8:
+		writeln("unexpected send")
+	}
+	var quit = make(chan bool)
+	go func() {
+		close(names)
+		quit <- true
+	}()
+	select {
+	case n, ok := <-names:
+		if !ok && n == "" {
+			writeln("names closed")
+		}
+	}
+	<-quit
+	var nilChan chan int
+	select {
+	case nilChan <- 1:
+		writeln("unexpected send to nil channel")
+	default:
+		writeln("nil channel is never ready")
+	}
+}
+
+type GoWorker struct {
+	name string
+}
+
+func (w *GoWorker) run(yields int, results []string, done []bool, i int) {
+	var k int
+	for k = 0; k < yields; k++ {
+		runtime.Gosched()
+	}
+	results[i] = w.name + " after " + itoa(yields) + " yields"
+	done[i] = true
+}
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func fibWorker(n int, results []string, done []bool) {
+	defer func() {
+		done[n] = true
+	}()
+	results[n] = "fib(" + itoa(n) + ") = " + itoa(fib(n))
+}
+
+func allDone(done []bool) bool {
+	for _, d := range done {
+		if !d {
+			return false
+		}
+	}
+	return true
+}
+
+func testGoroutine() {
+	var results = make([]string, 12, 12)
+	var done = make([]bool, 12, 12)
+	var i int
+	for i = 0; i < 8; i++ {
+		go fibWorker(i, results, done)
+	}
+	var w = &GoWorker{name: "worker"}
+	go w.run(3, results, done, 8)
+	var msg = "closure"
+	go func() {
+		results[9] = msg
+		done[9] = true
+	}()
+	for j := 10; j < 12; j++ {
+		go func() {
+			results[j] = "loop " + itoa(j)
+			done[j] = true
+		}()
+	}
+	for !allDone(done) {
+		runtime.Gosched()
+	}
+	for _, r := range results {
+		writeln(r)
+	}
+}
+
+type DeferLog struct {
+	log string
+}
+
+func (l *DeferLog) add(s string) {
+	l.log = l.log + s
+}
+
+func (l DeferLog) show(prefix string) {
+	writeln(prefix + l.log)
+}
+
+type Appender interface {
+	add(s string)
+}
+
+type CodeError struct {
+	code int
+}
+
+func (e *CodeError) Error() string {
+	return "code " + itoa(e.code)
+}
+
+func deferOrder() {
+	var i int
+	for i = 0; i < 3; i++ {
+		defer write(itoa(i) + " ")
+	}
+	defer func() {
+		write("closure ")
+	}()
+	write("body ")
+}
+
+func safeDiv(a int, b int) int {
+	defer func() {
+		var r = recover()
+		if r != nil {
+			writeln("recovered: " + r.(string))
+		}
+	}()
+	if b == 0 {
+		panic("division by zero")
+	}
+	return a / b
+}
+
+func panicWithError() {
+	defer func() {
+		err, ok := recover().(error)
+		if ok {
+			writeln("error: " + err.Error())
+		}
+	}()
+	panic(&CodeError{code: 7})
+}
+
+func panicInDefer() {
+	defer func() {
+		writeln("recovered: " + recover().(string))
+	}()
+	defer func() {
+		panic("second")
+	}()
+	panic("first")
+}
+
+func indirectRecover() interface{} {
+	return recover()
+}
+
+func recoverOnlyInDeferredCall() {
+	defer func() {
+		recover()
+	}()
+	defer func() {
+		if indirectRecover() == nil {
+			writeln("not recovered by a helper")
+		}
+	}()
+	panic("indirect")
+}
+
+func unwind(n int) {
+	if n == 0 {
+		panic("deep")
+	}
+	defer writeln("unwind " + itoa(n))
+	unwind(n - 1)
+}
+
+func catchUnwind() bool {
+	defer func() {
+		if recover() != nil {
+			writeln("caught")
+		}
+	}()
+	unwind(3)
+	return true
+}
+
+func recoveredStruct() DeferLog {
+	defer func() {
+		recover()
+	}()
+	panic("struct")
+}
+
+func recoverNilMap() {
+	defer func() {
+		if recover() != nil {
+			writeln("recovered from a runtime error")
+		}
+	}()
+	var m map[string]int
+	m["a"] = 1
+}
+
+func testDefer() {
+	deferOrder()
+	writeln("")
+
+	// recover
+	writeln(itoa(safeDiv(10, 2)))
+	writeln(itoa(safeDiv(1, 0)))
+	panicWithError()
+	panicInDefer()
+	recoverOnlyInDeferredCall()
+	if !catchUnwind() {
+		writeln("zero value")
+	}
+	var ds = recoveredStruct()
+	writeln("[" + ds.log + "]")
+	recoverNilMap()
+	if recover() == nil {
+		writeln("not panicking")
+	}
+
+	// receivers and arguments are evaluated at the defer statement
+	var l = &DeferLog{}
+	func() {
+		defer l.show("value receiver: ")
+		defer l.add("a")
+		l.add("b")
+	}()
+	l.show("after: ")
+	var ap Appender = l
+	func() {
+		defer ap.add("!")
+		defer ap.add("?")
+	}()
+	l.show("interface: ")
+	var f = func(s string) {
+		writeln("func value " + s)
+	}
+	defer f("at the end")
+}
+
+type IntVisitor func(i int) bool
+
+type Counter struct {
+	n    int
+	step func(n int) int
+}
+
+func double(n int) int {
+	return n * 2
+}
+
+func apply(f func(int) int, x int) int {
+	return f(x)
+}
+
+func makeCounter() func() int {
+	var c int
+	return func() int {
+		c++
+		return c
+	}
+}
+
+func makeAdder(base int) func(int) int {
+	return func(x int) int {
+		return base + x
+	}
+}
+
+func visitAll(list []int, visit IntVisitor) {
+	for _, v := range list {
+		if !visit(v) {
+			return
+		}
+	}
+}
+
+func sortInts(list []int, less func(a int, b int) bool) {
+	var i int
+	var j int
+	for i = 0; i < len(list); i++ {
+		for j = i + 1; j < len(list); j++ {
+			if less(list[j], list[i]) {
+				var tmp = list[i]
+				list[i] = list[j]
+				list[j] = tmp
+			}
+		}
+	}
+}
+
+func testClosure() {
+	// function values
+	var f func(int) int = double
+	writeln(itoa(f(21)))
+	writeln(itoa(apply(double, 5)) + " " + itoa(apply(func(x int) int { return x * x }, 5)))
+	var g func(int) int
+	if g == nil {
+		writeln("nil func")
+	}
+	g = f
+	if g != nil {
+		writeln(itoa(g(1)))
+	}
+
+	// closures capture variables by reference
+	var next = makeCounter()
+	next()
+	next()
+	writeln(itoa(next()))
+	var other = makeCounter()
+	writeln(itoa(other()))
+
+	var add10 = makeAdder(10)
+	writeln(itoa(add10(5)))
+
+	var total int
+	var sum = func(x int) {
+		total = total + x
+	}
+	sum(3)
+	sum(4)
+	writeln(itoa(total))
+	total = 100
+	sum(1)
+	writeln(itoa(total))
+
+	// nested closures
+	var msg = "hello"
+	var outer = func() func() string {
+		return func() string {
+			msg = msg + "!"
+			return msg
+		}
+	}
+	var inner = outer()
+	inner()
+	writeln(inner() + " " + msg)
+
+	// each iteration has its own variables
+	var funcs []func() int
+	for i := 0; i < 3; i++ {
+		funcs = append(funcs, func() int { return i * 10 })
+	}
+	for _, v := range []int{7, 8} {
+		funcs = append(funcs, func() int { return v })
+	}
+	for _, fn := range funcs {
+		write(itoa(fn()) + " ")
+	}
+	writeln("")
+
+	// callbacks
+	var list = []int{5, 2, 8, 1, 9}
+	sortInts(list, func(a int, b int) bool { return a < b })
+	var visited string
+	visitAll(list, func(i int) bool {
+		visited = visited + itoa(i)
+		return i < 8
+	})
+	writeln(visited)
+
+	// func fields and immediately invoked literals
+	var c = &Counter{step: func(n int) int { return n + 5 }}
+	c.n = c.step(c.n)
+	c.n = c.step(c.n)
+	writeln(itoa(c.n))
+	writeln(func(s string) string { return s + s }("ab"))
+	func() {
+		writeln("called immediately")
+	}()
+	var fs = map[string]func(int) int{"double": double}
+	writeln(itoa(fs["double"](8)))
+
+	// func values in interfaces
+	var x interface{} = add10
+	var h = x.(func(int) int)
+	writeln(itoa(h(1)))
+}
+
+func typeName(x interface{}) string {
+	switch v := x.(type) {
+	case nil:
+		return "nil"
+	case int:
+		return "int " + itoa(v+1)
+	case string:
+		return "string " + v
+	case *Square:
+		return "*Square " + itoa(v.side)
+	case Rect:
+		return "Rect " + itoa(v.Area())
+	case uint8, uint16:
+		if v == x {
+			return "small int"
+		}
+		return "never"
+	case Shape:
+		return "Shape " + v.Name()
+	default:
+		return "other"
+	}
+}
+
+func shapeKind(sh Shape) string {
+	switch sh.(type) {
+	case *Square:
+		return "square"
+	case Named:
+		return "named"
+	}
+	return ""
+}
+
+func testTypeSwitch() {
+	writeln(typeName(nil))
+	writeln(typeName(41))
+	writeln(typeName("str"))
+	writeln(typeName(&Square{side: 5}))
+	writeln(typeName(Rect{w: 2, h: 2}))
+	writeln(typeName(uint8(1)))
+	writeln(typeName(uint16(1)))
+	writeln(typeName(true))
+	writeln(shapeKind(&Square{}))
+	writeln(shapeKind(Rect{}))
+
+	var e error = &MyError{msg: "switch"}
+	switch err := e.(type) {
+	case *MyError:
+		writeln(err.msg)
+	}
+}
+
+func testInterface() {
+	// dynamic dispatch with value and pointer receivers
+	var sq = &Square{side: 3}
+	var shapes = []Shape{Rect{w: 2, h: 5}, sq}
+	for _, sh := range shapes {
+		writeln(sh.Name() + " " + itoa(sh.Area()))
+	}
+	sq.Grow(1)
+	writeln(itoa(totalArea(shapes)))
+
+	// struct values are copied when boxed
+	var r = Rect{w: 1, h: 1}
+	var s Shape = r
+	r.w = 100
+	writeln(itoa(s.Area()) + " " + itoa(r.Area()))
+
+	// conversion between interface types
+	var n Named = s
+	writeln(n.Name())
+	var ns = n.(Shape)
+	writeln(itoa(ns.Area()))
+	sh2, ok := n.(Shape)
+	if ok {
+		writeln("Named is Shape: " + sh2.Name())
+	}
+
+	// empty interface
+	writeln(describe(42))
+	writeln(describe("hello"))
+	writeln(describe(Rect{w: 3, h: 4}))
+	writeln(describe(sq))
+	writeln(describe(nil))
+	writeln(describe(true))
+	sqp, isSquare := shapes[1].(*Square)
+	if isSquare {
+		writeln("side " + itoa(sqp.side))
+	}
+	_, isSquare = shapes[0].(*Square)
+	if !isSquare {
+		writeln("not a square")
+	}
+
+	// comparison
+	var a interface{} = 7
+	var b interface{} = 7
+	var c interface{} = "7"
+	if a == b && a != c {
+		writeln("interface equality ok")
+	}
+	var e1 interface{}
+	if e1 == nil && a != nil {
+		writeln("nil interface ok")
+	}
+	var sh3 Shape = sq
+	if sh3 == shapes[1] {
+		writeln("same pointer")
+	}
+
+	// error type
+	var err = findError(false)
+	if err == nil {
+		writeln("no error")
+	}
+	err = findError(true)
+	if err != nil {
+		writeln(err.Error())
+	}
+	var myErr = err.(*MyError)
+	writeln(myErr.msg)
+
+	// interface values in maps and conversions
+	var m = map[string]interface{}{}
+	m["k"] = "v"
+	m["n"] = 1
+	writeln(m["k"].(string) + itoa(m["n"].(int)))
+	var x = interface{}(uint8('A'))
+	_, ok = x.(uint8)
+	if ok {
+		writeln("uint8")
+	}
+
+	// structs passed and returned by value
+	writeln(itoa(rectArea(makeRect(6, 7))))
+	writeln(itoa(makeRect(2, 3).Area()))
+
+	// fields of non-addressable struct values
+	var boxed interface{} = Rect{w: 8, h: 9}
+	writeln(itoa(boxed.(Rect).w) + " " + itoa((boxed.(Rect)).h) + " " + itoa(shapes[0].(Rect).h))
+	var emb interface{} = newEmbDerived()
+	writeln(emb.(EmbDerived).name + " " + itoa(emb.(EmbDerived).EmbBase.id) + " " + emb.(EmbDerived).prefix)
+	var rects = map[string]Rect{"r": {w: 4, h: 6}}
+	writeln(itoa(rects["r"].w+rects["r"].h) + " " + itoa(rects["none"].w) + " " + itoa(len(rects)))
+	var embs = map[int]EmbDerived{1: newEmbDerived()}
+	writeln(embs[1].name + " " + itoa(embs[1].extra) + " " + embs[2].name + " " + itoa(len(embs)))
+}
+
+type MapPoint struct {
+	x int
+	y int
+}
+
+type StringSet map[string]bool
+
+func countMapValues(m map[string]int) int {
+	var total int
+	for _, v := range m {
+		total = total + v
+	}
+	return total
+}
+
+func newMapOfNames() map[int]string {
+	return map[int]string{
+		1: "one",
+		2: "two",
+		3: "three",
+	}
+}
+
+func testMap() {
+	var m = make(map[string]int)
+	m["a"] = 1
+	m["bb"] = 2
+	writeln(itoa(m["a"]) + itoa(m["bb"]) + itoa(m["none"]))
+	writeln(itoa(len(m)))
+
+	v, ok := m["bb"]
+	if ok {
+		writeln("bb found: " + itoa(v))
+	}
+	_, ok = m["none"]
+	if !ok {
+		writeln("none not found")
+	}
+
+	m["a"] = 10
+	m["a"]++
+	writeln(itoa(m["a"]))
+
+	delete(m, "a")
+	delete(m, "none")
+	writeln(itoa(len(m)))
+
+	var i int
+	for i = 0; i < 100; i++ {
+		m[itoa(i)] = i
+	}
+	writeln(itoa(len(m)))
+	writeln(itoa(countMapValues(m)))
+
+	// deletion during iteration
+	var n int
+	for k := range m {
+		delete(m, k)
+		n++
+	}
+	writeln(itoa(n) + " " + itoa(len(m)))
+
+	var names = newMapOfNames()
+	writeln(names[1] + names[2] + names[3])
+	var sum int
+	for k, name := range names {
+		sum = sum + k*len(name)
+	}
+	writeln(itoa(sum))
+
+	var nilMap map[int]int
+	if nilMap == nil {
+		writeln("nil map")
+	}
+	writeln(itoa(len(nilMap)) + itoa(nilMap[7]))
+	for range nilMap {
+		writeln("never")
+	}
+
+	// pointer keys and values
+	var p1 = &MapPoint{x: 1, y: 2}
+	var p2 = &MapPoint{x: 3, y: 4}
+	var points = map[*MapPoint]*MapPoint{}
+	points[p1] = p2
+	points[p2] = p1
+	writeln(itoa(points[p1].x) + itoa(points[p2].y))
+
+	// struct values
+	var structs = map[uint8]MapPoint{}
+	structs['a'] = MapPoint{x: 5, y: 6}
+	var pt = structs['a']
+	writeln(itoa(pt.x) + itoa(pt.y) + itoa(structs['z'].y))
+
+	// named map type
+	var set = StringSet{"x": true}
+	set["y"] = true
+	if set["x"] && set["y"] && !set["z"] {
+		writeln("set ok")
+	}
+}
+
+func test() {
+	testCompositeLit()
+	testMethodValues()
+	testGlobalInit()
+	testSwitchInit()
+	testLabels()
+	testRuntimeChecks()
+	testSlice()
+	testAppend()
+	testEquality()
+	testEmbedded()
+	testRawString()
+	testRune()
+	testFloat()
+	testBitwise()
+	testIntegers()
+	testConst()
+	testGroupedDecl()
+	testTuple()
+	testChannel()
+	testGoroutine()
+	testDefer()
+	testClosure()
+	testTypeSwitch()
+	testInterface()
+	testMap()
+}
+
+func main() {
+	test()
+}
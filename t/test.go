@@ -308,7 +308,11 @@ const O_READONLY_ int = 0
 func testOpenRead() {
 	var fd int
 	fd, _ = syscall.Open("t/text.txt", O_READONLY_, 0)
-	writeln(itoa(fd)) // should be 3
+	// The number is 3 for babygo, but not in t/expected.txt made by "go run",
+	// since the runtime of gc keeps the cgroup CPU quota files open.
+	if fd < 3 {
+		writeln("ERROR: fd=" + itoa(fd))
+	}
 	var buf []uint8 = make([]uint8, 300, 300)
 	var n int
 	n, _ = syscall.Read(fd, buf)
@@ -1,10 +1,15 @@
 #!/bin/bash
 set -u
 program=$1
+expected=${2:-}
 ${program} myargs 1>/tmp/actual.1 2> /tmp/actual.2
 exit_status=$?
 
 if [[ $exit_status -eq 0 ]]; then
+  if [[ -n $expected ]] && ! diff -u $expected /tmp/actual.1; then
+    echo FAILED
+    exit 1
+  fi
   echo ok
 else
   echo FAILED